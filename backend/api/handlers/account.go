@@ -3,17 +3,21 @@ package handlers
 import (
 	"errors"
 	"net/http"
-	"slices"
 	"time"
 
 	"strings"
 
 	"github.com/Neat-Snap/blueprint-backend/config"
 	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/events"
+	"github.com/Neat-Snap/blueprint-backend/images"
 	"github.com/Neat-Snap/blueprint-backend/logger"
 	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/notify"
 	"github.com/Neat-Snap/blueprint-backend/utils"
 	"github.com/Neat-Snap/blueprint-backend/utils/email"
+	"github.com/Neat-Snap/blueprint-backend/utils/geoip"
+	"gorm.io/gorm"
 )
 
 type UsersAPI struct {
@@ -22,41 +26,60 @@ type UsersAPI struct {
 	EmailClient *email.EmailClient
 	RedisSecret string
 	Config      config.Config
+	GeoIP       *geoip.Resolver
+	Notifier    *notify.Dispatcher
+	Images      *images.Library
 }
 
-func NewUsersAPI(logger logger.MultiLogger, connection *db.Connection, emailClient *email.EmailClient, redisSecret string, config config.Config) *UsersAPI {
-	return &UsersAPI{logger: logger, Connection: connection, EmailClient: emailClient, RedisSecret: redisSecret, Config: config}
+func NewUsersAPI(logger logger.MultiLogger, connection *db.Connection, emailClient *email.EmailClient, redisSecret string, config config.Config, geo *geoip.Resolver, notifier *notify.Dispatcher, library *images.Library) *UsersAPI {
+	return &UsersAPI{logger: logger, Connection: connection, EmailClient: emailClient, RedisSecret: redisSecret, Config: config, GeoIP: geo, Notifier: notifier, Images: library}
 }
 
 // PATCH /account/profile
+//
+// avatar_url can only be cleared here; new avatars are uploaded to POST /account/avatar.
 func (h *UsersAPI) UpdateProfileEndpoint(w http.ResponseWriter, r *http.Request) {
 	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
 
-	type Rreq struct {
-		Name      string `json:"name"`
-		AvatarURL string `json:"avatar_url"`
+	var req struct {
+		Name      string  `json:"name"`
+		AvatarURL *string `json:"avatar_url"`
 	}
 
-	var req Rreq
-
 	err := utils.ReadJSON(r.Body, w, h.logger, &req)
 	if err != nil {
 		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
 		return
 	}
 
+	current := avatarURL(userObj)
+	if req.AvatarURL != nil && *req.AvatarURL != "" && *req.AvatarURL != current {
+		utils.WriteError(w, h.logger, nil, "avatars are uploaded with POST /account/avatar", http.StatusBadRequest)
+		return
+	}
+	clearAvatar := req.AvatarURL != nil && *req.AvatarURL == "" && current != ""
+	oldKey := userObj.AvatarKey
+
 	userObj.Name = &req.Name
-	userObj.AvatarURL = &req.AvatarURL
+	if clearAvatar {
+		userObj.AvatarURL, userObj.AvatarKey = nil, ""
+	}
 
 	err = h.Connection.Users.Update(r.Context(), userObj)
 	if err != nil {
 		utils.WriteError(w, h.logger, err, "failed to update user", http.StatusInternalServerError)
 		return
 	}
+	if clearAvatar {
+		h.deleteAvatar(r.Context(), oldKey)
+	}
 
-	resp := Rreq{
+	resp := struct {
+		Name      string `json:"name"`
+		AvatarURL string `json:"avatar_url"`
+	}{
 		Name:      *userObj.Name,
-		AvatarURL: *userObj.AvatarURL,
+		AvatarURL: avatarURL(userObj),
 	}
 
 	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
@@ -108,6 +131,13 @@ func (h *UsersAPI) ChangePasswordEndpoint(w http.ResponseWriter, r *http.Request
 		return
 	}
 
+	if userObj.Email != nil {
+		sendSecurityAlert(r.Context(), h.Notifier, h.Connection, h.logger, userObj, *userObj.Email, email.SecurityAlert{
+			Kind:    email.AlertPasswordChanged,
+			Details: append([]email.AlertDetail{{Label: email.DetailChangedVia, Value: email.ViaAccountSettings, Localized: true}}, requestAlertDetails(r, h.GeoIP)...),
+		})
+	}
+
 	utils.WriteSuccess(w, h.logger, nil, http.StatusOK)
 }
 
@@ -140,6 +170,11 @@ func (h *UsersAPI) ChangeEmailEndpoint(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 
+	var previousEmail string
+	if userObj.Email != nil {
+		previousEmail = *userObj.Email
+	}
+
 	userObj.Email = &newEmail
 	userObj.EmailVerifiedAt = nil
 
@@ -155,9 +190,16 @@ func (h *UsersAPI) ChangeEmailEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := h.EmailClient.SendConfirmationEmail(*userObj.Email, "Updating your email", 60)
+	if previousEmail != "" && previousEmail != newEmail {
+		sendSecurityAlert(r.Context(), h.Notifier, h.Connection, h.logger, userObj, previousEmail, email.SecurityAlert{
+			Kind:    email.AlertEmailChanged,
+			Details: append([]email.AlertDetail{{Label: email.DetailNewAddress, Value: utils.MaskEmail(newEmail)}}, requestAlertDetails(r, h.GeoIP)...),
+		})
+	}
+
+	id, err := h.EmailClient.QueueConfirmationEmail(r.Context(), *userObj.Email, 60)
 	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to send confirmation email", http.StatusInternalServerError)
+		writeQueueEmailError(w, h.logger, err, "failed to send confirmation email")
 		return
 	}
 
@@ -203,7 +245,10 @@ func (h *UsersAPI) ConfirmEmailEndpoint(w http.ResponseWriter, r *http.Request)
 		}
 		now := time.Now()
 		u.EmailVerifiedAt = &now
-		return tx.Users.Update(r.Context(), u)
+		if err := tx.Users.Update(r.Context(), u); err != nil {
+			return err
+		}
+		return events.Emit(r.Context(), tx, events.EmailVerified, events.EmailVerifiedPayload{UserID: u.ID, Email: verifiedEmail})
 	})
 	if err != nil {
 		utils.WriteError(w, h.logger, err, "Failed to verify email", http.StatusInternalServerError)
@@ -221,112 +266,77 @@ func (h *UsersAPI) ConfirmEmailEndpoint(w http.ResponseWriter, r *http.Request)
 	returnDefaultPositiveResponse(w, h.logger)
 }
 
-// GET /account/preferences
-func (h *UsersAPI) GetPreferencesEndpoint(w http.ResponseWriter, r *http.Request) {
-	userEmail := r.Context().Value(middleware.UserEmailContextKey).(string)
-
-	preferences, err := h.Connection.Preferences.GetByEmail(r.Context(), userEmail)
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "internal server error", http.StatusInternalServerError)
-		return
-	}
-
-	resp := struct {
-		Theme    string `json:"theme"`
-		Language string `json:"lang"`
-	}{
-		Theme:    preferences.Theme,
-		Language: preferences.Language,
-	}
+// GET /account/security/logins
+func (h *UsersAPI) LoginHistoryEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
 
-	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
+	writeLoginHistory(w, r, h.Connection, h.logger, userObj.ID)
 }
 
-// POST /account/preferences/theme
-func (h *UsersAPI) UpdateUserThemeEndpoint(w http.ResponseWriter, r *http.Request) {
-	userEmail := r.Context().Value(middleware.UserEmailContextKey).(string)
-
-	var req struct {
-		Theme string `json:"theme"`
-	}
-
-	err := utils.ReadJSON(r.Body, w, h.logger, &req)
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
+// GET /account/email/delivery
+func (h *UsersAPI) EmailDeliveryEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	if userObj.Email == nil {
+		utils.WriteError(w, h.logger, nil, "account has no email address", http.StatusNotFound)
 		return
 	}
 
-	allowedThemes := []string{"light", "dark", "system"}
-	if !slices.Contains(allowedThemes, req.Theme) {
-		h.logger.Debug("got theme", req.Theme)
-		utils.WriteError(w, h.logger, err, "theme type not allowed", http.StatusBadRequest)
+	suppression, err := h.Connection.Emails.Suppression(r.Context(), *userObj.Email)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, h.logger, err, "failed to check email delivery", http.StatusInternalServerError)
 		return
 	}
-
-	preference, err := h.Connection.Preferences.GetByEmail(r.Context(), userEmail)
+	recent, err := h.Connection.Emails.ListMessages(r.Context(), *userObj.Email, 0, 10)
 	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
+		utils.WriteError(w, h.logger, err, "failed to list recent emails", http.StatusInternalServerError)
 		return
 	}
 
-	preference.Theme = req.Theme
-
-	if err = h.Connection.Preferences.Update(r.Context(), preference); err != nil {
-		utils.WriteError(w, h.logger, err, "internal server error", http.StatusInternalServerError)
-		return
+	type message struct {
+		Type        string     `json:"type"`
+		Status      string     `json:"status"`
+		CreatedAt   time.Time  `json:"created_at"`
+		DeliveredAt *time.Time `json:"delivered_at,omitempty"`
 	}
-
-	resp := struct {
-		Success bool   `json:"success"`
-		Message string `json:"message"`
-	}{
-		Success: true,
-		Message: "user theme updated successfully",
+	messages := make([]message, 0, len(recent))
+	for _, m := range recent {
+		messages = append(messages, message{Type: m.Type, Status: m.Status, CreatedAt: m.CreatedAt, DeliveredAt: m.DeliveredAt})
 	}
 
-	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
-}
-
-// POST /account/preferences/language
-func (h *UsersAPI) UpdateUserLanguage(w http.ResponseWriter, r *http.Request) {
-	userEmail := r.Context().Value(middleware.UserEmailContextKey).(string)
-
-	var req struct {
-		Lang string `json:"lang"`
+	resp := map[string]any{
+		"email":      *userObj.Email,
+		"suppressed": suppression != nil,
+		"recent":     messages,
 	}
-
-	err := utils.ReadJSON(r.Body, w, h.logger, &req)
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
-		return
+	if suppression != nil {
+		resp["suppression"] = map[string]any{
+			"reason":     suppression.Reason,
+			"detail":     suppression.Detail,
+			"created_at": suppression.CreatedAt,
+		}
 	}
+	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
+}
 
-	allowedLanguages := []string{"en", "ru", "zh"}
-	if !slices.Contains(allowedLanguages, req.Lang) {
-		utils.WriteError(w, h.logger, err, "language not found", http.StatusBadRequest)
+// DELETE /account/email/suppression
+//
+// Lets the user ask for email again once the mailbox is fixed. Another hard bounce puts
+// the address straight back on the list.
+func (h *UsersAPI) ClearEmailSuppressionEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	if userObj.Email == nil {
+		utils.WriteError(w, h.logger, nil, "account has no email address", http.StatusNotFound)
 		return
 	}
 
-	preference, err := h.Connection.Preferences.GetByEmail(r.Context(), userEmail)
+	deleted, err := h.Connection.Emails.DeleteSuppression(r.Context(), *userObj.Email)
 	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
+		utils.WriteError(w, h.logger, err, "failed to clear suppression", http.StatusInternalServerError)
 		return
 	}
-
-	preference.Language = req.Lang
-
-	if err = h.Connection.Preferences.Update(r.Context(), preference); err != nil {
-		utils.WriteError(w, h.logger, err, "internal server error", http.StatusInternalServerError)
+	if !deleted {
+		utils.WriteError(w, h.logger, nil, "email address is not suppressed", http.StatusNotFound)
 		return
 	}
-
-	resp := struct {
-		Success bool   `json:"success"`
-		Message string `json:"message"`
-	}{
-		Success: true,
-		Message: "user language updated successfully",
-	}
-
-	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "cleared"}, http.StatusOK)
 }
@@ -0,0 +1,160 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/jobs"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+type AdminAPI struct {
+	logger     logger.MultiLogger
+	Connection *db.Connection
+	Jobs       *jobs.Queue
+}
+
+func NewAdminAPI(logger logger.MultiLogger, connection *db.Connection, queue *jobs.Queue) *AdminAPI {
+	return &AdminAPI{logger: logger, Connection: connection, Jobs: queue}
+}
+
+// GET /admin/users/{id}/logins
+func (h *AdminAPI) UserLoginHistoryEndpoint(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "invalid user ID", http.StatusBadRequest)
+		return
+	}
+
+	if _, err := h.Connection.Users.ByID(r.Context(), uint(userID)); err != nil {
+		utils.WriteError(w, h.logger, err, "user not found", http.StatusNotFound)
+		return
+	}
+
+	writeLoginHistory(w, r, h.Connection, h.logger, uint(userID))
+}
+
+// jobs that have run or waited this long are reported as stuck
+const stuckJobAge = 10 * time.Minute
+
+// GET /admin/jobs
+func (h *AdminAPI) JobsOverviewEndpoint(w http.ResponseWriter, r *http.Request) {
+	stats, err := h.Jobs.Stats(r.Context())
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get job stats", http.StatusInternalServerError)
+		return
+	}
+	stuck, err := h.Jobs.Stuck(r.Context(), stuckJobAge, 100)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list stuck jobs", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{
+		"stats": stats,
+		"stuck": redactedJobs(stuck),
+	}, http.StatusOK)
+}
+
+// GET /admin/jobs/dead
+func (h *AdminAPI) DeadJobsEndpoint(w http.ResponseWriter, r *http.Request) {
+	limit, offset := utils.ParsePagination(r, 50, 200)
+	list, err := h.Jobs.Dead(r.Context(), int64(offset), int64(limit))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list dead jobs", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"items": redactedJobs(list)}, http.StatusOK)
+}
+
+func redactedJobs(list []jobs.Job) []jobs.Job {
+	out := make([]jobs.Job, len(list))
+	for i, j := range list {
+		out[i] = j.Redacted()
+	}
+	return out
+}
+
+func jobErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, jobs.ErrJobNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, jobs.ErrJobRunning):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// POST /admin/jobs/{job_id}/retry
+func (h *AdminAPI) RetryJobEndpoint(w http.ResponseWriter, r *http.Request) {
+	job, err := h.Jobs.Retry(r.Context(), chi.URLParam(r, "job_id"))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), jobErrorStatus(err))
+		return
+	}
+	utils.WriteSuccess(w, h.logger, job.Redacted(), http.StatusOK)
+}
+
+// DELETE /admin/jobs/{job_id}
+func (h *AdminAPI) DeleteJobEndpoint(w http.ResponseWriter, r *http.Request) {
+	if err := h.Jobs.Delete(r.Context(), chi.URLParam(r, "job_id")); err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), jobErrorStatus(err))
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "deleted"}, http.StatusOK)
+}
+
+// GET /admin/emails
+func (h *AdminAPI) EmailLogEndpoint(w http.ResponseWriter, r *http.Request) {
+	limit, _ := utils.ParsePagination(r, 50, 200)
+	var beforeID uint
+	if v := r.URL.Query().Get("before"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			utils.WriteError(w, h.logger, err, "invalid before cursor", http.StatusBadRequest)
+			return
+		}
+		beforeID = uint(id)
+	}
+
+	list, err := h.Connection.Emails.ListMessages(r.Context(), r.URL.Query().Get("to"), beforeID, limit)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list emails", http.StatusInternalServerError)
+		return
+	}
+	resp := map[string]any{"items": list}
+	if len(list) == limit {
+		resp["next_before"] = list[len(list)-1].ID
+	}
+	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
+}
+
+// GET /admin/emails/suppressions
+func (h *AdminAPI) EmailSuppressionsEndpoint(w http.ResponseWriter, r *http.Request) {
+	limit, offset := utils.ParsePagination(r, 50, 200)
+	list, total, err := h.Connection.Emails.ListSuppressions(r.Context(), limit, offset)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list suppressions", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"items": list, "total": total}, http.StatusOK)
+}
+
+// DELETE /admin/emails/suppressions/{address}
+func (h *AdminAPI) DeleteEmailSuppressionEndpoint(w http.ResponseWriter, r *http.Request) {
+	deleted, err := h.Connection.Emails.DeleteSuppression(r.Context(), chi.URLParam(r, "address"))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to delete suppression", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		utils.WriteError(w, h.logger, nil, "suppression not found", http.StatusNotFound)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "deleted"}, http.StatusOK)
+}
@@ -11,9 +11,13 @@ import (
 
 	"github.com/Neat-Snap/blueprint-backend/config"
 	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/events"
 	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/notify"
 	"github.com/Neat-Snap/blueprint-backend/utils"
 	"github.com/Neat-Snap/blueprint-backend/utils/email"
+	"github.com/Neat-Snap/blueprint-backend/utils/geoip"
+	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/sessions"
 	"github.com/markbates/goth"
 	"github.com/markbates/goth/gothic"
@@ -32,6 +36,8 @@ type AuthAPI struct {
 	Environment   string
 	SessionSecret string
 	Config        config.Config
+	GeoIP         *geoip.Resolver
+	Notifier      *notify.Dispatcher
 }
 
 // -----------------------------------
@@ -90,9 +96,18 @@ func (a *AuthAPI) MeEndpoint(w http.ResponseWriter, r *http.Request) {
 	}
 
 	resp := map[string]any{
-		"id":    u.ID,
-		"email": u.Email,
-		"name":  u.Name,
+		"id":         u.ID,
+		"email":      u.Email,
+		"name":       u.Name,
+		"avatar_url": u.AvatarURL,
+	}
+	if u.Email != nil {
+		_, err := a.Connection.Emails.Suppression(r.Context(), *u.Email)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.WriteError(w, a.logger, err, "failed to check email delivery", http.StatusInternalServerError)
+			return
+		}
+		resp["email_suppressed"] = err == nil
 	}
 	w.WriteHeader(http.StatusOK)
 	_ = json.NewEncoder(w).Encode(resp)
@@ -136,7 +151,7 @@ func returnDefaultPositiveResponse(w http.ResponseWriter, log logger.MultiLogger
 // 	}
 // }
 
-func NewAuthAPI(db *gorm.DB, logger logger.MultiLogger, connection *db.Connection, emailClient *email.EmailClient, redisSecret string, environment string, sessionSecret string, config config.Config) *AuthAPI {
+func NewAuthAPI(db *gorm.DB, logger logger.MultiLogger, connection *db.Connection, emailClient *email.EmailClient, redisSecret string, environment string, sessionSecret string, config config.Config, geo *geoip.Resolver, notifier *notify.Dispatcher) *AuthAPI {
 	gob.Register(SessionUser{})
 	logger.Info("app url from config is", "app_url", config.BACKEND_PUBLIC_URL)
 	cookieStore := sessions.NewCookieStore([]byte(sessionSecret))
@@ -164,7 +179,7 @@ func NewAuthAPI(db *gorm.DB, logger logger.MultiLogger, connection *db.Connectio
 		),
 	)
 
-	return &AuthAPI{DB: db, logger: logger, Connection: connection, EmailClient: emailClient, RedisSecret: redisSecret, CookieStore: cookieStore, Environment: environment, SessionSecret: sessionSecret, Config: config}
+	return &AuthAPI{DB: db, logger: logger, Connection: connection, EmailClient: emailClient, RedisSecret: redisSecret, CookieStore: cookieStore, Environment: environment, SessionSecret: sessionSecret, Config: config, GeoIP: geo, Notifier: notifier}
 }
 
 // POST /auth/register
@@ -199,9 +214,9 @@ func (a *AuthAPI) RegisterEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	id, err := a.EmailClient.SendConfirmationEmail(email, "Confirm your email", 60)
+	id, err := a.EmailClient.QueueConfirmationEmail(r.Context(), email, 60)
 	if err != nil {
-		utils.WriteError(w, a.logger, err, "Failed to send confirmation email", http.StatusInternalServerError)
+		writeQueueEmailError(w, a.logger, err, "Failed to queue confirmation email")
 		return
 	}
 
@@ -247,26 +262,18 @@ func (a *AuthAPI) ConfirmEmailEndpoint(w http.ResponseWriter, r *http.Request) {
 		if err := tx.Users.Update(r.Context(), u); err != nil {
 			return err
 		}
+		if err := events.Emit(r.Context(), tx, events.EmailVerified, events.EmailVerifiedPayload{UserID: u.ID, Email: verifiedEmail}); err != nil {
+			return err
+		}
 
 		existing, err := tx.Teams.ListForUser(r.Context(), u.ID)
 		if err != nil {
 			return err
 		}
 		if len(existing) == 0 {
-			name := "My team"
-			if u.Name != nil && *u.Name != "" {
-				name = *u.Name + "'s team"
-			}
-			ws := &db.Team{
-				Name:    name,
-				OwnerID: u.ID,
-				Owner:   *u,
-				Users:   []db.User{*u},
-			}
-			if err := tx.Teams.Create(r.Context(), ws); err != nil {
+			if _, err := createPersonalTeam(r.Context(), tx, u); err != nil {
 				return err
 			}
-			_ = tx.Teams.AddMember(r.Context(), ws.ID, u.ID, "owner")
 		}
 		return nil
 	})
@@ -283,7 +290,14 @@ func (a *AuthAPI) ConfirmEmailEndpoint(w http.ResponseWriter, r *http.Request) {
 
 	returnCookieToken(a.Config.APP_URL, w, token, a.Config)
 
-	returnDefaultPositiveResponse(w, a.logger)
+	resp := struct {
+		utils.DefaultResponse
+		InviteToken string `json:"invite_token,omitempty"`
+	}{
+		DefaultResponse: utils.DefaultResponse{Success: true, Message: "Email confirmed"},
+		InviteToken:     pendingInvitationToken(r, a.Connection, verifiedEmail),
+	}
+	utils.WriteSuccess(w, a.logger, resp, http.StatusOK)
 }
 
 // POST /auth/login
@@ -304,11 +318,13 @@ func (a *AuthAPI) LoginEndpoint(w http.ResponseWriter, r *http.Request) {
 	}
 
 	var tokenOnSuccess string
+	var attemptedUser *db.User
 	err = a.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
 		user, err := tx.Users.ByEmail(r.Context(), email)
 		if err != nil {
 			return err
 		}
+		attemptedUser = user
 
 		// Guard against users who registered via OAuth and do not have a password credential
 		if user.PasswordCredential == nil || user.PasswordCredential.PasswordDisabled {
@@ -334,14 +350,22 @@ func (a *AuthAPI) LoginEndpoint(w http.ResponseWriter, r *http.Request) {
 	})
 
 	if err != nil {
+		attempt := loginAttempt{User: attemptedUser, Email: email, Method: LoginMethodPassword, Reason: "invalid_credentials"}
 		if errors.Is(err, utils.ErrOAuthOnlyAccount) {
+			attempt.Reason = "oauth_only_account"
+			recordLogin(r, a.Connection, a.GeoIP, a.logger, attempt)
 			utils.WriteError(w, a.logger, err, "This account uses Google sign-in. Please continue with Google.", http.StatusConflict)
 			return
 		}
+		recordLogin(r, a.Connection, a.GeoIP, a.logger, attempt)
 		utils.WriteError(w, a.logger, err, "Invalid email or password", http.StatusUnauthorized)
 		return
 	}
 
+	if event, newDevice := recordLogin(r, a.Connection, a.GeoIP, a.logger, loginAttempt{User: attemptedUser, Email: email, Method: LoginMethodPassword, Success: true}); newDevice {
+		a.alertNewDevice(r.Context(), attemptedUser, event)
+	}
+
 	returnCookieToken(a.Config.APP_URL, w, tokenOnSuccess, a.Config)
 
 	returnDefaultPositiveResponse(w, a.logger)
@@ -362,6 +386,7 @@ func (a *AuthAPI) ProviderBeginAuthEndpoint(w http.ResponseWriter, r *http.Reque
 func (a *AuthAPI) ProviderCallbackEndpoint(w http.ResponseWriter, r *http.Request) {
 	u, err := gothic.CompleteUserAuth(w, r)
 	if err != nil {
+		recordLogin(r, a.Connection, a.GeoIP, a.logger, loginAttempt{Method: LoginMethodOAuth, Provider: chi.URLParam(r, "provider"), Reason: "provider_error"})
 		a.logger.Error("failed to complete auth...", "error", err)
 		utils.WriteError(w, a.logger, err, "Authentication failed", http.StatusUnauthorized)
 		return
@@ -389,6 +414,7 @@ func (a *AuthAPI) ProviderCallbackEndpoint(w http.ResponseWriter, r *http.Reques
 	a.logger.Debug("got user from provider: ", "user", u)
 
 	var signedInUser *db.User
+	var linkedProvider bool
 	err = a.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
 		if ai, err := tx.Auth.FindAuthIdentity(r.Context(), provider, subject); err == nil {
 			a.logger.Debug("found auth identity", "provider", provider, "subject", subject)
@@ -426,6 +452,7 @@ func (a *AuthAPI) ProviderCallbackEndpoint(w http.ResponseWriter, r *http.Reques
 			}
 
 			signedInUser = curr
+			linkedProvider = true
 			return nil
 		}
 
@@ -439,6 +466,7 @@ func (a *AuthAPI) ProviderCallbackEndpoint(w http.ResponseWriter, r *http.Reques
 					return err
 				}
 				signedInUser = u2
+				linkedProvider = true
 				return nil
 			}
 		}
@@ -450,12 +478,16 @@ func (a *AuthAPI) ProviderCallbackEndpoint(w http.ResponseWriter, r *http.Reques
 		if err := tx.Preferences.Create(r.Context(), dbUser.ID); err != nil {
 			return err
 		}
+		if err := events.Emit(r.Context(), tx, events.UserRegistered, events.UserRegisteredPayload{UserID: dbUser.ID, Email: email, Method: provider}); err != nil {
+			return err
+		}
 
 		signedInUser = dbUser
 		return nil
 	})
 
 	if err != nil {
+		recordLogin(r, a.Connection, a.GeoIP, a.logger, loginAttempt{Email: email, Method: LoginMethodOAuth, Provider: provider, Reason: "account_error"})
 		a.logger.Error("failed to complete auth with provider "+provider, "error", err)
 		http.Error(w, "auth failed: "+err.Error(), http.StatusUnauthorized)
 		return
@@ -469,21 +501,8 @@ func (a *AuthAPI) ProviderCallbackEndpoint(w http.ResponseWriter, r *http.Reques
 		if len(existing) > 0 {
 			return nil
 		}
-		name := "My team"
-		if signedInUser.Name != nil && *signedInUser.Name != "" {
-			name = *signedInUser.Name + "'s team"
-		}
-		ws := &db.Team{
-			Name:    name,
-			OwnerID: signedInUser.ID,
-			Owner:   *signedInUser,
-			Users:   []db.User{*signedInUser},
-		}
-		if err := tx.Teams.Create(r.Context(), ws); err != nil {
-			return err
-		}
-		_ = tx.Teams.AddMember(r.Context(), ws.ID, signedInUser.ID, "owner")
-		return nil
+		_, err = createPersonalTeam(r.Context(), tx, signedInUser)
+		return err
 	}); terr != nil {
 		a.logger.Warn("failed to ensure default team on oauth sign-in", "error", terr)
 	}
@@ -494,8 +513,20 @@ func (a *AuthAPI) ProviderCallbackEndpoint(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	event, newDevice := recordLogin(r, a.Connection, a.GeoIP, a.logger, loginAttempt{User: signedInUser, Email: email, Method: LoginMethodOAuth, Provider: provider, Success: true})
+	if linkedProvider {
+		a.alertProviderLinked(r, signedInUser, provider)
+	} else if newDevice {
+		a.alertNewDevice(r.Context(), signedInUser, event)
+	}
+
 	returnCookieToken(a.Config.APP_URL, w, token, a.Config)
 
+	if inviteToken := pendingInvitationToken(r, a.Connection, *signedInUser.Email); inviteToken != "" {
+		http.Redirect(w, r, a.EmailClient.BuildInvitationUrl(inviteToken), http.StatusFound)
+		return
+	}
+
 	http.Redirect(w, r, a.Config.APP_URL+"/auth/ready", http.StatusFound)
 }
 
@@ -556,9 +587,9 @@ func (a *AuthAPI) ResendEmailEndpoint(w http.ResponseWriter, r *http.Request) {
 		)
 		return
 	}
-	id, err := a.EmailClient.SendConfirmationEmail(mail, "Confirm your email", 60)
+	id, err := a.EmailClient.QueueConfirmationEmail(r.Context(), mail, 60)
 	if err != nil {
-		utils.WriteError(w, a.logger, err, "error sending confirmation email", http.StatusInternalServerError)
+		writeQueueEmailError(w, a.logger, err, "error queueing confirmation email")
 		return
 	}
 
@@ -623,8 +654,8 @@ func (a *AuthAPI) ResetPasswordEndpoint(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
-	if _, err := a.EmailClient.SendResetPasswordEmail(mail, "Reset your password", 60); err != nil {
-		utils.WriteError(w, a.logger, err, "error sending reset password email", http.StatusInternalServerError)
+	if _, err := a.EmailClient.QueueResetPasswordEmail(r.Context(), mail, 60); err != nil {
+		writeQueueEmailError(w, a.logger, err, "error queueing reset password email")
 		return
 	}
 
@@ -664,6 +695,7 @@ func (a *AuthAPI) ResetPasswordConfirmEndpoint(w http.ResponseWriter, r *http.Re
 
 	mail_address, err := a.EmailClient.R.Verify(r.Context(), []byte(a.RedisSecret), email.ResetPasswordPurpose, requestStruct.ResetID, requestStruct.Code)
 	if err != nil {
+		recordLogin(r, a.Connection, a.GeoIP, a.logger, loginAttempt{Method: LoginMethodPasswordReset, Reason: "invalid_code"})
 		switch {
 		case errors.Is(err, email.ErrNotFound), errors.Is(err, email.ErrExpired):
 			utils.WriteError(w, a.logger, err, "Invalid or expired code", http.StatusBadRequest)
@@ -679,8 +711,11 @@ func (a *AuthAPI) ResetPasswordConfirmEndpoint(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	resetUser, _ := a.Connection.Users.ByEmail(r.Context(), mail_address)
+
 	err = utils.ResetPassword(r.Context(), a.Connection, mail_address, requestStruct.Password)
 	if err != nil {
+		recordLogin(r, a.Connection, a.GeoIP, a.logger, loginAttempt{User: resetUser, Email: mail_address, Method: LoginMethodPasswordReset, Reason: "reset_failed"})
 		utils.WriteError(w, a.logger, err, "Failed to update password", http.StatusInternalServerError)
 		return
 	}
@@ -691,7 +726,80 @@ func (a *AuthAPI) ResetPasswordConfirmEndpoint(w http.ResponseWriter, r *http.Re
 		return
 	}
 
+	recordLogin(r, a.Connection, a.GeoIP, a.logger, loginAttempt{User: resetUser, Email: mail_address, Method: LoginMethodPasswordReset, Success: true})
+	sendSecurityAlert(r.Context(), a.Notifier, a.Connection, a.logger, resetUser, mail_address, email.SecurityAlert{
+		Kind:    email.AlertPasswordChanged,
+		Details: append([]email.AlertDetail{{Label: email.DetailChangedVia, Value: email.ViaResetLink, Localized: true}}, requestAlertDetails(r, a.GeoIP)...),
+	})
+
 	returnCookieToken(a.Config.APP_URL, w, token, a.Config)
 
 	http.Redirect(w, r, a.Config.APP_URL+"/auth/ready?password_reset=true", http.StatusFound)
 }
+
+// POST /auth/secure-account
+//
+// The link in security alert emails opens a confirmation page in the app, which posts
+// here: link scanners and prefetchers follow GET links and must not lock the user out.
+func (a *AuthAPI) SecureAccountEndpoint(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		ID   string `json:"cid"`
+		Code string `json:"code"`
+	}
+	if err := utils.ReadJSON(r.Body, w, a.logger, &req); err != nil {
+		return
+	}
+	if req.ID == "" || req.Code == "" {
+		utils.WriteError(w, a.logger, nil, "invalid link", http.StatusBadRequest)
+		return
+	}
+
+	mail, err := a.EmailClient.R.Verify(r.Context(), []byte(a.RedisSecret), email.SecureAccountPurpose, req.ID, req.Code)
+	if err != nil {
+		switch {
+		case errors.Is(err, email.ErrNotFound), errors.Is(err, email.ErrExpired), errors.Is(err, email.ErrConsumed), errors.Is(err, email.ErrMismatch):
+			utils.WriteError(w, a.logger, err, "Invalid or expired link", http.StatusBadRequest)
+		case errors.Is(err, email.ErrTooMany):
+			utils.WriteError(w, a.logger, err, "Too many attempts, try again later", http.StatusTooManyRequests)
+		default:
+			utils.WriteError(w, a.logger, err, "Failed to verify link", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	var u *db.User
+	err = a.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		found, err := tx.Users.ByEmail(r.Context(), mail)
+		if err != nil {
+			return err
+		}
+		now := time.Now()
+		found.SessionsRevokedAt = &now
+		u = found
+		return tx.Users.Update(r.Context(), found)
+	})
+	if err != nil {
+		utils.WriteError(w, a.logger, err, "Failed to secure account", http.StatusInternalServerError)
+		return
+	}
+
+	// OAuth-only accounts have no password to reset; revoking sessions is all we can do for them.
+	if u.PasswordCredential != nil && !u.PasswordCredential.PasswordDisabled {
+		if _, err := a.EmailClient.QueueResetPasswordEmail(r.Context(), mail, 60); err != nil {
+			a.logger.Error("failed to queue reset password email while securing account", "error", err, "user_id", u.ID)
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     "token",
+		Value:    "",
+		Path:     "/",
+		MaxAge:   -1,
+		Expires:  time.Unix(0, 0),
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	utils.WriteSuccess(w, a.logger, map[string]any{"status": "secured"}, http.StatusOK)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/images"
+	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+)
+
+type avatarResponse struct {
+	AvatarURL string `json:"avatar_url"`
+	// every size the upload was resized to, by edge length in pixels
+	Variants map[int]string `json:"variants"`
+}
+
+func avatarURL(u *db.User) string {
+	if u.AvatarURL == nil {
+		return ""
+	}
+	return *u.AvatarURL
+}
+
+// POST /account/avatar
+//
+// Multipart form with the image in "file": JPEG, PNG or GIF up to MAX_UPLOAD_MB. It is
+// cropped square and stored at each size of images.Avatar, replacing the current avatar.
+func (h *UsersAPI) UploadAvatarEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	data, ok := readImageUpload(w, r, h.logger, h.Config.MaxUploadMB<<20)
+	if !ok {
+		return
+	}
+	variants, err := images.Process(data, images.Avatar)
+	if err != nil {
+		writeImageError(w, h.logger, err)
+		return
+	}
+	key, err := h.Images.Save(r.Context(), images.Avatar, userObj.ID, variants)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to store avatar", http.StatusInternalServerError)
+		return
+	}
+
+	oldKey := userObj.AvatarKey
+	url := h.Images.URL(images.Avatar, key, images.Avatar.DefaultSize)
+	userObj.AvatarURL, userObj.AvatarKey = &url, key
+	if err := h.Connection.Users.Update(r.Context(), userObj); err != nil {
+		h.deleteAvatar(r.Context(), key)
+		utils.WriteError(w, h.logger, err, "failed to update user", http.StatusInternalServerError)
+		return
+	}
+	h.deleteAvatar(r.Context(), oldKey)
+
+	utils.WriteSuccess(w, h.logger, avatarResponse{AvatarURL: url, Variants: h.Images.URLs(images.Avatar, key)}, http.StatusOK)
+}
+
+// DELETE /account/avatar
+func (h *UsersAPI) DeleteAvatarEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	oldKey := userObj.AvatarKey
+	userObj.AvatarURL, userObj.AvatarKey = nil, ""
+	if err := h.Connection.Users.Update(r.Context(), userObj); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to update user", http.StatusInternalServerError)
+		return
+	}
+	h.deleteAvatar(r.Context(), oldKey)
+
+	utils.WriteSuccess(w, h.logger, avatarResponse{Variants: map[int]string{}}, http.StatusOK)
+}
+
+// deleteAvatar removes the files of an avatar that is no longer used. A failure only
+// leaves unreferenced files behind, so it is logged rather than reported.
+func (h *UsersAPI) deleteAvatar(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	if err := h.Images.Delete(ctx, images.Avatar, key); err != nil {
+		h.logger.Warn("failed to delete old avatar", "error", err, "key", key)
+	}
+}
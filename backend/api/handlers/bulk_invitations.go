@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+)
+
+const (
+	bulkInvitationsMaxRows   = 1000
+	bulkInvitationsBatchSize = 100
+	bulkInvitationsMaxBytes  = 2 << 20
+)
+
+type bulkInvitationRow struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+}
+
+type bulkInvitationResult struct {
+	Row    int    `json:"row"`
+	Email  string `json:"email"`
+	Role   string `json:"role,omitempty"`
+	Status string `json:"status"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// readBulkInvitationRows accepts text/csv, a multipart "file" field with CSV, or JSON
+// (either an array of rows or {"invitations": [...]}).
+func readBulkInvitationRows(r *http.Request) ([]bulkInvitationRow, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case "application/json", "":
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		var rows []bulkInvitationRow
+		if err := json.Unmarshal(body, &rows); err == nil {
+			return rows, nil
+		}
+		var wrapped struct {
+			Invitations []bulkInvitationRow `json:"invitations"`
+		}
+		if err := json.Unmarshal(body, &wrapped); err != nil {
+			return nil, errors.New("invalid JSON body")
+		}
+		return wrapped.Invitations, nil
+	case "multipart/form-data":
+		file, _, err := r.FormFile("file")
+		if err != nil {
+			return nil, errors.New("missing CSV file in \"file\" field")
+		}
+		defer file.Close()
+		return parseBulkInvitationCSV(file)
+	case "text/csv", "application/csv", "text/plain":
+		return parseBulkInvitationCSV(r.Body)
+	}
+	return nil, fmt.Errorf("unsupported content type %q", mediaType)
+}
+
+func parseBulkInvitationCSV(src io.Reader) ([]bulkInvitationRow, error) {
+	reader := csv.NewReader(src)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("invalid CSV: %w", err)
+	}
+
+	emailCol, roleCol := 0, 1
+	if len(records) > 0 {
+		header := make(map[string]int)
+		for i, col := range records[0] {
+			header[strings.ToLower(strings.TrimSpace(col))] = i
+		}
+		if idx, ok := header["email"]; ok {
+			emailCol = idx
+			roleCol = -1
+			if ri, ok := header["role"]; ok {
+				roleCol = ri
+			}
+			records = records[1:]
+		}
+	}
+
+	rows := make([]bulkInvitationRow, 0, len(records))
+	for _, rec := range records {
+		var row bulkInvitationRow
+		if emailCol < len(rec) {
+			row.Email = strings.TrimSpace(rec[emailCol])
+		}
+		if roleCol >= 0 && roleCol < len(rec) {
+			row.Role = strings.TrimSpace(rec[roleCol])
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// POST /teams/{id}/invitations/bulk
+func (h *TeamsAPI) BulkCreateInvitationsEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	access := middleware.TeamAccessFromContext(r.Context())
+	team := access.Team
+
+	r.Body = http.MaxBytesReader(w, r.Body, bulkInvitationsMaxBytes)
+	rows, err := readBulkInvitationRows(r)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(rows) == 0 {
+		utils.WriteError(w, h.logger, nil, "no rows to import", http.StatusBadRequest)
+		return
+	}
+	if len(rows) > bulkInvitationsMaxRows {
+		utils.WriteError(w, h.logger, nil, fmt.Sprintf("too many rows, the limit is %d", bulkInvitationsMaxRows), http.StatusBadRequest)
+		return
+	}
+
+	memberEmails := make(map[string]bool, len(team.Users))
+	for _, u := range team.Users {
+		if u.Email != nil {
+			memberEmails[strings.ToLower(*u.Email)] = true
+		}
+	}
+
+	pending, err := h.Connection.Invitations.ListByTeam(r.Context(), team.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to check existing invitations", http.StatusInternalServerError)
+		return
+	}
+	pendingEmails := make(map[string]bool, len(pending))
+	for _, p := range pending {
+		if time.Now().Before(p.ExpiresAt) {
+			pendingEmails[strings.ToLower(p.Email)] = true
+		}
+	}
+	defaultRole, ttl, err := h.invitationDefaults(r.Context(), team.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to read team settings", http.StatusInternalServerError)
+		return
+	}
+
+	results := make([]bulkInvitationResult, len(rows))
+	roleErrs := make(map[string]error)
+	seen := make(map[string]int, len(rows))
+	var toCreate []db.TeamInvitation
+	var createdRows []int
+
+	for i, row := range rows {
+		res := bulkInvitationResult{Row: i + 1, Email: row.Email}
+
+		addr, err := utils.ValidateEmail(row.Email)
+		if err != nil {
+			res.Status, res.Reason = "failed", err.Error()
+			results[i] = res
+			continue
+		}
+		res.Email = addr
+
+		role := strings.ToLower(row.Role)
+		if role == "" {
+			role = defaultRole
+		}
+		res.Role = role
+		roleErr, checked := roleErrs[role]
+		if !checked {
+			roleErr = h.checkAssignableRole(r.Context(), access, role)
+			roleErrs[role] = roleErr
+		}
+		if roleErr != nil {
+			res.Status, res.Reason = "failed", roleErr.Error()
+			results[i] = res
+			continue
+		}
+
+		switch {
+		case memberEmails[addr]:
+			res.Status, res.Reason = "skipped", "already a team member"
+		case pendingEmails[addr]:
+			res.Status, res.Reason = "skipped", "an active invitation already exists"
+		case seen[addr] > 0:
+			res.Status, res.Reason = "skipped", fmt.Sprintf("duplicate of row %d", seen[addr])
+		default:
+			seen[addr] = i + 1
+			toCreate = append(toCreate, db.TeamInvitation{
+				TeamID:    team.ID,
+				Email:     addr,
+				Token:     generateToken(),
+				Role:      role,
+				Status:    "pending",
+				ExpiresAt: time.Now().Add(ttl),
+			})
+			createdRows = append(createdRows, i)
+			res.Status = "created"
+		}
+		results[i] = res
+	}
+
+	if len(toCreate) > 0 {
+		emails := make([]string, 0, len(toCreate))
+		for _, inv := range toCreate {
+			emails = append(emails, inv.Email)
+		}
+		users, err := h.Connection.Users.ListByEmails(r.Context(), emails)
+		if err != nil {
+			h.logger.Warn("failed to look up invited users", "error", err)
+		}
+		byEmail := make(map[string]*db.User, len(users))
+		for i := range users {
+			if users[i].Email != nil {
+				byEmail[strings.ToLower(*users[i].Email)] = &users[i]
+			}
+		}
+
+		// hundreds of emails do not fit in a request; each one is sent when the outbox
+		// relays its InvitationCreated event
+		err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+			if err := tx.Invitations.CreateBatch(r.Context(), toCreate, bulkInvitationsBatchSize); err != nil {
+				return err
+			}
+			for i := range toCreate {
+				inv := &toCreate[i]
+				if err := h.audit(r, tx, team.ID, auditEntry{
+					Action:      auditInvitationCreated,
+					TargetType:  "invitation",
+					TargetID:    inv.ID,
+					TargetLabel: inv.Email,
+					After:       map[string]any{"role": inv.Role, "expires_at": inv.ExpiresAt, "bulk": true},
+				}); err != nil {
+					return err
+				}
+				if err := h.recordInvitation(r.Context(), tx, team, userObj, inv, byEmail[inv.Email]); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			h.logger.Error("failed to create invitations in bulk", "error", err, "team_id", team.ID)
+			for _, idx := range createdRows {
+				results[idx].Status, results[idx].Reason = "failed", "could not save invitation"
+			}
+		}
+	}
+
+	summary := map[string]int{"created": 0, "skipped": 0, "failed": 0}
+	for _, res := range results {
+		summary[res.Status]++
+	}
+
+	utils.WriteSuccess(w, h.logger, map[string]any{
+		"summary": summary,
+		"results": results,
+	}, http.StatusOK)
+}
@@ -5,6 +5,7 @@ import (
 	"net/http"
 
 	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/images"
 	"github.com/Neat-Snap/blueprint-backend/logger"
 	"github.com/Neat-Snap/blueprint-backend/middleware"
 	"github.com/Neat-Snap/blueprint-backend/utils"
@@ -13,10 +14,11 @@ import (
 type HandlersAPI struct {
 	logger     logger.MultiLogger
 	Connection *db.Connection
+	Images     *images.Library
 }
 
-func NewDashboardAPI(logger logger.MultiLogger, connection *db.Connection) *HandlersAPI {
-	return &HandlersAPI{logger: logger, Connection: connection}
+func NewDashboardAPI(logger logger.MultiLogger, connection *db.Connection, library *images.Library) *HandlersAPI {
+	return &HandlersAPI{logger: logger, Connection: connection, Images: library}
 }
 
 // GET /dashboard/overview
@@ -42,9 +44,11 @@ func (h *HandlersAPI) OverViewEndpoint(w http.ResponseWriter, r *http.Request) {
 		}
 
 		teamResp = append(teamResp, map[string]any{
-			"id":   team.ID,
-			"name": team.Name,
-			"role": role,
+			"id":       team.ID,
+			"name":     team.Name,
+			"icon":     team.Icon,
+			"logo_url": teamLogoURL(h.Images, &team),
+			"role":     role,
 		})
 	}
 
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/Neat-Snap/blueprint-backend/utils/email"
+	"github.com/go-chi/chi/v5"
+)
+
+// DevMailboxAPI exposes emails caught by the capture transport. It is only routed with
+// APP_ENV=dev, for local requests and admins.
+type DevMailboxAPI struct {
+	logger  logger.MultiLogger
+	mailbox *email.CaptureMailer
+}
+
+func NewDevMailboxAPI(logger logger.MultiLogger, mailbox *email.CaptureMailer) *DevMailboxAPI {
+	return &DevMailboxAPI{logger: logger, mailbox: mailbox}
+}
+
+// GET /dev/mailbox
+func (h *DevMailboxAPI) ListEndpoint(w http.ResponseWriter, r *http.Request) {
+	limit, _ := utils.ParsePagination(r, 50, 500)
+	list, err := h.mailbox.List(r.URL.Query().Get("to"), limit)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list messages", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"items": list}, http.StatusOK)
+}
+
+func (h *DevMailboxAPI) load(w http.ResponseWriter, r *http.Request) (*email.CapturedMessage, bool) {
+	msg, err := h.mailbox.Get(chi.URLParam(r, "message_id"))
+	if errors.Is(err, email.ErrMessageNotFound) {
+		utils.WriteError(w, h.logger, err, "message not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to read message", http.StatusInternalServerError)
+		return nil, false
+	}
+	return msg, true
+}
+
+// GET /dev/mailbox/{message_id}
+func (h *DevMailboxAPI) GetEndpoint(w http.ResponseWriter, r *http.Request) {
+	if msg, ok := h.load(w, r); ok {
+		utils.WriteSuccess(w, h.logger, msg, http.StatusOK)
+	}
+}
+
+// GET /dev/mailbox/{message_id}/html renders the message as a mail client would.
+func (h *DevMailboxAPI) HTMLEndpoint(w http.ResponseWriter, r *http.Request) {
+	msg, ok := h.load(w, r)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; img-src * data:; style-src 'unsafe-inline'")
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte(msg.HTML))
+}
+
+// DELETE /dev/mailbox
+func (h *DevMailboxAPI) ClearEndpoint(w http.ResponseWriter, r *http.Request) {
+	if err := h.mailbox.Clear(); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to clear mailbox", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "cleared"}, http.StatusOK)
+}
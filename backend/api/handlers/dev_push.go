@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/Neat-Snap/blueprint-backend/webpush"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+const devPushMaxBytes = 8 << 10
+
+// DevPushAPI runs a stand-in push service so Web Push can be tried without a browser. It
+// is only routed with APP_ENV=dev, for local requests and admins; deliveries to it need
+// WEB_PUSH_ALLOW_PRIVATE when the backend runs on a private address.
+type DevPushAPI struct {
+	logger     logger.MultiLogger
+	Connection *db.Connection
+	standIn    *webpush.StandIn
+}
+
+func NewDevPushAPI(logger logger.MultiLogger, connection *db.Connection, standIn *webpush.StandIn) *DevPushAPI {
+	return &DevPushAPI{logger: logger, Connection: connection, standIn: standIn}
+}
+
+// POST /dev/push/subscriptions
+//
+// Body: {"user_id": 1}. Subscribes the user as a browser would, with an endpoint on the
+// stand-in.
+func (h *DevPushAPI) SubscribeEndpoint(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		UserID uint `json:"user_id"`
+	}
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		return
+	}
+	if _, err := h.Connection.Users.ByID(r.Context(), req.UserID); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.WriteError(w, h.logger, err, "user not found", http.StatusNotFound)
+			return
+		}
+		utils.WriteError(w, h.logger, err, "failed to get user", http.StatusInternalServerError)
+		return
+	}
+
+	id, sub, err := h.standIn.Subscribe()
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to create subscription", http.StatusInternalServerError)
+		return
+	}
+	if err := h.Connection.Push.Save(r.Context(), &db.PushSubscription{
+		UserID:    req.UserID,
+		Endpoint:  sub.Endpoint,
+		P256dh:    sub.P256dh,
+		Auth:      sub.Auth,
+		UserAgent: "dev stand-in",
+	}); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to save push subscription", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"id": id, "endpoint": sub.Endpoint}, http.StatusCreated)
+}
+
+// POST /dev/push/endpoints/{id} is where the stand-in receives pushes.
+func (h *DevPushAPI) ReceiveEndpoint(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, devPushMaxBytes))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "push too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	status, err := h.standIn.Receive(chi.URLParam(r, "id"), r.Header, body)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), status)
+		return
+	}
+	w.WriteHeader(status)
+}
+
+// DELETE /dev/push/endpoints/{id} expires a subscription, as when a browser drops it;
+// pushes to it then fail with 410 Gone.
+func (h *DevPushAPI) ExpireEndpoint(w http.ResponseWriter, r *http.Request) {
+	if !h.standIn.Expire(chi.URLParam(r, "id")) {
+		utils.WriteError(w, h.logger, nil, "subscription not found", http.StatusNotFound)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "expired"}, http.StatusOK)
+}
+
+// GET /dev/push/messages lists the decrypted pushes received, newest first.
+func (h *DevPushAPI) MessagesEndpoint(w http.ResponseWriter, r *http.Request) {
+	utils.WriteSuccess(w, h.logger, map[string]any{"items": h.standIn.Messages()}, http.StatusOK)
+}
+
+// DELETE /dev/push/messages
+func (h *DevPushAPI) ClearEndpoint(w http.ResponseWriter, r *http.Request) {
+	h.standIn.Clear()
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "cleared"}, http.StatusOK)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/notify"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/Neat-Snap/blueprint-backend/utils/email"
+	"gorm.io/gorm"
+)
+
+const emailEventMaxBytes = 1 << 20
+
+type EmailEventsAPI struct {
+	logger       logger.MultiLogger
+	Connection   *db.Connection
+	EmailClient  *email.EmailClient
+	Notifier     *notify.Dispatcher
+	resendSecret string
+}
+
+func NewEmailEventsAPI(logger logger.MultiLogger, connection *db.Connection, emailClient *email.EmailClient, notifier *notify.Dispatcher, resendSecret string) *EmailEventsAPI {
+	return &EmailEventsAPI{logger: logger, Connection: connection, EmailClient: emailClient, Notifier: notifier, resendSecret: resendSecret}
+}
+
+// POST /email/webhooks/resend
+func (h *EmailEventsAPI) ResendWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, emailEventMaxBytes))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if err := email.VerifyResendWebhook(h.resendSecret, r.Header, body, time.Now()); err != nil {
+		utils.WriteError(w, h.logger, err, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	ev, ok, err := email.ParseResendEvent(body)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "invalid event", http.StatusBadRequest)
+		return
+	}
+	if ok {
+		suppression, err := h.EmailClient.ApplyDeliveryEvent(r.Context(), ev)
+		if err != nil {
+			// a non-2xx response makes the provider retry the event later
+			utils.WriteError(w, h.logger, err, "failed to record event", http.StatusInternalServerError)
+			return
+		}
+		if suppression != nil {
+			h.notifySuppressed(r, suppression)
+		}
+	}
+
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "ok"}, http.StatusOK)
+}
+
+// notifySuppressed tells the account owner in the app, since email no longer reaches them.
+func (h *EmailEventsAPI) notifySuppressed(r *http.Request, s *db.EmailSuppression) {
+	user, err := h.Connection.Users.ByEmail(r.Context(), s.Email)
+	if err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			h.logger.Warn("failed to look up suppressed address", "error", err)
+		}
+		return
+	}
+	if err := h.Notifier.Send(r.Context(), h.Connection, notify.Message{
+		UserID: user.ID,
+		Data:   notify.EmailSuppressed{Email: s.Email, Reason: s.Reason},
+	}); err != nil {
+		h.logger.Warn("failed to notify about suppressed address", "error", err, "user_id", user.ID)
+	}
+}
+
+// writeQueueEmailError answers a request whose email could not be queued. A suppressed
+// address is the caller's problem to fix, anything else is ours.
+func writeQueueEmailError(w http.ResponseWriter, log logger.MultiLogger, err error, msg string) {
+	if errors.Is(err, email.ErrSuppressed) {
+		utils.WriteError(w, log, err, "emails to this address bounced or were reported as spam; use a different address or contact support", http.StatusUnprocessableEntity)
+		return
+	}
+	utils.WriteError(w, log, err, msg, http.StatusInternalServerError)
+}
@@ -72,6 +72,10 @@ func (h *FeedbackAPI) SubmitEndpoint(w http.ResponseWriter, r *http.Request) {
 		"<p><strong>User:</strong> " + html.EscapeString(uname) + " (" + html.EscapeString(uemail) + ")</p>" +
 		"<p><strong>User ID:</strong> " + strconv.Itoa(int(userObj.ID)) + "</p>" +
 		"<hr/><pre style=\"white-space:pre-wrap; font-family: ui-monospace, SFMono-Regular, Menlo, monospace\">" + html.EscapeString(req.Message) + "</pre>"
+	text := "You received new feedback.\n\n" +
+		"User: " + uname + " (" + uemail + ")\n" +
+		"User ID: " + strconv.Itoa(int(userObj.ID)) + "\n\n" +
+		req.Message + "\n"
 
 	recipient := h.cfg.SUPPORT_EMAIL
 	if h.cfg.DEVELOPER_EMAIL != "" {
@@ -80,7 +84,7 @@ func (h *FeedbackAPI) SubmitEndpoint(w http.ResponseWriter, r *http.Request) {
 
 	h.logger.Debug("sending feedback email to %s", recipient)
 
-	if _, err := h.emailClient.SendEmail(recipient, subject, body); err != nil {
+	if err := h.emailClient.Enqueue(r.Context(), email.Message{Type: email.TypeFeedback, To: recipient, Subject: subject, HTML: body, Text: text}); err != nil {
 		utils.WriteError(w, h.logger, err, "failed to send feedback", http.StatusInternalServerError)
 		return
 	}
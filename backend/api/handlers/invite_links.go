@@ -0,0 +1,291 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/events"
+	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+var (
+	errAlreadyMember         = errors.New("user is already a team member")
+	errInviteLinkUnavailable = errors.New("invite link unavailable")
+	errTeamDeleted           = errors.New("team has been deleted")
+)
+
+// joinTeam is the single membership path for invitations and invite links.
+// Existing members keep their current role.
+func joinTeam(ctx context.Context, tx *db.Connection, teamID, userID uint, role string) error {
+	if _, err := tx.Teams.GetUserRole(ctx, teamID, userID); err == nil {
+		return errAlreadyMember
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err := tx.Teams.AddMember(ctx, teamID, userID, role); err != nil {
+		return err
+	}
+	return events.Emit(ctx, tx, events.MemberAdded, events.MemberPayload{TeamID: teamID, UserID: userID, Role: role, ActorID: userID})
+}
+
+type inviteLinkResponse struct {
+	ID            uint       `json:"id"`
+	Role          string     `json:"role"`
+	AllowedDomain string     `json:"allowed_domain,omitempty"`
+	MaxUses       int        `json:"max_uses"`
+	Uses          int        `json:"uses"`
+	Status        string     `json:"status"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	Token         string     `json:"token,omitempty"`
+	URL           string     `json:"url,omitempty"`
+}
+
+func inviteLinkStatus(l *db.TeamInviteLink) string {
+	switch {
+	case l.RevokedAt != nil:
+		return "revoked"
+	case time.Now().After(l.ExpiresAt):
+		return "expired"
+	case l.MaxUses > 0 && l.Uses >= l.MaxUses:
+		return "exhausted"
+	}
+	return "active"
+}
+
+func toInviteLinkResponse(l *db.TeamInviteLink) inviteLinkResponse {
+	return inviteLinkResponse{
+		ID:            l.ID,
+		Role:          l.Role,
+		AllowedDomain: l.AllowedDomain,
+		MaxUses:       l.MaxUses,
+		Uses:          l.Uses,
+		Status:        inviteLinkStatus(l),
+		CreatedAt:     l.CreatedAt,
+		ExpiresAt:     l.ExpiresAt,
+		RevokedAt:     l.RevokedAt,
+	}
+}
+
+// POST /teams/{id}/invite-links
+func (h *TeamsAPI) CreateInviteLinkEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	access := middleware.TeamAccessFromContext(r.Context())
+	team := access.Team
+
+	var req struct {
+		Role           string `json:"role"`
+		ExpiresInHours int    `json:"expires_in_hours"`
+		MaxUses        int    `json:"max_uses"`
+		AllowedDomain  string `json:"allowed_domain"`
+	}
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		return
+	}
+	defaultRole, ttl, err := h.invitationDefaults(r.Context(), team.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to read team settings", http.StatusInternalServerError)
+		return
+	}
+	if req.Role == "" {
+		req.Role = defaultRole
+	}
+	if err := h.checkAssignableRole(r.Context(), access, req.Role); err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), roleErrorStatus(err))
+		return
+	}
+	if req.ExpiresInHours <= 0 {
+		req.ExpiresInHours = int(ttl.Hours())
+	}
+	if req.ExpiresInHours > 90*24 {
+		utils.WriteError(w, h.logger, nil, "expiry cannot exceed 90 days", http.StatusBadRequest)
+		return
+	}
+	if req.MaxUses < 0 {
+		utils.WriteError(w, h.logger, nil, "max_uses cannot be negative", http.StatusBadRequest)
+		return
+	}
+	domain := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(req.AllowedDomain, "@")))
+	if domain != "" && (!strings.Contains(domain, ".") || strings.ContainsAny(domain, "@ /")) {
+		utils.WriteError(w, h.logger, nil, "invalid allowed domain", http.StatusBadRequest)
+		return
+	}
+
+	token := generateToken()
+	link := &db.TeamInviteLink{
+		TeamID:        team.ID,
+		CreatedByID:   userObj.ID,
+		TokenHash:     utils.HashToken(token),
+		Role:          req.Role,
+		AllowedDomain: domain,
+		MaxUses:       req.MaxUses,
+		ExpiresAt:     time.Now().Add(time.Duration(req.ExpiresInHours) * time.Hour),
+	}
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.InviteLinks.Create(r.Context(), link); err != nil {
+			return err
+		}
+		return h.audit(r, tx, team.ID, auditEntry{
+			Action:     auditInviteLinkCreated,
+			TargetType: "invite_link",
+			TargetID:   link.ID,
+			After:      map[string]any{"role": link.Role, "max_uses": link.MaxUses, "allowed_domain": link.AllowedDomain, "expires_at": link.ExpiresAt},
+		})
+	})
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to create invite link", http.StatusInternalServerError)
+		return
+	}
+
+	resp := toInviteLinkResponse(link)
+	resp.Token = token
+	resp.URL = h.EmailClient.Config.APP_URL + "/invite?link=" + url.QueryEscape(token)
+	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
+}
+
+// GET /teams/{id}/invite-links
+func (h *TeamsAPI) ListInviteLinksEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+
+	list, err := h.Connection.InviteLinks.ListByTeam(r.Context(), team.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list invite links", http.StatusInternalServerError)
+		return
+	}
+
+	resp := make([]inviteLinkResponse, 0, len(list))
+	for i := range list {
+		resp = append(resp, toInviteLinkResponse(&list[i]))
+	}
+	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
+}
+
+// DELETE /teams/{id}/invite-links/{link_id}
+func (h *TeamsAPI) RevokeInviteLinkEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+
+	linkID, err := strconv.Atoi(chi.URLParam(r, "link_id"))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "invalid invite link ID", http.StatusBadRequest)
+		return
+	}
+
+	link, err := h.Connection.InviteLinks.ByID(r.Context(), uint(linkID))
+	if err != nil || link.TeamID != team.ID {
+		utils.WriteError(w, h.logger, err, "invite link not found", http.StatusNotFound)
+		return
+	}
+
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.InviteLinks.Revoke(r.Context(), link.ID); err != nil {
+			return err
+		}
+		return h.audit(r, tx, team.ID, auditEntry{
+			Action:     auditInviteLinkRevoked,
+			TargetType: "invite_link",
+			TargetID:   link.ID,
+			Before:     map[string]any{"uses": link.Uses},
+		})
+	})
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to revoke invite link", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "revoked"}, http.StatusOK)
+}
+
+// POST /teams/invite-links/redeem
+func (h *TeamsAPI) RedeemInviteLinkEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	var req struct {
+		Token string `json:"token"`
+	}
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		return
+	}
+	if strings.TrimSpace(req.Token) == "" {
+		utils.WriteError(w, h.logger, nil, "token is required", http.StatusBadRequest)
+		return
+	}
+
+	link, err := h.Connection.InviteLinks.ByTokenHash(r.Context(), utils.HashToken(strings.TrimSpace(req.Token)))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "invite link not found", http.StatusNotFound)
+		return
+	}
+	if status := inviteLinkStatus(link); status != "active" {
+		utils.WriteError(w, h.logger, nil, "invite link is "+status, http.StatusBadRequest)
+		return
+	}
+	if link.AllowedDomain != "" {
+		if userObj.Email == nil || !strings.HasSuffix(strings.ToLower(*userObj.Email), "@"+link.AllowedDomain) {
+			utils.WriteError(w, h.logger, nil, "this invite link is restricted to @"+link.AllowedDomain+" addresses", http.StatusForbidden)
+			return
+		}
+	}
+
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		// links outlive a soft-deleted team; the lock keeps it from being deleted until this commits
+		if _, err := tx.Teams.LockByID(r.Context(), link.TeamID); errors.Is(err, gorm.ErrRecordNotFound) {
+			return errTeamDeleted
+		} else if err != nil {
+			return err
+		}
+		if err := joinTeam(r.Context(), tx, link.TeamID, userObj.ID, link.Role); err != nil {
+			return err
+		}
+		ok, err := tx.InviteLinks.ConsumeUse(r.Context(), link.ID)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return errInviteLinkUnavailable
+		}
+		return h.audit(r, tx, link.TeamID, auditEntry{
+			Action:      auditMemberAdded,
+			TargetType:  "user",
+			TargetID:    userObj.ID,
+			TargetLabel: memberLabel(userObj),
+			After:       map[string]any{"role": link.Role, "invite_link_id": link.ID},
+		})
+	})
+	switch {
+	case errors.Is(err, errAlreadyMember):
+		utils.WriteError(w, h.logger, err, "you are already a member of this team", http.StatusConflict)
+		return
+	case errors.Is(err, errInviteLinkUnavailable):
+		utils.WriteError(w, h.logger, err, "invite link is no longer valid", http.StatusBadRequest)
+		return
+	case errors.Is(err, errTeamDeleted):
+		utils.WriteError(w, h.logger, err, "this team has been deleted", http.StatusGone)
+		return
+	case err != nil:
+		utils.WriteError(w, h.logger, err, "failed to redeem invite link", http.StatusInternalServerError)
+		return
+	}
+
+	team, err := h.Connection.Teams.ByID(r.Context(), link.TeamID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get team", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccess(w, h.logger, map[string]any{
+		"status":    "accepted",
+		"team_id":   team.ID,
+		"team_name": team.Name,
+		"role":      link.Role,
+	}, http.StatusOK)
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/Neat-Snap/blueprint-backend/utils/geoip"
+)
+
+const (
+	LoginMethodPassword      = "password"
+	LoginMethodOAuth         = "oauth"
+	LoginMethodPasswordReset = "password_reset"
+)
+
+type loginAttempt struct {
+	User     *db.User
+	Email    string
+	Method   string
+	Provider string
+	Success  bool
+	Reason   string
+}
+
+type LoginEventResponse struct {
+	ID         uint      `json:"id"`
+	CreatedAt  time.Time `json:"created_at"`
+	Method     string    `json:"method"`
+	Provider   string    `json:"provider,omitempty"`
+	Success    bool      `json:"success"`
+	Reason     string    `json:"reason,omitempty"`
+	IP         string    `json:"ip"`
+	UserAgent  string    `json:"user_agent"`
+	Browser    string    `json:"browser"`
+	OS         string    `json:"os"`
+	DeviceType string    `json:"device_type"`
+	Country    string    `json:"country,omitempty"`
+}
+
+// recordLogin stores the attempt and reports whether a successful sign-in came from a
+// device the user has not signed in from before (never true for the very first sign-in).
+func recordLogin(r *http.Request, conn *db.Connection, geo *geoip.Resolver, log logger.MultiLogger, a loginAttempt) (*db.LoginEvent, bool) {
+	ua := r.UserAgent()
+	info := utils.ParseUserAgent(ua)
+	ip := utils.ClientIP(r)
+
+	event := &db.LoginEvent{
+		Email:      strings.ToLower(strings.TrimSpace(a.Email)),
+		Method:     a.Method,
+		Provider:   a.Provider,
+		Success:    a.Success,
+		Reason:     a.Reason,
+		IP:         ip,
+		UserAgent:  ua,
+		Browser:    info.Browser,
+		OS:         info.OS,
+		DeviceType: info.DeviceType,
+		DeviceHash: info.DeviceHash(),
+		Country:    geo.Country(ip),
+	}
+	newDevice := false
+	if a.User != nil && a.User.ID != 0 {
+		event.UserID = &a.User.ID
+		if event.Email == "" && a.User.Email != nil {
+			event.Email = *a.User.Email
+		}
+		if a.Success {
+			seen, anyBefore, err := conn.LoginEvents.DeviceSeen(r.Context(), a.User.ID, event.DeviceHash)
+			if err != nil {
+				log.Warn("failed to check known devices", "error", err, "user_id", a.User.ID)
+			} else {
+				newDevice = anyBefore && !seen
+			}
+		}
+	}
+
+	if err := conn.LoginEvents.Create(r.Context(), event); err != nil {
+		log.Warn("failed to record login event", "error", err, "method", a.Method)
+		return nil, false
+	}
+	return event, newDevice
+}
+
+func loginEventsResponse(list []db.LoginEvent) []LoginEventResponse {
+	resp := make([]LoginEventResponse, 0, len(list))
+	for _, e := range list {
+		resp = append(resp, LoginEventResponse{
+			ID:         e.ID,
+			CreatedAt:  e.CreatedAt,
+			Method:     e.Method,
+			Provider:   e.Provider,
+			Success:    e.Success,
+			Reason:     e.Reason,
+			IP:         e.IP,
+			UserAgent:  e.UserAgent,
+			Browser:    e.Browser,
+			OS:         e.OS,
+			DeviceType: e.DeviceType,
+			Country:    e.Country,
+		})
+	}
+	return resp
+}
+
+func writeLoginHistory(w http.ResponseWriter, r *http.Request, conn *db.Connection, log logger.MultiLogger, userID uint) {
+	limit, offset := utils.ParsePagination(r, 20, 100)
+	list, total, err := conn.LoginEvents.ListForUser(r.Context(), userID, limit, offset)
+	if err != nil {
+		utils.WriteError(w, log, err, "failed to list login history", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccess(w, log, map[string]any{
+		"items":  loginEventsResponse(list),
+		"total":  total,
+		"limit":  limit,
+		"offset": offset,
+	}, http.StatusOK)
+}
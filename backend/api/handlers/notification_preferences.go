@@ -0,0 +1,229 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/notify"
+	"github.com/Neat-Snap/blueprint-backend/settings"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/Neat-Snap/blueprint-backend/utils/email"
+	"gorm.io/gorm"
+)
+
+type notificationPreferenceResponse struct {
+	Type string `json:"type"`
+	// the channels in use; empty means the type is turned off
+	Channels  []string `json:"channels"`
+	Available []string `json:"available"`
+	Default   []string `json:"default"`
+	Locked    bool     `json:"locked"`
+}
+
+func (h *NotificationsAPI) notificationPreferences(r *http.Request, userID uint) ([]notificationPreferenceResponse, error) {
+	saved, err := h.Connection.Preferences.NotificationChannels(r.Context(), userID)
+	if err != nil {
+		return nil, err
+	}
+	byType := make(map[string]*db.NotificationPreference, len(saved))
+	for i := range saved {
+		byType[saved[i].Type] = &saved[i]
+	}
+
+	list := make([]notificationPreferenceResponse, 0, len(notify.Types))
+	for _, spec := range notify.Types {
+		channels := spec.Default
+		if pref, ok := byType[spec.Type]; ok && !spec.Locked {
+			channels = notify.PreferenceChannels(spec, pref)
+		}
+		list = append(list, notificationPreferenceResponse{
+			Type:      spec.Type,
+			Channels:  channels,
+			Available: spec.Channels,
+			Default:   spec.Default,
+			Locked:    spec.Locked,
+		})
+	}
+	return list, nil
+}
+
+// GET /notifications/preferences
+func (h *NotificationsAPI) GetPreferencesEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	list, err := h.notificationPreferences(r, userObj.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get notification preferences", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"preferences": list}, http.StatusOK)
+}
+
+// PUT /notifications/preferences
+//
+// Body: {"preferences": {"<type>": ["in_app", "push", "email", "digest"]}}. Types left
+// out keep their current channels; an empty list turns a type off.
+func (h *NotificationsAPI) UpdatePreferencesEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	var req struct {
+		Preferences map[string][]string `json:"preferences"`
+	}
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		return
+	}
+
+	prefs := make([]db.NotificationPreference, 0, len(req.Preferences))
+	for typ, channels := range req.Preferences {
+		if !notify.Known(typ) {
+			utils.WriteError(w, h.logger, nil, fmt.Sprintf("unknown notification type %q", typ), http.StatusBadRequest)
+			return
+		}
+		spec := notify.Lookup(typ)
+		if spec.Locked {
+			utils.WriteError(w, h.logger, nil, fmt.Sprintf("%s notifications cannot be changed", typ), http.StatusBadRequest)
+			return
+		}
+		pref := db.NotificationPreference{UserID: userObj.ID, Type: typ}
+		for _, channel := range channels {
+			if !spec.Allows(channel) {
+				utils.WriteError(w, h.logger, nil, fmt.Sprintf("%s notifications cannot be sent by %s", typ, channel), http.StatusBadRequest)
+				return
+			}
+			switch channel {
+			case notify.ChannelInApp:
+				pref.InApp = true
+			case notify.ChannelEmail:
+				pref.Email = true
+			case notify.ChannelDigest:
+				pref.Digest = true
+			case notify.ChannelPush:
+				pref.Push = true
+			}
+		}
+		prefs = append(prefs, pref)
+	}
+
+	if err := h.Connection.Preferences.SetNotificationChannels(r.Context(), prefs); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to update notification preferences", http.StatusInternalServerError)
+		return
+	}
+	list, err := h.notificationPreferences(r, userObj.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get notification preferences", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"preferences": list}, http.StatusOK)
+}
+
+type digestSettingsResponse struct {
+	Frequency   string   `json:"frequency"`
+	Timezone    string   `json:"timezone"`
+	Frequencies []string `json:"frequencies"`
+}
+
+func toDigestSettingsResponse(p *db.UserPreference) digestSettingsResponse {
+	return digestSettingsResponse{Frequency: p.DigestFrequency, Timezone: p.Timezone, Frequencies: notify.DigestFrequencies}
+}
+
+// GET /notifications/digest
+func (h *NotificationsAPI) GetDigestEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	pref, err := h.Connection.Preferences.Get(r.Context(), userObj.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get digest settings", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, toDigestSettingsResponse(pref), http.StatusOK)
+}
+
+// PUT /notifications/digest
+//
+// Body: {"frequency": "off"|"daily"|"weekly", "timezone": "Europe/Berlin"}; either may be
+// left out.
+func (h *NotificationsAPI) UpdateDigestEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	var req struct {
+		Frequency *string `json:"frequency"`
+		Timezone  *string `json:"timezone"`
+	}
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		return
+	}
+	if req.Frequency != nil && !slices.Contains(notify.DigestFrequencies, *req.Frequency) {
+		utils.WriteError(w, h.logger, nil, "frequency must be one of off, daily or weekly", http.StatusBadRequest)
+		return
+	}
+	if req.Timezone != nil {
+		if err := settings.Timezone.Check(r.Context(), h.Connection, userObj.ID, *req.Timezone); err != nil {
+			utils.WriteError(w, h.logger, err, err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+
+	pref, err := h.Connection.Preferences.Get(r.Context(), userObj.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get digest settings", http.StatusInternalServerError)
+		return
+	}
+	if req.Frequency != nil {
+		pref.DigestFrequency = *req.Frequency
+	}
+	if req.Timezone != nil {
+		pref.Timezone = *req.Timezone
+	}
+	if err := h.Connection.Preferences.Update(r.Context(), pref); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to update digest settings", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, toDigestSettingsResponse(pref), http.StatusOK)
+}
+
+// POST /notifications/digest/unsubscribe
+//
+// Reached without a session, from the unsubscribe link in digest emails or straight from
+// the mail client (RFC 8058), so the token in the query or body is all that identifies
+// the user.
+func (h *NotificationsAPI) DigestUnsubscribeEndpoint(w http.ResponseWriter, r *http.Request) {
+	token := r.URL.Query().Get("token")
+	if token == "" {
+		var req struct {
+			Token string `json:"token"`
+		}
+		if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+			return
+		}
+		token = req.Token
+	}
+
+	userID, frequency, err := email.ParseDigestUnsubscribeToken([]byte(h.UnsubscribeSecret), token)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "invalid link", http.StatusBadRequest)
+		return
+	}
+	pref, err := h.Connection.Preferences.Get(r.Context(), userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, h.logger, err, "invalid link", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to unsubscribe", http.StatusInternalServerError)
+		return
+	}
+	if pref.DigestFrequency != notify.DigestOff && pref.DigestFrequency != frequency {
+		utils.WriteError(w, h.logger, nil, "this link has expired", http.StatusGone)
+		return
+	}
+	if pref.DigestFrequency != notify.DigestOff {
+		pref.DigestFrequency = notify.DigestOff
+		if err := h.Connection.Preferences.Update(r.Context(), pref); err != nil {
+			utils.WriteError(w, h.logger, err, "failed to unsubscribe", http.StatusInternalServerError)
+			return
+		}
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "unsubscribed"}, http.StatusOK)
+}
@@ -1,52 +1,463 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
 
 	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/events"
+	"github.com/Neat-Snap/blueprint-backend/jobs"
 	"github.com/Neat-Snap/blueprint-backend/logger"
 	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/notify"
 	"github.com/Neat-Snap/blueprint-backend/utils"
 	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
 )
 
+const (
+	notificationPageLimit = 30
+	notificationBulkLimit = 200
+
+	// proxies tend to close connections that stay silent for a minute
+	streamHeartbeat = 25 * time.Second
+	// how long browsers wait before reconnecting a dropped stream
+	streamRetry = 5 * time.Second
+)
+
+var pruneNotificationsJob = jobs.Kind[struct{}]("notifications.prune")
+
 type NotificationsAPI struct {
 	logger     logger.MultiLogger
 	Connection *db.Connection
+	Hub        *notify.Hub
+	Notifier   *notify.Dispatcher
+	// signs digest unsubscribe links, see email.DigestUnsubscribeToken
+	UnsubscribeSecret string
+}
+
+func NewNotificationsAPI(logger logger.MultiLogger, connection *db.Connection, hub *notify.Hub, notifier *notify.Dispatcher, unsubscribeSecret string) *NotificationsAPI {
+	return &NotificationsAPI{logger: logger, Connection: connection, Hub: hub, Notifier: notifier, UnsubscribeSecret: unsubscribeSecret}
+}
+
+// SubscribeEvents registers the notification subscribers on the in-process bus.
+func (h *NotificationsAPI) SubscribeEvents(bus *events.Bus) {
+	bus.Subscribe(events.NotificationCreated, "notification-stream", h.pushNotification)
+}
+
+func (h *NotificationsAPI) pushNotification(ctx context.Context, e events.Event) error {
+	p, err := events.Decode[events.NotificationCreatedPayload](e)
+	if err != nil {
+		return err
+	}
+	n, err := h.Connection.Notifications.ByID(ctx, p.UserID, p.NotificationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	user, err := h.Connection.Users.ByID(ctx, p.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return h.Hub.Publish(ctx, n.UserID, notify.EventNotification, h.toNotificationResponse(h.Notifier.Locale(ctx, user), n))
+}
+
+// GET /notifications/stream
+//
+// Server-Sent Events: "notification" carries a new notification, "read" a read-state
+// change and "resync" asks the client to reload, because events it missed while
+// disconnected are no longer available.
+func (h *NotificationsAPI) StreamEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	rc := http.NewResponseController(w)
+	// the server's write timeout is meant for ordinary requests
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		h.logger.Debug("failed to clear write deadline for stream", "error", err)
+	}
+
+	stream, unsubscribe, err := h.Hub.Subscribe(r.Context(), userObj.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to open notification stream", http.StatusInternalServerError)
+		return
+	}
+	defer unsubscribe()
+
+	// subscribed before reading the backlog, so nothing published in between is lost;
+	// events seen in both are skipped by ID below
+	lastID := r.Header.Get("Last-Event-ID")
+	if lastID == "" {
+		// EventSource only sends the header on its own reconnects
+		lastID = r.URL.Query().Get("last_event_id")
+	}
+	var backlog []notify.Event
+	gap := false
+	if lastID != "" {
+		backlog, gap, err = h.Hub.Since(r.Context(), userObj.ID, lastID)
+		if err != nil {
+			utils.WriteError(w, h.logger, err, "failed to resume notification stream", http.StatusInternalServerError)
+			return
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	// keeps nginx from buffering the stream
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	fmt.Fprintf(w, "retry: %d\n\n", streamRetry.Milliseconds())
+	if gap {
+		fmt.Fprintf(w, "event: %s\ndata: {}\n\n", notify.EventResync)
+	}
+	for _, e := range backlog {
+		writeStreamEvent(w, e)
+		lastID = e.ID
+	}
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case e, ok := <-stream:
+			if !ok {
+				// too far behind; the client reconnects and resumes from the backlog
+				return
+			}
+			if lastID != "" && !notify.After(e.ID, lastID) {
+				continue
+			}
+			writeStreamEvent(w, e)
+			lastID = e.ID
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": heartbeat\n\n")
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func writeStreamEvent(w http.ResponseWriter, e notify.Event) {
+	fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data)
+}
+
+type notificationResponse struct {
+	ID        uint      `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	Type      string    `json:"type"`
+	// the payload, see notify.Payload; title, body and link are rendered from it
+	Data    json.RawMessage `json:"data"`
+	Version int             `json:"version"`
+	notify.Rendered
+	ReadAt     *time.Time `json:"read_at"`
+	ArchivedAt *time.Time `json:"archived_at"`
+}
+
+// toNotificationResponse renders n in lang, see notify.Dispatcher.Locale.
+func (h *NotificationsAPI) toNotificationResponse(lang string, n *db.Notification) notificationResponse {
+	data := json.RawMessage(n.Data)
+	if !json.Valid(data) {
+		data = json.RawMessage("null")
+	}
+	return notificationResponse{
+		ID:         n.ID,
+		CreatedAt:  n.CreatedAt,
+		Type:       n.Type,
+		Data:       data,
+		Version:    n.Version,
+		Rendered:   h.Notifier.Render(lang, n.Type, n.Version, []byte(n.Data)),
+		ReadAt:     n.ReadAt,
+		ArchivedAt: n.ArchivedAt,
+	}
 }
 
-func NewNotificationsAPI(logger logger.MultiLogger, connection *db.Connection) *NotificationsAPI {
-	return &NotificationsAPI{logger: logger, Connection: connection}
+func parseNotificationFilter(r *http.Request, userID uint) (db.NotificationFilter, error) {
+	q := r.URL.Query()
+	f := db.NotificationFilter{UserID: userID}
+	for _, t := range strings.Split(q.Get("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			f.Types = append(f.Types, t)
+		}
+	}
+	if v := q.Get("read"); v != "" {
+		read, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, errors.New("invalid read, expected true or false")
+		}
+		f.Read = &read
+	}
+	if v := q.Get("archived"); v != "" {
+		archived, err := strconv.ParseBool(v)
+		if err != nil {
+			return f, errors.New("invalid archived, expected true or false")
+		}
+		f.Archived = archived
+	}
+	if v := q.Get("cursor"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return f, errors.New("invalid cursor")
+		}
+		f.BeforeID = uint(n)
+	}
+	return f, nil
 }
 
 // GET /notifications
 func (h *NotificationsAPI) ListEndpoint(w http.ResponseWriter, r *http.Request) {
 	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
-	list, err := h.Connection.Notifications.ListForUser(r.Context(), userObj.ID)
+
+	filter, err := parseNotificationFilter(r, userObj.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+	filter.Limit, _ = utils.ParsePagination(r, notificationPageLimit, 100)
+
+	list, err := h.Connection.Notifications.List(r.Context(), filter)
 	if err != nil {
 		utils.WriteError(w, h.logger, err, "failed to list notifications", http.StatusInternalServerError)
 		return
 	}
-	utils.WriteSuccess(w, h.logger, list, http.StatusOK)
+	lang := h.Notifier.Locale(r.Context(), userObj)
+	items := make([]notificationResponse, 0, len(list))
+	for i := range list {
+		items = append(items, h.toNotificationResponse(lang, &list[i]))
+	}
+	var nextCursor *uint
+	if len(list) == filter.Limit {
+		nextCursor = &list[len(list)-1].ID
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{
+		"items":       items,
+		"next_cursor": nextCursor,
+	}, http.StatusOK)
+}
+
+// GET /notifications/unread-count
+func (h *NotificationsAPI) UnreadCountEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	count, err := h.Connection.Notifications.CountUnread(r.Context(), userObj.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to count notifications", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"count": count}, http.StatusOK)
 }
 
 // PATCH /notifications/{id}/read
 func (h *NotificationsAPI) MarkReadEndpoint(w http.ResponseWriter, r *http.Request) {
 	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
-	id, err := strconv.Atoi(chi.URLParam(r, "id"))
+	n, ok := h.notificationFromURL(w, r, userObj.ID)
+	if !ok {
+		return
+	}
+	if err := h.Connection.Notifications.MarkRead(r.Context(), n.ID); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to mark notification read", http.StatusInternalServerError)
+		return
+	}
+	h.publishRead(r.Context(), userObj.ID, []uint{n.ID})
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "ok"}, http.StatusOK)
+}
+
+// POST /notifications/read-all
+func (h *NotificationsAPI) MarkAllReadEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	ids, err := h.Connection.Notifications.MarkReadMany(r.Context(), userObj.ID, nil)
 	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid notification id", http.StatusBadRequest)
+		utils.WriteError(w, h.logger, err, "failed to mark notifications read", http.StatusInternalServerError)
 		return
 	}
-	var n db.Notification
-	if err := h.Connection.DBConn.WithContext(r.Context()).Where("id = ? AND user_id = ?", id, userObj.ID).First(&n).Error; err != nil {
-		utils.WriteError(w, h.logger, err, "notification not found", http.StatusNotFound)
+	h.publishRead(r.Context(), userObj.ID, ids)
+	utils.WriteSuccess(w, h.logger, map[string]any{"updated": len(ids)}, http.StatusOK)
+}
+
+// PATCH /notifications/{id}/archive
+func (h *NotificationsAPI) ArchiveEndpoint(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, true)
+}
+
+// DELETE /notifications/{id}/archive
+func (h *NotificationsAPI) UnarchiveEndpoint(w http.ResponseWriter, r *http.Request) {
+	h.setArchived(w, r, false)
+}
+
+func (h *NotificationsAPI) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	n, ok := h.notificationFromURL(w, r, userObj.ID)
+	if !ok {
 		return
 	}
-	if err := h.Connection.Notifications.MarkRead(r.Context(), uint(id)); err != nil {
-		utils.WriteError(w, h.logger, err, "failed to mark notification read", http.StatusInternalServerError)
+	ids, err := h.Connection.Notifications.SetArchived(r.Context(), userObj.ID, []uint{n.ID}, archived)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to update notification", http.StatusInternalServerError)
 		return
 	}
+	h.publishArchived(r.Context(), userObj.ID, ids, archived)
 	utils.WriteSuccess(w, h.logger, map[string]any{"status": "ok"}, http.StatusOK)
 }
+
+// DELETE /notifications/{id}
+func (h *NotificationsAPI) DeleteEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	n, ok := h.notificationFromURL(w, r, userObj.ID)
+	if !ok {
+		return
+	}
+	ids, err := h.Connection.Notifications.Delete(r.Context(), userObj.ID, []uint{n.ID})
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to delete notification", http.StatusInternalServerError)
+		return
+	}
+	h.publishDeleted(r.Context(), userObj.ID, ids)
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "ok"}, http.StatusOK)
+}
+
+type bulkNotificationsRequest struct {
+	// "read", "archive", "unarchive" or "delete"
+	Action string `json:"action"`
+	IDs    []uint `json:"ids"`
+}
+
+// POST /notifications/bulk
+func (h *NotificationsAPI) BulkEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	var req bulkNotificationsRequest
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		return
+	}
+	if len(req.IDs) == 0 {
+		utils.WriteError(w, h.logger, nil, "ids are required", http.StatusBadRequest)
+		return
+	}
+	if len(req.IDs) > notificationBulkLimit {
+		utils.WriteError(w, h.logger, nil, fmt.Sprintf("at most %d ids per request", notificationBulkLimit), http.StatusBadRequest)
+		return
+	}
+
+	ctx := r.Context()
+	var ids []uint
+	var err error
+	switch req.Action {
+	case "read":
+		if ids, err = h.Connection.Notifications.MarkReadMany(ctx, userObj.ID, req.IDs); err == nil {
+			h.publishRead(ctx, userObj.ID, ids)
+		}
+	case "archive", "unarchive":
+		archived := req.Action == "archive"
+		if ids, err = h.Connection.Notifications.SetArchived(ctx, userObj.ID, req.IDs, archived); err == nil {
+			h.publishArchived(ctx, userObj.ID, ids, archived)
+		}
+	case "delete":
+		if ids, err = h.Connection.Notifications.Delete(ctx, userObj.ID, req.IDs); err == nil {
+			h.publishDeleted(ctx, userObj.ID, ids)
+		}
+	default:
+		utils.WriteError(w, h.logger, nil, "action must be read, archive, unarchive or delete", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to update notifications", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"updated": len(ids)}, http.StatusOK)
+}
+
+func (h *NotificationsAPI) notificationFromURL(w http.ResponseWriter, r *http.Request, userID uint) (*db.Notification, bool) {
+	id, err := strconv.ParseUint(chi.URLParam(r, "id"), 10, 64)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "invalid notification id", http.StatusBadRequest)
+		return nil, false
+	}
+	n, err := h.Connection.Notifications.ByID(r.Context(), userID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, h.logger, err, "notification not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to load notification", http.StatusInternalServerError)
+		return nil, false
+	}
+	return n, true
+}
+
+// publishRead tells the user's other tabs and devices about a read-state change.
+func (h *NotificationsAPI) publishRead(ctx context.Context, userID uint, ids []uint) {
+	if len(ids) == 0 {
+		return
+	}
+	h.publish(ctx, userID, notify.EventRead, map[string]any{"ids": ids, "read_at": time.Now()})
+}
+
+func (h *NotificationsAPI) publishArchived(ctx context.Context, userID uint, ids []uint, archived bool) {
+	if len(ids) == 0 {
+		return
+	}
+	if archived {
+		h.publish(ctx, userID, notify.EventArchived, map[string]any{"ids": ids, "archived_at": time.Now()})
+	} else {
+		h.publish(ctx, userID, notify.EventUnarchived, map[string]any{"ids": ids})
+	}
+}
+
+func (h *NotificationsAPI) publishDeleted(ctx context.Context, userID uint, ids []uint) {
+	if len(ids) == 0 {
+		return
+	}
+	h.publish(ctx, userID, notify.EventDeleted, map[string]any{"ids": ids})
+}
+
+func (h *NotificationsAPI) publish(ctx context.Context, userID uint, eventType string, payload any) {
+	if err := h.Hub.Publish(ctx, userID, eventType, payload); err != nil {
+		h.logger.Warn("failed to publish notification event", "error", err, "user_id", userID, "type", eventType)
+	}
+}
+
+// ScheduleRetention prunes notifications read, and digest items sent, longer than
+// retention ago once a day.
+// A zero retention keeps them forever.
+func (h *NotificationsAPI) ScheduleRetention(q *jobs.Queue, retention time.Duration) {
+	if retention <= 0 {
+		return
+	}
+	jobs.Handle(q, pruneNotificationsJob, func(ctx context.Context, _ struct{}) error {
+		cutoff := time.Now().Add(-retention)
+		n, err := h.Connection.Notifications.DeleteReadBefore(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			h.logger.Info("pruned read notifications", "deleted", n)
+		}
+		n, err = h.Connection.Notifications.DeleteDigestItemsSentBefore(ctx, cutoff)
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			h.logger.Info("pruned sent digest items", "deleted", n)
+		}
+		return nil
+	})
+	if err := jobs.Every(q, pruneNotificationsJob, 24*time.Hour, struct{}{}); err != nil {
+		h.logger.Error("failed to schedule notification pruning", "error", err)
+	}
+}
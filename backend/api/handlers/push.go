@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/notify"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/Neat-Snap/blueprint-backend/webhooks"
+	"github.com/Neat-Snap/blueprint-backend/webpush"
+)
+
+const pushUserAgentMaxLength = 512
+
+type PushAPI struct {
+	logger       logger.MultiLogger
+	Connection   *db.Connection
+	Notifier     *notify.Dispatcher
+	allowPrivate bool
+}
+
+func NewPushAPI(logger logger.MultiLogger, connection *db.Connection, notifier *notify.Dispatcher, allowPrivate bool) *PushAPI {
+	return &PushAPI{logger: logger, Connection: connection, Notifier: notifier, allowPrivate: allowPrivate}
+}
+
+type pushSubscriptionResponse struct {
+	Endpoint      string     `json:"endpoint"`
+	UserAgent     string     `json:"user_agent"`
+	CreatedAt     time.Time  `json:"created_at"`
+	LastSuccessAt *time.Time `json:"last_success_at"`
+	LastFailureAt *time.Time `json:"last_failure_at"`
+}
+
+// GET /notifications/push/key returns the VAPID public key to subscribe with.
+func (h *PushAPI) KeyEndpoint(w http.ResponseWriter, r *http.Request) {
+	key, ok := h.Notifier.PushPublicKey()
+	if !ok {
+		utils.WriteError(w, h.logger, nil, "push notifications are not available", http.StatusServiceUnavailable)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"public_key": key}, http.StatusOK)
+}
+
+// GET /notifications/push/subscriptions
+func (h *PushAPI) ListSubscriptionsEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	list, err := h.Connection.Push.ListByUser(r.Context(), userObj.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list push subscriptions", http.StatusInternalServerError)
+		return
+	}
+	items := make([]pushSubscriptionResponse, 0, len(list))
+	for _, sub := range list {
+		items = append(items, pushSubscriptionResponse{
+			Endpoint:      sub.Endpoint,
+			UserAgent:     sub.UserAgent,
+			CreatedAt:     sub.CreatedAt,
+			LastSuccessAt: sub.LastSuccessAt,
+			LastFailureAt: sub.LastFailureAt,
+		})
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"items": items}, http.StatusOK)
+}
+
+// POST /notifications/push/subscriptions
+//
+// Body: the browser's PushSubscription as JSON, {"endpoint": "...", "keys": {"p256dh":
+// "...", "auth": "..."}}. Registering an endpoint again replaces its keys.
+func (h *PushAPI) SubscribeEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	var req struct {
+		Endpoint string `json:"endpoint"`
+		Keys     struct {
+			P256dh string `json:"p256dh"`
+			Auth   string `json:"auth"`
+		} `json:"keys"`
+	}
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		return
+	}
+	if _, ok := h.Notifier.PushPublicKey(); !ok {
+		utils.WriteError(w, h.logger, nil, "push notifications are not available", http.StatusServiceUnavailable)
+		return
+	}
+
+	u, err := webhooks.ValidateURL(r.Context(), req.Endpoint, h.allowPrivate)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "push endpoint must be a public https URL", http.StatusBadRequest)
+		return
+	}
+	sub := webpush.Subscription{Endpoint: u.String(), P256dh: req.Keys.P256dh, Auth: req.Keys.Auth}
+	if err := sub.Validate(); err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	userAgent := r.UserAgent()
+	if len(userAgent) > pushUserAgentMaxLength {
+		userAgent = userAgent[:pushUserAgentMaxLength]
+	}
+	if err := h.Connection.Push.Save(r.Context(), &db.PushSubscription{
+		UserID:    userObj.ID,
+		Endpoint:  sub.Endpoint,
+		P256dh:    sub.P256dh,
+		Auth:      sub.Auth,
+		UserAgent: userAgent,
+	}); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to save push subscription", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "subscribed"}, http.StatusCreated)
+}
+
+// DELETE /notifications/push/subscriptions
+//
+// Body: {"endpoint": "..."}.
+func (h *PushAPI) UnsubscribeEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	var req struct {
+		Endpoint string `json:"endpoint"`
+	}
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		return
+	}
+	deleted, err := h.Connection.Push.Delete(r.Context(), userObj.ID, req.Endpoint)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to delete push subscription", http.StatusInternalServerError)
+		return
+	}
+	if !deleted {
+		utils.WriteError(w, h.logger, nil, "push subscription not found", http.StatusNotFound)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "unsubscribed"}, http.StatusOK)
+}
+
+// POST /notifications/push/test pushes a test message to each of the user's browsers.
+func (h *PushAPI) TestEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	if _, ok := h.Notifier.PushPublicKey(); !ok {
+		utils.WriteError(w, h.logger, nil, "push notifications are not available", http.StatusServiceUnavailable)
+		return
+	}
+	res, err := h.Notifier.SendTestPush(r.Context(), userObj)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to send test push", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, res, http.StatusOK)
+}
@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/notify"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/Neat-Snap/blueprint-backend/utils/email"
+	"github.com/Neat-Snap/blueprint-backend/utils/geoip"
+)
+
+// requestAlertDetails describes where an account change came from.
+func requestAlertDetails(r *http.Request, geo *geoip.Resolver) []email.AlertDetail {
+	ip := utils.ClientIP(r)
+	ua := utils.ParseUserAgent(r.UserAgent())
+	return []email.AlertDetail{
+		{Label: email.DetailTime, Value: time.Now().UTC().Format("2006-01-02 15:04 MST")},
+		{Label: email.DetailDevice, Value: ua.Browser + " on " + ua.OS + " (" + ua.DeviceType + ")"},
+		{Label: email.DetailIP, Value: ip},
+		{Label: email.DetailCountry, Value: geo.Country(ip)},
+	}
+}
+
+func loginEventAlertDetails(e *db.LoginEvent) []email.AlertDetail {
+	return []email.AlertDetail{
+		{Label: email.DetailTime, Value: e.CreatedAt.UTC().Format("2006-01-02 15:04 MST")},
+		{Label: email.DetailMethod, Value: utils.PickNonEmpty(e.Provider, e.Method)},
+		{Label: email.DetailDevice, Value: e.Browser + " on " + e.OS + " (" + e.DeviceType + ")"},
+		{Label: email.DetailIP, Value: e.IP},
+		{Label: email.DetailCountry, Value: e.Country},
+	}
+}
+
+// sendSecurityAlert notifies user in the app and emails recipient, which may be an
+// address the account no longer uses. Failing to do so must not fail the request that
+// triggered it.
+func sendSecurityAlert(ctx context.Context, notifier *notify.Dispatcher, conn *db.Connection, log logger.MultiLogger, user *db.User, recipient string, alert email.SecurityAlert) {
+	if user == nil || recipient == "" {
+		return
+	}
+	err := notifier.Send(ctx, conn, notify.Message{UserID: user.ID, Data: notify.SecurityAlert(alert), Email: recipient})
+	if err != nil {
+		log.Error("failed to send security alert", "error", err, "kind", alert.Kind)
+	}
+}
+
+func (a *AuthAPI) alertNewDevice(ctx context.Context, user *db.User, event *db.LoginEvent) {
+	if user == nil || user.Email == nil || event == nil {
+		return
+	}
+	sendSecurityAlert(ctx, a.Notifier, a.Connection, a.logger, user, *user.Email, email.SecurityAlert{
+		Kind:    email.AlertNewDevice,
+		Details: loginEventAlertDetails(event),
+	})
+}
+
+func (a *AuthAPI) alertProviderLinked(r *http.Request, user *db.User, provider string) {
+	if user == nil || user.Email == nil {
+		return
+	}
+	details := append([]email.AlertDetail{{Label: email.DetailProvider, Value: provider}}, requestAlertDetails(r, a.GeoIP)...)
+	sendSecurityAlert(r.Context(), a.Notifier, a.Connection, a.logger, user, *user.Email, email.SecurityAlert{
+		Kind:    email.AlertProviderLinked,
+		Details: details,
+	})
+}
@@ -0,0 +1,114 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/settings"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+)
+
+// GET /account/settings
+func (h *UsersAPI) GetSettingsEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	writeSettings(w, r, h.Connection, h.logger, settings.ScopeUser, userObj.ID)
+}
+
+// PATCH /account/settings
+//
+// Body: the settings to change, e.g. {"theme": "dark", "timezone": "Europe/Berlin"}; null
+// resets one to its default. Responds with all of them.
+func (h *UsersAPI) UpdateSettingsEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	var changes map[string]json.RawMessage
+	if err := utils.ReadJSON(r.Body, w, h.logger, &changes); err != nil {
+		return
+	}
+	if err := settings.Apply(r.Context(), h.Connection, settings.ScopeUser, userObj.ID, changes); err != nil {
+		writeSettingsError(w, h.logger, err)
+		return
+	}
+	writeSettings(w, r, h.Connection, h.logger, settings.ScopeUser, userObj.ID)
+}
+
+// GET /account/settings/schema
+func (h *UsersAPI) SettingsSchemaEndpoint(w http.ResponseWriter, r *http.Request) {
+	utils.WriteSuccess(w, h.logger, settings.Schema(settings.ScopeUser), http.StatusOK)
+}
+
+// GET /teams/{id}/settings
+func (h *TeamsAPI) GetSettingsEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+	writeSettings(w, r, h.Connection, h.logger, settings.ScopeTeam, team.ID)
+}
+
+// PATCH /teams/{id}/settings
+//
+// Body and response as for PATCH /account/settings.
+func (h *TeamsAPI) UpdateSettingsEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+
+	var changes map[string]json.RawMessage
+	if err := utils.ReadJSON(r.Body, w, h.logger, &changes); err != nil {
+		return
+	}
+	before, err := settings.Values(r.Context(), h.Connection, settings.ScopeTeam, team.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get team settings", http.StatusInternalServerError)
+		return
+	}
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := settings.Apply(r.Context(), tx, settings.ScopeTeam, team.ID, changes); err != nil {
+			return err
+		}
+		after, err := settings.Values(r.Context(), tx, settings.ScopeTeam, team.ID)
+		if err != nil {
+			return err
+		}
+		// only what was sent goes in the log
+		old, changed := map[string]any{}, map[string]any{}
+		for key := range changes {
+			old[key], changed[key] = before[key], after[key]
+		}
+		return h.audit(r, tx, team.ID, auditEntry{
+			Action:     auditTeamSettingsChanged,
+			TargetType: "team",
+			TargetID:   team.ID,
+			Before:     old,
+			After:      changed,
+		})
+	})
+	if err != nil {
+		writeSettingsError(w, h.logger, err)
+		return
+	}
+	writeSettings(w, r, h.Connection, h.logger, settings.ScopeTeam, team.ID)
+}
+
+// GET /teams/{id}/settings/schema
+func (h *TeamsAPI) SettingsSchemaEndpoint(w http.ResponseWriter, r *http.Request) {
+	utils.WriteSuccess(w, h.logger, settings.Schema(settings.ScopeTeam), http.StatusOK)
+}
+
+func writeSettings(w http.ResponseWriter, r *http.Request, conn *db.Connection, log logger.MultiLogger, scope settings.Scope, ownerID uint) {
+	values, err := settings.Values(r.Context(), conn, scope, ownerID)
+	if err != nil {
+		utils.WriteError(w, log, err, "failed to get settings", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, log, values, http.StatusOK)
+}
+
+func writeSettingsError(w http.ResponseWriter, log logger.MultiLogger, err error) {
+	var invalid *settings.Error
+	if errors.As(err, &invalid) {
+		utils.WriteError(w, log, err, invalid.Error(), http.StatusBadRequest)
+		return
+	}
+	utils.WriteError(w, log, err, "failed to update settings", http.StatusInternalServerError)
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+)
+
+const (
+	auditMemberAdded         = "member.added"
+	auditMemberRemoved       = "member.removed"
+	auditMemberLeft          = "member.left"
+	auditMemberRoleChanged   = "member.role_changed"
+	auditInvitationCreated   = "invitation.created"
+	auditInvitationRevoked   = "invitation.revoked"
+	auditInvitationAccepted  = "invitation.accepted"
+	auditInviteLinkCreated   = "invite_link.created"
+	auditInviteLinkRevoked   = "invite_link.revoked"
+	auditTeamRenamed         = "team.renamed"
+	auditTeamIconChanged     = "team.icon_changed"
+	auditTeamSettingsChanged = "team.settings_changed"
+	auditTeamDeleted         = "team.deleted"
+	auditTeamRestored        = "team.restored"
+	auditOwnershipOffered    = "ownership.transfer_started"
+	auditOwnershipAccepted   = "ownership.transferred"
+	auditWebhookCreated      = "webhook.created"
+	auditWebhookUpdated      = "webhook.updated"
+	auditWebhookDeleted      = "webhook.deleted"
+)
+
+const (
+	auditPageLimit   = 50
+	auditExportLimit = 10000
+)
+
+type auditEntry struct {
+	Action      string
+	TargetType  string
+	TargetID    uint
+	TargetLabel string
+	Before      any
+	After       any
+}
+
+func auditJSON(v any) string {
+	if v == nil {
+		return ""
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return ""
+	}
+	return string(b)
+}
+
+// audit appends an entry to the team's audit log and queues the matching webhook event
+// on conn. Make the change and call audit in the same transaction and return its error,
+// so no change commits without its entry.
+func (h *TeamsAPI) audit(r *http.Request, conn *db.Connection, teamID uint, e auditEntry) error {
+	ev := &db.TeamAuditEvent{
+		TeamID:      teamID,
+		Action:      e.Action,
+		TargetType:  e.TargetType,
+		TargetLabel: e.TargetLabel,
+		Before:      auditJSON(e.Before),
+		After:       auditJSON(e.After),
+		IP:          utils.ClientIP(r),
+		UserAgent:   r.UserAgent(),
+	}
+	if userObj, ok := r.Context().Value(middleware.UserObjectContextKey).(*db.User); ok {
+		ev.ActorID = &userObj.ID
+	}
+	if e.TargetID != 0 {
+		ev.TargetID = &e.TargetID
+	}
+	if err := conn.Audit.Create(r.Context(), ev); err != nil {
+		return fmt.Errorf("write audit event: %w", err)
+	}
+
+	if event, ok := webhookEvents[e.Action]; ok && h.Webhooks != nil {
+		data := toAuditEventResponse(ev)
+		// the actor's IP and user agent stay in the audit log and are not sent to third parties
+		data.IP, data.UserAgent = "", ""
+		if err := h.Webhooks.Enqueue(r.Context(), conn, teamID, event, data); err != nil {
+			return fmt.Errorf("queue webhook %s: %w", event, err)
+		}
+	}
+	return nil
+}
+
+func memberLabel(u *db.User) string {
+	if u == nil || u.Email == nil {
+		return ""
+	}
+	return *u.Email
+}
+
+type auditEventResponse struct {
+	ID          uint            `json:"id"`
+	CreatedAt   time.Time       `json:"created_at"`
+	ActorID     *uint           `json:"actor_id"`
+	Action      string          `json:"action"`
+	TargetType  string          `json:"target_type"`
+	TargetID    *uint           `json:"target_id"`
+	TargetLabel string          `json:"target_label"`
+	Before      json.RawMessage `json:"before,omitempty"`
+	After       json.RawMessage `json:"after,omitempty"`
+	IP          string          `json:"ip,omitempty"`
+	UserAgent   string          `json:"user_agent,omitempty"`
+}
+
+func toAuditEventResponse(e *db.TeamAuditEvent) auditEventResponse {
+	resp := auditEventResponse{
+		ID:          e.ID,
+		CreatedAt:   e.CreatedAt,
+		ActorID:     e.ActorID,
+		Action:      e.Action,
+		TargetType:  e.TargetType,
+		TargetID:    e.TargetID,
+		TargetLabel: e.TargetLabel,
+		IP:          e.IP,
+		UserAgent:   e.UserAgent,
+	}
+	if e.Before != "" {
+		resp.Before = json.RawMessage(e.Before)
+	}
+	if e.After != "" {
+		resp.After = json.RawMessage(e.After)
+	}
+	return resp
+}
+
+func parseAuditFilter(r *http.Request, teamID uint) (db.AuditFilter, error) {
+	q := r.URL.Query()
+	f := db.AuditFilter{TeamID: teamID, Action: q.Get("action")}
+
+	uintParams := map[string]*uint{"actor_id": &f.ActorID, "target_id": &f.TargetID, "cursor": &f.BeforeID}
+	for name, dst := range uintParams {
+		if v := q.Get(name); v != "" {
+			n, err := strconv.ParseUint(v, 10, 64)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s", name)
+			}
+			*dst = uint(n)
+		}
+	}
+	timeParams := map[string]*time.Time{"since": &f.Since, "until": &f.Until}
+	for name, dst := range timeParams {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				return f, fmt.Errorf("invalid %s, expected RFC 3339", name)
+			}
+			*dst = t
+		}
+	}
+	return f, nil
+}
+
+// GET /teams/{id}/audit
+func (h *TeamsAPI) ListAuditEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+
+	filter, err := parseAuditFilter(r, team.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	switch format := r.URL.Query().Get("format"); format {
+	case "csv", "json":
+		h.exportAudit(w, r, team, filter, format)
+		return
+	case "":
+	default:
+		utils.WriteError(w, h.logger, nil, "format must be csv or json", http.StatusBadRequest)
+		return
+	}
+
+	filter.Limit, _ = utils.ParsePagination(r, auditPageLimit, 200)
+	list, err := h.Connection.Audit.List(r.Context(), filter)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list audit events", http.StatusInternalServerError)
+		return
+	}
+
+	items := make([]auditEventResponse, 0, len(list))
+	for i := range list {
+		items = append(items, toAuditEventResponse(&list[i]))
+	}
+	var nextCursor *uint
+	if len(list) == filter.Limit {
+		nextCursor = &list[len(list)-1].ID
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{
+		"items":       items,
+		"next_cursor": nextCursor,
+	}, http.StatusOK)
+}
+
+// exportAudit writes every matching event, newest first, up to auditExportLimit.
+func (h *TeamsAPI) exportAudit(w http.ResponseWriter, r *http.Request, team *db.Team, filter db.AuditFilter, format string) {
+	var all []db.TeamAuditEvent
+	filter.Limit = 500
+	for len(all) < auditExportLimit {
+		page, err := h.Connection.Audit.List(r.Context(), filter)
+		if err != nil {
+			utils.WriteError(w, h.logger, err, "failed to export audit events", http.StatusInternalServerError)
+			return
+		}
+		all = append(all, page...)
+		if len(page) < filter.Limit {
+			break
+		}
+		filter.BeforeID = page[len(page)-1].ID
+	}
+	if len(all) > auditExportLimit {
+		all = all[:auditExportLimit]
+	}
+
+	filename := fmt.Sprintf("team-%d-audit-%s.%s", team.ID, time.Now().UTC().Format("20060102"), format)
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+
+	if format == "json" {
+		items := make([]auditEventResponse, 0, len(all))
+		for i := range all {
+			items = append(items, toAuditEventResponse(&all[i]))
+		}
+		utils.WriteSuccess(w, h.logger, items, http.StatusOK)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	cw := csv.NewWriter(w)
+	_ = cw.Write([]string{"id", "created_at", "actor_id", "action", "target_type", "target_id", "target_label", "before", "after", "ip", "user_agent"})
+	for _, e := range all {
+		_ = cw.Write([]string{
+			strconv.FormatUint(uint64(e.ID), 10),
+			e.CreatedAt.UTC().Format(time.RFC3339),
+			optionalID(e.ActorID),
+			e.Action,
+			e.TargetType,
+			optionalID(e.TargetID),
+			e.TargetLabel,
+			e.Before,
+			e.After,
+			e.IP,
+			e.UserAgent,
+		})
+	}
+	cw.Flush()
+	if err := cw.Error(); err != nil {
+		h.logger.Error("failed to write audit csv", "error", err, "team_id", team.ID)
+	}
+}
+
+func optionalID(id *uint) string {
+	if id == nil {
+		return ""
+	}
+	return strconv.FormatUint(uint64(*id), 10)
+}
@@ -0,0 +1,126 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/images"
+	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+)
+
+type teamLogoResponse struct {
+	Icon    string `json:"icon"`
+	LogoURL string `json:"logo_url"`
+	// every size the logo was resized to, by edge length in pixels
+	Variants map[int]string `json:"variants"`
+}
+
+// teamLogoURL is where the team's uploaded logo is served, or "" when it uses a built-in
+// icon.
+func teamLogoURL(library *images.Library, team *db.Team) string {
+	if team.LogoKey == "" {
+		return ""
+	}
+	return library.URL(images.TeamLogo, team.LogoKey, images.TeamLogo.DefaultSize)
+}
+
+func (h *TeamsAPI) teamIconState(team *db.Team) map[string]any {
+	return map[string]any{"icon": team.Icon, "logo_url": teamLogoURL(h.Images, team)}
+}
+
+// POST /teams/{id}/logo
+//
+// Multipart form with the image in "file": JPEG, PNG or GIF up to MAX_UPLOAD_MB. It is
+// fitted into a square, padded with transparency, and stored at each size of
+// images.TeamLogo. The logo is shown instead of the built-in icon until it is reset.
+func (h *TeamsAPI) UploadLogoEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+
+	data, ok := readImageUpload(w, r, h.logger, h.Config.MaxUploadMB<<20)
+	if !ok {
+		return
+	}
+	variants, err := images.Process(data, images.TeamLogo)
+	if err != nil {
+		writeImageError(w, h.logger, err)
+		return
+	}
+	key, err := h.Images.Save(r.Context(), images.TeamLogo, team.ID, variants)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to store logo", http.StatusInternalServerError)
+		return
+	}
+
+	before := *team
+	team.LogoKey = key
+	if err := h.updateIcon(r, &before, team); err != nil {
+		h.deleteLogo(r.Context(), key)
+		utils.WriteError(w, h.logger, err, "failed to update team", http.StatusInternalServerError)
+		return
+	}
+	h.deleteLogo(r.Context(), before.LogoKey)
+
+	utils.WriteSuccess(w, h.logger, teamLogoResponse{
+		Icon:     team.Icon,
+		LogoURL:  teamLogoURL(h.Images, team),
+		Variants: h.Images.URLs(images.TeamLogo, key),
+	}, http.StatusOK)
+}
+
+// DELETE /teams/{id}/logo?icon=<name>
+//
+// Removes the uploaded logo so the team is shown with a built-in icon again: the one
+// given in the query, or the one it had before the upload.
+func (h *TeamsAPI) ResetLogoEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+
+	icon := r.URL.Query().Get("icon")
+	if !isAllowedIcon(icon) {
+		utils.WriteError(w, h.logger, nil, "invalid icon", http.StatusBadRequest)
+		return
+	}
+
+	before := *team
+	team.LogoKey = ""
+	if icon != "" {
+		team.Icon = icon
+	}
+	if before.LogoKey != team.LogoKey || before.Icon != team.Icon {
+		if err := h.updateIcon(r, &before, team); err != nil {
+			utils.WriteError(w, h.logger, err, "failed to update team", http.StatusInternalServerError)
+			return
+		}
+		h.deleteLogo(r.Context(), before.LogoKey)
+	}
+
+	utils.WriteSuccess(w, h.logger, teamLogoResponse{Icon: team.Icon, Variants: map[int]string{}}, http.StatusOK)
+}
+
+// updateIcon saves the team's new icon or logo together with its audit entry.
+func (h *TeamsAPI) updateIcon(r *http.Request, before, team *db.Team) error {
+	return h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Teams.Update(r.Context(), team); err != nil {
+			return err
+		}
+		return h.audit(r, tx, team.ID, auditEntry{
+			Action:     auditTeamIconChanged,
+			TargetType: "team",
+			TargetID:   team.ID,
+			Before:     h.teamIconState(before),
+			After:      h.teamIconState(team),
+		})
+	})
+}
+
+// deleteLogo removes the files of a logo that is no longer used. A failure only leaves
+// unreferenced files behind, so it is logged rather than reported.
+func (h *TeamsAPI) deleteLogo(ctx context.Context, key string) {
+	if key == "" {
+		return
+	}
+	if err := h.Images.Delete(ctx, images.TeamLogo, key); err != nil {
+		h.logger.Warn("failed to delete old team logo", "error", err, "key", key)
+	}
+}
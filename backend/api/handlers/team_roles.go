@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/rbac"
+	"github.com/Neat-Snap/blueprint-backend/settings"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+var (
+	errInvalidRole     = errors.New("invalid role")
+	errRoleTooPowerful = errors.New("cannot grant a role with more permissions than your own")
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,31}$`)
+
+func roleErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, errInvalidRole):
+		return http.StatusBadRequest
+	case errors.Is(err, errRoleTooPowerful):
+		return http.StatusForbidden
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// checkAssignableRole verifies that role exists in the team and that the caller may hand it out.
+// The owner role is never assignable; it only changes hands through an ownership transfer.
+func (h *TeamsAPI) checkAssignableRole(ctx context.Context, access *middleware.TeamAccess, role string) error {
+	if role == rbac.RoleOwner {
+		return errInvalidRole
+	}
+	var perms rbac.Set
+	if builtin, ok := rbac.BuiltinRoles[role]; ok {
+		perms = rbac.NewSet(builtin...)
+	} else {
+		custom, err := h.Connection.TeamRoles.ByName(ctx, access.Team.ID, role)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errInvalidRole
+		}
+		if err != nil {
+			return err
+		}
+		perms = rbac.FromStrings(custom.Permissions)
+	}
+	if !access.IsOwner && !access.Permissions.Covers(perms) {
+		return errRoleTooPowerful
+	}
+	return nil
+}
+
+func parsePermissions(raw []string) (rbac.Set, error) {
+	set := make(rbac.Set, len(raw))
+	for _, p := range raw {
+		if !rbac.IsValid(rbac.Permission(p)) {
+			return nil, fmt.Errorf("unknown permission: %s", p)
+		}
+		set[rbac.Permission(p)] = true
+	}
+	return set, nil
+}
+
+type teamRoleResponse struct {
+	ID          uint     `json:"id,omitempty"`
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	Builtin     bool     `json:"builtin"`
+}
+
+// GET /teams/{id}/roles
+func (h *TeamsAPI) ListTeamRolesEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+
+	custom, err := h.Connection.TeamRoles.ListByTeam(r.Context(), team.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list roles", http.StatusInternalServerError)
+		return
+	}
+
+	roles := make([]teamRoleResponse, 0, len(rbac.BuiltinRoles)+len(custom))
+	for _, name := range []string{rbac.RoleOwner, rbac.RoleAdmin, rbac.RoleRegular} {
+		roles = append(roles, teamRoleResponse{
+			Name:        name,
+			Permissions: rbac.NewSet(rbac.BuiltinRoles[name]...).List(),
+			Builtin:     true,
+		})
+	}
+	for _, role := range custom {
+		roles = append(roles, teamRoleResponse{
+			ID:          role.ID,
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: rbac.FromStrings(role.Permissions).List(),
+		})
+	}
+
+	available := make([]string, 0, len(rbac.All))
+	for _, p := range rbac.All {
+		available = append(available, string(p))
+	}
+
+	utils.WriteSuccess(w, h.logger, map[string]any{
+		"roles":       roles,
+		"permissions": available,
+	}, http.StatusOK)
+}
+
+// POST /teams/{id}/roles
+func (h *TeamsAPI) CreateTeamRoleEndpoint(w http.ResponseWriter, r *http.Request) {
+	access := middleware.TeamAccessFromContext(r.Context())
+	team := access.Team
+
+	var req struct {
+		Name        string   `json:"name"`
+		Description string   `json:"description"`
+		Permissions []string `json:"permissions"`
+	}
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	name := strings.ToLower(strings.TrimSpace(req.Name))
+	if !roleNamePattern.MatchString(name) {
+		utils.WriteError(w, h.logger, nil, "role name must be 2-32 lowercase letters, digits, dashes or underscores", http.StatusBadRequest)
+		return
+	}
+	if rbac.IsBuiltin(name) {
+		utils.WriteError(w, h.logger, nil, "role name is reserved", http.StatusBadRequest)
+		return
+	}
+	perms, err := parsePermissions(req.Permissions)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !access.IsOwner && !access.Permissions.Covers(perms) {
+		utils.WriteError(w, h.logger, errRoleTooPowerful, errRoleTooPowerful.Error(), http.StatusForbidden)
+		return
+	}
+
+	if _, err := h.Connection.TeamRoles.ByName(r.Context(), team.ID, name); err == nil {
+		utils.WriteError(w, h.logger, nil, "a role with this name already exists", http.StatusConflict)
+		return
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, h.logger, err, "failed to check existing roles", http.StatusInternalServerError)
+		return
+	}
+
+	role := &db.TeamRole{
+		TeamID:      team.ID,
+		Name:        name,
+		Description: strings.TrimSpace(req.Description),
+		Permissions: perms.List(),
+	}
+	if err := h.Connection.TeamRoles.Create(r.Context(), role); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to create role", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccess(w, h.logger, teamRoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+	}, http.StatusOK)
+}
+
+func (h *TeamsAPI) loadTeamRole(w http.ResponseWriter, r *http.Request, teamID uint) (*db.TeamRole, bool) {
+	roleID, err := strconv.Atoi(chi.URLParam(r, "role_id"))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "invalid role ID", http.StatusBadRequest)
+		return nil, false
+	}
+	role, err := h.Connection.TeamRoles.ByID(r.Context(), teamID, uint(roleID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, h.logger, err, "role not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get role", http.StatusInternalServerError)
+		return nil, false
+	}
+	return role, true
+}
+
+// PATCH /teams/{id}/roles/{role_id}
+func (h *TeamsAPI) UpdateTeamRoleEndpoint(w http.ResponseWriter, r *http.Request) {
+	access := middleware.TeamAccessFromContext(r.Context())
+
+	role, ok := h.loadTeamRole(w, r, access.Team.ID)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Description *string   `json:"description"`
+		Permissions *[]string `json:"permissions"`
+	}
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+
+	if req.Description != nil {
+		role.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Permissions != nil {
+		perms, err := parsePermissions(*req.Permissions)
+		if err != nil {
+			utils.WriteError(w, h.logger, err, err.Error(), http.StatusBadRequest)
+			return
+		}
+		// both the old and the new grant must be within the caller's reach
+		if !access.IsOwner && (!access.Permissions.Covers(perms) || !access.Permissions.Covers(rbac.FromStrings(role.Permissions))) {
+			utils.WriteError(w, h.logger, errRoleTooPowerful, errRoleTooPowerful.Error(), http.StatusForbidden)
+			return
+		}
+		role.Permissions = perms.List()
+	}
+
+	if err := h.Connection.TeamRoles.Update(r.Context(), role); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to update role", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccess(w, h.logger, teamRoleResponse{
+		ID:          role.ID,
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+	}, http.StatusOK)
+}
+
+// DELETE /teams/{id}/roles/{role_id}
+func (h *TeamsAPI) DeleteTeamRoleEndpoint(w http.ResponseWriter, r *http.Request) {
+	access := middleware.TeamAccessFromContext(r.Context())
+
+	role, ok := h.loadTeamRole(w, r, access.Team.ID)
+	if !ok {
+		return
+	}
+	if !access.IsOwner && !access.Permissions.Covers(rbac.FromStrings(role.Permissions)) {
+		utils.WriteError(w, h.logger, errRoleTooPowerful, errRoleTooPowerful.Error(), http.StatusForbidden)
+		return
+	}
+
+	assigned, err := h.Connection.TeamRoles.CountMembers(r.Context(), access.Team.ID, role.Name)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to check role usage", http.StatusInternalServerError)
+		return
+	}
+	if assigned > 0 {
+		utils.WriteError(w, h.logger, nil, fmt.Sprintf("role is assigned to %d member(s); reassign them first", assigned), http.StatusConflict)
+		return
+	}
+	inviteRole, err := settings.DefaultInviteRole.Get(r.Context(), h.Connection, access.Team.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to check role usage", http.StatusInternalServerError)
+		return
+	}
+	if inviteRole == role.Name {
+		utils.WriteError(w, h.logger, nil, "role is the default for invitations; change the team's default_invite_role first", http.StatusConflict)
+		return
+	}
+
+	if err := h.Connection.TeamRoles.Delete(r.Context(), role); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to delete role", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "deleted"}, http.StatusOK)
+}
@@ -0,0 +1,261 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/notify"
+	"github.com/Neat-Snap/blueprint-backend/rbac"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"gorm.io/gorm"
+)
+
+const transferTTLDays = 7
+
+var errTransferStale = errors.New("transfer is no longer valid")
+
+// notify sends a notification on the user's chosen channels; failures are logged and
+// otherwise ignored. Outside a transaction only: inside one a failed insert aborts it, so
+// send with h.Notifier and return the error there.
+func (h *TeamsAPI) notify(ctx context.Context, conn *db.Connection, userID uint, payload notify.Payload) {
+	if err := h.Notifier.Send(ctx, conn, notify.Message{UserID: userID, Data: payload}); err != nil {
+		h.logger.Error("failed to send notification", "error", err, "type", payload.NotificationType(), "user_id", userID)
+	}
+}
+
+func transferResponse(t *db.TeamOwnershipTransfer) map[string]any {
+	return map[string]any{
+		"id":           t.ID,
+		"team_id":      t.TeamID,
+		"from_user_id": t.FromUserID,
+		"to_user_id":   t.ToUserID,
+		"status":       t.Status,
+		"created_at":   t.CreatedAt,
+		"expires_at":   t.ExpiresAt,
+	}
+}
+
+// POST /teams/{id}/transfer
+func (h *TeamsAPI) StartTransferEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	access := middleware.TeamAccessFromContext(r.Context())
+	team := access.Team
+
+	if !access.IsOwner {
+		utils.WriteError(w, h.logger, nil, "only the team owner can transfer ownership", http.StatusForbidden)
+		return
+	}
+
+	var req struct {
+		UserID uint `json:"user_id"`
+	}
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	if req.UserID == 0 || req.UserID == userObj.ID {
+		utils.WriteError(w, h.logger, nil, "choose another team member as the new owner", http.StatusBadRequest)
+		return
+	}
+	if _, err := h.Connection.Teams.GetUserRole(r.Context(), team.ID, req.UserID); errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, h.logger, err, "the new owner must be a member of the team", http.StatusBadRequest)
+		return
+	} else if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to check team membership", http.StatusInternalServerError)
+		return
+	}
+
+	transfer := &db.TeamOwnershipTransfer{
+		TeamID:     team.ID,
+		FromUserID: userObj.ID,
+		ToUserID:   req.UserID,
+		Status:     "pending",
+		ExpiresAt:  time.Now().Add(transferTTLDays * 24 * time.Hour),
+	}
+	// a new offer replaces any earlier one that is still open
+	err := h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Transfers.CancelPendingForTeam(r.Context(), team.ID); err != nil {
+			return err
+		}
+		if err := tx.Transfers.Create(r.Context(), transfer); err != nil {
+			return err
+		}
+		return h.audit(r, tx, team.ID, auditEntry{
+			Action:     auditOwnershipOffered,
+			TargetType: "user",
+			TargetID:   req.UserID,
+			After:      map[string]any{"transfer_id": transfer.ID, "expires_at": transfer.ExpiresAt},
+		})
+	})
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to start ownership transfer", http.StatusInternalServerError)
+		return
+	}
+
+	h.notify(r.Context(), h.Connection, req.UserID, notify.TransferRequest{
+		TransferID: transfer.ID,
+		TeamID:     team.ID,
+		TeamName:   team.Name,
+		From:       inviterName(userObj),
+		ExpiresAt:  transfer.ExpiresAt,
+	})
+
+	utils.WriteSuccess(w, h.logger, transferResponse(transfer), http.StatusOK)
+}
+
+// GET /teams/{id}/transfer
+func (h *TeamsAPI) GetTransferEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+
+	transfer, err := h.Connection.Transfers.PendingForTeam(r.Context(), team.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, h.logger, err, "no pending transfer", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get transfer", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, transferResponse(transfer), http.StatusOK)
+}
+
+// DELETE /teams/{id}/transfer
+func (h *TeamsAPI) CancelTransferEndpoint(w http.ResponseWriter, r *http.Request) {
+	access := middleware.TeamAccessFromContext(r.Context())
+	if !access.IsOwner {
+		utils.WriteError(w, h.logger, nil, "only the team owner can cancel a transfer", http.StatusForbidden)
+		return
+	}
+	if err := h.Connection.Transfers.CancelPendingForTeam(r.Context(), access.Team.ID); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to cancel transfer", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "cancelled"}, http.StatusOK)
+}
+
+// pendingTransferFor loads the open transfer addressed to the caller.
+func (h *TeamsAPI) pendingTransferFor(w http.ResponseWriter, r *http.Request, teamID, userID uint) (*db.TeamOwnershipTransfer, bool) {
+	transfer, err := h.Connection.Transfers.PendingForTeam(r.Context(), teamID)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && transfer.ToUserID != userID) {
+		utils.WriteError(w, h.logger, err, "no pending transfer for you", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get transfer", http.StatusInternalServerError)
+		return nil, false
+	}
+	return transfer, true
+}
+
+// POST /teams/{id}/transfer/accept
+func (h *TeamsAPI) AcceptTransferEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+
+	transfer, ok := h.pendingTransferFor(w, r, team.ID, userObj.ID)
+	if !ok {
+		return
+	}
+
+	err := h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		// the owner may have changed since the offer was made, or since the team was
+		// loaded for this request; the lock keeps it from changing until this commits
+		current, err := tx.Teams.LockByID(r.Context(), team.ID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errTransferStale
+		}
+		if err != nil {
+			return err
+		}
+		if current.OwnerID != transfer.FromUserID {
+			return errTransferStale
+		}
+		resolved, err := tx.Transfers.Resolve(r.Context(), transfer.ID, "accepted")
+		if err != nil {
+			return err
+		}
+		if !resolved {
+			return errTransferStale
+		}
+		if err := tx.Teams.ReassignOwner(r.Context(), team.ID, userObj.ID); err != nil {
+			return err
+		}
+		if err := tx.Teams.AddMember(r.Context(), team.ID, userObj.ID, rbac.RoleOwner); err != nil {
+			return err
+		}
+		if err := tx.Teams.AddMember(r.Context(), team.ID, transfer.FromUserID, rbac.RoleAdmin); err != nil {
+			return err
+		}
+
+		payload := notify.OwnershipTransferred{
+			TeamID:     team.ID,
+			TeamName:   team.Name,
+			FromUserID: transfer.FromUserID,
+			ToUserID:   userObj.ID,
+			NewOwner:   inviterName(userObj),
+		}
+		if err := h.audit(r, tx, team.ID, auditEntry{
+			Action:     auditOwnershipAccepted,
+			TargetType: "user",
+			TargetID:   userObj.ID,
+			Before:     map[string]any{"owner_id": transfer.FromUserID},
+			After:      map[string]any{"owner_id": userObj.ID},
+		}); err != nil {
+			return err
+		}
+		// a failed insert aborts the transaction, so the notifications are part of the
+		// change rather than best effort
+		for _, id := range []uint{transfer.FromUserID, userObj.ID} {
+			if err := h.Notifier.Send(r.Context(), tx, notify.Message{UserID: id, Data: payload}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if errors.Is(err, errTransferStale) {
+		utils.WriteError(w, h.logger, err, "transfer is no longer valid", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to accept transfer", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccess(w, h.logger, map[string]any{
+		"status":   "accepted",
+		"team_id":  team.ID,
+		"owner_id": userObj.ID,
+	}, http.StatusOK)
+}
+
+// POST /teams/{id}/transfer/decline
+func (h *TeamsAPI) DeclineTransferEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+
+	transfer, ok := h.pendingTransferFor(w, r, team.ID, userObj.ID)
+	if !ok {
+		return
+	}
+	resolved, err := h.Connection.Transfers.Resolve(r.Context(), transfer.ID, "declined")
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to decline transfer", http.StatusInternalServerError)
+		return
+	}
+	if !resolved {
+		utils.WriteError(w, h.logger, errTransferStale, "transfer is no longer valid", http.StatusConflict)
+		return
+	}
+
+	h.notify(r.Context(), h.Connection, transfer.FromUserID, notify.TransferDeclined{
+		TeamID:   team.ID,
+		TeamName: team.Name,
+		By:       inviterName(userObj),
+	})
+
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "declined"}, http.StatusOK)
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+func (h *TeamsAPI) retention() time.Duration {
+	return time.Duration(h.Config.TEAM_RETENTION_DAYS) * 24 * time.Hour
+}
+
+// GET /teams/trash
+func (h *TeamsAPI) ListTrashEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	teams, err := h.Connection.Teams.ListDeletedOwnedBy(r.Context(), userObj.ID, time.Now().Add(-h.retention()))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list deleted teams", http.StatusInternalServerError)
+		return
+	}
+
+	type item struct {
+		ID        uint      `json:"id"`
+		Name      string    `json:"name"`
+		Icon      string    `json:"icon"`
+		LogoURL   string    `json:"logo_url"`
+		DeletedAt time.Time `json:"deleted_at"`
+		PurgeAt   time.Time `json:"purge_at"`
+	}
+	resp := make([]item, 0, len(teams))
+	for _, t := range teams {
+		resp = append(resp, item{
+			ID:        t.ID,
+			Name:      t.Name,
+			Icon:      t.Icon,
+			LogoURL:   teamLogoURL(h.Images, &t),
+			DeletedAt: t.DeletedAt.Time,
+			PurgeAt:   t.DeletedAt.Time.Add(h.retention()),
+		})
+	}
+	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
+}
+
+// POST /teams/{id}/restore
+func (h *TeamsAPI) RestoreTeamEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+
+	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "invalid team ID", http.StatusBadRequest)
+		return
+	}
+
+	team, err := h.Connection.Teams.DeletedByID(r.Context(), uint(teamID))
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && team.OwnerID != userObj.ID) {
+		utils.WriteError(w, h.logger, err, "deleted team not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get team", http.StatusInternalServerError)
+		return
+	}
+	if time.Since(team.DeletedAt.Time) > h.retention() {
+		utils.WriteError(w, h.logger, nil, "the retention window for this team has passed", http.StatusGone)
+		return
+	}
+
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Teams.Restore(r.Context(), team.ID); err != nil {
+			return err
+		}
+		return h.audit(r, tx, team.ID, auditEntry{
+			Action:      auditTeamRestored,
+			TargetType:  "team",
+			TargetID:    team.ID,
+			TargetLabel: team.Name,
+		})
+	})
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to restore team", http.StatusInternalServerError)
+		return
+	}
+
+	utils.WriteSuccess(w, h.logger, map[string]any{
+		"status": "restored",
+		"id":     team.ID,
+		"name":   team.Name,
+	}, http.StatusOK)
+}
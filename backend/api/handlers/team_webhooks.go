@@ -0,0 +1,369 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/Neat-Snap/blueprint-backend/webhooks"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+const maxWebhooksPerTeam = 10
+
+// webhookEvents maps audit actions to the webhook event they publish, if any.
+var webhookEvents = map[string]string{
+	auditMemberAdded:        webhooks.EventMemberAdded,
+	auditInvitationAccepted: webhooks.EventMemberAdded,
+	auditMemberRemoved:      webhooks.EventMemberRemoved,
+	auditMemberLeft:         webhooks.EventMemberRemoved,
+	auditMemberRoleChanged:  webhooks.EventMemberRoleChanged,
+	auditInvitationCreated:  webhooks.EventInvitationCreated,
+	auditInvitationRevoked:  webhooks.EventInvitationRevoked,
+	auditTeamRenamed:        webhooks.EventTeamUpdated,
+	auditTeamIconChanged:    webhooks.EventTeamUpdated,
+	auditOwnershipAccepted:  webhooks.EventTeamUpdated,
+	auditTeamDeleted:        webhooks.EventTeamDeleted,
+}
+
+type webhookEndpointResponse struct {
+	ID          uint      `json:"id"`
+	URL         string    `json:"url"`
+	Description string    `json:"description"`
+	Events      []string  `json:"events"`
+	Active      bool      `json:"active"`
+	CreatedAt   time.Time `json:"created_at"`
+	// only set when the endpoint is created
+	Secret string `json:"secret,omitempty"`
+}
+
+func toWebhookEndpointResponse(e *db.WebhookEndpoint) webhookEndpointResponse {
+	return webhookEndpointResponse{
+		ID:          e.ID,
+		URL:         e.URL,
+		Description: e.Description,
+		Events:      e.Events,
+		Active:      e.Active,
+		CreatedAt:   e.CreatedAt,
+	}
+}
+
+// webhookAuditState is what the audit log keeps of an endpoint; never the secret.
+func webhookAuditState(e *db.WebhookEndpoint) map[string]any {
+	return map[string]any{"url": e.URL, "description": e.Description, "events": e.Events, "active": e.Active}
+}
+
+func validateWebhookEvents(events []string) error {
+	if len(events) == 0 {
+		return errors.New("subscribe to at least one event")
+	}
+	for _, e := range events {
+		if !webhooks.IsEventType(e) {
+			return errors.New("unknown event type: " + e)
+		}
+	}
+	return nil
+}
+
+// GET /teams/{id}/webhooks
+func (h *TeamsAPI) ListWebhooksEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+
+	list, err := h.Connection.Webhooks.ListEndpoints(r.Context(), team.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list webhooks", http.StatusInternalServerError)
+		return
+	}
+	resp := make([]webhookEndpointResponse, 0, len(list))
+	for i := range list {
+		resp = append(resp, toWebhookEndpointResponse(&list[i]))
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{
+		"webhooks":    resp,
+		"event_types": webhooks.EventTypes,
+	}, http.StatusOK)
+}
+
+// POST /teams/{id}/webhooks
+func (h *TeamsAPI) CreateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+
+	var req struct {
+		URL         string   `json:"url"`
+		Description string   `json:"description"`
+		Events      []string `json:"events"`
+	}
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	u, err := webhooks.ValidateURL(r.Context(), req.URL, h.Webhooks.AllowPrivate)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := validateWebhookEvents(req.Events); err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	existing, err := h.Connection.Webhooks.ListEndpoints(r.Context(), team.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list webhooks", http.StatusInternalServerError)
+		return
+	}
+	if len(existing) >= maxWebhooksPerTeam {
+		utils.WriteError(w, h.logger, nil, "webhook limit reached for this team", http.StatusConflict)
+		return
+	}
+
+	endpoint := &db.WebhookEndpoint{
+		TeamID:      team.ID,
+		CreatedByID: userObj.ID,
+		URL:         u.String(),
+		Description: strings.TrimSpace(req.Description),
+		Events:      req.Events,
+		Secret:      webhooks.NewSecret(),
+		Active:      true,
+	}
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Webhooks.CreateEndpoint(r.Context(), endpoint); err != nil {
+			return err
+		}
+		return h.audit(r, tx, team.ID, auditEntry{
+			Action:      auditWebhookCreated,
+			TargetType:  "webhook",
+			TargetID:    endpoint.ID,
+			TargetLabel: endpoint.URL,
+			After:       webhookAuditState(endpoint),
+		})
+	})
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	resp := toWebhookEndpointResponse(endpoint)
+	resp.Secret = endpoint.Secret
+	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
+}
+
+func (h *TeamsAPI) loadWebhook(w http.ResponseWriter, r *http.Request, teamID uint) (*db.WebhookEndpoint, bool) {
+	id, err := strconv.Atoi(chi.URLParam(r, "webhook_id"))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "invalid webhook ID", http.StatusBadRequest)
+		return nil, false
+	}
+	endpoint, err := h.Connection.Webhooks.EndpointByID(r.Context(), teamID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, h.logger, err, "webhook not found", http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get webhook", http.StatusInternalServerError)
+		return nil, false
+	}
+	return endpoint, true
+}
+
+// PATCH /teams/{id}/webhooks/{webhook_id}
+func (h *TeamsAPI) UpdateWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+	endpoint, ok := h.loadWebhook(w, r, team.ID)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		URL         *string   `json:"url"`
+		Description *string   `json:"description"`
+		Events      *[]string `json:"events"`
+		Active      *bool     `json:"active"`
+	}
+	if err := utils.ReadJSON(r.Body, w, h.logger, &req); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
+		return
+	}
+	before := webhookAuditState(endpoint)
+	if req.URL != nil {
+		u, err := webhooks.ValidateURL(r.Context(), *req.URL, h.Webhooks.AllowPrivate)
+		if err != nil {
+			utils.WriteError(w, h.logger, err, err.Error(), http.StatusBadRequest)
+			return
+		}
+		endpoint.URL = u.String()
+	}
+	if req.Events != nil {
+		if err := validateWebhookEvents(*req.Events); err != nil {
+			utils.WriteError(w, h.logger, err, err.Error(), http.StatusBadRequest)
+			return
+		}
+		endpoint.Events = *req.Events
+	}
+	if req.Description != nil {
+		endpoint.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Active != nil {
+		endpoint.Active = *req.Active
+	}
+
+	err := h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Webhooks.UpdateEndpoint(r.Context(), endpoint); err != nil {
+			return err
+		}
+		return h.audit(r, tx, team.ID, auditEntry{
+			Action:      auditWebhookUpdated,
+			TargetType:  "webhook",
+			TargetID:    endpoint.ID,
+			TargetLabel: endpoint.URL,
+			Before:      before,
+			After:       webhookAuditState(endpoint),
+		})
+	})
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to update webhook", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, toWebhookEndpointResponse(endpoint), http.StatusOK)
+}
+
+// DELETE /teams/{id}/webhooks/{webhook_id}
+func (h *TeamsAPI) DeleteWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+	endpoint, ok := h.loadWebhook(w, r, team.ID)
+	if !ok {
+		return
+	}
+	err := h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Webhooks.DeleteEndpoint(r.Context(), endpoint); err != nil {
+			return err
+		}
+		return h.audit(r, tx, team.ID, auditEntry{
+			Action:      auditWebhookDeleted,
+			TargetType:  "webhook",
+			TargetID:    endpoint.ID,
+			TargetLabel: endpoint.URL,
+			Before:      webhookAuditState(endpoint),
+		})
+	})
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "deleted"}, http.StatusOK)
+}
+
+// POST /teams/{id}/webhooks/{webhook_id}/ping
+func (h *TeamsAPI) PingWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+	endpoint, ok := h.loadWebhook(w, r, team.ID)
+	if !ok {
+		return
+	}
+	del, err := h.Webhooks.Ping(r.Context(), endpoint)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to queue ping", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "queued", "delivery_id": del.ID}, http.StatusOK)
+}
+
+type webhookDeliveryResponse struct {
+	ID             uint       `json:"id"`
+	EventID        string     `json:"event_id"`
+	Event          string     `json:"event"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at"`
+	ResponseStatus int        `json:"response_status"`
+	ResponseBody   string     `json:"response_body"`
+	Error          string     `json:"error"`
+	DurationMs     int64      `json:"duration_ms"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// GET /teams/{id}/webhooks/{webhook_id}/deliveries
+func (h *TeamsAPI) ListWebhookDeliveriesEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+	endpoint, ok := h.loadWebhook(w, r, team.ID)
+	if !ok {
+		return
+	}
+
+	var cursor uint
+	if v := r.URL.Query().Get("cursor"); v != "" {
+		n, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			utils.WriteError(w, h.logger, err, "invalid cursor", http.StatusBadRequest)
+			return
+		}
+		cursor = uint(n)
+	}
+	limit, _ := utils.ParsePagination(r, 50, 200)
+
+	list, err := h.Connection.Webhooks.ListDeliveries(r.Context(), endpoint.ID, cursor, limit)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to list deliveries", http.StatusInternalServerError)
+		return
+	}
+	items := make([]webhookDeliveryResponse, 0, len(list))
+	for _, d := range list {
+		items = append(items, webhookDeliveryResponse{
+			ID:             d.ID,
+			EventID:        d.EventID,
+			Event:          d.Event,
+			Status:         d.Status,
+			Attempts:       d.Attempts,
+			NextAttemptAt:  d.NextAttemptAt,
+			LastAttemptAt:  d.LastAttemptAt,
+			ResponseStatus: d.ResponseStatus,
+			ResponseBody:   d.ResponseBody,
+			Error:          d.Error,
+			DurationMs:     d.DurationMs,
+			CreatedAt:      d.CreatedAt,
+		})
+	}
+	var nextCursor *uint
+	if len(list) == limit {
+		nextCursor = &list[len(list)-1].ID
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"items": items, "next_cursor": nextCursor}, http.StatusOK)
+}
+
+// POST /teams/{id}/webhooks/{webhook_id}/deliveries/{delivery_id}/redeliver
+func (h *TeamsAPI) RedeliverWebhookEndpoint(w http.ResponseWriter, r *http.Request) {
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+	endpoint, ok := h.loadWebhook(w, r, team.ID)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.Atoi(chi.URLParam(r, "delivery_id"))
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "invalid delivery ID", http.StatusBadRequest)
+		return
+	}
+	prev, err := h.Connection.Webhooks.DeliveryByID(r.Context(), endpoint.ID, uint(deliveryID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, h.logger, err, "delivery not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get delivery", http.StatusInternalServerError)
+		return
+	}
+
+	del, err := h.Webhooks.Redeliver(r.Context(), prev)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to queue redelivery", http.StatusInternalServerError)
+		return
+	}
+	utils.WriteSuccess(w, h.logger, map[string]any{"status": "queued", "delivery_id": del.ID}, http.StatusOK)
+}
@@ -1,59 +1,76 @@
 package handlers
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
-	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/Neat-Snap/blueprint-backend/config"
 	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/events"
+	"github.com/Neat-Snap/blueprint-backend/images"
 	"github.com/Neat-Snap/blueprint-backend/logger"
 	"github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/notify"
+	"github.com/Neat-Snap/blueprint-backend/rbac"
+	"github.com/Neat-Snap/blueprint-backend/settings"
 	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/Neat-Snap/blueprint-backend/utils/email"
+	"github.com/Neat-Snap/blueprint-backend/webhooks"
 	"github.com/go-chi/chi/v5"
 	"gorm.io/gorm"
 )
 
 type TeamsAPI struct {
-	logger     logger.MultiLogger
-	Connection *db.Connection
+	logger      logger.MultiLogger
+	Connection  *db.Connection
+	EmailClient *email.EmailClient
+	Config      config.Config
+	Webhooks    *webhooks.Dispatcher
+	Notifier    *notify.Dispatcher
+	Images      *images.Library
 }
 
 // PATCH /teams/{id}/members/{user_id}/role
 func (h *TeamsAPI) UpdateMemberRoleEndpoint(w http.ResponseWriter, r *http.Request) {
-	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
-	userID := userObj.ID
+	access := middleware.TeamAccessFromContext(r.Context())
+	team := access.Team
 
-	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	memberID, err := strconv.Atoi(chi.URLParam(r, "user_id"))
 	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid team ID", http.StatusBadRequest)
+		utils.WriteError(w, h.logger, err, "invalid member ID", http.StatusBadRequest)
 		return
 	}
 
-	team, err := h.Connection.Teams.ByID(r.Context(), uint(teamID))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to get team", http.StatusInternalServerError)
+	if uint(memberID) == team.OwnerID {
+		utils.WriteError(w, h.logger, nil, "cannot change role of the team owner", http.StatusBadRequest)
 		return
 	}
 
-	if team.OwnerID != userID {
-		utils.WriteError(w, h.logger, nil, "user does not have access to change member roles", http.StatusForbidden)
+	currentRole, err := h.Connection.Teams.GetUserRole(r.Context(), team.ID, uint(memberID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, h.logger, err, "member not found", http.StatusNotFound)
 		return
 	}
-
-	memberID, err := strconv.Atoi(chi.URLParam(r, "user_id"))
 	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid member ID", http.StatusBadRequest)
+		utils.WriteError(w, h.logger, err, "failed to get member role", http.StatusInternalServerError)
 		return
 	}
-
-	if uint(memberID) == team.OwnerID {
-		utils.WriteError(w, h.logger, nil, "cannot change role of the team owner", http.StatusBadRequest)
+	currentPerms, err := middleware.RolePermissions(r.Context(), h.Connection, team.ID, currentRole)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get member role", http.StatusInternalServerError)
+		return
+	}
+	if !access.IsOwner && !access.Permissions.Covers(currentPerms) {
+		utils.WriteError(w, h.logger, nil, "cannot change the role of a member with more permissions than you", http.StatusForbidden)
 		return
 	}
 
@@ -64,39 +81,45 @@ func (h *TeamsAPI) UpdateMemberRoleEndpoint(w http.ResponseWriter, r *http.Reque
 		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
 		return
 	}
-	if req.Role != "regular" && req.Role != "admin" {
-		utils.WriteError(w, h.logger, fmt.Errorf("invalid role: %s", req.Role), "invalid role", http.StatusBadRequest)
+	if err := h.checkAssignableRole(r.Context(), access, req.Role); err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), roleErrorStatus(err))
 		return
 	}
 
-	if err := h.Connection.Teams.AddMember(r.Context(), uint(teamID), uint(memberID), req.Role); err != nil {
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Teams.AddMember(r.Context(), team.ID, uint(memberID), req.Role); err != nil {
+			return err
+		}
+		return h.audit(r, tx, team.ID, auditEntry{
+			Action:     auditMemberRoleChanged,
+			TargetType: "user",
+			TargetID:   uint(memberID),
+			Before:     map[string]any{"role": currentRole},
+			After:      map[string]any{"role": req.Role},
+		})
+	})
+	if err != nil {
 		utils.WriteError(w, h.logger, err, "failed to update member role", http.StatusInternalServerError)
 		return
 	}
+	if req.Role != currentRole {
+		userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+		h.notify(r.Context(), h.Connection, uint(memberID), notify.RoleChanged{
+			TeamID:   team.ID,
+			TeamName: team.Name,
+			Role:     req.Role,
+			FromRole: currentRole,
+			By:       inviterName(userObj),
+		})
+	}
 
 	utils.WriteSuccess(w, h.logger, map[string]any{"status": "updated"}, http.StatusOK)
 }
 
 // GET /teams/{id}/invitations
 func (h *TeamsAPI) ListInvitationsEndpoint(w http.ResponseWriter, r *http.Request) {
-	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
-	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid team ID", http.StatusBadRequest)
-		return
-	}
-	team, err := h.Connection.Teams.ByID(r.Context(), uint(teamID))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to get team", http.StatusInternalServerError)
-		return
-	}
-	rolesMap, _ := h.Connection.Teams.RolesForTeam(r.Context(), uint(teamID))
-	role := rolesMap[userObj.ID]
-	if team.OwnerID != userObj.ID && role != "admin" {
-		utils.WriteError(w, h.logger, nil, "forbidden", http.StatusForbidden)
-		return
-	}
-	list, err := h.Connection.Invitations.ListByTeam(r.Context(), uint(teamID))
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+	list, err := h.Connection.Invitations.ListByTeam(r.Context(), team.ID)
 	if err != nil {
 		utils.WriteError(w, h.logger, err, "failed to list invitations", http.StatusInternalServerError)
 		return
@@ -118,29 +141,31 @@ func (h *TeamsAPI) ListInvitationsEndpoint(w http.ResponseWriter, r *http.Reques
 
 // DELETE /teams/{id}/invitations/{inv_id}
 func (h *TeamsAPI) RevokeInvitationEndpoint(w http.ResponseWriter, r *http.Request) {
-	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
-	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid team ID", http.StatusBadRequest)
-		return
-	}
-	team, err := h.Connection.Teams.ByID(r.Context(), uint(teamID))
+	team := middleware.TeamAccessFromContext(r.Context()).Team
+	invID, err := strconv.Atoi(chi.URLParam(r, "inv_id"))
 	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to get team", http.StatusInternalServerError)
+		utils.WriteError(w, h.logger, err, "invalid invitation ID", http.StatusBadRequest)
 		return
 	}
-	rolesMap, _ := h.Connection.Teams.RolesForTeam(r.Context(), uint(teamID))
-	role := rolesMap[userObj.ID]
-	if team.OwnerID != userObj.ID && role != "admin" {
-		utils.WriteError(w, h.logger, nil, "forbidden", http.StatusForbidden)
+	inv, err := h.Connection.Invitations.ByID(r.Context(), uint(invID))
+	if err != nil || inv.TeamID != team.ID {
+		utils.WriteError(w, h.logger, err, "invitation not found", http.StatusNotFound)
 		return
 	}
-	invID, err := strconv.Atoi(chi.URLParam(r, "inv_id"))
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Invitations.Revoke(r.Context(), inv.ID); err != nil {
+			return err
+		}
+		return h.audit(r, tx, team.ID, auditEntry{
+			Action:      auditInvitationRevoked,
+			TargetType:  "invitation",
+			TargetID:    inv.ID,
+			TargetLabel: inv.Email,
+			Before:      map[string]any{"status": inv.Status, "role": inv.Role},
+			After:       map[string]any{"status": "revoked"},
+		})
+	})
 	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid invitation ID", http.StatusBadRequest)
-		return
-	}
-	if err := h.Connection.Invitations.Revoke(r.Context(), uint(invID)); err != nil {
 		utils.WriteError(w, h.logger, err, "failed to revoke invitation", http.StatusInternalServerError)
 		return
 	}
@@ -151,24 +176,8 @@ func (h *TeamsAPI) RevokeInvitationEndpoint(w http.ResponseWriter, r *http.Reque
 func (h *TeamsAPI) CreateInvitationEndpoint(w http.ResponseWriter, r *http.Request) {
 	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
 
-	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid team ID", http.StatusBadRequest)
-		return
-	}
-
-	team, err := h.Connection.Teams.ByID(r.Context(), uint(teamID))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to get team", http.StatusInternalServerError)
-		return
-	}
-
-	rolesMap, _ := h.Connection.Teams.RolesForTeam(r.Context(), uint(teamID))
-	role := rolesMap[userObj.ID]
-	if team.OwnerID != userObj.ID && role != "admin" {
-		utils.WriteError(w, h.logger, nil, "forbidden", http.StatusForbidden)
-		return
-	}
+	access := middleware.TeamAccessFromContext(r.Context())
+	team := access.Team
 
 	var req struct {
 		Email string `json:"email"`
@@ -178,35 +187,45 @@ func (h *TeamsAPI) CreateInvitationEndpoint(w http.ResponseWriter, r *http.Reque
 		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
 		return
 	}
-	if req.Email == "" {
-		utils.WriteError(w, h.logger, nil, "email is required", http.StatusBadRequest)
+	inviteEmail, err := utils.ValidateEmail(req.Email)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "invalid email", http.StatusBadRequest)
+		return
+	}
+	defaultRole, ttl, err := h.invitationDefaults(r.Context(), team.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to read team settings", http.StatusInternalServerError)
 		return
 	}
 	if req.Role == "" {
-		req.Role = "regular"
+		req.Role = defaultRole
 	}
-	if req.Role != "regular" && req.Role != "admin" {
-		utils.WriteError(w, h.logger, fmt.Errorf("invalid role: %s", req.Role), "invalid role", http.StatusBadRequest)
+	if err := h.checkAssignableRole(r.Context(), access, req.Role); err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), roleErrorStatus(err))
 		return
 	}
 
-	inviteEmail := strings.ToLower(req.Email)
+	// the invitee may not have an account yet; they get the email and sign up with this address
 	u, uerr := h.Connection.Users.ByEmail(r.Context(), inviteEmail)
-	if uerr != nil {
-		if errors.Is(uerr, gorm.ErrRecordNotFound) {
-			utils.WriteError(w, h.logger, nil, "user with this email does not exist", http.StatusBadRequest)
-			return
-		}
+	if uerr != nil && !errors.Is(uerr, gorm.ErrRecordNotFound) {
 		utils.WriteError(w, h.logger, uerr, "failed to look up user", http.StatusInternalServerError)
 		return
 	}
+	if uerr != nil {
+		u = nil
+	}
 
-	if rolesMap[u.ID] != "" {
-		utils.WriteError(w, h.logger, nil, "user is already a team member", http.StatusBadRequest)
-		return
+	if u != nil {
+		if _, err := h.Connection.Teams.GetUserRole(r.Context(), team.ID, u.ID); err == nil {
+			utils.WriteError(w, h.logger, nil, "user is already a team member", http.StatusBadRequest)
+			return
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			utils.WriteError(w, h.logger, err, "failed to check team membership", http.StatusInternalServerError)
+			return
+		}
 	}
 
-	if existing, lerr := h.Connection.Invitations.ListByTeam(r.Context(), uint(teamID)); lerr == nil {
+	if existing, lerr := h.Connection.Invitations.ListByTeam(r.Context(), team.ID); lerr == nil {
 		for _, e := range existing {
 			if strings.EqualFold(e.Email, inviteEmail) && e.Status == "pending" && time.Now().Before(e.ExpiresAt) {
 				utils.WriteError(w, h.logger, nil, "an active invitation already exists for this user", http.StatusBadRequest)
@@ -220,35 +239,41 @@ func (h *TeamsAPI) CreateInvitationEndpoint(w http.ResponseWriter, r *http.Reque
 
 	token := generateToken()
 	inv := &db.TeamInvitation{
-		TeamID:    uint(teamID),
+		TeamID:    team.ID,
 		Email:     inviteEmail,
 		Token:     token,
 		Role:      req.Role,
 		Status:    "pending",
-		ExpiresAt: time.Now().Add(7 * 24 * time.Hour),
+		ExpiresAt: time.Now().Add(ttl),
 	}
-	if err := h.Connection.Invitations.Create(r.Context(), inv); err != nil {
+	// the notification and the event that sends the email commit with the invitation
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Invitations.Create(r.Context(), inv); err != nil {
+			return err
+		}
+		if err := h.audit(r, tx, team.ID, auditEntry{
+			Action:      auditInvitationCreated,
+			TargetType:  "invitation",
+			TargetID:    inv.ID,
+			TargetLabel: inv.Email,
+			After:       map[string]any{"role": inv.Role, "expires_at": inv.ExpiresAt},
+		}); err != nil {
+			return err
+		}
+		return h.recordInvitation(r.Context(), tx, team, userObj, inv, u)
+	})
+	if err != nil {
 		utils.WriteError(w, h.logger, err, "failed to create team invitation", http.StatusInternalServerError)
 		return
 	}
 
-	payload := map[string]any{
-		"team_id":   team.ID,
-		"team_name": team.Name,
-		"token":     token,
-		"role":      req.Role,
-	}
-	if b, perr := json.Marshal(payload); perr == nil {
-		_ = h.Connection.Notifications.Create(r.Context(), &db.Notification{
-			UserID: u.ID,
-			Type:   "team_invite",
-			Data:   string(b),
-		})
-	}
-
+	// the email goes out asynchronously once the outbox relays the event
 	resp := struct {
-		Token string `json:"token"`
-	}{Token: token}
+		Token       string `json:"token"`
+		EmailQueued bool   `json:"email_queued"`
+		// kept for existing clients; the same as email_queued
+		EmailSent bool `json:"email_sent"`
+	}{Token: token, EmailQueued: true, EmailSent: true}
 	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
 }
 
@@ -278,10 +303,19 @@ func (h *TeamsAPI) AcceptInvitationEndpoint(w http.ResponseWriter, r *http.Reque
 	}
 
 	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
-		if err := tx.Teams.AddMember(r.Context(), inv.TeamID, userObj.ID, inv.Role); err != nil {
+		if err := joinTeam(r.Context(), tx, inv.TeamID, userObj.ID, inv.Role); err != nil && !errors.Is(err, errAlreadyMember) {
+			return err
+		}
+		if err := tx.Invitations.MarkAccepted(r.Context(), inv.ID); err != nil {
 			return err
 		}
-		return tx.Invitations.MarkAccepted(r.Context(), inv.ID)
+		return h.audit(r, tx, inv.TeamID, auditEntry{
+			Action:      auditInvitationAccepted,
+			TargetType:  "invitation",
+			TargetID:    inv.ID,
+			TargetLabel: inv.Email,
+			After:       map[string]any{"user_id": userObj.ID, "role": inv.Role},
+		})
 	})
 	if err != nil {
 		utils.WriteError(w, h.logger, err, "failed to accept invitation", http.StatusInternalServerError)
@@ -370,33 +404,7 @@ func generateToken() string {
 
 // GET /teams/{id}/overview
 func (h *TeamsAPI) GetTeamOverviewEndpoint(w http.ResponseWriter, r *http.Request) {
-	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
-	userID := userObj.ID
-
-	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid team ID", http.StatusBadRequest)
-		return
-	}
-
-	team, err := h.Connection.Teams.ByID(r.Context(), uint(teamID))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to get team", http.StatusInternalServerError)
-		return
-	}
-
-	var hasAccess bool
-	for _, user := range team.Users {
-		if user.ID == userID {
-			hasAccess = true
-			break
-		}
-	}
-	if !hasAccess {
-		h.logger.Warn("user does not have access to team", "team_id", teamID, "user_id", userID)
-		utils.WriteError(w, h.logger, nil, "team not found", http.StatusNotFound)
-		return
-	}
+	team := middleware.TeamAccessFromContext(r.Context()).Team
 
 	stats := map[string]any{
 		"members_count": len(team.Users),
@@ -404,9 +412,10 @@ func (h *TeamsAPI) GetTeamOverviewEndpoint(w http.ResponseWriter, r *http.Reques
 
 	resp := map[string]any{
 		"team": map[string]any{
-			"id":   team.ID,
-			"name": team.Name,
-			"icon": team.Icon,
+			"id":       team.ID,
+			"name":     team.Name,
+			"icon":     team.Icon,
+			"logo_url": teamLogoURL(h.Images, team),
 		},
 		"stats": stats,
 	}
@@ -414,8 +423,8 @@ func (h *TeamsAPI) GetTeamOverviewEndpoint(w http.ResponseWriter, r *http.Reques
 	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
 }
 
-func NewTeamsAPI(logger logger.MultiLogger, connection *db.Connection) *TeamsAPI {
-	return &TeamsAPI{logger: logger, Connection: connection}
+func NewTeamsAPI(logger logger.MultiLogger, connection *db.Connection, emailClient *email.EmailClient, cfg config.Config, dispatcher *webhooks.Dispatcher, notifier *notify.Dispatcher, library *images.Library) *TeamsAPI {
+	return &TeamsAPI{logger: logger, Connection: connection, EmailClient: emailClient, Config: cfg, Webhooks: dispatcher, Notifier: notifier, Images: library}
 }
 
 // GET /teams
@@ -432,6 +441,7 @@ func (h *TeamsAPI) GetTeamsEndpoint(w http.ResponseWriter, r *http.Request) {
 		ID      uint   `json:"id"`
 		Name    string `json:"name"`
 		Icon    string `json:"icon"`
+		LogoURL string `json:"logo_url"`
 		OwnerID int    `json:"owner_id"`
 	}
 
@@ -442,6 +452,7 @@ func (h *TeamsAPI) GetTeamsEndpoint(w http.ResponseWriter, r *http.Request) {
 			ID:      ws.ID,
 			Name:    ws.Name,
 			Icon:    ws.Icon,
+			LogoURL: teamLogoURL(h.Images, &ws),
 			OwnerID: int(ws.OwnerID),
 		})
 	}
@@ -481,7 +492,10 @@ func (h *TeamsAPI) CreateTeamEndpoint(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	_ = h.Connection.Teams.AddMember(r.Context(), ws.ID, userObj.ID, "admin")
+	if err := h.Connection.Teams.AddMember(r.Context(), ws.ID, userObj.ID, rbac.RoleOwner); err != nil {
+		utils.WriteError(w, h.logger, err, "failed to add team owner", http.StatusInternalServerError)
+		return
+	}
 
 	resp := struct {
 		ID   uint   `json:"id"`
@@ -492,7 +506,7 @@ func (h *TeamsAPI) CreateTeamEndpoint(w http.ResponseWriter, r *http.Request) {
 		ID:   ws.ID,
 		Name: ws.Name,
 		Icon: ws.Icon,
-		Role: "admin",
+		Role: rbac.RoleOwner,
 	}
 
 	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
@@ -500,22 +514,7 @@ func (h *TeamsAPI) CreateTeamEndpoint(w http.ResponseWriter, r *http.Request) {
 
 // GET /teams/{id}
 func (h *TeamsAPI) GetTeamEndpoint(w http.ResponseWriter, r *http.Request) {
-	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
-	userID := userObj.ID
-
-	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid team ID", http.StatusBadRequest)
-		return
-	}
-
-	team, err := h.Connection.Teams.ByID(r.Context(), uint(teamID))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to get team", http.StatusInternalServerError)
-		return
-	}
-
-	var hasAccess bool
+	team := middleware.TeamAccessFromContext(r.Context()).Team
 
 	members := []struct {
 		ID    uint   `json:"id"`
@@ -524,14 +523,17 @@ func (h *TeamsAPI) GetTeamEndpoint(w http.ResponseWriter, r *http.Request) {
 		Role  string `json:"role"`
 	}{}
 
-	rolesMap, _ := h.Connection.Teams.RolesForTeam(r.Context(), uint(teamID))
+	rolesMap, err := h.Connection.Teams.RolesForTeam(r.Context(), team.ID)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get member roles", http.StatusInternalServerError)
+		return
+	}
 	for _, user := range team.Users {
-		if user.ID == userID {
-			hasAccess = true
-		}
 		role := rolesMap[user.ID]
-		if role == "" {
-			role = "regular"
+		if user.ID == team.OwnerID {
+			role = rbac.RoleOwner
+		} else if role == "" {
+			role = rbac.RoleRegular
 		}
 		var nameVal string
 		if user.Name != nil {
@@ -554,16 +556,11 @@ func (h *TeamsAPI) GetTeamEndpoint(w http.ResponseWriter, r *http.Request) {
 		})
 	}
 
-	if !hasAccess {
-		h.logger.Warn("user does not have access to team", "team_id", teamID, "user_id", userID)
-		utils.WriteError(w, h.logger, nil, "team not found", http.StatusNotFound)
-		return
-	}
-
 	resp := struct {
 		ID      uint   `json:"id"`
 		Name    string `json:"name"`
 		Icon    string `json:"icon"`
+		LogoURL string `json:"logo_url"`
 		OwnerID int    `json:"owner_id"`
 		Members []struct {
 			ID    uint   `json:"id"`
@@ -575,6 +572,7 @@ func (h *TeamsAPI) GetTeamEndpoint(w http.ResponseWriter, r *http.Request) {
 		ID:      team.ID,
 		Name:    team.Name,
 		Icon:    team.Icon,
+		LogoURL: teamLogoURL(h.Images, team),
 		OwnerID: int(team.OwnerID),
 		Members: members,
 	}
@@ -584,39 +582,20 @@ func (h *TeamsAPI) GetTeamEndpoint(w http.ResponseWriter, r *http.Request) {
 
 // PATCH /teams/{id}
 func (h *TeamsAPI) UpdateTeamNameEndpoint(w http.ResponseWriter, r *http.Request) {
-	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
-	userID := userObj.ID
-
-	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid team ID", http.StatusBadRequest)
-		return
-	}
-
-	team, err := h.Connection.Teams.ByID(r.Context(), uint(teamID))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to get team", http.StatusInternalServerError)
-		return
-	}
-
-	rolesMap, _ := h.Connection.Teams.RolesForTeam(r.Context(), uint(teamID))
-	if team.OwnerID != userID && rolesMap[userID] != "admin" {
-		h.logger.Warn("user does not have access to team", "team_id", teamID, "user_id", userID)
-		utils.WriteError(w, h.logger, nil, "user does not have access to change the team info", http.StatusForbidden)
-		return
-	}
+	team := middleware.TeamAccessFromContext(r.Context()).Team
 
 	var req struct {
 		Name string `json:"name"`
 		Icon string `json:"icon"`
 	}
 
-	err = utils.ReadJSON(r.Body, w, h.logger, &req)
+	err := utils.ReadJSON(r.Body, w, h.logger, &req)
 	if err != nil {
 		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
 		return
 	}
 
+	before := *team
 	if req.Name != "" {
 		team.Name = req.Name
 	}
@@ -628,7 +607,32 @@ func (h *TeamsAPI) UpdateTeamNameEndpoint(w http.ResponseWriter, r *http.Request
 		team.Icon = req.Icon
 	}
 
-	err = h.Connection.Teams.Update(r.Context(), team)
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Teams.Update(r.Context(), team); err != nil {
+			return err
+		}
+		if before.Name != team.Name {
+			if err := h.audit(r, tx, team.ID, auditEntry{
+				Action:     auditTeamRenamed,
+				TargetType: "team",
+				TargetID:   team.ID,
+				Before:     map[string]any{"name": before.Name},
+				After:      map[string]any{"name": team.Name},
+			}); err != nil {
+				return err
+			}
+		}
+		if before.Icon != team.Icon {
+			return h.audit(r, tx, team.ID, auditEntry{
+				Action:     auditTeamIconChanged,
+				TargetType: "team",
+				TargetID:   team.ID,
+				Before:     map[string]any{"icon": before.Icon},
+				After:      map[string]any{"icon": team.Icon},
+			})
+		}
+		return nil
+	})
 	if err != nil {
 		utils.WriteError(w, h.logger, err, "failed to update team", http.StatusInternalServerError)
 		return
@@ -647,28 +651,19 @@ func (h *TeamsAPI) UpdateTeamNameEndpoint(w http.ResponseWriter, r *http.Request
 
 // DELETE /teams/{id}
 func (h *TeamsAPI) DeleteTeamEndpoint(w http.ResponseWriter, r *http.Request) {
-	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
-	userID := userObj.ID
+	team := middleware.TeamAccessFromContext(r.Context()).Team
 
-	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid team ID", http.StatusBadRequest)
-		return
-	}
-
-	team, err := h.Connection.Teams.ByID(r.Context(), uint(teamID))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to get team", http.StatusInternalServerError)
-		return
-	}
-
-	if team.OwnerID != userID {
-		h.logger.Warn("user does not have access to team", "team_id", teamID, "user_id", userID)
-		utils.WriteError(w, h.logger, nil, "user does not have access to delete the team", http.StatusForbidden)
-		return
-	}
-
-	err = h.Connection.Teams.Delete(r.Context(), team)
+	err := h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Teams.Delete(r.Context(), team); err != nil {
+			return err
+		}
+		return h.audit(r, tx, team.ID, auditEntry{
+			Action:      auditTeamDeleted,
+			TargetType:  "team",
+			TargetID:    team.ID,
+			TargetLabel: team.Name,
+		})
+	})
 	if err != nil {
 		utils.WriteError(w, h.logger, err, "failed to delete team", http.StatusInternalServerError)
 		return
@@ -687,45 +682,50 @@ func (h *TeamsAPI) DeleteTeamEndpoint(w http.ResponseWriter, r *http.Request) {
 
 // POST /teams/{id}/members
 func (h *TeamsAPI) AddMemberEndpoint(w http.ResponseWriter, r *http.Request) {
-	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
-	userID := userObj.ID
+	access := middleware.TeamAccessFromContext(r.Context())
+	team := access.Team
 
-	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid team ID", http.StatusBadRequest)
-		return
+	var req struct {
+		UserID uint   `json:"user_id"`
+		Role   string `json:"role"`
 	}
 
-	team, err := h.Connection.Teams.ByID(r.Context(), uint(teamID))
+	err := utils.ReadJSON(r.Body, w, h.logger, &req)
 	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to get team", http.StatusInternalServerError)
+		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
 		return
 	}
 
-	rolesMap, _ := h.Connection.Teams.RolesForTeam(r.Context(), uint(teamID))
-	if team.OwnerID != userID && rolesMap[userID] != "admin" {
-		h.logger.Warn("user does not have access to team", "team_id", teamID, "user_id", userID)
-		utils.WriteError(w, h.logger, nil, "user does not have access to add a member to the team", http.StatusForbidden)
+	if err := h.checkAssignableRole(r.Context(), access, req.Role); err != nil {
+		utils.WriteError(w, h.logger, err, err.Error(), roleErrorStatus(err))
 		return
 	}
 
-	var req struct {
-		UserID uint   `json:"user_id"`
-		Role   string `json:"role"`
-	}
-
-	err = utils.ReadJSON(r.Body, w, h.logger, &req)
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to read request body", http.StatusBadRequest)
+	member, err := h.Connection.Users.ByID(r.Context(), req.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, h.logger, err, "user not found", http.StatusNotFound)
 		return
 	}
-
-	if req.Role != "regular" && req.Role != "admin" {
-		utils.WriteError(w, h.logger, fmt.Errorf("invalid role: %s", req.Role), "invalid role", http.StatusBadRequest)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get user", http.StatusInternalServerError)
 		return
 	}
 
-	err = h.Connection.Teams.AddMember(r.Context(), uint(teamID), req.UserID, req.Role)
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Teams.AddMember(r.Context(), team.ID, req.UserID, req.Role); err != nil {
+			return err
+		}
+		if err := h.audit(r, tx, team.ID, auditEntry{
+			Action:      auditMemberAdded,
+			TargetType:  "user",
+			TargetID:    req.UserID,
+			TargetLabel: memberLabel(member),
+			After:       map[string]any{"role": req.Role},
+		}); err != nil {
+			return err
+		}
+		return events.Emit(r.Context(), tx, events.MemberAdded, events.MemberPayload{TeamID: team.ID, UserID: req.UserID, Role: req.Role, ActorID: access.UserID})
+	})
 	if err != nil {
 		utils.WriteError(w, h.logger, err, "failed to add member to team", http.StatusInternalServerError)
 		return
@@ -744,39 +744,51 @@ func (h *TeamsAPI) AddMemberEndpoint(w http.ResponseWriter, r *http.Request) {
 
 // DELETE /teams/{id}/members/{user_id}
 func (h *TeamsAPI) RemoveMemberEndpoint(w http.ResponseWriter, r *http.Request) {
-	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
-	userID := userObj.ID
+	access := middleware.TeamAccessFromContext(r.Context())
+	team := access.Team
 
-	teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
+	memberID, err := strconv.Atoi(chi.URLParam(r, "user_id"))
 	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid team ID", http.StatusBadRequest)
+		utils.WriteError(w, h.logger, err, "invalid member ID", http.StatusBadRequest)
 		return
 	}
 
-	team, err := h.Connection.Teams.ByID(r.Context(), uint(teamID))
-	if err != nil {
-		utils.WriteError(w, h.logger, err, "failed to get team", http.StatusInternalServerError)
+	if uint(memberID) == team.OwnerID {
+		utils.WriteError(w, h.logger, nil, "cannot remove team owner", http.StatusBadRequest)
 		return
 	}
-
-	rolesMap, _ := h.Connection.Teams.RolesForTeam(r.Context(), uint(teamID))
-	if team.OwnerID != userID && rolesMap[userID] != "admin" {
-		h.logger.Warn("user does not have access to team", "team_id", teamID, "user_id", userID)
-		utils.WriteError(w, h.logger, nil, "user does not have access to remove a member from the team", http.StatusForbidden)
+	memberRole, err := h.Connection.Teams.GetUserRole(r.Context(), team.ID, uint(memberID))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		utils.WriteError(w, h.logger, err, "member not found", http.StatusNotFound)
 		return
 	}
-
-	memberID, err := strconv.Atoi(chi.URLParam(r, "user_id"))
 	if err != nil {
-		utils.WriteError(w, h.logger, err, "invalid member ID", http.StatusBadRequest)
+		utils.WriteError(w, h.logger, err, "failed to get member role", http.StatusInternalServerError)
 		return
 	}
-
-	if uint(memberID) == team.OwnerID {
-		utils.WriteError(w, h.logger, nil, "cannot remove team owner", http.StatusBadRequest)
+	memberPerms, err := middleware.RolePermissions(r.Context(), h.Connection, team.ID, memberRole)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get member role", http.StatusInternalServerError)
+		return
+	}
+	if !access.IsOwner && !access.Permissions.Covers(memberPerms) {
+		utils.WriteError(w, h.logger, nil, "cannot remove a member with more permissions than you", http.StatusForbidden)
 		return
 	}
-	err = h.Connection.Teams.RemoveMember(r.Context(), uint(teamID), uint(memberID))
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Teams.RemoveMember(r.Context(), team.ID, uint(memberID)); err != nil {
+			return err
+		}
+		if err := h.audit(r, tx, team.ID, auditEntry{
+			Action:     auditMemberRemoved,
+			TargetType: "user",
+			TargetID:   uint(memberID),
+			Before:     map[string]any{"role": memberRole},
+		}); err != nil {
+			return err
+		}
+		return events.Emit(r.Context(), tx, events.MemberRemoved, events.MemberPayload{TeamID: team.ID, UserID: uint(memberID), Role: memberRole, ActorID: access.UserID})
+	})
 	if err != nil {
 		utils.WriteError(w, h.logger, err, "failed to remove member from team", http.StatusInternalServerError)
 		return
@@ -792,3 +804,286 @@ func (h *TeamsAPI) RemoveMemberEndpoint(w http.ResponseWriter, r *http.Request)
 
 	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
 }
+
+// POST /teams/{id}/leave
+func (h *TeamsAPI) LeaveTeamEndpoint(w http.ResponseWriter, r *http.Request) {
+	userObj := r.Context().Value(middleware.UserObjectContextKey).(*db.User)
+	access := middleware.TeamAccessFromContext(r.Context())
+	team := access.Team
+
+	if access.IsOwner {
+		utils.WriteError(w, h.logger, nil, "transfer ownership before leaving the team", http.StatusConflict)
+		return
+	}
+
+	// those who can remove members hear about it; resolved before the member is gone
+	recipients, err := membersWith(r.Context(), h.Connection, team, rbac.MembersRemove)
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to get member roles", http.StatusInternalServerError)
+		return
+	}
+
+	var personalTeam *db.Team
+	err = h.Connection.WithTx(r.Context(), func(tx *db.Connection) error {
+		if err := tx.Teams.RemoveMember(r.Context(), team.ID, userObj.ID); err != nil {
+			return err
+		}
+		if err := h.audit(r, tx, team.ID, auditEntry{
+			Action:      auditMemberLeft,
+			TargetType:  "user",
+			TargetID:    userObj.ID,
+			TargetLabel: memberLabel(userObj),
+			Before:      map[string]any{"role": access.Role},
+		}); err != nil {
+			return err
+		}
+		if err := events.Emit(r.Context(), tx, events.MemberRemoved, events.MemberPayload{TeamID: team.ID, UserID: userObj.ID, Role: access.Role, ActorID: userObj.ID}); err != nil {
+			return err
+		}
+		if userObj.Email != nil {
+			if err := tx.Invitations.RevokePendingForEmail(r.Context(), team.ID, *userObj.Email); err != nil {
+				return err
+			}
+		}
+		if transfer, err := tx.Transfers.PendingForTeam(r.Context(), team.ID); err == nil && transfer.ToUserID == userObj.ID {
+			if _, err := tx.Transfers.Resolve(r.Context(), transfer.ID, "cancelled"); err != nil {
+				return err
+			}
+		} else if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		remaining, err := tx.Teams.ListForUser(r.Context(), userObj.ID)
+		if err != nil {
+			return err
+		}
+		if len(remaining) == 0 {
+			personalTeam, err = createPersonalTeam(r.Context(), tx, userObj)
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to leave team", http.StatusInternalServerError)
+		return
+	}
+
+	payload := notify.MemberLeft{
+		TeamID:   team.ID,
+		TeamName: team.Name,
+		UserID:   userObj.ID,
+		Member:   inviterName(userObj),
+	}
+	for _, memberID := range recipients {
+		if memberID != userObj.ID {
+			h.notify(r.Context(), h.Connection, memberID, payload)
+		}
+	}
+
+	resp := map[string]any{"status": "left"}
+	if personalTeam != nil {
+		resp["personal_team_id"] = personalTeam.ID
+	}
+	utils.WriteSuccess(w, h.logger, resp, http.StatusOK)
+}
+
+// createPersonalTeam gives u a team of their own; every account keeps at least one team.
+func createPersonalTeam(ctx context.Context, tx *db.Connection, u *db.User) (*db.Team, error) {
+	name := "My team"
+	if u.Name != nil && *u.Name != "" {
+		name = *u.Name + "'s team"
+	}
+	ws := &db.Team{
+		Name:    name,
+		OwnerID: u.ID,
+		Owner:   *u,
+		Users:   []db.User{*u},
+	}
+	if err := tx.Teams.Create(ctx, ws); err != nil {
+		return nil, err
+	}
+	_ = tx.Teams.AddMember(ctx, ws.ID, u.ID, rbac.RoleOwner)
+	return ws, nil
+}
+
+func inviterName(u *db.User) string {
+	if u.Name != nil && *u.Name != "" {
+		return *u.Name
+	}
+	if u.Email != nil {
+		return *u.Email
+	}
+	return ""
+}
+
+// recordInvitation notifies existing users and writes the InvitationCreated event that
+// gets the invitation emailed. invitee is nil when the address has no account yet. Call
+// it inside the transaction that creates inv.
+func (h *TeamsAPI) recordInvitation(ctx context.Context, tx *db.Connection, team *db.Team, inviter *db.User, inv *db.TeamInvitation, invitee *db.User) error {
+	if invitee != nil {
+		err := h.Notifier.Send(ctx, tx, notify.Message{
+			UserID: invitee.ID,
+			Data: notify.TeamInvite{
+				TeamID:   team.ID,
+				TeamName: team.Name,
+				Token:    inv.Token,
+				Role:     inv.Role,
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return events.Emit(ctx, tx, events.InvitationCreated, events.InvitationCreatedPayload{
+		InvitationID: inv.ID,
+		TeamID:       team.ID,
+		InviterID:    inviter.ID,
+		Email:        inv.Email,
+		Role:         inv.Role,
+	})
+}
+
+// SubscribeEvents registers the team subscribers on the in-process bus.
+func (h *TeamsAPI) SubscribeEvents(bus *events.Bus) {
+	bus.Subscribe(events.InvitationCreated, "invitation-email", h.sendInvitationEmail)
+	bus.Subscribe(events.MemberAdded, "member-joined-notification", h.notifyMemberJoined)
+}
+
+// membersWith lists the owner and the members whose role grants perm, built-in or custom.
+func membersWith(ctx context.Context, conn *db.Connection, team *db.Team, perm rbac.Permission) ([]uint, error) {
+	roles, err := conn.Teams.RolesForTeam(ctx, team.ID)
+	if err != nil {
+		return nil, err
+	}
+	granted := map[string]bool{}
+	ids := []uint{team.OwnerID}
+	for userID, role := range roles {
+		if userID == team.OwnerID {
+			continue
+		}
+		has, ok := granted[role]
+		if !ok {
+			perms, err := middleware.RolePermissions(ctx, conn, team.ID, role)
+			if err != nil {
+				return nil, err
+			}
+			has = perms.Has(perm)
+			granted[role] = has
+		}
+		if has {
+			ids = append(ids, userID)
+		}
+	}
+	slices.Sort(ids)
+	return ids, nil
+}
+
+// notifyMemberJoined tells the owner and everyone who can invite members, except whoever
+// added the member.
+func (h *TeamsAPI) notifyMemberJoined(ctx context.Context, e events.Event) error {
+	p, err := events.Decode[events.MemberPayload](e)
+	if err != nil {
+		return err
+	}
+	team, err := h.Connection.Teams.ByID(ctx, p.TeamID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	member, err := h.Connection.Users.ByID(ctx, p.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	recipients, err := membersWith(ctx, h.Connection, team, rbac.MembersInvite)
+	if err != nil {
+		return err
+	}
+
+	payload := notify.MemberJoined{
+		TeamID:   team.ID,
+		TeamName: team.Name,
+		UserID:   member.ID,
+		Member:   inviterName(member),
+		Role:     p.Role,
+	}
+	// one transaction, so a retried event does not notify anyone twice
+	return h.Connection.WithTx(ctx, func(tx *db.Connection) error {
+		for _, userID := range recipients {
+			if userID == member.ID || userID == p.ActorID {
+				continue
+			}
+			if err := h.Notifier.Send(ctx, tx, notify.Message{UserID: userID, Data: payload}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// sendInvitationEmail queues the email for a new invitation. It runs again if the relay
+// retries the event, so invitations that are no longer pending are skipped.
+func (h *TeamsAPI) sendInvitationEmail(ctx context.Context, e events.Event) error {
+	p, err := events.Decode[events.InvitationCreatedPayload](e)
+	if err != nil {
+		return err
+	}
+	inv, err := h.Connection.Invitations.ByID(ctx, p.InvitationID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if inv.Status != "pending" || time.Now().After(inv.ExpiresAt) {
+		return nil
+	}
+	team, err := h.Connection.Teams.ByID(ctx, p.TeamID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	inviter, err := h.Connection.Users.ByID(ctx, p.InviterID)
+	if err != nil {
+		return err
+	}
+
+	days := int(math.Ceil(inv.ExpiresAt.Sub(inv.CreatedAt).Hours() / 24))
+	err = h.EmailClient.QueueInvitationEmail(ctx, inv.Email, team.Name, inviterName(inviter), inv.Role, inv.Token, days)
+	// the address bounced before; retrying would only log another suppressed message
+	if errors.Is(err, email.ErrSuppressed) {
+		h.logger.Info("skipped invitation email to suppressed address", "invitation_id", inv.ID, "team_id", team.ID)
+		return nil
+	}
+	return err
+}
+
+// invitationDefaults returns the role and lifetime of invitations to teamID that do not
+// set their own, from the team's settings.
+func (h *TeamsAPI) invitationDefaults(ctx context.Context, teamID uint) (string, time.Duration, error) {
+	role, err := settings.DefaultInviteRole.Get(ctx, h.Connection, teamID)
+	if err != nil {
+		return "", 0, err
+	}
+	days, err := settings.InvitationExpiryDays.Get(ctx, h.Connection, teamID)
+	if err != nil {
+		return "", 0, err
+	}
+	return role, time.Duration(days) * 24 * time.Hour, nil
+}
+
+// pendingInvitationToken returns the newest usable invitation token for email, so freshly
+// signed-up or signed-in users can be sent straight to the acceptance screen.
+func pendingInvitationToken(r *http.Request, conn *db.Connection, email string) string {
+	list, err := conn.Invitations.PendingForEmail(r.Context(), email)
+	if err != nil || len(list) == 0 {
+		return ""
+	}
+	return list[0].Token
+}
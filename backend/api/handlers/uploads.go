@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/Neat-Snap/blueprint-backend/images"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/storage"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/go-chi/chi/v5"
+)
+
+// room for the multipart boundaries and part headers around the file itself
+const uploadFormOverhead = 64 << 10
+
+type UploadsAPI struct {
+	logger logger.MultiLogger
+	Images *images.Library
+}
+
+func NewUploadsAPI(logger logger.MultiLogger, library *images.Library) *UploadsAPI {
+	return &UploadsAPI{logger: logger, Images: library}
+}
+
+// GET /uploads/*
+//
+// Public: uploads are stored under random keys that change with every upload, so the
+// files are served to anyone holding the URL and cached for good.
+func (h *UploadsAPI) ServeEndpoint(w http.ResponseWriter, r *http.Request) {
+	file := chi.URLParam(r, "*")
+	body, obj, err := h.Images.Open(r.Context(), file)
+	if errors.Is(err, storage.ErrNotFound) {
+		utils.WriteError(w, h.logger, nil, "not found", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.WriteError(w, h.logger, err, "failed to read upload", http.StatusInternalServerError)
+		return
+	}
+	defer body.Close()
+
+	contentType := obj.ContentType
+	if contentType == "" {
+		contentType = mime.TypeByExtension(path.Ext(file))
+	}
+	// only images are ever stored; anything else is not rendered by the browser
+	if !strings.HasPrefix(contentType, "image/") {
+		contentType = "application/octet-stream"
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Content-Security-Policy", "default-src 'none'")
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	if obj.Size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(obj.Size, 10))
+	}
+	w.WriteHeader(http.StatusOK)
+	if _, err := io.Copy(w, body); err != nil {
+		h.logger.Warn("failed to send upload", "error", err, "file", file)
+	}
+}
+
+// readImageUpload reads the "file" field of a multipart form, up to maxBytes. On failure
+// it has already answered the request.
+func readImageUpload(w http.ResponseWriter, r *http.Request, log logger.MultiLogger, maxBytes int64) ([]byte, bool) {
+	tooLarge := fmt.Sprintf("file is larger than %d MB", maxBytes>>20)
+	r.Body = http.MaxBytesReader(w, r.Body, maxBytes+uploadFormOverhead)
+	form, err := r.MultipartReader()
+	if err != nil {
+		utils.WriteError(w, log, err, "expected a multipart/form-data body", http.StatusBadRequest)
+		return nil, false
+	}
+	for {
+		part, err := form.NextPart()
+		if err == io.EOF {
+			utils.WriteError(w, log, nil, "the file field is missing", http.StatusBadRequest)
+			return nil, false
+		}
+		var maxErr *http.MaxBytesError
+		if errors.As(err, &maxErr) {
+			utils.WriteError(w, log, err, tooLarge, http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		if err != nil {
+			utils.WriteError(w, log, err, "failed to read the form", http.StatusBadRequest)
+			return nil, false
+		}
+		if part.FormName() != "file" {
+			part.Close()
+			continue
+		}
+
+		data, err := io.ReadAll(io.LimitReader(part, maxBytes+1))
+		part.Close()
+		if errors.As(err, &maxErr) || int64(len(data)) > maxBytes {
+			utils.WriteError(w, log, err, tooLarge, http.StatusRequestEntityTooLarge)
+			return nil, false
+		}
+		if err != nil {
+			utils.WriteError(w, log, err, "failed to read the file", http.StatusBadRequest)
+			return nil, false
+		}
+		return data, true
+	}
+}
+
+// writeImageError answers a request whose upload images.Process rejected.
+func writeImageError(w http.ResponseWriter, log logger.MultiLogger, err error) {
+	switch {
+	case errors.Is(err, images.ErrUnsupported):
+		utils.WriteError(w, log, err, images.ErrUnsupported.Error(), http.StatusUnsupportedMediaType)
+	case errors.Is(err, images.ErrTooLarge):
+		utils.WriteError(w, log, err, images.ErrTooLarge.Error(), http.StatusUnprocessableEntity)
+	default:
+		utils.WriteError(w, log, err, "failed to process image", http.StatusInternalServerError)
+	}
+}
@@ -1,14 +1,23 @@
 package api
 
 import (
+	"net/http"
 	"time"
 
 	"github.com/Neat-Snap/blueprint-backend/api/handlers"
 	"github.com/Neat-Snap/blueprint-backend/config"
 	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/events"
+	"github.com/Neat-Snap/blueprint-backend/images"
+	"github.com/Neat-Snap/blueprint-backend/jobs"
 	"github.com/Neat-Snap/blueprint-backend/logger"
 	mw "github.com/Neat-Snap/blueprint-backend/middleware"
+	"github.com/Neat-Snap/blueprint-backend/notify"
+	"github.com/Neat-Snap/blueprint-backend/rbac"
 	"github.com/Neat-Snap/blueprint-backend/utils/email"
+	"github.com/Neat-Snap/blueprint-backend/utils/geoip"
+	"github.com/Neat-Snap/blueprint-backend/webhooks"
+	"github.com/Neat-Snap/blueprint-backend/webpush"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/httprate"
@@ -23,6 +32,16 @@ type RouterConfig struct {
 	EmailClient *email.EmailClient
 	RedisSecret string
 	Config      config.Config
+	GeoIP       *geoip.Resolver
+	Webhooks    *webhooks.Dispatcher
+	Events      *events.Bus
+	Jobs        *jobs.Queue
+	// pushes notifications to the streams users have open
+	Notifications *notify.Hub
+	// delivers notifications on the channels users chose
+	Notifier *notify.Dispatcher
+	// stores uploaded images and says where they are served
+	Images *images.Library
 }
 
 func NewRouter(c RouterConfig) chi.Router {
@@ -47,10 +66,13 @@ func NewRouter(c RouterConfig) chi.Router {
 	api := handlers.NewTestHealthAPI(c.DB, c.Logger)
 	r.Get("/health", api.HealthHandler)
 
+	uploadsAPI := handlers.NewUploadsAPI(c.Logger, c.Images)
+	r.Get("/uploads/*", uploadsAPI.ServeEndpoint)
+
 	feedbackAPI := handlers.NewFeedbackAPI(c.Logger, c.Connection, c.EmailClient, c.Config)
 	r.With(mw.Confirmation(c.Config, c.EmailClient.R)).Post("/feedback", feedbackAPI.SubmitEndpoint)
 
-	authAPI := handlers.NewAuthAPI(c.DB, c.Logger, c.Connection, c.EmailClient, c.RedisSecret, c.Env, c.Config.SESSION_SECRET, c.Config)
+	authAPI := handlers.NewAuthAPI(c.DB, c.Logger, c.Connection, c.EmailClient, c.RedisSecret, c.Env, c.Config.SESSION_SECRET, c.Config, c.GeoIP, c.Notifier)
 	r.Route("/auth", func(r chi.Router) {
 		r.Post("/signup", authAPI.RegisterEndpoint)
 		r.Post("/confirm-email", authAPI.ConfirmEmailEndpoint)
@@ -62,52 +84,182 @@ func NewRouter(c RouterConfig) chi.Router {
 		r.Post("/resend-email", authAPI.ResendEmailEndpoint)
 		r.Post("/password/reset", authAPI.ResetPasswordEndpoint)
 		r.Post("/password/confirm", authAPI.ResetPasswordConfirmEndpoint)
+		r.Post("/secure-account", authAPI.SecureAccountEndpoint)
 	})
 
-	dashboardAPI := handlers.NewDashboardAPI(c.Logger, c.Connection)
+	dashboardAPI := handlers.NewDashboardAPI(c.Logger, c.Connection, c.Images)
 	r.Route("/dashboard", func(r chi.Router) {
 		r.Use(mw.Confirmation(c.Config, c.EmailClient.R))
 		r.Get("/overview", dashboardAPI.OverViewEndpoint)
 	})
 
-	teamsAPI := handlers.NewTeamsAPI(c.Logger, c.Connection)
+	teamsAPI := handlers.NewTeamsAPI(c.Logger, c.Connection, c.EmailClient, c.Config, c.Webhooks, c.Notifier, c.Images)
+	teamsAPI.SubscribeEvents(c.Events)
 	r.Route("/teams", func(r chi.Router) {
 		r.Use(mw.Confirmation(c.Config, c.EmailClient.R))
 		r.Get("/", teamsAPI.GetTeamsEndpoint)
 		r.Post("/", teamsAPI.CreateTeamEndpoint)
-		r.Get("/{id}", teamsAPI.GetTeamEndpoint)
-		r.Get("/{id}/overview", teamsAPI.GetTeamOverviewEndpoint)
-		r.Get("/{id}/invitations", teamsAPI.ListInvitationsEndpoint)
-		r.Delete("/{id}/invitations/{inv_id}", teamsAPI.RevokeInvitationEndpoint)
 		r.Post("/invitations/check", teamsAPI.CheckInvitationStatusEndpoint)
-		r.Patch("/{id}", teamsAPI.UpdateTeamNameEndpoint)
-		r.Delete("/{id}", teamsAPI.DeleteTeamEndpoint)
-		r.Post("/{id}/members", teamsAPI.AddMemberEndpoint)
-		r.Patch("/{id}/members/{user_id}/role", teamsAPI.UpdateMemberRoleEndpoint)
-		r.Delete("/{id}/members/{user_id}", teamsAPI.RemoveMemberEndpoint)
-		r.Post("/{id}/invitations", teamsAPI.CreateInvitationEndpoint)
 		r.Post("/invitations/accept", teamsAPI.AcceptInvitationEndpoint)
+		r.Post("/invite-links/redeem", teamsAPI.RedeemInviteLinkEndpoint)
+		r.Get("/trash", teamsAPI.ListTrashEndpoint)
+		// deleted teams are invisible to TeamAuthorization, so restore checks ownership itself
+		r.Post("/{id}/restore", teamsAPI.RestoreTeamEndpoint)
+
+		r.Route("/{id}", func(r chi.Router) {
+			r.Use(mw.TeamAuthorization(c.Connection, c.Logger))
+			can := func(p rbac.Permission) func(http.Handler) http.Handler {
+				return mw.RequireTeamPermission(c.Logger, p)
+			}
+
+			r.With(can(rbac.TeamRead)).Get("/", teamsAPI.GetTeamEndpoint)
+			r.With(can(rbac.TeamRead)).Get("/overview", teamsAPI.GetTeamOverviewEndpoint)
+			r.With(can(rbac.TeamUpdate)).Patch("/", teamsAPI.UpdateTeamNameEndpoint)
+			r.With(can(rbac.TeamUpdate)).Post("/logo", teamsAPI.UploadLogoEndpoint)
+			r.With(can(rbac.TeamUpdate)).Delete("/logo", teamsAPI.ResetLogoEndpoint)
+			r.With(can(rbac.TeamDelete)).Delete("/", teamsAPI.DeleteTeamEndpoint)
+
+			r.With(can(rbac.TeamRead)).Get("/settings", teamsAPI.GetSettingsEndpoint)
+			r.With(can(rbac.TeamUpdate)).Patch("/settings", teamsAPI.UpdateSettingsEndpoint)
+			r.With(can(rbac.TeamRead)).Get("/settings/schema", teamsAPI.SettingsSchemaEndpoint)
+
+			r.With(can(rbac.MembersInvite)).Post("/members", teamsAPI.AddMemberEndpoint)
+			r.With(can(rbac.MembersUpdateRole)).Patch("/members/{user_id}/role", teamsAPI.UpdateMemberRoleEndpoint)
+			r.With(can(rbac.MembersRemove)).Delete("/members/{user_id}", teamsAPI.RemoveMemberEndpoint)
+			// membership, checked by TeamAuthorization, is all it takes to leave
+			r.Post("/leave", teamsAPI.LeaveTeamEndpoint)
+
+			r.With(can(rbac.MembersInvite)).Get("/invitations", teamsAPI.ListInvitationsEndpoint)
+			r.With(can(rbac.MembersInvite)).Post("/invitations", teamsAPI.CreateInvitationEndpoint)
+			r.With(can(rbac.MembersInvite)).Post("/invitations/bulk", teamsAPI.BulkCreateInvitationsEndpoint)
+			r.With(can(rbac.MembersInvite)).Delete("/invitations/{inv_id}", teamsAPI.RevokeInvitationEndpoint)
+			r.With(can(rbac.MembersInvite)).Get("/invite-links", teamsAPI.ListInviteLinksEndpoint)
+			r.With(can(rbac.MembersInvite)).Post("/invite-links", teamsAPI.CreateInviteLinkEndpoint)
+			r.With(can(rbac.MembersInvite)).Delete("/invite-links/{link_id}", teamsAPI.RevokeInviteLinkEndpoint)
+
+			// transfers are checked against ownership in the handlers; any member may hold an offer
+			r.With(can(rbac.TeamRead)).Get("/transfer", teamsAPI.GetTransferEndpoint)
+			r.With(can(rbac.TeamRead)).Post("/transfer", teamsAPI.StartTransferEndpoint)
+			r.With(can(rbac.TeamRead)).Delete("/transfer", teamsAPI.CancelTransferEndpoint)
+			r.With(can(rbac.TeamRead)).Post("/transfer/accept", teamsAPI.AcceptTransferEndpoint)
+			r.With(can(rbac.TeamRead)).Post("/transfer/decline", teamsAPI.DeclineTransferEndpoint)
+
+			r.With(can(rbac.AuditRead)).Get("/audit", teamsAPI.ListAuditEndpoint)
+
+			r.Route("/webhooks", func(r chi.Router) {
+				r.Use(can(rbac.WebhooksManage))
+				r.Get("/", teamsAPI.ListWebhooksEndpoint)
+				r.Post("/", teamsAPI.CreateWebhookEndpoint)
+				r.Patch("/{webhook_id}", teamsAPI.UpdateWebhookEndpoint)
+				r.Delete("/{webhook_id}", teamsAPI.DeleteWebhookEndpoint)
+				r.Post("/{webhook_id}/ping", teamsAPI.PingWebhookEndpoint)
+				r.Get("/{webhook_id}/deliveries", teamsAPI.ListWebhookDeliveriesEndpoint)
+				r.Post("/{webhook_id}/deliveries/{delivery_id}/redeliver", teamsAPI.RedeliverWebhookEndpoint)
+			})
+
+			r.With(can(rbac.TeamRead)).Get("/roles", teamsAPI.ListTeamRolesEndpoint)
+			r.With(can(rbac.RolesManage)).Post("/roles", teamsAPI.CreateTeamRoleEndpoint)
+			r.With(can(rbac.RolesManage)).Patch("/roles/{role_id}", teamsAPI.UpdateTeamRoleEndpoint)
+			r.With(can(rbac.RolesManage)).Delete("/roles/{role_id}", teamsAPI.DeleteTeamRoleEndpoint)
+		})
 	})
 
-	usersAPI := handlers.NewUsersAPI(c.Logger, c.Connection, c.EmailClient, c.RedisSecret, c.Config)
+	usersAPI := handlers.NewUsersAPI(c.Logger, c.Connection, c.EmailClient, c.RedisSecret, c.Config, c.GeoIP, c.Notifier, c.Images)
 	r.Route("/account", func(r chi.Router) {
 		r.Use(mw.Confirmation(c.Config, c.EmailClient.R))
 		r.Patch("/me", authAPI.MeEndpoint)
 		r.Patch("/profile", usersAPI.UpdateProfileEndpoint)
+		r.Post("/avatar", usersAPI.UploadAvatarEndpoint)
+		r.Delete("/avatar", usersAPI.DeleteAvatarEndpoint)
 		r.Patch("/email/change", usersAPI.ChangeEmailEndpoint)
 		r.Patch("/email/confirm", usersAPI.ConfirmEmailEndpoint)
 		r.Patch("/password/change", usersAPI.ChangePasswordEndpoint)
+		r.Get("/security/logins", usersAPI.LoginHistoryEndpoint)
+		r.Get("/email/delivery", usersAPI.EmailDeliveryEndpoint)
+		r.Delete("/email/suppression", usersAPI.ClearEmailSuppressionEndpoint)
 
-		r.Get("/preferences", usersAPI.GetPreferencesEndpoint)
-		r.Post("/preferences/theme", usersAPI.UpdateUserThemeEndpoint)
-		r.Post("/preferences/language", usersAPI.UpdateUserLanguage)
+		r.Get("/settings", usersAPI.GetSettingsEndpoint)
+		r.Patch("/settings", usersAPI.UpdateSettingsEndpoint)
+		r.Get("/settings/schema", usersAPI.SettingsSchemaEndpoint)
 	})
 
-	notificationsAPI := handlers.NewNotificationsAPI(c.Logger, c.Connection)
+	notificationsAPI := handlers.NewNotificationsAPI(c.Logger, c.Connection, c.Notifications, c.Notifier, c.Config.DIGEST_UNSUBSCRIBE_SECRET)
+	notificationsAPI.SubscribeEvents(c.Events)
+	c.Notifier.SubscribeEvents(c.Events)
+	c.Notifier.ScheduleDigests(c.Jobs, c.Config.DIGEST_HOUR)
+	notificationsAPI.ScheduleRetention(c.Jobs, time.Duration(c.Config.NOTIFICATION_RETENTION_DAYS)*24*time.Hour)
+	pushAPI := handlers.NewPushAPI(c.Logger, c.Connection, c.Notifier, c.Config.WEB_PUSH_ALLOW_PRIVATE)
 	r.Route("/notifications", func(r chi.Router) {
-		r.Use(mw.Confirmation(c.Config, c.EmailClient.R))
-		r.Get("/", notificationsAPI.ListEndpoint)
-		r.Patch("/{id}/read", notificationsAPI.MarkReadEndpoint)
+		// linked from digest emails, so it works without a session
+		r.Post("/digest/unsubscribe", notificationsAPI.DigestUnsubscribeEndpoint)
+
+		r.Group(func(r chi.Router) {
+			r.Use(mw.Confirmation(c.Config, c.EmailClient.R))
+			r.Get("/", notificationsAPI.ListEndpoint)
+			r.Get("/stream", notificationsAPI.StreamEndpoint)
+			r.Get("/unread-count", notificationsAPI.UnreadCountEndpoint)
+			r.Get("/preferences", notificationsAPI.GetPreferencesEndpoint)
+			r.Put("/preferences", notificationsAPI.UpdatePreferencesEndpoint)
+			r.Get("/digest", notificationsAPI.GetDigestEndpoint)
+			r.Put("/digest", notificationsAPI.UpdateDigestEndpoint)
+			r.Post("/read-all", notificationsAPI.MarkAllReadEndpoint)
+			r.Post("/bulk", notificationsAPI.BulkEndpoint)
+			r.Patch("/{id}/read", notificationsAPI.MarkReadEndpoint)
+			r.Patch("/{id}/archive", notificationsAPI.ArchiveEndpoint)
+			r.Delete("/{id}/archive", notificationsAPI.UnarchiveEndpoint)
+			r.Delete("/{id}", notificationsAPI.DeleteEndpoint)
+
+			r.Get("/push/key", pushAPI.KeyEndpoint)
+			r.Get("/push/subscriptions", pushAPI.ListSubscriptionsEndpoint)
+			r.Post("/push/subscriptions", pushAPI.SubscribeEndpoint)
+			r.Delete("/push/subscriptions", pushAPI.UnsubscribeEndpoint)
+			r.Post("/push/test", pushAPI.TestEndpoint)
+		})
+	})
+
+	if c.Config.RESEND_WEBHOOK_SECRET != "" {
+		emailEventsAPI := handlers.NewEmailEventsAPI(c.Logger, c.Connection, c.EmailClient, c.Notifier, c.Config.RESEND_WEBHOOK_SECRET)
+		r.Post("/email/webhooks/resend", emailEventsAPI.ResendWebhookEndpoint)
+	}
+
+	// the mailbox holds every code sent by email, so it is for local development only
+	if capture, ok := c.EmailClient.Mailer.(*email.CaptureMailer); ok && c.Env == "dev" {
+		mailboxAPI := handlers.NewDevMailboxAPI(c.Logger, capture)
+		r.Route("/dev/mailbox", func(r chi.Router) {
+			r.Use(mw.LocalOrAdmin(c.Config))
+			r.Get("/", mailboxAPI.ListEndpoint)
+			r.Delete("/", mailboxAPI.ClearEndpoint)
+			r.Get("/{message_id}", mailboxAPI.GetEndpoint)
+			r.Get("/{message_id}/html", mailboxAPI.HTMLEndpoint)
+		})
+	}
+
+	// the stand-in decrypts what it receives and can subscribe any user, so like the
+	// mailbox it is for local development only; pushes are sent to it from this machine
+	if c.Env == "dev" {
+		standIn := webpush.NewStandIn(c.Config.BACKEND_PUBLIC_URL + "/dev/push/endpoints")
+		devPushAPI := handlers.NewDevPushAPI(c.Logger, c.Connection, standIn)
+		r.Route("/dev/push", func(r chi.Router) {
+			r.Use(mw.LocalOrAdmin(c.Config))
+			r.Post("/subscriptions", devPushAPI.SubscribeEndpoint)
+			r.Post("/endpoints/{id}", devPushAPI.ReceiveEndpoint)
+			r.Delete("/endpoints/{id}", devPushAPI.ExpireEndpoint)
+			r.Get("/messages", devPushAPI.MessagesEndpoint)
+			r.Delete("/messages", devPushAPI.ClearEndpoint)
+		})
+	}
+
+	adminAPI := handlers.NewAdminAPI(c.Logger, c.Connection, c.Jobs)
+	r.Route("/admin", func(r chi.Router) {
+		r.Use(mw.AdminOnly(c.Config))
+		r.Get("/users/{id}/logins", adminAPI.UserLoginHistoryEndpoint)
+		r.Get("/jobs", adminAPI.JobsOverviewEndpoint)
+		r.Get("/jobs/dead", adminAPI.DeadJobsEndpoint)
+		r.Post("/jobs/{job_id}/retry", adminAPI.RetryJobEndpoint)
+		r.Delete("/jobs/{job_id}", adminAPI.DeleteJobEndpoint)
+		r.Get("/emails", adminAPI.EmailLogEndpoint)
+		r.Get("/emails/suppressions", adminAPI.EmailSuppressionsEndpoint)
+		r.Delete("/emails/suppressions/{address}", adminAPI.DeleteEmailSuppressionEndpoint)
 	})
 
 	return r
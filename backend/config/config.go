@@ -5,6 +5,7 @@ import (
 	"log"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
@@ -24,13 +25,28 @@ type Config struct {
 	DBHost string
 	DBPort string
 
-	RESEND_API_KEY string
+	// resend, smtp or capture; defaults to resend when RESEND_API_KEY is set, capture otherwise
+	MAIL_TRANSPORT    string
+	MAIL_FROM         string
+	MAIL_FROM_BY_TYPE map[string]string
+	MAIL_CAPTURE_DIR  string
+	RESEND_API_KEY    string
+	// whsec_ signing secret of the Resend webhook that reports deliveries and bounces
+	RESEND_WEBHOOK_SECRET string
+	SMTP_HOST             string
+	SMTP_PORT             int
+	SMTP_USERNAME         string
+	SMTP_PASSWORD         string
+	SMTP_TLS              string
+
 	REDIS_HOST     string
 	REDIS_PORT     string
 	REDIS_PASS     string
 	REDIS_DB       int
 	REDIS_SECRET   string
 	SESSION_SECRET string
+	// signs the unsubscribe links in digest emails; changing it breaks the links already sent
+	DIGEST_UNSUBSCRIBE_SECRET string
 
 	APP_NAME           string
 	APP_URL            string
@@ -44,6 +60,49 @@ type Config struct {
 
 	SUPPORT_EMAIL   string
 	DEVELOPER_EMAIL string
+	ADMIN_EMAILS    []string
+
+	GEOIP_DB_PATH string
+
+	TEAM_RETENTION_DAYS int
+	// read notifications older than this are deleted; 0 keeps them
+	NOTIFICATION_RETENTION_DAYS int
+	// local hour, in each user's timezone, that digest emails are sent at
+	DIGEST_HOUR int
+
+	// lets webhooks target private and loopback addresses; for local development only
+	WEBHOOK_ALLOW_PRIVATE bool
+
+	// base64url P-256 private key push is signed with; required outside dev, where it is
+	// generated and kept in Redis when unset
+	VAPID_PRIVATE_KEY string
+	// contact push services can reach the operator at, a mailto: or https: URL
+	VAPID_SUBJECT string
+	// lets push subscriptions point at private and loopback addresses, such as the dev
+	// stand-in push service; for local development only
+	WEB_PUSH_ALLOW_PRIVATE bool
+
+	// where uploads are kept: "local" (UploadDir) or "s3"
+	STORAGE_BACKEND      string
+	S3_ENDPOINT          string
+	S3_REGION            string
+	S3_BUCKET            string
+	S3_ACCESS_KEY_ID     string
+	S3_SECRET_ACCESS_KEY string
+	// address the bucket as endpoint/bucket, as MinIO needs
+	S3_PATH_STYLE bool
+	// where uploaded files are served from; the API's /uploads unless a bucket or CDN
+	// serves them directly
+	UPLOADS_PUBLIC_URL string
+
+	JOBS_CONCURRENCY     int
+	JOBS_DRAIN_TIMEOUT_S int
+
+	// outbox publishers besides the in-process bus: "redis", "nats"
+	EVENTS_PUBLISHERS   []string
+	EVENTS_REDIS_STREAM string
+	NATS_URL            string
+	NATS_SUBJECT_PREFIX string
 
 	JWT_SECRET   string
 	JWT_ISSUER   string
@@ -87,6 +146,51 @@ func getint(k string, def int) int {
 	return i
 }
 
+func getbool(k string, def bool) bool {
+	v := getenv(k, "")
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		log.Printf("invalid bool for %s: %v, using %t", k, err, def)
+		return def
+	}
+	return b
+}
+
+func getlist(k string) []string {
+	var out []string
+	for _, v := range strings.Split(getenv(k, ""), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// getprefixed collects every variable starting with prefix, keyed by the lowercased rest
+// of its name: MAIL_FROM_INVITATION becomes "invitation".
+func getprefixed(prefix string) map[string]string {
+	out := make(map[string]string)
+	for _, kv := range os.Environ() {
+		k, v, _ := strings.Cut(kv, "=")
+		if rest, ok := strings.CutPrefix(k, prefix); ok && rest != "" && v != "" {
+			out[strings.ToLower(rest)] = v
+		}
+	}
+	return out
+}
+
+// defaultMailTransport is resend when it has a key. There is no fallback otherwise: the
+// capture transport exposes every message, codes included, so it must be chosen explicitly.
+func defaultMailTransport() string {
+	if os.Getenv("RESEND_API_KEY") != "" {
+		return "resend"
+	}
+	return ""
+}
+
 // func getint64(k string, def int64) int64 {
 // 	v := getenv(k, "")
 // 	if v == "" {
@@ -114,6 +218,8 @@ func Load() Config {
 		ReadTimeoutS:  getint("APP_READ_TIMEOUT_S", 15),
 		WriteTimeoutS: getint("APP_WRITE_TIMEOUT_S", 30),
 		IdleTimeoutS:  getint("APP_IDLE_TIMEOUT_S", 60),
+		UploadDir:     getenv("UPLOAD_DIR", "tmp/uploads"),
+		MaxUploadMB:   int64(getint("MAX_UPLOAD_MB", 5)),
 
 		DBName: getenvStrict("DB_NAME"),
 		DBUser: getenvStrict("DB_USER"),
@@ -121,7 +227,17 @@ func Load() Config {
 		DBHost: getenvStrict("DB_HOST"),
 		DBPort: getenv("DB_PORT", "5432"),
 
-		RESEND_API_KEY: getenvStrict("RESEND_API_KEY"),
+		MAIL_TRANSPORT:        getenv("MAIL_TRANSPORT", defaultMailTransport()),
+		MAIL_FROM:             getenv("MAIL_FROM", "Devenv <onboarding@resend.dev>"),
+		MAIL_FROM_BY_TYPE:     getprefixed("MAIL_FROM_"),
+		MAIL_CAPTURE_DIR:      getenv("MAIL_CAPTURE_DIR", "tmp/mailbox"),
+		RESEND_API_KEY:        getenv("RESEND_API_KEY", ""),
+		RESEND_WEBHOOK_SECRET: getenv("RESEND_WEBHOOK_SECRET", ""),
+		SMTP_HOST:             getenv("SMTP_HOST", ""),
+		SMTP_PORT:             getint("SMTP_PORT", 587),
+		SMTP_USERNAME:         getenv("SMTP_USERNAME", ""),
+		SMTP_PASSWORD:         getenv("SMTP_PASSWORD", ""),
+		SMTP_TLS:              getenv("SMTP_TLS", "starttls"),
 
 		REDIS_HOST:     getenv("REDIS_HOST", "localhost"),
 		REDIS_PORT:     getenv("REDIS_PORT", "6379"),
@@ -130,6 +246,8 @@ func Load() Config {
 		REDIS_SECRET:   getenvStrict("REDIS_SECRET"),
 		SESSION_SECRET: getenvStrict("SESSION_SECRET"),
 
+		DIGEST_UNSUBSCRIBE_SECRET: getenvStrict("DIGEST_UNSUBSCRIBE_SECRET"),
+
 		APP_NAME:           getenvStrict("APP_NAME"),
 		APP_URL:            getenvStrict("APP_URL"),
 		BACKEND_PUBLIC_URL: getenvStrict("BACKEND_PUBLIC_URL"),
@@ -142,6 +260,36 @@ func Load() Config {
 
 		SUPPORT_EMAIL:   fmt.Sprintf("support@%s", getenvStrict("APP_URL")),
 		DEVELOPER_EMAIL: getenv("DEVELOPER_EMAIL", ""),
+		ADMIN_EMAILS:    getlist("ADMIN_EMAILS"),
+
+		GEOIP_DB_PATH: getenv("GEOIP_DB_PATH", ""),
+
+		TEAM_RETENTION_DAYS:         getint("TEAM_RETENTION_DAYS", 30),
+		NOTIFICATION_RETENTION_DAYS: getint("NOTIFICATION_RETENTION_DAYS", 90),
+		DIGEST_HOUR:                 getint("DIGEST_HOUR", 8),
+
+		WEBHOOK_ALLOW_PRIVATE: getbool("WEBHOOK_ALLOW_PRIVATE", false),
+
+		VAPID_PRIVATE_KEY:      getenv("VAPID_PRIVATE_KEY", ""),
+		VAPID_SUBJECT:          getenv("VAPID_SUBJECT", getenvStrict("APP_URL")),
+		WEB_PUSH_ALLOW_PRIVATE: getbool("WEB_PUSH_ALLOW_PRIVATE", false),
+
+		STORAGE_BACKEND:      getenv("STORAGE_BACKEND", "local"),
+		S3_ENDPOINT:          getenv("S3_ENDPOINT", ""),
+		S3_REGION:            getenv("S3_REGION", "us-east-1"),
+		S3_BUCKET:            getenv("S3_BUCKET", ""),
+		S3_ACCESS_KEY_ID:     getenv("S3_ACCESS_KEY_ID", ""),
+		S3_SECRET_ACCESS_KEY: getenv("S3_SECRET_ACCESS_KEY", ""),
+		S3_PATH_STYLE:        getbool("S3_PATH_STYLE", false),
+		UPLOADS_PUBLIC_URL:   getenv("UPLOADS_PUBLIC_URL", getenvStrict("BACKEND_PUBLIC_URL")+"/uploads"),
+
+		JOBS_CONCURRENCY:     getint("JOBS_CONCURRENCY", 4),
+		JOBS_DRAIN_TIMEOUT_S: getint("JOBS_DRAIN_TIMEOUT_S", 20),
+
+		EVENTS_PUBLISHERS:   getlist("EVENTS_PUBLISHERS"),
+		EVENTS_REDIS_STREAM: getenv("EVENTS_REDIS_STREAM", "blueprint:events"),
+		NATS_URL:            getenv("NATS_URL", "nats://localhost:4222"),
+		NATS_SUBJECT_PREFIX: getenv("NATS_SUBJECT_PREFIX", "blueprint"),
 
 		JWT_SECRET:   getenvStrict("JWT_SECRET"),
 		JWT_ISSUER:   getenv("JWT_ISSUER", "statgrad"),
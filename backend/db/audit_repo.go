@@ -0,0 +1,53 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type AuditFilter struct {
+	TeamID   uint
+	Action   string
+	ActorID  uint
+	TargetID uint
+	Since    time.Time
+	Until    time.Time
+	// BeforeID is the pagination cursor: only events older than it are returned
+	BeforeID uint
+	Limit    int
+}
+
+type auditRepo struct{ db *gorm.DB }
+
+// Create is the only write the audit log supports; entries are never updated or deleted.
+func (r *auditRepo) Create(ctx context.Context, e *TeamAuditEvent) error {
+	return r.db.WithContext(ctx).Create(e).Error
+}
+
+func (r *auditRepo) List(ctx context.Context, f AuditFilter) ([]TeamAuditEvent, error) {
+	q := r.db.WithContext(ctx).Where("team_id = ?", f.TeamID)
+	if f.Action != "" {
+		q = q.Where("action = ?", f.Action)
+	}
+	if f.ActorID != 0 {
+		q = q.Where("actor_id = ?", f.ActorID)
+	}
+	if f.TargetID != 0 {
+		q = q.Where("target_id = ?", f.TargetID)
+	}
+	if !f.Since.IsZero() {
+		q = q.Where("created_at >= ?", f.Since)
+	}
+	if !f.Until.IsZero() {
+		q = q.Where("created_at < ?", f.Until)
+	}
+	if f.BeforeID != 0 {
+		q = q.Where("id < ?", f.BeforeID)
+	}
+
+	var list []TeamAuditEvent
+	err := q.Order("id DESC").Limit(f.Limit).Find(&list).Error
+	return list, err
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -14,6 +15,19 @@ type Connection struct {
 	Invitations   InvitationsRepo
 	Notifications NotificationsRepo
 	Preferences   UserPreferencesRepo
+	LoginEvents   LoginEventsRepo
+	InviteLinks   InviteLinksRepo
+	TeamRoles     TeamRolesRepo
+	Transfers     TeamTransfersRepo
+	Audit         AuditRepo
+	Webhooks      WebhooksRepo
+	Outbox        OutboxRepo
+	Emails        EmailsRepo
+	Push          PushSubscriptionsRepo
+	Settings      SettingsRepo
+
+	// set inside WithTx; functions to run once the outermost transaction commits
+	afterCommit *[]func()
 }
 
 func NewConnection(db *gorm.DB) *Connection {
@@ -25,11 +39,22 @@ func NewConnection(db *gorm.DB) *Connection {
 		Invitations:   &invitationsRepo{db: db},
 		Notifications: &notificationsRepo{db: db},
 		Preferences:   &preferencesRepo{db: db},
+		LoginEvents:   &loginEventsRepo{db: db},
+		InviteLinks:   &inviteLinksRepo{db: db},
+		TeamRoles:     &teamRolesRepo{db: db},
+		Transfers:     &teamTransfersRepo{db: db},
+		Audit:         &auditRepo{db: db},
+		Webhooks:      &webhooksRepo{db: db},
+		Outbox:        &outboxRepo{db: db},
+		Emails:        &emailsRepo{db: db},
+		Push:          &pushSubscriptionsRepo{db: db},
+		Settings:      &settingsRepo{db: db},
 	}
 }
 
 func (c *Connection) WithTx(ctx context.Context, fn func(tx *Connection) error) error {
-	return c.DBConn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	var hooks []func()
+	err := c.DBConn.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		localConn := &Connection{
 			DBConn:        tx,
 			Users:         &usersRepo{db: tx},
@@ -38,15 +63,44 @@ func (c *Connection) WithTx(ctx context.Context, fn func(tx *Connection) error)
 			Invitations:   &invitationsRepo{db: tx},
 			Notifications: &notificationsRepo{db: tx},
 			Preferences:   &preferencesRepo{db: tx},
+			LoginEvents:   &loginEventsRepo{db: tx},
+			InviteLinks:   &inviteLinksRepo{db: tx},
+			TeamRoles:     &teamRolesRepo{db: tx},
+			Transfers:     &teamTransfersRepo{db: tx},
+			Audit:         &auditRepo{db: tx},
+			Webhooks:      &webhooksRepo{db: tx},
+			Outbox:        &outboxRepo{db: tx},
+			Emails:        &emailsRepo{db: tx},
+			Push:          &pushSubscriptionsRepo{db: tx},
+			Settings:      &settingsRepo{db: tx},
+			afterCommit:   &hooks,
 		}
 		return fn(localConn)
 	})
+	if err != nil {
+		return err
+	}
+	for _, f := range hooks {
+		c.AfterCommit(f)
+	}
+	return nil
+}
+
+// AfterCommit runs f once the transaction c belongs to has committed, and not at all if it
+// rolls back. Outside a transaction f runs right away.
+func (c *Connection) AfterCommit(f func()) {
+	if c.afterCommit == nil {
+		f()
+		return
+	}
+	*c.afterCommit = append(*c.afterCommit, f)
 }
 
 type UsersRepo interface {
 	Create(ctx context.Context, u *User) error
 	ByID(ctx context.Context, id uint) (*User, error)
 	ByEmail(ctx context.Context, email string) (*User, error)
+	ListByEmails(ctx context.Context, emails []string) ([]User, error)
 	Update(ctx context.Context, u *User) error
 	SoftDelete(ctx context.Context, id uint) error
 }
@@ -54,6 +108,7 @@ type UsersRepo interface {
 type TeamsRepo interface {
 	Create(ctx context.Context, w *Team) error
 	ByID(ctx context.Context, id uint) (*Team, error)
+	LockByID(ctx context.Context, id uint) (*Team, error)
 	AddMember(ctx context.Context, teamID, userID uint, role string) error
 	RemoveMember(ctx context.Context, teamID, userID uint) error
 	ListForUser(ctx context.Context, userID uint) ([]Team, error)
@@ -62,6 +117,21 @@ type TeamsRepo interface {
 	RolesForTeam(ctx context.Context, teamID uint) (map[uint]string, error)
 	Update(ctx context.Context, w *Team) error
 	Delete(ctx context.Context, w *Team) error
+	DeletedByID(ctx context.Context, id uint) (*Team, error)
+	ListDeletedOwnedBy(ctx context.Context, ownerID uint, since time.Time) ([]Team, error)
+	Restore(ctx context.Context, id uint) error
+	ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]Team, error)
+	Purge(ctx context.Context, id uint) error
+}
+
+type TeamRolesRepo interface {
+	Create(ctx context.Context, role *TeamRole) error
+	ByID(ctx context.Context, teamID, id uint) (*TeamRole, error)
+	ByName(ctx context.Context, teamID uint, name string) (*TeamRole, error)
+	ListByTeam(ctx context.Context, teamID uint) ([]TeamRole, error)
+	Update(ctx context.Context, role *TeamRole) error
+	Delete(ctx context.Context, role *TeamRole) error
+	CountMembers(ctx context.Context, teamID uint, name string) (int64, error)
 }
 
 type AuthRepo interface {
@@ -75,16 +145,55 @@ type AuthRepo interface {
 
 type InvitationsRepo interface {
 	Create(ctx context.Context, inv *TeamInvitation) error
+	CreateBatch(ctx context.Context, invs []TeamInvitation, batchSize int) error
 	ByToken(ctx context.Context, token string) (*TeamInvitation, error)
+	ByID(ctx context.Context, id uint) (*TeamInvitation, error)
 	MarkAccepted(ctx context.Context, id uint) error
 	ListByTeam(ctx context.Context, teamID uint) ([]TeamInvitation, error)
+	PendingForEmail(ctx context.Context, email string) ([]TeamInvitation, error)
+	RevokePendingForEmail(ctx context.Context, teamID uint, email string) error
+	Revoke(ctx context.Context, id uint) error
+}
+
+type InviteLinksRepo interface {
+	Create(ctx context.Context, l *TeamInviteLink) error
+	ByID(ctx context.Context, id uint) (*TeamInviteLink, error)
+	ByTokenHash(ctx context.Context, hash string) (*TeamInviteLink, error)
+	ListByTeam(ctx context.Context, teamID uint) ([]TeamInviteLink, error)
 	Revoke(ctx context.Context, id uint) error
+	ConsumeUse(ctx context.Context, id uint) (bool, error)
 }
 
 type NotificationsRepo interface {
 	Create(ctx context.Context, n *Notification) error
-	ListForUser(ctx context.Context, userID uint) ([]Notification, error)
+	ByID(ctx context.Context, userID, id uint) (*Notification, error)
+	List(ctx context.Context, f NotificationFilter) ([]Notification, error)
+	CountUnread(ctx context.Context, userID uint) (int64, error)
 	MarkRead(ctx context.Context, id uint) error
+	MarkReadMany(ctx context.Context, userID uint, ids []uint) ([]uint, error)
+	SetArchived(ctx context.Context, userID uint, ids []uint, archived bool) ([]uint, error)
+	Delete(ctx context.Context, userID uint, ids []uint) ([]uint, error)
+	DeleteReadBefore(ctx context.Context, cutoff time.Time) (int64, error)
+	AddDigestItem(ctx context.Context, item *NotificationDigestItem) error
+	PendingDigestItems(ctx context.Context, userID uint, limit int) ([]NotificationDigestItem, error)
+	MarkDigestSent(ctx context.Context, userID, lastID uint, at time.Time) error
+	DeleteDigestItemsSentBefore(ctx context.Context, cutoff time.Time) (int64, error)
+}
+
+type PushSubscriptionsRepo interface {
+	Save(ctx context.Context, sub *PushSubscription) error
+	ListByUser(ctx context.Context, userID uint) ([]PushSubscription, error)
+	Delete(ctx context.Context, userID uint, endpoint string) (bool, error)
+	DeleteByID(ctx context.Context, id uint) error
+	RecordResult(ctx context.Context, id uint, ok bool, at time.Time) error
+}
+
+type SettingsRepo interface {
+	List(ctx context.Context, scope string, ownerID uint) ([]Setting, error)
+	Save(ctx context.Context, list []Setting) error
+	Delete(ctx context.Context, scope string, ownerID uint, keys []string) error
+	Columns(ctx context.Context, scope string, ownerID uint, columns []string) (map[string]any, error)
+	SetColumns(ctx context.Context, scope string, ownerID uint, values map[string]any) error
 }
 
 type UserPreferencesRepo interface {
@@ -92,4 +201,62 @@ type UserPreferencesRepo interface {
 	Get(ctx context.Context, userID uint) (*UserPreference, error)
 	GetByEmail(ctx context.Context, userEmail string) (*UserPreference, error)
 	Update(ctx context.Context, preference *UserPreference) error
+	NotificationChannels(ctx context.Context, userID uint) ([]NotificationPreference, error)
+	NotificationChannel(ctx context.Context, userID uint, notificationType string) (*NotificationPreference, error)
+	SetNotificationChannels(ctx context.Context, prefs []NotificationPreference) error
+	DigestZones(ctx context.Context) ([]DigestZone, error)
+	DueDigests(ctx context.Context, zone DigestZone, slot time.Time, firstDigests bool, afterID uint, limit int) ([]UserPreference, error)
+	ClaimDigest(ctx context.Context, userID uint, slot, now time.Time) (bool, error)
+}
+
+type LoginEventsRepo interface {
+	Create(ctx context.Context, e *LoginEvent) error
+	ListForUser(ctx context.Context, userID uint, limit, offset int) ([]LoginEvent, int64, error)
+	DeviceSeen(ctx context.Context, userID uint, deviceHash string) (seen bool, anyBefore bool, err error)
+}
+
+type TeamTransfersRepo interface {
+	Create(ctx context.Context, t *TeamOwnershipTransfer) error
+	PendingForTeam(ctx context.Context, teamID uint) (*TeamOwnershipTransfer, error)
+	Resolve(ctx context.Context, id uint, status string) (bool, error)
+	CancelPendingForTeam(ctx context.Context, teamID uint) error
+}
+
+type AuditRepo interface {
+	Create(ctx context.Context, e *TeamAuditEvent) error
+	List(ctx context.Context, f AuditFilter) ([]TeamAuditEvent, error)
+}
+
+type WebhooksRepo interface {
+	CreateEndpoint(ctx context.Context, e *WebhookEndpoint) error
+	EndpointByID(ctx context.Context, teamID, id uint) (*WebhookEndpoint, error)
+	ListEndpoints(ctx context.Context, teamID uint) ([]WebhookEndpoint, error)
+	ActiveEndpoints(ctx context.Context, teamID uint) ([]WebhookEndpoint, error)
+	UpdateEndpoint(ctx context.Context, e *WebhookEndpoint) error
+	DeleteEndpoint(ctx context.Context, e *WebhookEndpoint) error
+	CreateDeliveries(ctx context.Context, list []WebhookDelivery) error
+	DeliveryByID(ctx context.Context, endpointID, id uint) (*WebhookDelivery, error)
+	ListDeliveries(ctx context.Context, endpointID uint, beforeID uint, limit int) ([]WebhookDelivery, error)
+	ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error)
+	UpdateDelivery(ctx context.Context, d *WebhookDelivery) error
+}
+
+type OutboxRepo interface {
+	Add(ctx context.Context, e *OutboxEvent) error
+	ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxEvent, error)
+	Save(ctx context.Context, e *OutboxEvent) error
+	DeleteFinishedBefore(ctx context.Context, published, failed time.Time) (int64, error)
+}
+
+type EmailsRepo interface {
+	CreateMessage(ctx context.Context, m *EmailMessage) error
+	UpdateMessage(ctx context.Context, m *EmailMessage) error
+	MessageByID(ctx context.Context, id uint) (*EmailMessage, error)
+	MessageByProviderID(ctx context.Context, transport, providerID string) (*EmailMessage, error)
+	ListMessages(ctx context.Context, recipient string, beforeID uint, limit int) ([]EmailMessage, error)
+	DeleteMessagesBefore(ctx context.Context, before time.Time) (int64, error)
+	Suppress(ctx context.Context, s *EmailSuppression) (bool, error)
+	Suppression(ctx context.Context, address string) (*EmailSuppression, error)
+	ListSuppressions(ctx context.Context, limit, offset int) ([]EmailSuppression, int64, error)
+	DeleteSuppression(ctx context.Context, address string) (bool, error)
 }
@@ -2,11 +2,13 @@ package db
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/Neat-Snap/blueprint-backend/config"
 	"github.com/Neat-Snap/blueprint-backend/logger"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func Connect(cfg *config.Config, logger *logger.MultiLogger) (*gorm.DB, error) {
@@ -25,12 +27,57 @@ func Connect(cfg *config.Config, logger *logger.MultiLogger) (*gorm.DB, error) {
 		return nil, err
 	}
 
-	if err := db.AutoMigrate(&User{}, &PasswordCredential{}, &AuthIdentity{}, &Team{}, &UserTeam{}, &TeamInvitation{}, &Notification{}, &UserPreference{}); err != nil {
+	if err := db.AutoMigrate(&User{}, &PasswordCredential{}, &AuthIdentity{}, &Team{}, &UserTeam{}, &TeamInvitation{}, &Notification{}, &UserPreference{}, &LoginEvent{}, &TeamInviteLink{}, &TeamRole{}, &TeamOwnershipTransfer{}, &TeamAuditEvent{}, &WebhookEndpoint{}, &WebhookDelivery{}, &OutboxEvent{}, &EmailMessage{}, &EmailSuppression{}, &NotificationPreference{}, &NotificationDigestItem{}, &PushSubscription{}, &Setting{}, &DataMigration{}); err != nil {
 		logger.Error("failed to auto migrate", "error", err)
 		return nil, err
 	}
+	if err := runOnce(db, "digests_off_by_default", disableDefaultDigests); err != nil {
+		logger.Error("failed to turn off default digests", "error", err)
+		return nil, err
+	}
+	if err := runOnce(db, "notification_team_ids", backfillNotificationTeams); err != nil {
+		logger.Error("failed to backfill notification teams", "error", err)
+		return nil, err
+	}
+	if err := backfillOwnerRoles(db); err != nil {
+		logger.Error("failed to backfill team owner roles", "error", err)
+		return nil, err
+	}
 
 	logger.Info("successfully connected to database", "db_name", cfg.DBName)
 
 	return db, nil
 }
+
+// backfillNotificationTeams fills Notification.TeamID for rows from before the column;
+// newer rows get it when they are created. The ID is matched in the text rather than
+// cast from JSON, so a malformed payload is skipped instead of failing the statement.
+func backfillNotificationTeams(tx *gorm.DB) error {
+	return tx.Exec(`UPDATE notifications SET team_id = substring(data from '"team_id"\s*:\s*([0-9]{1,18})')::bigint
+		WHERE team_id IS NULL AND data ~ '"team_id"\s*:\s*[0-9]{1,18}'`).Error
+}
+
+// backfillOwnerRoles gives team owners the owner role on their membership; teams used to
+// be created with their owner as an admin.
+func backfillOwnerRoles(db *gorm.DB) error {
+	return db.Exec(`UPDATE user_teams SET role = 'owner' FROM teams
+		WHERE teams.id = user_teams.team_id AND teams.owner_id = user_teams.user_id AND user_teams.role <> 'owner'`).Error
+}
+
+// runOnce applies a data migration the first time the database meets it, in one
+// transaction with its DataMigration row, so replicas starting together apply it once.
+func runOnce(db *gorm.DB, name string, migrate func(tx *gorm.DB) error) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		res := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&DataMigration{Name: name, AppliedAt: time.Now()})
+		if res.Error != nil || res.RowsAffected == 0 {
+			return res.Error
+		}
+		return migrate(tx)
+	})
+}
+
+// disableDefaultDigests turns off the weekly digest preferences were created with before
+// digests became opt-in.
+func disableDefaultDigests(tx *gorm.DB) error {
+	return tx.Exec(`UPDATE user_preferences SET digest_frequency = 'off' WHERE digest_frequency = 'weekly'`).Error
+}
@@ -0,0 +1,93 @@
+package db
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type emailsRepo struct{ db *gorm.DB }
+
+func (r *emailsRepo) CreateMessage(ctx context.Context, m *EmailMessage) error {
+	m.Recipient = strings.ToLower(m.Recipient)
+	return r.db.WithContext(ctx).Create(m).Error
+}
+
+func (r *emailsRepo) UpdateMessage(ctx context.Context, m *EmailMessage) error {
+	return r.db.WithContext(ctx).Save(m).Error
+}
+
+func (r *emailsRepo) MessageByID(ctx context.Context, id uint) (*EmailMessage, error) {
+	var m EmailMessage
+	if err := r.db.WithContext(ctx).First(&m, id).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+func (r *emailsRepo) MessageByProviderID(ctx context.Context, transport, providerID string) (*EmailMessage, error) {
+	var m EmailMessage
+	err := r.db.WithContext(ctx).
+		Where("transport = ? AND provider_id = ?", transport, providerID).
+		First(&m).Error
+	if err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// ListMessages pages newest first by ID; beforeID 0 starts at the newest. An empty
+// recipient lists every message.
+func (r *emailsRepo) ListMessages(ctx context.Context, recipient string, beforeID uint, limit int) ([]EmailMessage, error) {
+	q := r.db.WithContext(ctx).Order("id DESC").Limit(limit)
+	if recipient != "" {
+		q = q.Where("recipient = ?", strings.ToLower(recipient))
+	}
+	if beforeID > 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	var list []EmailMessage
+	err := q.Find(&list).Error
+	return list, err
+}
+
+func (r *emailsRepo) DeleteMessagesBefore(ctx context.Context, before time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("created_at < ?", before).Delete(&EmailMessage{})
+	return res.RowsAffected, res.Error
+}
+
+// Suppress adds the address to the suppression list. It reports false when the address
+// was already there, which keeps repeated provider events from notifying twice.
+func (r *emailsRepo) Suppress(ctx context.Context, s *EmailSuppression) (bool, error) {
+	s.Email = strings.ToLower(s.Email)
+	res := r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{Columns: []clause.Column{{Name: "email"}}, DoNothing: true}).
+		Create(s)
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *emailsRepo) Suppression(ctx context.Context, address string) (*EmailSuppression, error) {
+	var s EmailSuppression
+	if err := r.db.WithContext(ctx).Where("email = ?", strings.ToLower(address)).First(&s).Error; err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (r *emailsRepo) ListSuppressions(ctx context.Context, limit, offset int) ([]EmailSuppression, int64, error) {
+	var total int64
+	if err := r.db.WithContext(ctx).Model(&EmailSuppression{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var list []EmailSuppression
+	err := r.db.WithContext(ctx).Order("id DESC").Limit(limit).Offset(offset).Find(&list).Error
+	return list, total, err
+}
+
+func (r *emailsRepo) DeleteSuppression(ctx context.Context, address string) (bool, error) {
+	res := r.db.WithContext(ctx).Where("email = ?", strings.ToLower(address)).Delete(&EmailSuppression{})
+	return res.RowsAffected > 0, res.Error
+}
@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
@@ -13,12 +14,25 @@ func (r *invitationsRepo) Create(ctx context.Context, inv *TeamInvitation) error
 	return r.db.WithContext(ctx).Create(inv).Error
 }
 
+func (r *invitationsRepo) CreateBatch(ctx context.Context, invs []TeamInvitation, batchSize int) error {
+	if len(invs) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).CreateInBatches(&invs, batchSize).Error
+}
+
 func (r *invitationsRepo) ByToken(ctx context.Context, token string) (*TeamInvitation, error) {
 	var i TeamInvitation
 	err := r.db.WithContext(ctx).Where("token = ?", token).First(&i).Error
 	return &i, err
 }
 
+func (r *invitationsRepo) ByID(ctx context.Context, id uint) (*TeamInvitation, error) {
+	var i TeamInvitation
+	err := r.db.WithContext(ctx).First(&i, id).Error
+	return &i, err
+}
+
 func (r *invitationsRepo) MarkAccepted(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).
 		Model(&TeamInvitation{}).
@@ -35,6 +49,25 @@ func (r *invitationsRepo) ListByTeam(ctx context.Context, teamID uint) ([]TeamIn
 	return list, err
 }
 
+func (r *invitationsRepo) PendingForEmail(ctx context.Context, email string) ([]TeamInvitation, error) {
+	var list []TeamInvitation
+	err := r.db.WithContext(ctx).
+		Where("LOWER(email) = ? AND status = ? AND expires_at > ?", strings.ToLower(email), "pending", time.Now()).
+		Order("created_at DESC").
+		Find(&list).Error
+	return list, err
+}
+
+func (r *invitationsRepo) RevokePendingForEmail(ctx context.Context, teamID uint, email string) error {
+	return r.db.WithContext(ctx).
+		Model(&TeamInvitation{}).
+		Where("team_id = ? AND LOWER(email) = ? AND status = ?", teamID, strings.ToLower(email), "pending").
+		Updates(map[string]any{
+			"status":     "revoked",
+			"updated_at": time.Now(),
+		}).Error
+}
+
 func (r *invitationsRepo) Revoke(ctx context.Context, id uint) error {
 	return r.db.WithContext(ctx).
 		Model(&TeamInvitation{}).
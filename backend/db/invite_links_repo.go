@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type inviteLinksRepo struct{ db *gorm.DB }
+
+func (r *inviteLinksRepo) Create(ctx context.Context, l *TeamInviteLink) error {
+	return r.db.WithContext(ctx).Create(l).Error
+}
+
+func (r *inviteLinksRepo) ByID(ctx context.Context, id uint) (*TeamInviteLink, error) {
+	var l TeamInviteLink
+	err := r.db.WithContext(ctx).First(&l, id).Error
+	return &l, err
+}
+
+func (r *inviteLinksRepo) ByTokenHash(ctx context.Context, hash string) (*TeamInviteLink, error) {
+	var l TeamInviteLink
+	err := r.db.WithContext(ctx).Where("token_hash = ?", hash).First(&l).Error
+	return &l, err
+}
+
+func (r *inviteLinksRepo) ListByTeam(ctx context.Context, teamID uint) ([]TeamInviteLink, error) {
+	var list []TeamInviteLink
+	err := r.db.WithContext(ctx).Where("team_id = ?", teamID).Order("created_at DESC").Find(&list).Error
+	return list, err
+}
+
+func (r *inviteLinksRepo) Revoke(ctx context.Context, id uint) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).
+		Model(&TeamInviteLink{}).
+		Where("id = ? AND revoked_at IS NULL", id).
+		Updates(map[string]any{
+			"revoked_at": &now,
+			"updated_at": now,
+		}).Error
+}
+
+// ConsumeUse atomically takes one use of the link. It reports false when the link is
+// revoked, expired or already used up, so concurrent redemptions cannot exceed MaxUses.
+func (r *inviteLinksRepo) ConsumeUse(ctx context.Context, id uint) (bool, error) {
+	now := time.Now()
+	res := r.db.WithContext(ctx).
+		Model(&TeamInviteLink{}).
+		Where("id = ? AND revoked_at IS NULL AND expires_at > ? AND (max_uses = 0 OR uses < max_uses)", id, now).
+		Updates(map[string]any{
+			"uses":       gorm.Expr("uses + 1"),
+			"updated_at": now,
+		})
+	return res.RowsAffected == 1, res.Error
+}
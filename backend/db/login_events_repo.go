@@ -0,0 +1,47 @@
+package db
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type loginEventsRepo struct{ db *gorm.DB }
+
+func (r *loginEventsRepo) Create(ctx context.Context, e *LoginEvent) error {
+	return r.db.WithContext(ctx).Create(e).Error
+}
+
+func (r *loginEventsRepo) ListForUser(ctx context.Context, userID uint, limit, offset int) ([]LoginEvent, int64, error) {
+	var total int64
+	q := r.db.WithContext(ctx).Model(&LoginEvent{}).Where("user_id = ?", userID)
+	if err := q.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var list []LoginEvent
+	err := q.Order("created_at DESC").Limit(limit).Offset(offset).Find(&list).Error
+	return list, total, err
+}
+
+func (r *loginEventsRepo) DeviceSeen(ctx context.Context, userID uint, deviceHash string) (seen bool, anyBefore bool, err error) {
+	var rows []struct {
+		DeviceHash string
+		Count      int64
+	}
+	err = r.db.WithContext(ctx).
+		Model(&LoginEvent{}).
+		Select("device_hash, COUNT(*) AS count").
+		Where("user_id = ? AND success = ?", userID, true).
+		Group("device_hash").
+		Scan(&rows).Error
+	if err != nil {
+		return false, false, err
+	}
+	for _, row := range rows {
+		if row.DeviceHash == deviceHash {
+			seen = true
+		}
+	}
+	return seen, len(rows) > 0, nil
+}
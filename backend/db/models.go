@@ -17,6 +17,12 @@ type User struct {
 
 	Name      *string
 	AvatarURL *string
+	// storage key of an uploaded avatar, see images.Avatar; empty when AvatarURL is the
+	// sign-in provider's picture or there is none
+	AvatarKey string `gorm:"type:varchar(191);default:''"`
+
+	// tokens issued before this moment are rejected by the auth middleware
+	SessionsRevokedAt *time.Time
 
 	PasswordCredential *PasswordCredential `gorm:"constraint:OnDelete:CASCADE"`
 	AuthIdentities     []AuthIdentity      `gorm:"constraint:OnDelete:CASCADE"`
@@ -32,6 +38,12 @@ type UserPreference struct {
 
 	Theme    string `gorm:"type:varchar(32);not null;default:'system'"`
 	Language string `gorm:"type:varchar(32);not null;default:'en'"`
+	// IANA name, e.g. "Europe/Berlin"; digests go out in the morning of this zone
+	Timezone string `gorm:"type:varchar(64);not null;default:'UTC'"`
+
+	// frequency values: "off", "daily", "weekly"
+	DigestFrequency string `gorm:"type:varchar(16);not null;default:'off'"`
+	LastDigestAt    *time.Time
 }
 
 type Notification struct {
@@ -44,10 +56,89 @@ type Notification struct {
 
 	// type examples: "team_invite"
 	Type string `gorm:"type:varchar(64);not null"`
-	// json payload
+	// json payload, see notify.Payload
 	Data string `gorm:"type:text;not null"`
+	// schema version of Data, see notify.TypeSpec
+	Version int `gorm:"not null;default:1"`
+	// the team_id of Data, if it has one, so the notification goes when the team is purged
+	TeamID *uint `gorm:"index"`
 
 	ReadAt *time.Time `gorm:"index"`
+	// archived notifications leave the inbox but are kept until deleted or pruned
+	ArchivedAt *time.Time `gorm:"index"`
+}
+
+// NotificationPreference is the set of channels a user picked for one notification type.
+// Types without a row use their defaults, see notify.Types.
+type NotificationPreference struct {
+	ID        uint `gorm:"primaryKey"`
+	UpdatedAt time.Time
+
+	UserID uint   `gorm:"uniqueIndex:idx_notification_preference;not null"`
+	User   *User  `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+	Type   string `gorm:"type:varchar(64);uniqueIndex:idx_notification_preference;not null"`
+
+	InApp  bool `gorm:"not null"`
+	Email  bool `gorm:"not null"`
+	Digest bool `gorm:"not null"`
+	Push   bool `gorm:"not null;default:false"`
+}
+
+// NotificationDigestItem is a notification waiting for the user's next digest email.
+type NotificationDigestItem struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	UserID uint  `gorm:"index;not null"`
+	User   *User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	Type string `gorm:"type:varchar(64);not null"`
+	// json payload and its version, as in Notification
+	Data    string `gorm:"type:text;not null"`
+	Version int    `gorm:"not null;default:1"`
+
+	SentAt *time.Time `gorm:"index"`
+}
+
+// PushSubscription is a browser that agreed to receive Web Push notifications for a user.
+type PushSubscription struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	UserID uint  `gorm:"index;not null"`
+	User   *User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	// the push service URL; it identifies the subscription
+	Endpoint string `gorm:"type:text;uniqueIndex;not null"`
+	// the browser's ECDH public key and auth secret, base64url as the browser reports them
+	P256dh    string `gorm:"type:varchar(128);not null"`
+	Auth      string `gorm:"type:varchar(64);not null"`
+	UserAgent string `gorm:"type:text"`
+
+	LastSuccessAt *time.Time
+	LastFailureAt *time.Time
+	Failures      int `gorm:"not null;default:0"`
+}
+
+// Setting holds the value of a registered setting that has no column of its own, for one
+// user or one team; see the settings package.
+type Setting struct {
+	ID        uint `gorm:"primaryKey"`
+	UpdatedAt time.Time
+
+	// scope values: "user", "team"; OwnerID is the user's or the team's ID
+	Scope   string `gorm:"type:varchar(16);not null;uniqueIndex:uniq_setting"`
+	OwnerID uint   `gorm:"not null;uniqueIndex:uniq_setting"`
+	Key     string `gorm:"type:varchar(64);not null;uniqueIndex:uniq_setting"`
+	// JSON encoded
+	Value string `gorm:"type:text;not null"`
+}
+
+// DataMigration marks a one-time data change as done, see runOnce.
+type DataMigration struct {
+	Name      string `gorm:"primaryKey;type:varchar(128)"`
+	AppliedAt time.Time
 }
 
 type PasswordCredential struct {
@@ -86,6 +177,9 @@ type Team struct {
 
 	Name string
 	Icon string `gorm:"type:varchar(64);default:''"`
+	// storage key of an uploaded logo, see images.TeamLogo; when set it is shown instead
+	// of the built-in Icon
+	LogoKey string `gorm:"type:varchar(191);default:''"`
 
 	Users []User `gorm:"many2many:user_teams;joinForeignKey:TeamID;joinReferences:UserID;constraint:OnDelete:CASCADE;"`
 
@@ -118,3 +212,205 @@ type TeamInvitation struct {
 	Status    string    `gorm:"type:varchar(32);not null;default:'pending'"`
 	ExpiresAt time.Time `gorm:"index"`
 }
+
+type LoginEvent struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+
+	// nil when the attempted email does not belong to any account
+	UserID *uint `gorm:"index"`
+	User   *User `gorm:"foreignKey:UserID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	Email string `gorm:"type:varchar(191);index"`
+	// method examples: "password", "oauth", "password_reset"
+	Method   string `gorm:"type:varchar(32);not null"`
+	Provider string `gorm:"type:varchar(32);default:''"`
+	Success  bool   `gorm:"not null;default:false"`
+	Reason   string `gorm:"type:varchar(128);default:''"`
+
+	IP         string `gorm:"type:varchar(64)"`
+	UserAgent  string `gorm:"type:text"`
+	Browser    string `gorm:"type:varchar(64)"`
+	OS         string `gorm:"type:varchar(64)"`
+	DeviceType string `gorm:"type:varchar(16)"`
+	DeviceHash string `gorm:"type:varchar(64);index"`
+	Country    string `gorm:"type:varchar(2)"`
+}
+
+type TeamInviteLink struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	TeamID uint  `gorm:"index;not null"`
+	Team   *Team `gorm:"foreignKey:TeamID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	CreatedByID uint `gorm:"index;not null"`
+
+	// sha256 of the link token; the raw token is only shown once at creation
+	TokenHash     string    `gorm:"type:varchar(64);uniqueIndex;not null"`
+	Role          string    `gorm:"type:varchar(32);not null;default:'regular'"`
+	AllowedDomain string    `gorm:"type:varchar(191);default:''"`
+	MaxUses       int       `gorm:"not null;default:0"`
+	Uses          int       `gorm:"not null;default:0"`
+	ExpiresAt     time.Time `gorm:"index"`
+	RevokedAt     *time.Time
+}
+
+// TeamRole is a custom role defined by a team in addition to the built-in ones.
+type TeamRole struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	TeamID uint  `gorm:"not null;uniqueIndex:uniq_team_role_name"`
+	Team   *Team `gorm:"foreignKey:TeamID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	Name        string   `gorm:"type:varchar(32);not null;uniqueIndex:uniq_team_role_name"`
+	Description string   `gorm:"type:varchar(255);default:''"`
+	Permissions []string `gorm:"type:text;serializer:json"`
+}
+
+// TeamOwnershipTransfer is an owner's offer to hand the team over to another member.
+// Ownership only changes once the recipient accepts.
+type TeamOwnershipTransfer struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	TeamID uint  `gorm:"index;not null"`
+	Team   *Team `gorm:"foreignKey:TeamID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	FromUserID uint `gorm:"index;not null"`
+	ToUserID   uint `gorm:"index;not null"`
+
+	// status values: "pending", "accepted", "declined", "cancelled"
+	Status      string    `gorm:"type:varchar(32);not null;default:'pending'"`
+	ExpiresAt   time.Time `gorm:"index"`
+	RespondedAt *time.Time
+}
+
+// TeamAuditEvent is an append-only record of a change made to a team. Rows are kept
+// without a foreign key so the history outlives the team itself.
+type TeamAuditEvent struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+
+	TeamID  uint  `gorm:"index;not null"`
+	ActorID *uint `gorm:"index"`
+
+	// action examples: "member.added", "invitation.revoked", "team.renamed"
+	Action      string `gorm:"type:varchar(64);index;not null"`
+	TargetType  string `gorm:"type:varchar(32);default:''"`
+	TargetID    *uint  `gorm:"index"`
+	TargetLabel string `gorm:"type:varchar(191);default:''"`
+
+	// json snapshots of the changed fields
+	Before string `gorm:"type:text;default:''"`
+	After  string `gorm:"type:text;default:''"`
+
+	IP        string `gorm:"type:varchar(64)"`
+	UserAgent string `gorm:"type:text"`
+}
+
+// WebhookEndpoint receives signed POSTs for the team events it subscribes to.
+type WebhookEndpoint struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+	UpdatedAt time.Time
+
+	TeamID uint  `gorm:"index;not null"`
+	Team   *Team `gorm:"foreignKey:TeamID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	CreatedByID uint `gorm:"not null"`
+
+	URL         string   `gorm:"type:text;not null"`
+	Description string   `gorm:"type:varchar(255);default:''"`
+	Events      []string `gorm:"type:text;serializer:json"`
+	// used as the HMAC key; only returned to the client when the endpoint is created
+	Secret string `gorm:"type:varchar(128);not null" json:"-"`
+	Active bool   `gorm:"not null;default:true"`
+}
+
+type WebhookDelivery struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+
+	EndpointID uint             `gorm:"index;not null"`
+	Endpoint   *WebhookEndpoint `gorm:"foreignKey:EndpointID;references:ID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE"`
+
+	// EventID is shared by redeliveries of the same event so receivers can deduplicate
+	EventID string `gorm:"type:varchar(64);index;not null"`
+	Event   string `gorm:"type:varchar(64);not null"`
+	Payload string `gorm:"type:text;not null"`
+
+	// status values: "pending", "succeeded", "failed"
+	Status        string     `gorm:"type:varchar(16);not null;default:'pending'"`
+	Attempts      int        `gorm:"not null;default:0"`
+	NextAttemptAt *time.Time `gorm:"index"`
+	LastAttemptAt *time.Time
+
+	ResponseStatus int    `gorm:"default:0"`
+	ResponseBody   string `gorm:"type:text;default:''"`
+	Error          string `gorm:"type:text;default:''"`
+	DurationMs     int64  `gorm:"default:0"`
+}
+
+// OutboxEvent is a domain event written in the same transaction as the change it
+// describes. The relay publishes it afterwards, at least once.
+type OutboxEvent struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+
+	EventID string `gorm:"type:varchar(64);uniqueIndex;not null"`
+	// type examples: "UserRegistered", "InvitationCreated"
+	Type    string `gorm:"type:varchar(64);index;not null"`
+	Payload string `gorm:"type:text;not null"`
+
+	// names of the publishers that already accepted the event, so a retry after a
+	// partial failure does not hand it to the same publisher twice
+	PublishedTo   []string   `gorm:"type:text;serializer:json"`
+	PublishedAt   *time.Time `gorm:"index"`
+	Attempts      int        `gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `gorm:"index"`
+	LastError     string     `gorm:"type:text;default:''"`
+	// set when the relay gave up; such events are not retried
+	FailedAt *time.Time `gorm:"index"`
+}
+
+// EmailMessage records an outbound email and what the provider later reported about it.
+type EmailMessage struct {
+	ID        uint      `gorm:"primaryKey"`
+	CreatedAt time.Time `gorm:"index"`
+	UpdatedAt time.Time
+
+	// stored lowercased
+	Recipient string `gorm:"type:varchar(320);index;not null"`
+	// type examples: "verification", "invitation"
+	Type      string `gorm:"type:varchar(32);index;not null"`
+	Subject   string `gorm:"type:text;default:''"`
+	Transport string `gorm:"type:varchar(32);not null"`
+	// the ID the transport returned; provider webhooks refer to messages by it
+	ProviderID string `gorm:"type:varchar(191);index;default:''"`
+
+	// status values: "queued", "sent", "failed", "delivered", "bounced", "complained", "suppressed"
+	Status      string `gorm:"type:varchar(16);index;not null;default:'queued'"`
+	Error       string `gorm:"type:text;default:''"`
+	SentAt      *time.Time
+	DeliveredAt *time.Time
+}
+
+// EmailSuppression stops further sends to an address that hard-bounced or complained.
+type EmailSuppression struct {
+	ID        uint `gorm:"primaryKey"`
+	CreatedAt time.Time
+
+	// stored lowercased
+	Email string `gorm:"type:varchar(320);uniqueIndex;not null"`
+	// reason values: "bounce", "complaint"
+	Reason    string `gorm:"type:varchar(16);not null"`
+	Detail    string `gorm:"type:text;default:''"`
+	MessageID *uint
+}
@@ -5,20 +5,76 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+type NotificationFilter struct {
+	UserID uint
+	// empty means any type
+	Types []string
+	// nil means read and unread
+	Read *bool
+	// archived notifications are listed only on their own
+	Archived bool
+	// BeforeID is the pagination cursor: only notifications older than it are returned
+	BeforeID uint
+	// zero means any time
+	CreatedAfter time.Time
+	Limit        int
+}
+
 type notificationsRepo struct{ db *gorm.DB }
 
 func (r *notificationsRepo) Create(ctx context.Context, n *Notification) error {
 	return r.db.WithContext(ctx).Create(n).Error
 }
 
-func (r *notificationsRepo) ListForUser(ctx context.Context, userID uint) ([]Notification, error) {
+func (r *notificationsRepo) ByID(ctx context.Context, userID, id uint) (*Notification, error) {
+	var n Notification
+	if err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userID).First(&n).Error; err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+func (r *notificationsRepo) List(ctx context.Context, f NotificationFilter) ([]Notification, error) {
+	q := r.db.WithContext(ctx).Where("user_id = ?", f.UserID)
+	if len(f.Types) > 0 {
+		q = q.Where("type IN ?", f.Types)
+	}
+	if f.Read != nil {
+		if *f.Read {
+			q = q.Where("read_at IS NOT NULL")
+		} else {
+			q = q.Where("read_at IS NULL")
+		}
+	}
+	if f.Archived {
+		q = q.Where("archived_at IS NOT NULL")
+	} else {
+		q = q.Where("archived_at IS NULL")
+	}
+	if f.BeforeID != 0 {
+		q = q.Where("id < ?", f.BeforeID)
+	}
+	if !f.CreatedAfter.IsZero() {
+		q = q.Where("created_at > ?", f.CreatedAfter)
+	}
+
 	var list []Notification
-	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("created_at DESC").Find(&list).Error
+	err := q.Order("id DESC").Limit(f.Limit).Find(&list).Error
 	return list, err
 }
 
+func (r *notificationsRepo) CountUnread(ctx context.Context, userID uint) (int64, error) {
+	var n int64
+	err := r.db.WithContext(ctx).
+		Model(&Notification{}).
+		Where("user_id = ? AND read_at IS NULL AND archived_at IS NULL", userID).
+		Count(&n).Error
+	return n, err
+}
+
 func (r *notificationsRepo) MarkRead(ctx context.Context, id uint) error {
 	now := time.Now()
 	return r.db.WithContext(ctx).
@@ -29,3 +85,87 @@ func (r *notificationsRepo) MarkRead(ctx context.Context, id uint) error {
 			"updated_at": now,
 		}).Error
 }
+
+// MarkReadMany marks the given unread notifications of userID read, or all of them when
+// ids is empty, and returns the IDs that changed.
+func (r *notificationsRepo) MarkReadMany(ctx context.Context, userID uint, ids []uint) ([]uint, error) {
+	q := r.db.WithContext(ctx).Where("user_id = ? AND read_at IS NULL", userID)
+	if len(ids) > 0 {
+		q = q.Where("id IN ?", ids)
+	}
+	now := time.Now()
+	return updatedIDs(q, map[string]any{"read_at": &now, "updated_at": now})
+}
+
+// SetArchived archives or restores the given notifications of userID and returns the IDs
+// that changed.
+func (r *notificationsRepo) SetArchived(ctx context.Context, userID uint, ids []uint, archived bool) ([]uint, error) {
+	q := r.db.WithContext(ctx).Where("user_id = ? AND id IN ?", userID, ids)
+	now := time.Now()
+	values := map[string]any{"updated_at": now}
+	if archived {
+		q = q.Where("archived_at IS NULL")
+		values["archived_at"] = &now
+	} else {
+		q = q.Where("archived_at IS NOT NULL")
+		values["archived_at"] = nil
+	}
+	return updatedIDs(q, values)
+}
+
+// Delete removes the given notifications of userID and returns the IDs that were deleted.
+func (r *notificationsRepo) Delete(ctx context.Context, userID uint, ids []uint) ([]uint, error) {
+	var deleted []Notification
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Where("user_id = ? AND id IN ?", userID, ids).
+		Delete(&deleted).Error
+	return notificationIDs(deleted), err
+}
+
+// DeleteReadBefore removes notifications that were read before cutoff.
+func (r *notificationsRepo) DeleteReadBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("read_at < ?", cutoff).Delete(&Notification{})
+	return res.RowsAffected, res.Error
+}
+
+func (r *notificationsRepo) AddDigestItem(ctx context.Context, item *NotificationDigestItem) error {
+	return r.db.WithContext(ctx).Create(item).Error
+}
+
+// PendingDigestItems returns the items waiting for userID's next digest, oldest first.
+func (r *notificationsRepo) PendingDigestItems(ctx context.Context, userID uint, limit int) ([]NotificationDigestItem, error) {
+	var list []NotificationDigestItem
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND sent_at IS NULL", userID).
+		Order("id").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// MarkDigestSent marks userID's pending items up to and including lastID as sent.
+func (r *notificationsRepo) MarkDigestSent(ctx context.Context, userID, lastID uint, at time.Time) error {
+	return r.db.WithContext(ctx).Model(&NotificationDigestItem{}).
+		Where("user_id = ? AND id <= ? AND sent_at IS NULL", userID, lastID).
+		Update("sent_at", at).Error
+}
+
+func (r *notificationsRepo) DeleteDigestItemsSentBefore(ctx context.Context, cutoff time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).Where("sent_at < ?", cutoff).Delete(&NotificationDigestItem{})
+	return res.RowsAffected, res.Error
+}
+
+func updatedIDs(q *gorm.DB, values map[string]any) ([]uint, error) {
+	var updated []Notification
+	err := q.Model(&updated).
+		Clauses(clause.Returning{Columns: []clause.Column{{Name: "id"}}}).
+		Updates(values).Error
+	return notificationIDs(updated), err
+}
+
+func notificationIDs(list []Notification) []uint {
+	ids := make([]uint, 0, len(list))
+	for _, n := range list {
+		ids = append(ids, n.ID)
+	}
+	return ids
+}
@@ -0,0 +1,54 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type outboxRepo struct{ db *gorm.DB }
+
+func (r *outboxRepo) Add(ctx context.Context, e *OutboxEvent) error {
+	return r.db.WithContext(ctx).Create(e).Error
+}
+
+// ClaimDue leases unpublished events whose attempt time has come, oldest first. Like
+// webhook deliveries, SKIP LOCKED lets several relays run side by side.
+func (r *outboxRepo) ClaimDue(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]OutboxEvent, error) {
+	var list []OutboxEvent
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", now).
+			Order("id ASC").
+			Limit(limit).
+			Find(&list).Error; err != nil {
+			return err
+		}
+		if len(list) == 0 {
+			return nil
+		}
+		ids := make([]uint, 0, len(list))
+		for _, e := range list {
+			ids = append(ids, e.ID)
+		}
+		return tx.Model(&OutboxEvent{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	return list, err
+}
+
+func (r *outboxRepo) Save(ctx context.Context, e *OutboxEvent) error {
+	return r.db.WithContext(ctx).Save(e).Error
+}
+
+// DeleteFinishedBefore trims the table; published and failed events are only kept for
+// inspection.
+func (r *outboxRepo) DeleteFinishedBefore(ctx context.Context, published, failed time.Time) (int64, error) {
+	res := r.db.WithContext(ctx).
+		Where("(published_at IS NOT NULL AND published_at < ?) OR (failed_at IS NOT NULL AND failed_at < ?)", published, failed).
+		Delete(&OutboxEvent{})
+	return res.RowsAffected, res.Error
+}
@@ -3,8 +3,10 @@ package db
 import (
 	"context"
 	"strings"
+	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 // type UserPreferencesRepo interface {
@@ -15,7 +17,7 @@ import (
 type preferencesRepo struct{ db *gorm.DB }
 
 func (p *preferencesRepo) Create(ctx context.Context, userID uint) error {
-	preference := UserPreference{UserID: userID, Theme: "system", Language: "en"}
+	preference := UserPreference{UserID: userID, Theme: "system", Language: "en", Timezone: "UTC", DigestFrequency: "off"}
 	return p.db.WithContext(ctx).Create(&preference).Error
 }
 
@@ -52,3 +54,74 @@ func (p *preferencesRepo) GetByEmail(ctx context.Context, userEmail string) (*Us
 func (p *preferencesRepo) Update(ctx context.Context, preference *UserPreference) error {
 	return p.db.WithContext(ctx).Save(preference).Error
 }
+
+func (p *preferencesRepo) NotificationChannels(ctx context.Context, userID uint) ([]NotificationPreference, error) {
+	var list []NotificationPreference
+	err := p.db.WithContext(ctx).Where("user_id = ?", userID).Order("type").Find(&list).Error
+	return list, err
+}
+
+func (p *preferencesRepo) NotificationChannel(ctx context.Context, userID uint, notificationType string) (*NotificationPreference, error) {
+	var pref NotificationPreference
+	err := p.db.WithContext(ctx).Where("user_id = ? AND type = ?", userID, notificationType).First(&pref).Error
+	if err != nil {
+		return nil, err
+	}
+	return &pref, nil
+}
+
+// SetNotificationChannels stores prefs, replacing the user's earlier choice for each type.
+func (p *preferencesRepo) SetNotificationChannels(ctx context.Context, prefs []NotificationPreference) error {
+	if len(prefs) == 0 {
+		return nil
+	}
+	return p.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+			DoUpdates: clause.AssignmentColumns([]string{"in_app", "email", "digest", "push", "updated_at"}),
+		}).
+		Create(&prefs).Error
+}
+
+// DigestZone is a timezone and digest frequency, see DigestZones.
+type DigestZone struct {
+	Timezone        string
+	DigestFrequency string
+}
+
+// DigestZones lists the timezone and frequency pairs of users who get digests; each pair
+// shares the time its digests are due at.
+func (p *preferencesRepo) DigestZones(ctx context.Context) ([]DigestZone, error) {
+	var list []DigestZone
+	err := p.db.WithContext(ctx).Model(&UserPreference{}).
+		Distinct("timezone", "digest_frequency").
+		Where("digest_frequency <> ?", "off").
+		Find(&list).Error
+	return list, err
+}
+
+// DueDigests pages through the preferences in zone, by ID, whose digest due at slot has
+// not been sent. Users who never had a digest are included only when firstDigests is
+// set; deleted accounts are left out.
+func (p *preferencesRepo) DueDigests(ctx context.Context, zone DigestZone, slot time.Time, firstDigests bool, afterID uint, limit int) ([]UserPreference, error) {
+	q := p.db.WithContext(ctx).
+		Where("id > ? AND timezone = ? AND digest_frequency = ?", afterID, zone.Timezone, zone.DigestFrequency).
+		Where("EXISTS (SELECT 1 FROM users WHERE users.id = user_preferences.user_id AND users.deleted_at IS NULL)")
+	if firstDigests {
+		q = q.Where("(last_digest_at IS NULL OR last_digest_at < ?)", slot)
+	} else {
+		q = q.Where("last_digest_at < ?", slot)
+	}
+	var list []UserPreference
+	err := q.Order("id").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// ClaimDigest records that the digest due at slot is being sent. It reports false when
+// it already was, so each digest goes out once even if the job runs twice.
+func (p *preferencesRepo) ClaimDigest(ctx context.Context, userID uint, slot, now time.Time) (bool, error) {
+	res := p.db.WithContext(ctx).Model(&UserPreference{}).
+		Where("user_id = ? AND (last_digest_at IS NULL OR last_digest_at < ?)", userID, slot).
+		Update("last_digest_at", now)
+	return res.RowsAffected == 1, res.Error
+}
@@ -0,0 +1,46 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type pushSubscriptionsRepo struct{ db *gorm.DB }
+
+// Save registers sub, or moves an endpoint the browser already registered to sub's user
+// and keys, since browsers reuse endpoints across sign-ins.
+func (r *pushSubscriptionsRepo) Save(ctx context.Context, sub *PushSubscription) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "endpoint"}},
+			DoUpdates: clause.AssignmentColumns([]string{"user_id", "p256dh", "auth", "user_agent", "updated_at"}),
+		}).
+		Create(sub).Error
+}
+
+func (r *pushSubscriptionsRepo) ListByUser(ctx context.Context, userID uint) ([]PushSubscription, error) {
+	var list []PushSubscription
+	err := r.db.WithContext(ctx).Where("user_id = ?", userID).Order("id").Find(&list).Error
+	return list, err
+}
+
+func (r *pushSubscriptionsRepo) Delete(ctx context.Context, userID uint, endpoint string) (bool, error) {
+	res := r.db.WithContext(ctx).Where("user_id = ? AND endpoint = ?", userID, endpoint).Delete(&PushSubscription{})
+	return res.RowsAffected > 0, res.Error
+}
+
+func (r *pushSubscriptionsRepo) DeleteByID(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Delete(&PushSubscription{}, id).Error
+}
+
+// RecordResult notes the outcome of a delivery; failures count up until one succeeds.
+func (r *pushSubscriptionsRepo) RecordResult(ctx context.Context, id uint, ok bool, at time.Time) error {
+	values := map[string]any{"last_success_at": at, "failures": 0}
+	if !ok {
+		values = map[string]any{"last_failure_at": at, "failures": gorm.Expr("failures + 1")}
+	}
+	return r.db.WithContext(ctx).Model(&PushSubscription{}).Where("id = ?", id).Updates(values).Error
+}
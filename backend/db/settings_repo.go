@@ -0,0 +1,68 @@
+package db
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type settingsRepo struct{ db *gorm.DB }
+
+// settingTables says where each scope keeps the settings that have a column of their own.
+var settingTables = map[string]struct{ table, key string }{
+	"user": {table: "user_preferences", key: "user_id"},
+	"team": {table: "teams", key: "id"},
+}
+
+func (r *settingsRepo) List(ctx context.Context, scope string, ownerID uint) ([]Setting, error) {
+	var list []Setting
+	err := r.db.WithContext(ctx).Where("scope = ? AND owner_id = ?", scope, ownerID).Order("key").Find(&list).Error
+	return list, err
+}
+
+// Save stores list, replacing earlier values of the same settings.
+func (r *settingsRepo) Save(ctx context.Context, list []Setting) error {
+	if len(list) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "scope"}, {Name: "owner_id"}, {Name: "key"}},
+			DoUpdates: clause.AssignmentColumns([]string{"value", "updated_at"}),
+		}).
+		Create(&list).Error
+}
+
+func (r *settingsRepo) Delete(ctx context.Context, scope string, ownerID uint, keys []string) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Where("scope = ? AND owner_id = ? AND key IN ?", scope, ownerID, keys).Delete(&Setting{}).Error
+}
+
+// Columns reads the given columns of ownerID's row in the table of scope.
+func (r *settingsRepo) Columns(ctx context.Context, scope string, ownerID uint, columns []string) (map[string]any, error) {
+	values := map[string]any{}
+	if len(columns) == 0 {
+		return values, nil
+	}
+	t, ok := settingTables[scope]
+	if !ok {
+		return nil, fmt.Errorf("unknown settings scope %q", scope)
+	}
+	err := r.db.WithContext(ctx).Table(t.table).Select(columns).Where(t.key+" = ?", ownerID).Take(&values).Error
+	return values, err
+}
+
+func (r *settingsRepo) SetColumns(ctx context.Context, scope string, ownerID uint, values map[string]any) error {
+	if len(values) == 0 {
+		return nil
+	}
+	t, ok := settingTables[scope]
+	if !ok {
+		return fmt.Errorf("unknown settings scope %q", scope)
+	}
+	return r.db.WithContext(ctx).Table(t.table).Where(t.key+" = ?", ownerID).Updates(values).Error
+}
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"context"
+
+	"gorm.io/gorm"
+)
+
+type teamRolesRepo struct{ db *gorm.DB }
+
+func (r *teamRolesRepo) Create(ctx context.Context, role *TeamRole) error {
+	return r.db.WithContext(ctx).Create(role).Error
+}
+
+func (r *teamRolesRepo) ByID(ctx context.Context, teamID, id uint) (*TeamRole, error) {
+	var role TeamRole
+	err := r.db.WithContext(ctx).Where("team_id = ? AND id = ?", teamID, id).First(&role).Error
+	return &role, err
+}
+
+func (r *teamRolesRepo) ByName(ctx context.Context, teamID uint, name string) (*TeamRole, error) {
+	var role TeamRole
+	err := r.db.WithContext(ctx).Where("team_id = ? AND name = ?", teamID, name).First(&role).Error
+	return &role, err
+}
+
+func (r *teamRolesRepo) ListByTeam(ctx context.Context, teamID uint) ([]TeamRole, error) {
+	var list []TeamRole
+	err := r.db.WithContext(ctx).Where("team_id = ?", teamID).Order("name ASC").Find(&list).Error
+	return list, err
+}
+
+func (r *teamRolesRepo) Update(ctx context.Context, role *TeamRole) error {
+	return r.db.WithContext(ctx).Save(role).Error
+}
+
+func (r *teamRolesRepo) Delete(ctx context.Context, role *TeamRole) error {
+	return r.db.WithContext(ctx).Delete(role).Error
+}
+
+func (r *teamRolesRepo) CountMembers(ctx context.Context, teamID uint, name string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&UserTeam{}).Where("team_id = ? AND role = ?", teamID, name).Count(&count).Error
+	return count, err
+}
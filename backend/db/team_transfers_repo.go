@@ -0,0 +1,51 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type teamTransfersRepo struct{ db *gorm.DB }
+
+func (r *teamTransfersRepo) Create(ctx context.Context, t *TeamOwnershipTransfer) error {
+	return r.db.WithContext(ctx).Create(t).Error
+}
+
+// PendingForTeam returns the open, unexpired transfer for the team, if any.
+func (r *teamTransfersRepo) PendingForTeam(ctx context.Context, teamID uint) (*TeamOwnershipTransfer, error) {
+	var t TeamOwnershipTransfer
+	err := r.db.WithContext(ctx).
+		Where("team_id = ? AND status = ? AND expires_at > ?", teamID, "pending", time.Now()).
+		Order("created_at DESC").
+		First(&t).Error
+	return &t, err
+}
+
+// Resolve moves a pending transfer to its final status. It reports false when the transfer
+// was already resolved, so a transfer can only be accepted once.
+func (r *teamTransfersRepo) Resolve(ctx context.Context, id uint, status string) (bool, error) {
+	now := time.Now()
+	res := r.db.WithContext(ctx).
+		Model(&TeamOwnershipTransfer{}).
+		Where("id = ? AND status = ?", id, "pending").
+		Updates(map[string]any{
+			"status":       status,
+			"responded_at": &now,
+			"updated_at":   now,
+		})
+	return res.RowsAffected == 1, res.Error
+}
+
+func (r *teamTransfersRepo) CancelPendingForTeam(ctx context.Context, teamID uint) error {
+	now := time.Now()
+	return r.db.WithContext(ctx).
+		Model(&TeamOwnershipTransfer{}).
+		Where("team_id = ? AND status = ?", teamID, "pending").
+		Updates(map[string]any{
+			"status":       "cancelled",
+			"responded_at": &now,
+			"updated_at":   now,
+		}).Error
+}
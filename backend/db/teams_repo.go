@@ -2,6 +2,7 @@ package db
 
 import (
 	"context"
+	"time"
 
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
@@ -22,6 +23,16 @@ func (r *teamsRepo) ByID(ctx context.Context, id uint) (*Team, error) {
 	return &team, err
 }
 
+// LockByID loads a team and locks its row until the transaction ends, so changes that
+// depend on its owner cannot interleave. Only useful inside WithTx.
+func (r *teamsRepo) LockByID(ctx context.Context, id uint) (*Team, error) {
+	var team Team
+	err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		First(&team, id).Error
+	return &team, err
+}
+
 func (r *teamsRepo) AddMember(ctx context.Context, teamID, userID uint, role string) error {
 	uw := UserTeam{UserID: userID, TeamID: teamID, Role: role}
 
@@ -85,3 +96,75 @@ func (r *teamsRepo) Update(ctx context.Context, t *Team) error {
 func (r *teamsRepo) Delete(ctx context.Context, t *Team) error {
 	return r.db.WithContext(ctx).Delete(t).Error
 }
+
+// DeletedByID returns a soft-deleted team; live teams are not found.
+func (r *teamsRepo) DeletedByID(ctx context.Context, id uint) (*Team, error) {
+	var team Team
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL").
+		First(&team, id).Error
+	return &team, err
+}
+
+func (r *teamsRepo) ListDeletedOwnedBy(ctx context.Context, ownerID uint, since time.Time) ([]Team, error) {
+	var teams []Team
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("owner_id = ? AND deleted_at IS NOT NULL AND deleted_at > ?", ownerID, since).
+		Order("deleted_at DESC").
+		Find(&teams).Error
+	return teams, err
+}
+
+func (r *teamsRepo) Restore(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Unscoped().
+		Model(&Team{}).
+		Where("id = ? AND deleted_at IS NOT NULL", id).
+		Updates(map[string]any{
+			"deleted_at": nil,
+			"updated_at": time.Now(),
+		}).Error
+}
+
+func (r *teamsRepo) ListDeletedBefore(ctx context.Context, before time.Time, limit int) ([]Team, error) {
+	var teams []Team
+	err := r.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at <= ?", before).
+		Order("deleted_at ASC").
+		Limit(limit).
+		Find(&teams).Error
+	return teams, err
+}
+
+// Purge hard-deletes a team and everything that only makes sense with it, including
+// notifications whose payload points at the team and the team's settings. Run it inside a transaction.
+func (r *teamsRepo) Purge(ctx context.Context, id uint) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Where("team_id = ?", id).Delete(&UserTeam{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("team_id = ?", id).Delete(&TeamInvitation{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("team_id = ?", id).Delete(&TeamInviteLink{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("team_id = ?", id).Delete(&TeamRole{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("team_id = ?", id).Delete(&TeamOwnershipTransfer{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("endpoint_id IN (?)", db.Model(&WebhookEndpoint{}).Select("id").Where("team_id = ?", id)).Delete(&WebhookDelivery{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("team_id = ?", id).Delete(&WebhookEndpoint{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("team_id = ?", id).Delete(&Notification{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("scope = ? AND owner_id = ?", "team", id).Delete(&Setting{}).Error; err != nil {
+		return err
+	}
+	return db.Unscoped().Delete(&Team{}, id).Error
+}
@@ -34,6 +34,19 @@ func (r *usersRepo) ByEmail(ctx context.Context, email string) (*User, error) {
 	return &u, err
 }
 
+func (r *usersRepo) ListByEmails(ctx context.Context, emails []string) ([]User, error) {
+	var list []User
+	if len(emails) == 0 {
+		return list, nil
+	}
+	lowered := make([]string, 0, len(emails))
+	for _, e := range emails {
+		lowered = append(lowered, strings.ToLower(e))
+	}
+	err := r.db.WithContext(ctx).Where("LOWER(email) IN ?", lowered).Find(&list).Error
+	return list, err
+}
+
 func (r *usersRepo) Update(ctx context.Context, u *User) error {
 	return r.db.WithContext(ctx).Save(u).Error
 }
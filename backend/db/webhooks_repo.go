@@ -0,0 +1,102 @@
+package db
+
+import (
+	"context"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type webhooksRepo struct{ db *gorm.DB }
+
+func (r *webhooksRepo) CreateEndpoint(ctx context.Context, e *WebhookEndpoint) error {
+	return r.db.WithContext(ctx).Create(e).Error
+}
+
+func (r *webhooksRepo) EndpointByID(ctx context.Context, teamID, id uint) (*WebhookEndpoint, error) {
+	var e WebhookEndpoint
+	err := r.db.WithContext(ctx).Where("team_id = ? AND id = ?", teamID, id).First(&e).Error
+	return &e, err
+}
+
+func (r *webhooksRepo) ListEndpoints(ctx context.Context, teamID uint) ([]WebhookEndpoint, error) {
+	var list []WebhookEndpoint
+	err := r.db.WithContext(ctx).Where("team_id = ?", teamID).Order("created_at ASC").Find(&list).Error
+	return list, err
+}
+
+func (r *webhooksRepo) ActiveEndpoints(ctx context.Context, teamID uint) ([]WebhookEndpoint, error) {
+	var list []WebhookEndpoint
+	err := r.db.WithContext(ctx).Where("team_id = ? AND active = ?", teamID, true).Find(&list).Error
+	return list, err
+}
+
+func (r *webhooksRepo) UpdateEndpoint(ctx context.Context, e *WebhookEndpoint) error {
+	return r.db.WithContext(ctx).Save(e).Error
+}
+
+func (r *webhooksRepo) DeleteEndpoint(ctx context.Context, e *WebhookEndpoint) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Where("endpoint_id = ?", e.ID).Delete(&WebhookDelivery{}).Error; err != nil {
+		return err
+	}
+	return db.Delete(e).Error
+}
+
+func (r *webhooksRepo) CreateDeliveries(ctx context.Context, list []WebhookDelivery) error {
+	if len(list) == 0 {
+		return nil
+	}
+	return r.db.WithContext(ctx).Create(&list).Error
+}
+
+func (r *webhooksRepo) DeliveryByID(ctx context.Context, endpointID, id uint) (*WebhookDelivery, error) {
+	var d WebhookDelivery
+	err := r.db.WithContext(ctx).Where("endpoint_id = ? AND id = ?", endpointID, id).First(&d).Error
+	return &d, err
+}
+
+// ListDeliveries pages backwards from beforeID; pass 0 for the newest deliveries.
+func (r *webhooksRepo) ListDeliveries(ctx context.Context, endpointID uint, beforeID uint, limit int) ([]WebhookDelivery, error) {
+	q := r.db.WithContext(ctx).Where("endpoint_id = ?", endpointID)
+	if beforeID != 0 {
+		q = q.Where("id < ?", beforeID)
+	}
+	var list []WebhookDelivery
+	err := q.Order("id DESC").Limit(limit).Find(&list).Error
+	return list, err
+}
+
+// ClaimDueDeliveries leases pending deliveries whose attempt time has come by pushing their
+// next attempt out by lease. SKIP LOCKED keeps several workers from claiming the same rows.
+func (r *webhooksRepo) ClaimDueDeliveries(ctx context.Context, now time.Time, lease time.Duration, limit int) ([]WebhookDelivery, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&WebhookDelivery{}).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", "pending", now).
+			Order("next_attempt_at ASC").
+			Limit(limit).
+			Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		return tx.Model(&WebhookDelivery{}).
+			Where("id IN ?", ids).
+			Update("next_attempt_at", now.Add(lease)).Error
+	})
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	var list []WebhookDelivery
+	err = r.db.WithContext(ctx).Preload("Endpoint").Where("id IN ?", ids).Find(&list).Error
+	return list, err
+}
+
+func (r *webhooksRepo) UpdateDelivery(ctx context.Context, d *WebhookDelivery) error {
+	return r.db.WithContext(ctx).Omit("Endpoint").Save(d).Error
+}
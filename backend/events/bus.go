@@ -0,0 +1,98 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+type Handler func(ctx context.Context, e Event) error
+
+// Publisher hands an event to a transport. Publishing the same event twice must be
+// harmless: the relay retries until every publisher has accepted it.
+type Publisher interface {
+	Name() string
+	Publish(ctx context.Context, e Event) error
+}
+
+// Fanout is a publisher that hands each event to several independent targets. The relay
+// records every target that accepted an event and retries only the others.
+type Fanout interface {
+	Publisher
+	Targets(eventType string) []string
+	PublishTo(ctx context.Context, e Event, target string) error
+}
+
+// Bus is the in-process publisher. It calls every subscriber of the event's type in
+// registration order. The relay records each subscriber that handled an event, so when
+// one fails only that one sees the event again; a subscriber can still see an event twice
+// if the relay stops before recording it, and must tolerate that.
+type Bus struct {
+	mu          sync.RWMutex
+	subscribers map[string][]subscriber
+}
+
+type subscriber struct {
+	name    string
+	handler Handler
+}
+
+func NewBus() *Bus {
+	return &Bus{subscribers: make(map[string][]subscriber)}
+}
+
+// Subscribe registers h for eventType under name, which identifies it in the outbox and
+// must stay the same across releases and be unique per event type.
+func (b *Bus) Subscribe(eventType, name string, h Handler) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, s := range b.subscribers[eventType] {
+		if s.name == name {
+			panic(fmt.Sprintf("events: %s already has a subscriber named %q", eventType, name))
+		}
+	}
+	b.subscribers[eventType] = append(b.subscribers[eventType], subscriber{name: name, handler: h})
+}
+
+func (b *Bus) Name() string { return "inprocess" }
+
+func (b *Bus) Publish(ctx context.Context, e Event) error {
+	var errs []error
+	for _, name := range b.Targets(e.Type) {
+		if err := b.PublishTo(ctx, e, name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Targets names the subscribers of eventType.
+func (b *Bus) Targets(eventType string) []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	names := make([]string, 0, len(b.subscribers[eventType]))
+	for _, s := range b.subscribers[eventType] {
+		names = append(names, s.name)
+	}
+	return names
+}
+
+// PublishTo hands e to the subscriber registered under name.
+func (b *Bus) PublishTo(ctx context.Context, e Event, name string) error {
+	b.mu.RLock()
+	var h Handler
+	for _, s := range b.subscribers[e.Type] {
+		if s.name == name {
+			h = s.handler
+		}
+	}
+	b.mu.RUnlock()
+	if h == nil {
+		return nil
+	}
+	if err := h(ctx, e); err != nil {
+		return fmt.Errorf("%s subscriber %s: %w", e.Type, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,109 @@
+package events
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+)
+
+const (
+	UserRegistered      = "UserRegistered"
+	EmailVerified       = "EmailVerified"
+	MemberAdded         = "MemberAdded"
+	MemberRemoved       = "MemberRemoved"
+	InvitationCreated   = "InvitationCreated"
+	NotificationCreated = "NotificationCreated"
+	// a notification is due on the email channel
+	NotificationEmailRequested = "NotificationEmailRequested"
+	// a notification is due on the Web Push channel
+	NotificationPushRequested = "NotificationPushRequested"
+)
+
+// Event is what publishers and subscribers receive. Payload is the JSON the event was
+// emitted with; subscribers decode it into the struct they expect.
+type Event struct {
+	ID         string          `json:"id"`
+	Type       string          `json:"type"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Payload    json.RawMessage `json:"payload"`
+}
+
+type UserRegisteredPayload struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+	Method string `json:"method"`
+}
+
+type EmailVerifiedPayload struct {
+	UserID uint   `json:"user_id"`
+	Email  string `json:"email"`
+}
+
+type MemberPayload struct {
+	TeamID  uint   `json:"team_id"`
+	UserID  uint   `json:"user_id"`
+	Role    string `json:"role"`
+	ActorID uint   `json:"actor_id,omitempty"`
+}
+
+type InvitationCreatedPayload struct {
+	InvitationID uint   `json:"invitation_id"`
+	TeamID       uint   `json:"team_id"`
+	InviterID    uint   `json:"inviter_id"`
+	Email        string `json:"email"`
+	Role         string `json:"role"`
+}
+
+type NotificationCreatedPayload struct {
+	NotificationID uint   `json:"notification_id"`
+	UserID         uint   `json:"user_id"`
+	Type           string `json:"type"`
+}
+
+type NotificationEmailRequestedPayload struct {
+	UserID  uint            `json:"user_id"`
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+	// overrides the user's current address, e.g. to warn the old one after a change
+	Email string `json:"email,omitempty"`
+}
+
+type NotificationPushRequestedPayload struct {
+	UserID  uint            `json:"user_id"`
+	Type    string          `json:"type"`
+	Version int             `json:"version"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// Emit writes an event to the outbox. Call it with the transaction's connection so the
+// event exists exactly when the change it describes commits.
+func Emit(ctx context.Context, tx *db.Connection, eventType string, payload any) error {
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	return tx.Outbox.Add(ctx, &db.OutboxEvent{
+		EventID:       newEventID(),
+		Type:          eventType,
+		Payload:       string(b),
+		NextAttemptAt: time.Now(),
+	})
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Decode unmarshals the payload of e into v.
+func Decode[T any](e Event) (T, error) {
+	var v T
+	err := json.Unmarshal(e.Payload, &v)
+	return v, err
+}
@@ -0,0 +1,142 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"math"
+	"slices"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+)
+
+const (
+	relayBatch        = 50
+	relayPollInterval = 2 * time.Second
+	relayLease        = time.Minute
+	relayMaxBackoff   = 30 * time.Minute
+	// about eight hours of retries; an event failing for that long will not succeed on its own
+	relayMaxAttempts = 25
+	publishedKeep    = 7 * 24 * time.Hour
+	failedKeep       = 30 * 24 * time.Hour
+)
+
+// Relay moves events from the outbox to the publishers. An event is marked published
+// once every publisher has accepted it; until then it is retried with backoff, and after
+// relayMaxAttempts it is marked failed and left for inspection.
+type Relay struct {
+	conn       *db.Connection
+	logger     logger.MultiLogger
+	publishers []Publisher
+}
+
+func NewRelay(conn *db.Connection, logger logger.MultiLogger, publishers ...Publisher) *Relay {
+	return &Relay{conn: conn, logger: logger, publishers: publishers}
+}
+
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(relayPollInterval)
+	defer ticker.Stop()
+	lastCleanup := time.Time{}
+
+	for {
+		r.relayDue(ctx)
+		if time.Since(lastCleanup) > time.Hour {
+			if n, err := r.conn.Outbox.DeleteFinishedBefore(ctx, time.Now().Add(-publishedKeep), time.Now().Add(-failedKeep)); err != nil && ctx.Err() == nil {
+				r.logger.Warn("failed to trim outbox", "error", err)
+			} else if n > 0 {
+				r.logger.Info("trimmed outbox", "deleted", n)
+			}
+			lastCleanup = time.Now()
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (r *Relay) relayDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		batch, err := r.conn.Outbox.ClaimDue(ctx, time.Now(), relayLease, relayBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				r.logger.Error("failed to claim outbox events", "error", err)
+			}
+			return
+		}
+		for i := range batch {
+			r.publish(ctx, &batch[i])
+		}
+		if len(batch) < relayBatch {
+			return
+		}
+	}
+}
+
+func (r *Relay) publish(ctx context.Context, row *db.OutboxEvent) {
+	e := Event{
+		ID:         row.EventID,
+		Type:       row.Type,
+		OccurredAt: row.CreatedAt,
+		Payload:    json.RawMessage(row.Payload),
+	}
+
+	var errs []error
+	for _, p := range r.publishers {
+		if slices.Contains(row.PublishedTo, p.Name()) {
+			continue
+		}
+		f, ok := p.(Fanout)
+		if !ok {
+			if err := p.Publish(ctx, e); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			row.PublishedTo = append(row.PublishedTo, p.Name())
+			continue
+		}
+		for _, target := range f.Targets(e.Type) {
+			key := p.Name() + ":" + target
+			if slices.Contains(row.PublishedTo, key) {
+				continue
+			}
+			if err := f.PublishTo(ctx, e, target); err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			row.PublishedTo = append(row.PublishedTo, key)
+		}
+	}
+
+	row.Attempts++
+	if err := errors.Join(errs...); err != nil {
+		row.LastError = err.Error()
+		if row.Attempts >= relayMaxAttempts {
+			now := time.Now()
+			row.FailedAt = &now
+			r.logger.Error("giving up on outbox event", "error", err, "event_id", row.EventID, "type", row.Type, "attempts", row.Attempts)
+		} else {
+			row.NextAttemptAt = time.Now().Add(backoff(row.Attempts))
+			r.logger.Warn("failed to publish outbox event", "error", err, "event_id", row.EventID, "type", row.Type, "attempts", row.Attempts)
+		}
+	} else {
+		now := time.Now()
+		row.PublishedAt = &now
+		row.LastError = ""
+	}
+	if err := r.conn.Outbox.Save(ctx, row); err != nil {
+		r.logger.Error("failed to update outbox event", "error", err, "event_id", row.EventID)
+	}
+}
+
+func backoff(attempts int) time.Duration {
+	d := time.Duration(float64(time.Second) * math.Pow(2, float64(attempts)))
+	if d > relayMaxBackoff || d <= 0 {
+		return relayMaxBackoff
+	}
+	return d
+}
@@ -0,0 +1,155 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+var (
+	ErrUnsupported = errors.New("only JPEG, PNG and GIF images are accepted")
+	ErrTooLarge    = errors.New("image dimensions are too large")
+)
+
+// maxPixels bounds what gets decoded, so a small file cannot claim a huge canvas. Each
+// RGBA copy of an image takes 4 bytes a pixel and processing holds about three.
+const maxPixels = 16_000_000
+
+// maxConcurrent bounds how many uploads are decoded at once, and with it the memory all
+// of them take together.
+const maxConcurrent = 4
+
+var slots = make(chan struct{}, maxConcurrent)
+
+type Format string
+
+const (
+	JPEG Format = "jpeg"
+	PNG  Format = "png"
+)
+
+func (f Format) Ext() string { return map[Format]string{JPEG: "jpg", PNG: "png"}[f] }
+
+func (f Format) ContentType() string { return "image/" + string(f) }
+
+// Fit says how an image that is not square fills the square variants.
+type Fit int
+
+const (
+	// Cover crops the middle of the image to fill the square.
+	Cover Fit = iota
+	// Contain fits the whole image and pads the rest, transparent for PNG.
+	Contain
+)
+
+// Spec says how one kind of upload is processed and stored.
+type Spec struct {
+	// first segment of the storage keys
+	Prefix string
+	// edge lengths of the square variants, in pixels
+	Sizes []int
+	// the variant a single URL points at
+	DefaultSize int
+	Format      Format
+	Fit         Fit
+}
+
+var (
+	Avatar = Spec{Prefix: "avatars", Sizes: []int{64, 128, 256, 512}, DefaultSize: 256, Format: JPEG, Fit: Cover}
+	// logos keep their whole shape and any transparency
+	TeamLogo = Spec{Prefix: "team-logos", Sizes: []int{64, 128, 256}, DefaultSize: 128, Format: PNG, Fit: Contain}
+)
+
+// Variant is one rendered size of an upload.
+type Variant struct {
+	Size int
+	Data []byte
+}
+
+// Process decodes an uploaded image and renders its variants for spec. The type is
+// sniffed from the content, whatever the client claimed, and everything but the pixels is
+// dropped by re-encoding, EXIF included, after applying its orientation.
+func Process(data []byte, spec Spec) ([]Variant, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupported
+	}
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrUnsupported
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxPixels {
+		return nil, ErrTooLarge
+	}
+
+	slots <- struct{}{}
+	defer func() { <-slots }()
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnsupported, err)
+	}
+	img := orient(toRGBA(src), jpegOrientation(data))
+
+	variants := make([]Variant, 0, len(spec.Sizes))
+	for _, size := range spec.Sizes {
+		out := square(img, size, spec.Fit)
+		var buf bytes.Buffer
+		switch spec.Format {
+		case JPEG:
+			err = jpeg.Encode(&buf, flatten(out), &jpeg.Options{Quality: 85})
+		default:
+			err = (&png.Encoder{CompressionLevel: png.BestCompression}).Encode(&buf, out)
+		}
+		if err != nil {
+			return nil, err
+		}
+		variants = append(variants, Variant{Size: size, Data: buf.Bytes()})
+	}
+	return variants, nil
+}
+
+func toRGBA(src image.Image) *image.RGBA {
+	b := src.Bounds()
+	dst := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(dst, dst.Bounds(), src, b.Min, draw.Src)
+	return dst
+}
+
+// flatten puts img on white, since JPEG has no transparency.
+func flatten(img *image.RGBA) *image.RGBA {
+	dst := image.NewRGBA(img.Bounds())
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.Draw(dst, dst.Bounds(), img, img.Bounds().Min, draw.Over)
+	return dst
+}
+
+// square renders img into a size x size square according to fit.
+func square(img *image.RGBA, size int, fit Fit) *image.RGBA {
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	if fit == Cover {
+		side := min(w, h)
+		crop := image.Rect((w-side)/2, (h-side)/2, (w-side)/2+side, (h-side)/2+side)
+		return resize(img.SubImage(crop).(*image.RGBA), size, size)
+	}
+
+	tw, th := size, size
+	if w > h {
+		th = max(1, size*h/w)
+	} else {
+		tw = max(1, size*w/h)
+	}
+	scaled := resize(img, tw, th)
+	dst := image.NewRGBA(image.Rect(0, 0, size, size))
+	offset := image.Pt((size-tw)/2, (size-th)/2)
+	draw.Draw(dst, scaled.Bounds().Add(offset), scaled, image.Point{}, draw.Src)
+	return dst
+}
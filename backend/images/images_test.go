@@ -0,0 +1,194 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+// halves draws a w x h image whose top half is red and bottom half blue.
+func halves(w, h int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.RGBA{255, 0, 0, 255}
+			if y >= h/2 {
+				c = color.RGBA{0, 0, 255, 255}
+			}
+			img.SetRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodePNG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// jpegWithOrientation encodes img as a JPEG carrying an EXIF orientation tag.
+func jpegWithOrientation(t *testing.T, img image.Image, orientation uint16) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	// big-endian TIFF with one IFD entry: 0x0112, SHORT, count 1
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08\x00\x01\x01\x12\x00\x03\x00\x00\x00\x01")
+	tiff = binary.BigEndian.AppendUint16(tiff, orientation)
+	tiff = append(tiff, 0, 0, 0, 0, 0, 0)
+	segment := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1}
+	app1 = binary.BigEndian.AppendUint16(app1, uint16(len(segment)+2))
+	app1 = append(app1, segment...)
+
+	data := buf.Bytes()
+	out := append([]byte{}, data[:2]...)
+	out = append(out, app1...)
+	return append(out, data[2:]...)
+}
+
+func decode(t *testing.T, data []byte) image.Image {
+	t.Helper()
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return img
+}
+
+func isRed(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return r > 0xC000 && g < 0x4000 && b < 0x4000
+}
+
+func isBlue(c color.Color) bool {
+	r, g, b, _ := c.RGBA()
+	return b > 0xC000 && r < 0x4000 && g < 0x4000
+}
+
+func TestProcessSizes(t *testing.T) {
+	variants, err := Process(encodePNG(t, halves(300, 200)), Avatar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(variants) != len(Avatar.Sizes) {
+		t.Fatalf("got %d variants, want %d", len(variants), len(Avatar.Sizes))
+	}
+	for i, v := range variants {
+		if v.Size != Avatar.Sizes[i] {
+			t.Errorf("variant %d has size %d, want %d", i, v.Size, Avatar.Sizes[i])
+		}
+		img, format, err := image.Decode(bytes.NewReader(v.Data))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if format != "jpeg" {
+			t.Errorf("variant %d is %s, want jpeg", v.Size, format)
+		}
+		if b := img.Bounds(); b.Dx() != v.Size || b.Dy() != v.Size {
+			t.Errorf("variant %d is %v", v.Size, b)
+		}
+	}
+}
+
+func TestProcessSniffsContent(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"text":      []byte("hello, this is not an image"),
+		"html":      []byte("<html><script>alert(1)</script></html>"),
+		"svg":       []byte(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`),
+		"empty":     nil,
+		"truncated": encodePNG(t, halves(10, 10))[:40],
+	} {
+		if _, err := Process(data, Avatar); !errors.Is(err, ErrUnsupported) {
+			t.Errorf("%s: err = %v, want ErrUnsupported", name, err)
+		}
+	}
+
+	// the format comes from the bytes, so a PNG is accepted and re-encoded as JPEG
+	variants, err := Process(encodePNG(t, halves(10, 10)), Avatar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, format, _ := image.DecodeConfig(bytes.NewReader(variants[0].Data)); format != "jpeg" {
+		t.Errorf("avatar is %s, want jpeg", format)
+	}
+}
+
+func TestProcessAppliesAndStripsEXIF(t *testing.T) {
+	// stored turned left: the red top half belongs on the right once turned upright
+	data := jpegWithOrientation(t, halves(40, 20), 6)
+	if got := jpegOrientation(data); got != 6 {
+		t.Fatalf("jpegOrientation = %d, want 6", got)
+	}
+
+	spec := Spec{Prefix: "test", Sizes: []int{40}, DefaultSize: 40, Format: PNG, Fit: Contain}
+	variants, err := Process(data, spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	img := decode(t, variants[0].Data)
+	// upright the image is 20 x 40, centered in the square from x=10 to 30
+	if c := img.At(25, 20); !isRed(c) {
+		t.Errorf("right half is %v, want red", c)
+	}
+	if c := img.At(15, 20); !isBlue(c) {
+		t.Errorf("left half is %v, want blue", c)
+	}
+	if _, _, _, a := img.At(2, 20).RGBA(); a != 0 {
+		t.Errorf("padding has alpha %d, want transparent", a)
+	}
+
+	variants, err = Process(data, Avatar)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range variants {
+		if bytes.Contains(v.Data, []byte("Exif")) || bytes.Contains(v.Data, []byte{0xFF, 0xE1}) {
+			t.Errorf("variant %d still carries EXIF data", v.Size)
+		}
+		if got := jpegOrientation(v.Data); got != 1 {
+			t.Errorf("variant %d has orientation %d", v.Size, got)
+		}
+	}
+}
+
+func TestProcessRejectsOversizedDimensions(t *testing.T) {
+	data := encodePNG(t, halves(2, 2))
+	// rewrite the IHDR chunk to claim 5000 x 5000 pixels, keeping its checksum valid
+	binary.BigEndian.PutUint32(data[16:], 5000)
+	binary.BigEndian.PutUint32(data[20:], 5000)
+	binary.BigEndian.PutUint32(data[29:], crc32.ChecksumIEEE(data[12:29]))
+
+	if _, err := Process(data, Avatar); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("err = %v, want ErrTooLarge", err)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	src := halves(4, 2)
+	for o, want := range map[int][2]int{1: {4, 2}, 3: {4, 2}, 6: {2, 4}, 8: {2, 4}} {
+		got := orient(src, o).Bounds()
+		if got.Dx() != want[0] || got.Dy() != want[1] {
+			t.Errorf("orientation %d: bounds %v, want %dx%d", o, got, want[0], want[1])
+		}
+	}
+	// upside down: the blue bottom comes to the top
+	if c := orient(src, 3).At(0, 0); !isBlue(c) {
+		t.Errorf("orientation 3: top is %v, want blue", c)
+	}
+	// turned right: the red top ends up on the left
+	if c := orient(src, 8).At(0, 0); !isRed(c) {
+		t.Errorf("orientation 8: left is %v, want red", c)
+	}
+}
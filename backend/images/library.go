@@ -0,0 +1,79 @@
+package images
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Neat-Snap/blueprint-backend/storage"
+)
+
+// Library keeps processed uploads in a storage backend and says where they are served.
+type Library struct {
+	store   storage.Storage
+	baseURL string
+}
+
+// NewLibrary stores uploads in store; baseURL is where the files are served from, the
+// API's /uploads or a bucket or CDN in front of the store.
+func NewLibrary(store storage.Storage, baseURL string) *Library {
+	return &Library{store: store, baseURL: strings.TrimRight(baseURL, "/")}
+}
+
+// File is the storage key of the size variant of the upload stored under key.
+func (s Spec) File(key string, size int) string {
+	return fmt.Sprintf("%s/%d.%s", key, size, s.Format.Ext())
+}
+
+// Save stores variants under a new key for ownerID, the user or team the image belongs
+// to, and returns the key.
+func (l *Library) Save(ctx context.Context, spec Spec, ownerID uint, variants []Variant) (string, error) {
+	name := make([]byte, 12)
+	if _, err := rand.Read(name); err != nil {
+		return "", err
+	}
+	// a new key per upload, so the files never change and can be cached for good
+	key := fmt.Sprintf("%s/%d/%s", spec.Prefix, ownerID, hex.EncodeToString(name))
+	for _, v := range variants {
+		if err := l.store.Put(ctx, spec.File(key, v.Size), v.Data, spec.Format.ContentType()); err != nil {
+			_ = l.Delete(ctx, spec, key)
+			return "", err
+		}
+	}
+	return key, nil
+}
+
+// URL is where the size variant of the upload stored under key is served.
+func (l *Library) URL(spec Spec, key string, size int) string {
+	return l.baseURL + "/" + spec.File(key, size)
+}
+
+// URLs maps each size of the upload stored under key to its URL.
+func (l *Library) URLs(spec Spec, key string) map[int]string {
+	urls := make(map[int]string, len(spec.Sizes))
+	for _, size := range spec.Sizes {
+		urls[size] = l.URL(spec, key, size)
+	}
+	return urls
+}
+
+// Delete removes every variant of the upload stored under key.
+func (l *Library) Delete(ctx context.Context, spec Spec, key string) error {
+	var errs []error
+	for _, size := range spec.Sizes {
+		errs = append(errs, l.store.Delete(ctx, spec.File(key, size)))
+	}
+	return errors.Join(errs...)
+}
+
+// Open reads a stored file by its storage key.
+func (l *Library) Open(ctx context.Context, file string) (io.ReadCloser, storage.Object, error) {
+	if err := storage.ValidKey(file); err != nil {
+		return nil, storage.Object{}, storage.ErrNotFound
+	}
+	return l.store.Get(ctx, file)
+}
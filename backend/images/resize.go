@@ -0,0 +1,80 @@
+package images
+
+import (
+	"image"
+	"math"
+)
+
+type tap struct {
+	i int
+	w float32
+}
+
+// weights lists, for each of n destination pixels in a row or column, the source pixels
+// out of srcN that make it up and how much each counts. The filter is a triangle widened
+// by the scale when shrinking, so every source pixel contributes: bilinear going up, close
+// to an area average going down.
+func weights(srcN, n int) [][]tap {
+	scale := float64(srcN) / float64(n)
+	support := max(1, scale)
+	out := make([][]tap, n)
+	for i := range out {
+		center := (float64(i)+0.5)*scale - 0.5
+		var taps []tap
+		var total float32
+		for j := int(math.Floor(center - support)); j <= int(math.Ceil(center+support)); j++ {
+			w := 1 - math.Abs(float64(j)-center)/support
+			if w <= 0 {
+				continue
+			}
+			taps = append(taps, tap{i: min(max(j, 0), srcN-1), w: float32(w)})
+			total += float32(w)
+		}
+		for k := range taps {
+			taps[k].w /= total
+		}
+		out[i] = taps
+	}
+	return out
+}
+
+// resize scales src to w x h. image.RGBA is alpha-premultiplied, so averaging its
+// channels does not bleed the color of transparent pixels into the edges.
+func resize(src *image.RGBA, w, h int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+
+	// horizontal pass into a w x sh buffer, then vertical into the result
+	tmp := make([]float32, w*sh*4)
+	for y, xw := 0, weights(sw, w); y < sh; y++ {
+		row := src.Pix[src.PixOffset(b.Min.X, b.Min.Y+y):]
+		for x, taps := range xw {
+			var acc [4]float32
+			for _, t := range taps {
+				p := row[t.i*4 : t.i*4+4]
+				for c := range acc {
+					acc[c] += float32(p[c]) * t.w
+				}
+			}
+			copy(tmp[(y*w+x)*4:], acc[:])
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y, taps := range weights(sh, h) {
+		for x := 0; x < w; x++ {
+			var acc [4]float32
+			for _, t := range taps {
+				p := tmp[(t.i*w+x)*4:]
+				for c := range acc {
+					acc[c] += p[c] * t.w
+				}
+			}
+			out := dst.Pix[y*dst.Stride+x*4:]
+			for c, v := range acc {
+				out[c] = uint8(min(max(v+0.5, 0), 255))
+			}
+		}
+	}
+	return dst
+}
@@ -0,0 +1,158 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+var (
+	ErrJobNotFound = errors.New("job not found")
+	ErrJobRunning  = errors.New("job is running")
+)
+
+type Stats struct {
+	Due     int64 `json:"due"`
+	Delayed int64 `json:"delayed"`
+	Running int64 `json:"running"`
+	Dead    int64 `json:"dead"`
+}
+
+func (q *Queue) Stats(ctx context.Context) (Stats, error) {
+	now := fmt.Sprint(time.Now().UnixMilli())
+	p := q.rdb.Pipeline()
+	due := p.ZCount(ctx, q.scheduledKey(), "-inf", now)
+	delayed := p.ZCount(ctx, q.scheduledKey(), "("+now, "+inf")
+	running := p.ZCard(ctx, q.activeKey())
+	dead := p.ZCard(ctx, q.deadKey())
+	if _, err := p.Exec(ctx); err != nil {
+		return Stats{}, err
+	}
+	return Stats{Due: due.Val(), Delayed: delayed.Val(), Running: running.Val(), Dead: dead.Val()}, nil
+}
+
+// Redacted returns j with every string in its payload masked, keeping the structure,
+// numbers and flags. Payloads carry addresses and, for some kinds, values that act as
+// credentials, so they are not shown in full outside their handlers.
+func (j Job) Redacted() Job {
+	var v any
+	if err := json.Unmarshal(j.Payload, &v); err != nil {
+		j.Payload = nil
+		return j
+	}
+	j.Payload, _ = json.Marshal(redact(v))
+	return j
+}
+
+func redact(v any) any {
+	switch v := v.(type) {
+	case string:
+		return "[redacted]"
+	case map[string]any:
+		for k, e := range v {
+			v[k] = redact(e)
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = redact(e)
+		}
+	}
+	return v
+}
+
+// Stuck returns jobs that have been running, or have been due without being picked up,
+// for longer than olderThan.
+func (q *Queue) Stuck(ctx context.Context, olderThan time.Duration, limit int64) ([]Job, error) {
+	cutoff := time.Now().Add(-olderThan)
+
+	// active jobs are scored by lease deadline, so a lease that started before cutoff ends before cutoff+lease
+	running, err := q.rdb.ZRangeByScore(ctx, q.activeKey(), &redis.ZRangeBy{
+		Min: "-inf", Max: fmt.Sprint(cutoff.Add(leaseDuration).UnixMilli()), Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	waiting, err := q.rdb.ZRangeByScore(ctx, q.scheduledKey(), &redis.ZRangeBy{
+		Min: "-inf", Max: fmt.Sprint(cutoff.UnixMilli()), Count: limit,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+	return q.loadMany(ctx, append(running, waiting...))
+}
+
+// Dead returns dead-lettered jobs, most recent first.
+func (q *Queue) Dead(ctx context.Context, offset, limit int64) ([]Job, error) {
+	ids, err := q.rdb.ZRevRange(ctx, q.deadKey(), offset, offset+limit-1).Result()
+	if err != nil {
+		return nil, err
+	}
+	return q.loadMany(ctx, ids)
+}
+
+func (q *Queue) loadMany(ctx context.Context, ids []string) ([]Job, error) {
+	out := make([]Job, 0, len(ids))
+	for _, id := range ids {
+		j, err := q.load(ctx, id)
+		if errors.Is(err, redis.Nil) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, *j)
+	}
+	return out, nil
+}
+
+// Retry moves a dead or waiting job to the front of the queue with a fresh set of
+// attempts. Running jobs cannot be retried.
+func (q *Queue) Retry(ctx context.Context, id string) (*Job, error) {
+	j, err := q.load(ctx, id)
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrJobNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if _, err := q.rdb.ZScore(ctx, q.activeKey(), id).Result(); err == nil {
+		return nil, ErrJobRunning
+	}
+
+	j.Attempts = 0
+	j.FailedAt = nil
+	j.StartedAt = nil
+	j.RunAt = time.Now()
+	if err := q.rdb.ZRem(ctx, q.deadKey(), id).Err(); err != nil {
+		return nil, err
+	}
+	if err := q.schedule(ctx, j); err != nil {
+		return nil, err
+	}
+	q.Wake()
+	return j, nil
+}
+
+// Delete drops a job that is not running.
+func (q *Queue) Delete(ctx context.Context, id string) error {
+	if _, err := q.rdb.ZScore(ctx, q.activeKey(), id).Result(); err == nil {
+		return ErrJobRunning
+	}
+	n, err := q.rdb.Del(ctx, q.jobKey(id)).Result()
+	if err != nil {
+		return err
+	}
+	_, err = q.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.ZRem(ctx, q.scheduledKey(), id)
+		p.ZRem(ctx, q.deadKey(), id)
+		return nil
+	})
+	if err == nil && n == 0 {
+		return ErrJobNotFound
+	}
+	return err
+}
@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type periodicJob struct {
+	kind     string
+	interval time.Duration
+	payload  json.RawMessage
+}
+
+// Every enqueues a job of kind once per interval, aligned to the interval. Every replica
+// may register the same schedule; a marker key in Redis makes sure each slot is enqueued
+// only once.
+func Every[T any](q *Queue, kind Kind[T], interval time.Duration, payload T) error {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.periodic = append(q.periodic, periodicJob{kind: string(kind), interval: interval, payload: raw})
+	return nil
+}
+
+func (q *Queue) runPeriodic(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	last := make(map[string]time.Time)
+
+	for {
+		q.mu.RLock()
+		periodic := q.periodic
+		q.mu.RUnlock()
+
+		now := time.Now()
+		for _, p := range periodic {
+			slot := now.Truncate(p.interval)
+			if !last[p.kind].Before(slot) {
+				continue
+			}
+			if err := q.enqueueSlot(ctx, p, slot); err != nil {
+				if ctx.Err() == nil {
+					q.logger.Error("failed to enqueue periodic job", "error", err, "type", p.kind)
+				}
+				continue
+			}
+			last[p.kind] = slot
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (q *Queue) enqueueSlot(ctx context.Context, p periodicJob, slot time.Time) error {
+	marker := fmt.Sprintf("%s:periodic:%s:%d", q.prefix, p.kind, slot.Unix())
+	ok, err := q.rdb.SetNX(ctx, marker, 1, 2*p.interval).Result()
+	if err != nil || !ok {
+		return err
+	}
+	j := &Job{
+		ID:          fmt.Sprintf("%s@%d", p.kind, slot.Unix()),
+		Type:        p.kind,
+		Payload:     p.payload,
+		MaxAttempts: defaultMaxAttempts,
+		CreatedAt:   time.Now(),
+		RunAt:       slot,
+	}
+	if err := q.schedule(ctx, j); err != nil {
+		return err
+	}
+	q.Wake()
+	return nil
+}
@@ -0,0 +1,387 @@
+package jobs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/go-redis/redis/v8"
+)
+
+// Kind names a job type and ties it to the payload its handler receives.
+type Kind[T any] string
+
+// Job is what is stored in Redis for every enqueued unit of work.
+type Job struct {
+	ID          string          `json:"id"`
+	Type        string          `json:"type"`
+	Payload     json.RawMessage `json:"payload"`
+	Attempts    int             `json:"attempts"`
+	MaxAttempts int             `json:"max_attempts"`
+	CreatedAt   time.Time       `json:"created_at"`
+	RunAt       time.Time       `json:"run_at"`
+	StartedAt   *time.Time      `json:"started_at,omitempty"`
+	FailedAt    *time.Time      `json:"failed_at,omitempty"`
+	LastError   string          `json:"last_error,omitempty"`
+}
+
+type handlerFunc func(ctx context.Context, payload json.RawMessage) error
+
+const (
+	defaultMaxAttempts = 5
+	baseBackoff        = 10 * time.Second
+	maxBackoff         = time.Hour
+	jobTimeout         = time.Minute
+	// longer than jobTimeout, so a lease only runs out when the worker holding it is gone
+	leaseDuration  = 5 * time.Minute
+	pollInterval   = time.Second
+	reapInterval   = 30 * time.Second
+	deadRetention  = 30 * 24 * time.Hour
+	deadListMaxLen = 10000
+)
+
+// Queue is a Redis-backed job queue. Jobs wait in a sorted set scored by when they are
+// due, are leased to a worker while they run, and end up in the dead-letter set once
+// they have used up their attempts. Any number of replicas can run workers on the same
+// queue.
+type Queue struct {
+	rdb         *redis.Client
+	logger      logger.MultiLogger
+	prefix      string
+	concurrency int
+
+	mu       sync.RWMutex
+	handlers map[string]handlerFunc
+	periodic []periodicJob
+
+	wake chan struct{}
+}
+
+func NewQueue(rdb *redis.Client, logger logger.MultiLogger, prefix string, concurrency int) *Queue {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &Queue{
+		rdb:         rdb,
+		logger:      logger,
+		prefix:      prefix,
+		concurrency: concurrency,
+		handlers:    make(map[string]handlerFunc),
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+func (q *Queue) jobKey(id string) string { return q.prefix + ":job:" + id }
+func (q *Queue) scheduledKey() string    { return q.prefix + ":scheduled" }
+func (q *Queue) activeKey() string       { return q.prefix + ":active" }
+func (q *Queue) deadKey() string         { return q.prefix + ":dead" }
+
+// Handle registers the handler for kind. A job whose handler returns an error is retried
+// with backoff; handlers must therefore be safe to run more than once.
+func Handle[T any](q *Queue, kind Kind[T], h func(ctx context.Context, payload T) error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.handlers[string(kind)] = func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return fmt.Errorf("decode payload: %w", err)
+		}
+		return h(ctx, payload)
+	}
+}
+
+type EnqueueOption func(*Job)
+
+// In delays the job by d.
+func In(d time.Duration) EnqueueOption {
+	return func(j *Job) { j.RunAt = time.Now().Add(d) }
+}
+
+// At schedules the job for t.
+func At(t time.Time) EnqueueOption {
+	return func(j *Job) { j.RunAt = t }
+}
+
+// MaxAttempts overrides how often the job is tried before it is dead-lettered.
+func MaxAttempts(n int) EnqueueOption {
+	return func(j *Job) { j.MaxAttempts = n }
+}
+
+// Enqueue stores a job of the given kind and returns its ID.
+func Enqueue[T any](ctx context.Context, q *Queue, kind Kind[T], payload T, opts ...EnqueueOption) (string, error) {
+	raw, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	j := &Job{
+		ID:          newJobID(),
+		Type:        string(kind),
+		Payload:     raw,
+		MaxAttempts: defaultMaxAttempts,
+		CreatedAt:   now,
+		RunAt:       now,
+	}
+	for _, o := range opts {
+		o(j)
+	}
+	if err := q.schedule(ctx, j); err != nil {
+		return "", err
+	}
+	q.Wake()
+	return j.ID, nil
+}
+
+func newJobID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func (q *Queue) schedule(ctx context.Context, j *Job) error {
+	body, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	_, err = q.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+		p.Set(ctx, q.jobKey(j.ID), body, 0)
+		p.ZAdd(ctx, q.scheduledKey(), &redis.Z{Score: float64(j.RunAt.UnixMilli()), Member: j.ID})
+		return nil
+	})
+	return err
+}
+
+// Wake makes an idle worker look for due jobs now instead of at the next poll.
+func (q *Queue) Wake() {
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes jobs until ctx is cancelled, then waits for the jobs already running to
+// finish. Callers that cannot wait indefinitely should bound it with their own timeout;
+// jobs abandoned that way are picked up again once their lease runs out.
+func (q *Queue) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for i := 0; i < q.concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			q.work(ctx)
+		}()
+	}
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		q.reap(ctx)
+	}()
+	go func() {
+		defer wg.Done()
+		q.runPeriodic(ctx)
+	}()
+	wg.Wait()
+}
+
+func (q *Queue) work(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for ctx.Err() == nil {
+		j, err := q.claim(ctx)
+		if err != nil && ctx.Err() == nil {
+			q.logger.Error("failed to claim job", "error", err)
+		}
+		if j != nil {
+			q.process(context.WithoutCancel(ctx), j)
+			continue
+		}
+		select {
+		case <-ctx.Done():
+		case <-ticker.C:
+		case <-q.wake:
+		}
+	}
+}
+
+// claimScript moves the earliest due job from the scheduled set to the active set, scored
+// by its lease deadline.
+var claimScript = redis.NewScript(`
+local ids = redis.call('ZRANGEBYSCORE', KEYS[1], '-inf', ARGV[1], 'LIMIT', 0, 1)
+if #ids == 0 then return false end
+redis.call('ZREM', KEYS[1], ids[1])
+redis.call('ZADD', KEYS[2], ARGV[2], ids[1])
+return ids[1]
+`)
+
+func (q *Queue) claim(ctx context.Context) (*Job, error) {
+	now := time.Now()
+	id, err := claimScript.Run(ctx, q.rdb, []string{q.scheduledKey(), q.activeKey()}, now.UnixMilli(), now.Add(leaseDuration).UnixMilli()).Text()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	j, err := q.load(ctx, id)
+	if errors.Is(err, redis.Nil) {
+		// the job was deleted while it waited
+		q.rdb.ZRem(ctx, q.activeKey(), id)
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	j.StartedAt = &now
+	return j, q.save(ctx, j)
+}
+
+func (q *Queue) load(ctx context.Context, id string) (*Job, error) {
+	body, err := q.rdb.Get(ctx, q.jobKey(id)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var j Job
+	if err := json.Unmarshal(body, &j); err != nil {
+		return nil, err
+	}
+	return &j, nil
+}
+
+func (q *Queue) save(ctx context.Context, j *Job) error {
+	body, err := json.Marshal(j)
+	if err != nil {
+		return err
+	}
+	return q.rdb.Set(ctx, q.jobKey(j.ID), body, redis.KeepTTL).Err()
+}
+
+func (q *Queue) process(ctx context.Context, j *Job) {
+	q.mu.RLock()
+	h, ok := q.handlers[j.Type]
+	q.mu.RUnlock()
+
+	var err error
+	if !ok {
+		err = fmt.Errorf("no handler registered for %q", j.Type)
+		// retrying cannot help, so the job goes straight to the dead-letter queue
+		j.Attempts = j.MaxAttempts - 1
+	} else {
+		err = q.call(ctx, h, j)
+	}
+
+	if err == nil {
+		_, derr := q.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.ZRem(ctx, q.activeKey(), j.ID)
+			p.Del(ctx, q.jobKey(j.ID))
+			return nil
+		})
+		if derr != nil {
+			q.logger.Error("failed to complete job", "error", derr, "job_id", j.ID, "type", j.Type)
+		}
+		return
+	}
+	q.fail(ctx, j, err)
+}
+
+func (q *Queue) call(ctx context.Context, h handlerFunc, j *Job) (err error) {
+	ctx, cancel := context.WithTimeout(ctx, jobTimeout)
+	defer cancel()
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+	return h(ctx, j.Payload)
+}
+
+// fail records a failed attempt and either schedules a retry or dead-letters the job.
+// The job must already have been removed from the scheduled set.
+func (q *Queue) fail(ctx context.Context, j *Job, cause error) {
+	now := time.Now()
+	j.Attempts++
+	j.LastError = cause.Error()
+	j.StartedAt = nil
+
+	var err error
+	if j.Attempts < j.MaxAttempts {
+		j.RunAt = now.Add(Backoff(j.Attempts))
+		body, _ := json.Marshal(j)
+		_, err = q.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.ZRem(ctx, q.activeKey(), j.ID)
+			p.Set(ctx, q.jobKey(j.ID), body, 0)
+			p.ZAdd(ctx, q.scheduledKey(), &redis.Z{Score: float64(j.RunAt.UnixMilli()), Member: j.ID})
+			return nil
+		})
+		q.logger.Warn("job failed, will retry", "error", cause, "job_id", j.ID, "type", j.Type, "attempts", j.Attempts, "run_at", j.RunAt)
+	} else {
+		j.FailedAt = &now
+		body, _ := json.Marshal(j)
+		_, err = q.rdb.TxPipelined(ctx, func(p redis.Pipeliner) error {
+			p.ZRem(ctx, q.activeKey(), j.ID)
+			p.Set(ctx, q.jobKey(j.ID), body, deadRetention)
+			p.ZAdd(ctx, q.deadKey(), &redis.Z{Score: float64(now.UnixMilli()), Member: j.ID})
+			p.ZRemRangeByRank(ctx, q.deadKey(), 0, -deadListMaxLen-1)
+			return nil
+		})
+		q.logger.Error("job moved to dead-letter queue", "error", cause, "job_id", j.ID, "type", j.Type, "attempts", j.Attempts)
+	}
+	if err != nil {
+		q.logger.Error("failed to record job failure", "error", err, "job_id", j.ID)
+	}
+}
+
+// reap returns jobs whose lease ran out, because the worker holding them stopped, to the
+// queue as a failed attempt.
+func (q *Queue) reap(ctx context.Context) {
+	ticker := time.NewTicker(reapInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ids, err := q.rdb.ZRangeByScore(ctx, q.activeKey(), &redis.ZRangeBy{
+			Min: "-inf", Max: fmt.Sprint(time.Now().UnixMilli()), Count: 100,
+		}).Result()
+		if err != nil {
+			if ctx.Err() == nil {
+				q.logger.Error("failed to list expired job leases", "error", err)
+			}
+			continue
+		}
+		for _, id := range ids {
+			// only the replica that removes the lease handles the job
+			if n, err := q.rdb.ZRem(ctx, q.activeKey(), id).Result(); err != nil || n == 0 {
+				continue
+			}
+			j, err := q.load(ctx, id)
+			if err != nil {
+				q.logger.Error("failed to load expired job", "error", err, "job_id", id)
+				continue
+			}
+			q.fail(ctx, j, errors.New("lease expired before the job finished"))
+		}
+	}
+}
+
+// Backoff returns the wait before the next attempt after the given number of failures:
+// 10s, 20s, 40s, ... capped at an hour.
+func Backoff(attempts int) time.Duration {
+	d := time.Duration(float64(baseBackoff) * math.Pow(2, float64(attempts-1)))
+	if d > maxBackoff || d <= 0 {
+		return maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,227 @@
+package jobs
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestBackoff(t *testing.T) {
+	for attempts, want := range map[int]time.Duration{
+		1:  10 * time.Second,
+		2:  20 * time.Second,
+		3:  40 * time.Second,
+		10: time.Hour,
+		64: time.Hour,
+	} {
+		if got := Backoff(attempts); got != want {
+			t.Errorf("Backoff(%d) = %v, want %v", attempts, got, want)
+		}
+	}
+}
+
+func TestRedacted(t *testing.T) {
+	j := Job{ID: "1", Payload: json.RawMessage(`{"to":"a@example.com","tries":2,"urgent":true,"tags":["x"]}`)}
+	got := j.Redacted()
+	want := `{"tags":["[redacted]"],"to":"[redacted]","tries":2,"urgent":true}`
+	if string(got.Payload) != want {
+		t.Errorf("payload = %s, want %s", got.Payload, want)
+	}
+	if string(j.Payload) == want {
+		t.Error("Redacted changed the original job")
+	}
+}
+
+// testQueue returns a queue on a Redis server, such as a local one:
+//
+//	docker run -p 6379:6379 redis
+//	JOBS_TEST_REDIS_ADDR=localhost:6379 go test ./jobs
+func testQueue(t *testing.T) *Queue {
+	t.Helper()
+	addr := os.Getenv("JOBS_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("JOBS_TEST_REDIS_ADDR is not set")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	t.Cleanup(func() { rdb.Close() })
+
+	q := NewQueue(rdb, logger.MultiLogger{}, "jobs-test:"+newJobID(), 2)
+	t.Cleanup(func() {
+		ctx := context.Background()
+		keys, _ := rdb.Keys(ctx, q.prefix+":*").Result()
+		if len(keys) > 0 {
+			rdb.Del(ctx, keys...)
+		}
+	})
+	return q
+}
+
+func stats(t *testing.T, q *Queue) Stats {
+	t.Helper()
+	s, err := q.Stats(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestEnqueue(t *testing.T) {
+	q := testQueue(t)
+	ctx := context.Background()
+	kind := Kind[string]("test.echo")
+
+	if _, err := Enqueue(ctx, q, kind, "now"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Enqueue(ctx, q, kind, "later", In(time.Hour)); err != nil {
+		t.Fatal(err)
+	}
+	if s := stats(t, q); s.Due != 1 || s.Delayed != 1 {
+		t.Fatalf("stats = %+v, want one due and one delayed", s)
+	}
+
+	var got []string
+	Handle(q, kind, func(ctx context.Context, payload string) error {
+		got = append(got, payload)
+		return nil
+	})
+	j, err := q.claim(ctx)
+	if err != nil || j == nil {
+		t.Fatalf("claim = %v, %v", j, err)
+	}
+	if next, err := q.claim(ctx); err != nil || next != nil {
+		t.Fatalf("claimed %v, %v before it was due", next, err)
+	}
+	q.process(ctx, j)
+
+	if len(got) != 1 || got[0] != "now" {
+		t.Errorf("handler got %v, want [now]", got)
+	}
+	if s := stats(t, q); s.Due != 0 || s.Delayed != 1 || s.Running != 0 {
+		t.Errorf("stats = %+v, want only the delayed job left", s)
+	}
+	if n, _ := q.rdb.Exists(ctx, q.jobKey(j.ID)).Result(); n != 0 {
+		t.Error("the finished job was not deleted")
+	}
+}
+
+func TestRetryAndDeadLetter(t *testing.T) {
+	q := testQueue(t)
+	ctx := context.Background()
+	kind := Kind[int]("test.fail")
+	Handle(q, kind, func(ctx context.Context, payload int) error {
+		return errors.New("boom")
+	})
+
+	id, err := Enqueue(ctx, q, kind, 1, MaxAttempts(2))
+	if err != nil {
+		t.Fatal(err)
+	}
+	j, err := q.claim(ctx)
+	if err != nil || j == nil {
+		t.Fatalf("claim = %v, %v", j, err)
+	}
+	before := time.Now()
+	q.process(ctx, j)
+
+	// the first failure schedules a retry after the backoff
+	if s := stats(t, q); s.Delayed != 1 || s.Running != 0 || s.Dead != 0 {
+		t.Fatalf("after one failure stats = %+v, want one delayed job", s)
+	}
+	j, err = q.load(ctx, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j.Attempts != 1 || j.LastError != "boom" || j.RunAt.Before(before.Add(Backoff(1))) {
+		t.Errorf("after one failure job = %+v", j)
+	}
+
+	// the last attempt dead-letters it
+	q.process(ctx, j)
+	if s := stats(t, q); s.Delayed != 0 || s.Dead != 1 {
+		t.Fatalf("after the last attempt stats = %+v, want one dead job", s)
+	}
+	dead, err := q.Dead(ctx, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].ID != id || dead[0].Attempts != 2 || dead[0].FailedAt == nil {
+		t.Errorf("dead = %+v", dead)
+	}
+
+	// a retried dead job is due again
+	if _, err := q.Retry(ctx, id); err != nil {
+		t.Fatal(err)
+	}
+	if s := stats(t, q); s.Due != 1 || s.Dead != 0 {
+		t.Errorf("after Retry stats = %+v, want one due job", s)
+	}
+}
+
+func TestUnknownKindIsDeadLettered(t *testing.T) {
+	q := testQueue(t)
+	ctx := context.Background()
+	if _, err := Enqueue(ctx, q, Kind[int]("test.unknown"), 1); err != nil {
+		t.Fatal(err)
+	}
+	j, err := q.claim(ctx)
+	if err != nil || j == nil {
+		t.Fatalf("claim = %v, %v", j, err)
+	}
+	q.process(ctx, j)
+	if s := stats(t, q); s.Dead != 1 || s.Due+s.Delayed != 0 {
+		t.Errorf("stats = %+v, want the job dead-lettered without retries", s)
+	}
+}
+
+func TestRunDrainsOnCancel(t *testing.T) {
+	q := testQueue(t)
+	kind := Kind[int]("test.slow")
+	started := make(chan struct{})
+	release := make(chan struct{})
+	Handle(q, kind, func(ctx context.Context, payload int) error {
+		close(started)
+		<-release
+		return ctx.Err()
+	})
+	if _, err := Enqueue(context.Background(), q, kind, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		q.Run(ctx)
+		close(done)
+	}()
+
+	select {
+	case <-started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the job did not start")
+	}
+	cancel()
+
+	// Run waits for the running job, which keeps its own context
+	select {
+	case <-done:
+		t.Fatal("Run returned while a job was running")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Run did not return after the job finished")
+	}
+
+	if s := stats(t, q); s != (Stats{}) {
+		t.Errorf("stats = %+v, want the job completed", s)
+	}
+}
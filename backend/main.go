@@ -2,8 +2,10 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log/slog"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
@@ -12,8 +14,17 @@ import (
 	"github.com/Neat-Snap/blueprint-backend/api"
 	"github.com/Neat-Snap/blueprint-backend/config"
 	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/events"
+	"github.com/Neat-Snap/blueprint-backend/images"
+	"github.com/Neat-Snap/blueprint-backend/jobs"
 	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/notify"
+	"github.com/Neat-Snap/blueprint-backend/storage"
 	"github.com/Neat-Snap/blueprint-backend/utils/email"
+	"github.com/Neat-Snap/blueprint-backend/utils/geoip"
+	"github.com/Neat-Snap/blueprint-backend/webhooks"
+	"github.com/Neat-Snap/blueprint-backend/webpush"
+	"github.com/Neat-Snap/blueprint-backend/workers"
 )
 
 func main() {
@@ -32,20 +43,136 @@ func main() {
 
 	connectionObject := db.NewConnection(dbConn)
 
-	emailClient := email.NewEmailClient(cfg, *log)
+	mailer, err := email.NewMailer(cfg)
+	if err != nil {
+		log.Error("failed to configure email transport", "error", err)
+		os.Exit(1)
+	}
+	log.Info("email transport configured", "transport", mailer.Name())
+	emailClient := email.NewEmailClient(cfg, *log, mailer)
+
+	queue := jobs.NewQueue(emailClient.R.R, *log, "jobs", cfg.JOBS_CONCURRENCY)
+	emailClient.TrackDeliveries(connectionObject)
+	emailClient.UseQueue(queue)
+	emailClient.UseLocaleResolver(func(ctx context.Context, address string) string {
+		preference, err := connectionObject.Preferences.GetByEmail(ctx, address)
+		if err != nil {
+			return ""
+		}
+		return preference.Language
+	})
+
+	geo, err := geoip.Open(cfg.GEOIP_DB_PATH)
+	if err != nil {
+		log.Warn("failed to open geoip database, countries will not be resolved", "error", err)
+	}
+	defer geo.Close()
+
+	dispatcher := webhooks.NewDispatcher(connectionObject, *log, cfg.WEBHOOK_ALLOW_PRIVATE)
+
+	bus := events.NewBus()
+	publishers := []events.Publisher{bus}
+	for _, name := range cfg.EVENTS_PUBLISHERS {
+		switch name {
+		case "redis":
+			publishers = append(publishers, events.NewRedisStreamPublisher(emailClient.R.R, cfg.EVENTS_REDIS_STREAM, 100000))
+		case "nats":
+			natsPublisher, err := events.NewNATSPublisher(cfg.NATS_URL, cfg.NATS_SUBJECT_PREFIX)
+			if err != nil {
+				log.Error("failed to connect to nats", "error", err)
+				os.Exit(1)
+			}
+			defer natsPublisher.Close()
+			publishers = append(publishers, natsPublisher)
+		default:
+			log.Warn("unknown events publisher, ignoring", "publisher", name)
+		}
+	}
+	relay := events.NewRelay(connectionObject, *log, publishers...)
+
+	notifications := notify.NewHub(emailClient.R.R, *log, "notify")
+	notifier := notify.NewDispatcher(connectionObject, emailClient, *log)
+
+	var vapidKeys *webpush.Keys
+	switch {
+	case cfg.VAPID_PRIVATE_KEY != "":
+		vapidKeys, err = webpush.ParseKeys(cfg.VAPID_PRIVATE_KEY)
+	case cfg.Env == "dev":
+		var created bool
+		vapidKeys, created, err = webpush.LoadOrCreateKeys(context.Background(), emailClient.R.R, "webpush:vapid")
+		if created {
+			log.Info("generated VAPID keys for web push", "public_key", vapidKeys.PublicKey())
+		}
+	default:
+		// a generated key lives only as long as Redis keeps it, and every subscription
+		// made with it breaks when it is lost
+		err = errors.New("VAPID_PRIVATE_KEY is required unless APP_ENV=dev")
+	}
+	if err != nil && cfg.Env != "dev" {
+		log.Error("failed to load VAPID keys", "error", err)
+		os.Exit(1)
+	}
+	if err != nil {
+		log.Warn("failed to load VAPID keys, web push is off", "error", err)
+	} else {
+		pushClient := webhooks.NewHTTPClient(10*time.Second, cfg.WEB_PUSH_ALLOW_PRIVATE)
+		notifier.UsePush(webpush.NewSender(vapidKeys, cfg.VAPID_SUBJECT, pushClient), queue)
+	}
+
+	var store storage.Storage
+	switch cfg.STORAGE_BACKEND {
+	case "local":
+		store, err = storage.NewLocal(cfg.UploadDir)
+	case "s3":
+		store, err = storage.NewS3(storage.S3Config{
+			Endpoint:        cfg.S3_ENDPOINT,
+			Region:          cfg.S3_REGION,
+			Bucket:          cfg.S3_BUCKET,
+			AccessKeyID:     cfg.S3_ACCESS_KEY_ID,
+			SecretAccessKey: cfg.S3_SECRET_ACCESS_KEY,
+			PathStyle:       cfg.S3_PATH_STYLE,
+		}, &http.Client{Timeout: 30 * time.Second})
+	default:
+		err = fmt.Errorf("unknown storage backend %q", cfg.STORAGE_BACKEND)
+	}
+	if err != nil {
+		log.Error("failed to configure upload storage", "error", err)
+		os.Exit(1)
+	}
+	library := images.NewLibrary(store, cfg.UPLOADS_PUBLIC_URL)
 
 	router := api.NewRouter(api.RouterConfig{
-		Env:         cfg.Env,
-		DB:          dbConn,
-		Logger:      *log,
-		Connection:  connectionObject,
-		EmailClient: emailClient,
-		RedisSecret: cfg.REDIS_SECRET,
-		Config:      cfg,
+		Env:           cfg.Env,
+		DB:            dbConn,
+		Logger:        *log,
+		Connection:    connectionObject,
+		EmailClient:   emailClient,
+		RedisSecret:   cfg.REDIS_SECRET,
+		Config:        cfg,
+		GeoIP:         geo,
+		Webhooks:      dispatcher,
+		Events:        bus,
+		Jobs:          queue,
+		Notifications: notifications,
+		Notifier:      notifier,
+		Images:        library,
 	})
 
 	server := api.NewServer(cfg, log, router)
 
+	workersCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	go workers.RunTeamPurge(workersCtx, connectionObject, *log, library, time.Duration(cfg.TEAM_RETENTION_DAYS)*24*time.Hour, time.Hour)
+	go dispatcher.Run(workersCtx)
+	go relay.Run(workersCtx)
+	go notifications.Run(workersCtx)
+
+	queueDone := make(chan struct{})
+	go func() {
+		queue.Run(workersCtx)
+		close(queueDone)
+	}()
+
 	errCh := make(chan error, 1)
 	go func() {
 		if err := server.Start(); err != nil && err.Error() != "http: Server closed" {
@@ -62,9 +189,19 @@ func main() {
 		log.Error("server error", err)
 	}
 
+	// stop taking requests first, so nothing enqueues jobs while the queue drains
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 	if err := server.Stop(ctx); err != nil {
 		log.Error("graceful shutdown failed", slog.Any("error", err))
 	}
+
+	stopWorkers()
+
+	// let running jobs finish; anything cut off here is retried once its lease expires
+	select {
+	case <-queueDone:
+	case <-time.After(time.Duration(cfg.JOBS_DRAIN_TIMEOUT_S) * time.Second):
+		log.Warn("job queue did not drain before the timeout")
+	}
 }
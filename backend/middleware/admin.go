@@ -0,0 +1,75 @@
+package middleware
+
+import (
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/Neat-Snap/blueprint-backend/config"
+	"github.com/Neat-Snap/blueprint-backend/db"
+)
+
+func IsAdmin(cfg config.Config, u *db.User) bool {
+	if u == nil || u.Email == nil || u.EmailVerifiedAt == nil {
+		return false
+	}
+	for _, e := range cfg.ADMIN_EMAILS {
+		if strings.EqualFold(e, *u.Email) {
+			return true
+		}
+	}
+	return false
+}
+
+func AdminOnly(cfg config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userObj, ok := r.Context().Value(UserObjectContextKey).(*db.User)
+			if !ok {
+				http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+				return
+			}
+			if !IsAdmin(cfg, userObj) {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// forwardedHeaders are the headers middleware.RealIP takes the client address from; a
+// request carrying any of them came through a proxy, whatever its peer address.
+var forwardedHeaders = []string{"True-Client-IP", "X-Real-IP", "X-Forwarded-For"}
+
+// IsLocalRequest reports whether r was made on this machine directly, not through a proxy.
+// The forwarding headers are checked as well because middleware.RealIP has already put
+// their address into RemoteAddr, so a remote client could otherwise claim to be local.
+func IsLocalRequest(r *http.Request) bool {
+	for _, h := range forwardedHeaders {
+		if r.Header.Get(h) != "" {
+			return false
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// LocalOrAdmin lets through requests made on this machine and, from anywhere else, admins.
+// Local requests are not authenticated, see DefaultSkipper.
+func LocalOrAdmin(cfg config.Config) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		admins := AdminOnly(cfg)(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if IsLocalRequest(r) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			admins.ServeHTTP(w, r)
+		})
+	}
+}
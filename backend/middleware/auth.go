@@ -39,7 +39,18 @@ func DefaultSkipper(r *http.Request) bool {
 		strings.HasPrefix(path, "/auth/google"),
 		strings.HasPrefix(path, "/auth/github"),
 		strings.HasPrefix(path, "/auth/resend-email"),
-		strings.HasPrefix(path, "/auth/azuread"):
+		strings.HasPrefix(path, "/auth/secure-account"),
+		strings.HasPrefix(path, "/auth/azuread"),
+
+		// signed by the email provider, see handlers.EmailEventsAPI
+		strings.HasPrefix(path, "/email/webhooks/"),
+		// carries a signed token, see handlers.NotificationsAPI.DigestUnsubscribeEndpoint
+		path == "/notifications/digest/unsubscribe",
+		// public files under unguessable keys, see handlers.UploadsAPI
+		strings.HasPrefix(path, "/uploads/"),
+
+		// only registered with APP_ENV=dev; remote callers must be admins, see LocalOrAdmin
+		strings.HasPrefix(path, "/dev/") && IsLocalRequest(r):
 		return true
 	}
 	return false
@@ -70,7 +81,7 @@ func AuthMiddlewareBuilder(secret string, issuer string, audience string, logger
 				return
 			}
 
-			email, err := utils.DecodeJWT([]byte(secret), token, issuer, audience)
+			email, issuedAt, err := utils.DecodeJWTClaims([]byte(secret), token, issuer, audience)
 			if err != nil {
 				logger.Debug("auth: error during jwt decoding", "error", err)
 				http.Error(w, "unauthorized", http.StatusUnauthorized)
@@ -89,6 +100,14 @@ func AuthMiddlewareBuilder(secret string, issuer string, audience string, logger
 				return
 			}
 
+			// iat has whole seconds, so a token from the second of the revocation may predate it
+			// and is rejected too; signing in again a moment later works
+			if dbUser.SessionsRevokedAt != nil && !issuedAt.After(*dbUser.SessionsRevokedAt) {
+				logger.Debug("auth: token was issued before sessions were revoked", "user_id", dbUser.ID)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+
 			ctx := context.WithValue(r.Context(), UserEmailContextKey, email)
 			ctx = context.WithValue(ctx, UserObjectContextKey, dbUser)
 
@@ -0,0 +1,141 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/rbac"
+	"github.com/Neat-Snap/blueprint-backend/utils"
+	"github.com/go-chi/chi/v5"
+	"gorm.io/gorm"
+)
+
+const TeamAccessContextKey contextKey = "teamAccess"
+
+// TeamAccess is the caller's resolved standing in the team addressed by the request.
+type TeamAccess struct {
+	Team        *db.Team
+	UserID      uint
+	Role        string
+	IsOwner     bool
+	Permissions rbac.Set
+}
+
+func (a *TeamAccess) Can(p rbac.Permission) bool {
+	return a != nil && a.Permissions.Has(p)
+}
+
+var ErrNotTeamMember = errors.New("user is not a member of the team")
+
+// RolePermissions resolves a built-in or team-defined role name to its permissions.
+// Unknown roles fall back to the regular role, since membership alone grants read access.
+func RolePermissions(ctx context.Context, conn *db.Connection, teamID uint, role string) (rbac.Set, error) {
+	if perms, ok := rbac.BuiltinRoles[role]; ok {
+		return rbac.NewSet(perms...), nil
+	}
+	custom, err := conn.TeamRoles.ByName(ctx, teamID, role)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return rbac.NewSet(rbac.BuiltinRoles[rbac.RoleRegular]...), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return rbac.FromStrings(custom.Permissions), nil
+}
+
+func ResolveTeamAccess(ctx context.Context, conn *db.Connection, team *db.Team, userID uint) (*TeamAccess, error) {
+	access := &TeamAccess{Team: team, UserID: userID}
+	if team.OwnerID == userID {
+		access.IsOwner = true
+		access.Role = rbac.RoleOwner
+		access.Permissions = rbac.NewSet(rbac.BuiltinRoles[rbac.RoleOwner]...)
+		return access, nil
+	}
+
+	role, err := conn.Teams.GetUserRole(ctx, team.ID, userID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrNotTeamMember
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	perms, err := RolePermissions(ctx, conn, team.ID, role)
+	if err != nil {
+		return nil, err
+	}
+	access.Role = role
+	access.Permissions = perms
+	return access, nil
+}
+
+// TeamAuthorization loads the team from the {id} URL parameter and the caller's role once
+// per request. Non-members get a 404 so team IDs cannot be probed.
+func TeamAuthorization(conn *db.Connection, logger logger.MultiLogger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			userObj, ok := r.Context().Value(UserObjectContextKey).(*db.User)
+			if !ok {
+				http.Error(w, "user is not authenticated", http.StatusUnauthorized)
+				return
+			}
+
+			teamID, err := strconv.Atoi(chi.URLParam(r, "id"))
+			if err != nil {
+				utils.WriteError(w, logger, err, "invalid team ID", http.StatusBadRequest)
+				return
+			}
+
+			team, err := conn.Teams.ByID(r.Context(), uint(teamID))
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				utils.WriteError(w, logger, err, "team not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				utils.WriteError(w, logger, err, "failed to get team", http.StatusInternalServerError)
+				return
+			}
+
+			access, err := ResolveTeamAccess(r.Context(), conn, team, userObj.ID)
+			if errors.Is(err, ErrNotTeamMember) {
+				logger.Warn("user does not have access to team", "team_id", teamID, "user_id", userObj.ID)
+				utils.WriteError(w, logger, err, "team not found", http.StatusNotFound)
+				return
+			}
+			if err != nil {
+				utils.WriteError(w, logger, err, "failed to resolve team role", http.StatusInternalServerError)
+				return
+			}
+
+			next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), TeamAccessContextKey, access)))
+		})
+	}
+}
+
+func TeamAccessFromContext(ctx context.Context) *TeamAccess {
+	access, _ := ctx.Value(TeamAccessContextKey).(*TeamAccess)
+	return access
+}
+
+// RequireTeamPermission must run after TeamAuthorization.
+func RequireTeamPermission(logger logger.MultiLogger, p rbac.Permission) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			access := TeamAccessFromContext(r.Context())
+			if access == nil {
+				utils.WriteError(w, logger, nil, "team access was not resolved", http.StatusInternalServerError)
+				return
+			}
+			if !access.Can(p) {
+				logger.Warn("team permission denied", "team_id", access.Team.ID, "user_id", access.UserID, "permission", p)
+				utils.WriteError(w, logger, nil, "forbidden", http.StatusForbidden)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
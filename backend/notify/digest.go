@@ -0,0 +1,227 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/jobs"
+	"github.com/Neat-Snap/blueprint-backend/utils/email"
+	"gorm.io/gorm"
+)
+
+// Digest frequencies, see db.UserPreference.
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+var DigestFrequencies = []string{DigestOff, DigestDaily, DigestWeekly}
+
+const (
+	// weekly digests go out on this day
+	digestWeekday = time.Monday
+	digestBatch   = 500
+	// of each kind of line in one digest; the rest waits for the next one or the inbox
+	digestMaxItems = 50
+)
+
+type digestPayload struct {
+	UserID uint      `json:"user_id"`
+	Slot   time.Time `json:"slot"`
+}
+
+var (
+	digestScanJob = jobs.Kind[struct{}]("notifications.digest_scan")
+	digestJob     = jobs.Kind[digestPayload]("notifications.digest")
+)
+
+// ScheduleDigests looks for due digests every hour and sends each one from a job of its
+// own. hour is the local time, in each user's timezone, that digests go out at.
+func (d *Dispatcher) ScheduleDigests(q *jobs.Queue, hour int) {
+	jobs.Handle(q, digestScanJob, func(ctx context.Context, _ struct{}) error {
+		return d.enqueueDigests(ctx, q, hour, time.Now())
+	})
+	jobs.Handle(q, digestJob, d.sendDigest)
+	if err := jobs.Every(q, digestScanJob, time.Hour, struct{}{}); err != nil {
+		d.logger.Error("failed to schedule notification digests", "error", err)
+	}
+}
+
+func (d *Dispatcher) enqueueDigests(ctx context.Context, q *jobs.Queue, hour int, now time.Time) error {
+	zones, err := d.conn.Preferences.DigestZones(ctx)
+	if err != nil {
+		return err
+	}
+	for _, zone := range zones {
+		slot := DigestSlot(zone.DigestFrequency, Location(zone.Timezone), hour, now)
+		// someone who never had a digest gets their first one in the hour it is due
+		// rather than right away
+		first := now.Sub(slot) < time.Hour
+
+		var after uint
+		for {
+			list, err := d.conn.Preferences.DueDigests(ctx, zone, slot, first, after, digestBatch)
+			if err != nil {
+				return err
+			}
+			for _, pref := range list {
+				after = pref.ID
+				if _, err := jobs.Enqueue(ctx, q, digestJob, digestPayload{UserID: pref.UserID, Slot: slot}); err != nil {
+					return err
+				}
+			}
+			if len(list) < digestBatch {
+				break
+			}
+		}
+	}
+	return nil
+}
+
+// DigestSlot returns when the latest digest of frequency was due at or before now: hour
+// o'clock in loc every day, or every digestWeekday for weekly digests.
+func DigestSlot(frequency string, loc *time.Location, hour int, now time.Time) time.Time {
+	local := now.In(loc)
+	slot := time.Date(local.Year(), local.Month(), local.Day(), hour, 0, 0, 0, loc)
+	if slot.After(local) {
+		slot = slot.AddDate(0, 0, -1)
+	}
+	if frequency == DigestWeekly {
+		slot = slot.AddDate(0, 0, -((int(slot.Weekday()) - int(digestWeekday) + 7) % 7))
+	}
+	return slot
+}
+
+// Location loads a timezone saved in the preferences, falling back to UTC.
+func Location(name string) *time.Location {
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+func (d *Dispatcher) sendDigest(ctx context.Context, p digestPayload) error {
+	user, err := d.conn.Users.ByID(ctx, p.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.Email == nil || *user.Email == "" {
+		return nil
+	}
+	types, err := d.digestTypes(ctx, p.UserID)
+	if err != nil {
+		return err
+	}
+	lang := d.emailClient.Locale(ctx, *user.Email)
+
+	return d.conn.WithTx(ctx, func(tx *db.Connection) error {
+		pref, err := tx.Preferences.Get(ctx, p.UserID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if pref.DigestFrequency == DigestOff {
+			return nil
+		}
+		since := p.Slot.AddDate(0, 0, -1)
+		if pref.DigestFrequency == DigestWeekly {
+			since = p.Slot.AddDate(0, 0, -7)
+		}
+		if pref.LastDigestAt != nil {
+			since = *pref.LastDigestAt
+		}
+
+		now := time.Now()
+		claimed, err := tx.Preferences.ClaimDigest(ctx, p.UserID, p.Slot, now)
+		if err != nil || !claimed {
+			return err
+		}
+
+		items, lastItemID, err := d.digestItems(ctx, tx, lang, p.UserID, types, since)
+		if err != nil {
+			return err
+		}
+		// nothing new: the claim is kept so the user is not checked again until the next slot
+		if len(items) == 0 {
+			return nil
+		}
+		if lastItemID != 0 {
+			if err := tx.Notifications.MarkDigestSent(ctx, p.UserID, lastItemID, now); err != nil {
+				return err
+			}
+		}
+
+		// queued last, so a failure rolls the claim back and the job retries
+		err = d.emailClient.QueueDigestEmail(ctx, *user.Email, p.UserID, pref.DigestFrequency, items, Location(pref.Timezone))
+		if errors.Is(err, email.ErrSuppressed) {
+			d.logger.Info("skipped digest to suppressed address", "user_id", p.UserID)
+			return nil
+		}
+		return err
+	})
+}
+
+// digestTypes returns the notification types userID wants in their digest.
+func (d *Dispatcher) digestTypes(ctx context.Context, userID uint) ([]string, error) {
+	saved, err := d.conn.Preferences.NotificationChannels(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	var types []string
+	for _, spec := range Types {
+		channels := spec.Default
+		if i := slices.IndexFunc(saved, func(p db.NotificationPreference) bool { return p.Type == spec.Type }); i >= 0 && !spec.Locked {
+			channels = PreferenceChannels(spec, &saved[i])
+		}
+		if slices.Contains(channels, ChannelDigest) {
+			types = append(types, spec.Type)
+		}
+	}
+	return types, nil
+}
+
+// digestItems collects what is new for userID since the last digest, with titles in
+// lang: unread in-app notifications of types in the digest, and items stored for types
+// only delivered by digest. lastItemID is the newest of the stored items used.
+func (d *Dispatcher) digestItems(ctx context.Context, conn *db.Connection, lang string, userID uint, types []string, since time.Time) ([]email.DigestItem, uint, error) {
+	var items []email.DigestItem
+	if len(types) > 0 {
+		unread := false
+		list, err := conn.Notifications.List(ctx, db.NotificationFilter{
+			UserID:       userID,
+			Types:        types,
+			Read:         &unread,
+			CreatedAfter: since,
+			Limit:        digestMaxItems,
+		})
+		if err != nil {
+			return nil, 0, err
+		}
+		for _, n := range list {
+			items = append(items, email.DigestItem{Title: d.Render(lang, n.Type, n.Version, []byte(n.Data)).Title, CreatedAt: n.CreatedAt})
+		}
+	}
+
+	pending, err := conn.Notifications.PendingDigestItems(ctx, userID, digestMaxItems)
+	if err != nil {
+		return nil, 0, err
+	}
+	var lastID uint
+	for _, item := range pending {
+		items = append(items, email.DigestItem{Title: d.Render(lang, item.Type, item.Version, []byte(item.Data)).Title, CreatedAt: item.CreatedAt})
+		lastID = item.ID
+	}
+
+	slices.SortFunc(items, func(a, b email.DigestItem) int { return b.CreatedAt.Compare(a.CreatedAt) })
+	return items, lastID, nil
+}
@@ -0,0 +1,38 @@
+package notify
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDigestSlot(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	if err != nil {
+		t.Skip("no timezone database:", err)
+	}
+	// Wednesday 2026-10-14 and the Monday before it
+	for _, tc := range []struct {
+		name      string
+		frequency string
+		loc       *time.Location
+		now       time.Time
+		want      time.Time
+	}{
+		{"daily, after the hour", DigestDaily, time.UTC, time.Date(2026, 10, 14, 9, 30, 0, 0, time.UTC), time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)},
+		{"daily, on the hour", DigestDaily, time.UTC, time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC), time.Date(2026, 10, 14, 8, 0, 0, 0, time.UTC)},
+		{"daily, before the hour", DigestDaily, time.UTC, time.Date(2026, 10, 14, 7, 59, 0, 0, time.UTC), time.Date(2026, 10, 13, 8, 0, 0, 0, time.UTC)},
+		{"weekly, midweek", DigestWeekly, time.UTC, time.Date(2026, 10, 14, 9, 0, 0, 0, time.UTC), time.Date(2026, 10, 12, 8, 0, 0, 0, time.UTC)},
+		{"weekly, Monday after the hour", DigestWeekly, time.UTC, time.Date(2026, 10, 12, 8, 0, 0, 0, time.UTC), time.Date(2026, 10, 12, 8, 0, 0, 0, time.UTC)},
+		{"weekly, Monday before the hour", DigestWeekly, time.UTC, time.Date(2026, 10, 12, 7, 0, 0, 0, time.UTC), time.Date(2026, 10, 5, 8, 0, 0, 0, time.UTC)},
+		// 08:00 in Berlin is 06:00 UTC in summer time
+		{"daily, in the user's zone", DigestDaily, berlin, time.Date(2026, 10, 14, 6, 30, 0, 0, time.UTC), time.Date(2026, 10, 14, 8, 0, 0, 0, berlin)},
+		{"daily, not yet due in the user's zone", DigestDaily, berlin, time.Date(2026, 10, 14, 5, 30, 0, 0, time.UTC), time.Date(2026, 10, 13, 8, 0, 0, 0, berlin)},
+		// the clocks go back on 2026-10-25; the slot stays at 08:00 local time
+		{"weekly, across the change from summer time", DigestWeekly, berlin, time.Date(2026, 10, 27, 12, 0, 0, 0, time.UTC), time.Date(2026, 10, 26, 8, 0, 0, 0, berlin)},
+	} {
+		got := DigestSlot(tc.frequency, tc.loc, 8, tc.now)
+		if !got.Equal(tc.want) {
+			t.Errorf("%s: DigestSlot = %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,192 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/events"
+	"github.com/Neat-Snap/blueprint-backend/jobs"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/Neat-Snap/blueprint-backend/utils/email"
+	"github.com/Neat-Snap/blueprint-backend/webpush"
+	"gorm.io/gorm"
+)
+
+// Message is one notification for one user.
+type Message struct {
+	UserID uint
+	// decides the type; stored as JSON with the notification
+	Data Payload
+	// sends the email to this address instead of the user's current one
+	Email string
+}
+
+// Dispatcher delivers notifications on the channels each user chose for their type.
+type Dispatcher struct {
+	conn        *db.Connection
+	emailClient *email.EmailClient
+	logger      logger.MultiLogger
+	// nil until UsePush; the push channel is skipped without it
+	push  *webpush.Sender
+	queue *jobs.Queue
+}
+
+func NewDispatcher(conn *db.Connection, emailClient *email.EmailClient, logger logger.MultiLogger) *Dispatcher {
+	return &Dispatcher{conn: conn, emailClient: emailClient, logger: logger}
+}
+
+// Channels returns the channels userID receives notifications of notificationType on.
+func (d *Dispatcher) Channels(ctx context.Context, conn *db.Connection, userID uint, notificationType string) ([]string, error) {
+	spec := Lookup(notificationType)
+	if spec.Locked {
+		return spec.Default, nil
+	}
+	pref, err := conn.Preferences.NotificationChannel(ctx, userID, notificationType)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return spec.Default, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return PreferenceChannels(spec, pref), nil
+}
+
+// PreferenceChannels lists the channels enabled in pref that spec allows.
+func PreferenceChannels(spec TypeSpec, pref *db.NotificationPreference) []string {
+	channels := []string{}
+	for channel, on := range map[string]bool{ChannelInApp: pref.InApp, ChannelPush: pref.Push, ChannelEmail: pref.Email, ChannelDigest: pref.Digest} {
+		if on && spec.Allows(channel) {
+			channels = append(channels, channel)
+		}
+	}
+	slices.SortFunc(channels, func(a, b string) int {
+		return slices.Index(spec.Channels, a) - slices.Index(spec.Channels, b)
+	})
+	return channels
+}
+
+// Send delivers m through conn, so inside a transaction nothing goes out unless it
+// commits: the in-app notification and digest entry are rows, and emails are queued
+// from an outbox event.
+// Payloads of unregistered types or failing validation are refused.
+func (d *Dispatcher) Send(ctx context.Context, conn *db.Connection, m Message) error {
+	if m.Data == nil {
+		return errors.New("notification has no payload")
+	}
+	typ := m.Data.NotificationType()
+	if !Known(typ) {
+		return fmt.Errorf("unknown notification type %q", typ)
+	}
+	if err := m.Data.Validate(); err != nil {
+		return fmt.Errorf("invalid %s notification: %w", typ, err)
+	}
+	data, err := json.Marshal(m.Data)
+	if err != nil {
+		return err
+	}
+	version := Lookup(typ).Version()
+	channels, err := d.Channels(ctx, conn, m.UserID, typ)
+	if err != nil {
+		return err
+	}
+
+	for _, channel := range channels {
+		switch channel {
+		case ChannelInApp:
+			n := &db.Notification{UserID: m.UserID, Type: typ, Data: string(data), Version: version, TeamID: payloadTeam(data)}
+			if err := conn.Notifications.Create(ctx, n); err != nil {
+				return err
+			}
+			err = events.Emit(ctx, conn, events.NotificationCreated, events.NotificationCreatedPayload{
+				NotificationID: n.ID,
+				UserID:         n.UserID,
+				Type:           n.Type,
+			})
+		case ChannelPush:
+			err = events.Emit(ctx, conn, events.NotificationPushRequested, events.NotificationPushRequestedPayload{
+				UserID:  m.UserID,
+				Type:    typ,
+				Version: version,
+				Data:    data,
+			})
+		case ChannelEmail:
+			err = events.Emit(ctx, conn, events.NotificationEmailRequested, events.NotificationEmailRequestedPayload{
+				UserID:  m.UserID,
+				Type:    typ,
+				Version: version,
+				Data:    data,
+				Email:   m.Email,
+			})
+		case ChannelDigest:
+			// the digest picks up the in-app copy while it is unread
+			if slices.Contains(channels, ChannelInApp) {
+				continue
+			}
+			err = conn.Notifications.AddDigestItem(ctx, &db.NotificationDigestItem{UserID: m.UserID, Type: typ, Data: string(data), Version: version})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SubscribeEvents registers the email and push channels on the in-process bus.
+func (d *Dispatcher) SubscribeEvents(bus *events.Bus) {
+	bus.Subscribe(events.NotificationEmailRequested, "notification-email", d.sendEmail)
+	bus.Subscribe(events.NotificationPushRequested, "notification-push", d.sendPush)
+}
+
+func (d *Dispatcher) sendEmail(ctx context.Context, e events.Event) error {
+	p, err := events.Decode[events.NotificationEmailRequestedPayload](e)
+	if err != nil {
+		return err
+	}
+
+	recipient := p.Email
+	if recipient == "" {
+		user, err := d.conn.Users.ByID(ctx, p.UserID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if user.Email == nil || *user.Email == "" {
+			return nil
+		}
+		recipient = *user.Email
+	}
+
+	payload, err := Decode(p.Type, p.Version, p.Data)
+	if err != nil {
+		return err
+	}
+	if alert, ok := payload.(*SecurityAlert); ok {
+		err = d.emailClient.QueueSecurityAlertEmail(ctx, recipient, email.SecurityAlert(*alert))
+	} else {
+		rendered := d.renderPayload(d.emailClient.Locale(ctx, recipient), payload)
+		err = d.emailClient.QueueNotificationEmail(ctx, recipient, rendered.Title, rendered.Body, d.emailClient.Config.APP_URL+rendered.Link)
+	}
+	// the address bounced before; the in-app copy is all the user gets
+	if errors.Is(err, email.ErrSuppressed) {
+		d.logger.Info("skipped notification email to suppressed address", "type", p.Type, "user_id", p.UserID)
+		return nil
+	}
+	return err
+}
+
+// payloadTeam returns the team_id of a payload, or nil when it is not about a team.
+func payloadTeam(data []byte) *uint {
+	var p struct {
+		TeamID uint `json:"team_id"`
+	}
+	if json.Unmarshal(data, &p) != nil || p.TeamID == 0 {
+		return nil
+	}
+	return &p.TeamID
+}
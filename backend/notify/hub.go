@@ -0,0 +1,263 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/go-redis/redis/v8"
+)
+
+// Stream event types.
+const (
+	EventNotification = "notification"
+	EventRead         = "read"
+	EventArchived     = "archived"
+	EventUnarchived   = "unarchived"
+	EventDeleted      = "deleted"
+	// the client missed events it cannot be sent any more and should reload its inbox
+	EventResync = "resync"
+)
+
+const (
+	// events kept per user for Last-Event-ID resume
+	backlogSize = 200
+	backlogTTL  = time.Hour
+	// a subscriber this far behind is dropped; it reconnects and resumes from the backlog
+	subscriberBuffer = 32
+)
+
+// Event is one message on a user's stream. ID is the Redis stream entry ID, which orders
+// events and is what clients send back as Last-Event-ID.
+type Event struct {
+	ID     string          `json:"id"`
+	UserID uint            `json:"user_id"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Hub fans events out to the streams users have open on any replica. Every event is
+// appended to a short per-user Redis stream, for resume, and published on a per-user
+// pub/sub channel that a replica only subscribes to while that user has a stream open on
+// it.
+type Hub struct {
+	rdb    *redis.Client
+	logger logger.MultiLogger
+	prefix string
+	pubsub *redis.PubSub
+
+	mu   sync.Mutex
+	subs map[uint]map[chan Event]struct{}
+	// serializes changes to the Redis subscriptions
+	channelsMu sync.Mutex
+}
+
+func NewHub(rdb *redis.Client, logger logger.MultiLogger, prefix string) *Hub {
+	return &Hub{
+		rdb:    rdb,
+		logger: logger,
+		prefix: prefix,
+		pubsub: rdb.Subscribe(context.Background()),
+		subs:   make(map[uint]map[chan Event]struct{}),
+	}
+}
+
+func (h *Hub) backlogKey(userID uint) string {
+	return h.prefix + ":backlog:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+func (h *Hub) channel(userID uint) string {
+	return h.prefix + ":user:" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// Publish sends an event to every stream userID has open.
+func (h *Hub) Publish(ctx context.Context, userID uint, eventType string, payload any) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	key := h.backlogKey(userID)
+	id, err := h.rdb.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: backlogSize,
+		Approx: true,
+		Values: map[string]any{"type": eventType, "data": string(data)},
+	}).Result()
+	if err != nil {
+		return err
+	}
+	if err := h.rdb.Expire(ctx, key, backlogTTL).Err(); err != nil {
+		return err
+	}
+
+	msg, err := json.Marshal(Event{ID: id, UserID: userID, Type: eventType, Data: data})
+	if err != nil {
+		return err
+	}
+	return h.rdb.Publish(ctx, h.channel(userID), msg).Err()
+}
+
+// Subscribe registers a local listener for userID. The channel is closed when the
+// listener falls too far behind; call the returned func once done.
+func (h *Hub) Subscribe(ctx context.Context, userID uint) (<-chan Event, func(), error) {
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	first := len(h.subs[userID]) == 0
+	if first {
+		h.subs[userID] = make(map[chan Event]struct{})
+	}
+	h.subs[userID][ch] = struct{}{}
+	h.mu.Unlock()
+
+	if first {
+		h.channelsMu.Lock()
+		err := h.pubsub.Subscribe(ctx, h.channel(userID))
+		h.channelsMu.Unlock()
+		if err != nil {
+			h.remove(userID, ch)
+			return nil, nil, err
+		}
+	}
+
+	var once sync.Once
+	return ch, func() { once.Do(func() { h.remove(userID, ch) }) }, nil
+}
+
+func (h *Hub) remove(userID uint, ch chan Event) {
+	h.mu.Lock()
+	subs := h.subs[userID]
+	if _, ok := subs[ch]; ok {
+		delete(subs, ch)
+		close(ch)
+	}
+	last := len(subs) == 0
+	if last {
+		delete(h.subs, userID)
+	}
+	h.mu.Unlock()
+
+	if !last {
+		return
+	}
+	h.channelsMu.Lock()
+	defer h.channelsMu.Unlock()
+	// a new listener may have arrived in the meantime
+	h.mu.Lock()
+	last = len(h.subs[userID]) == 0
+	h.mu.Unlock()
+	if last {
+		if err := h.pubsub.Unsubscribe(context.Background(), h.channel(userID)); err != nil {
+			h.logger.Warn("failed to unsubscribe notification channel", "error", err, "user_id", userID)
+		}
+	}
+}
+
+// Run delivers published events to local listeners until ctx is done. Listeners still
+// open then are closed, so their streams end and the server can shut down.
+func (h *Hub) Run(ctx context.Context) {
+	defer h.closeAll()
+	defer h.pubsub.Close()
+	messages := h.pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg, ok := <-messages:
+			if !ok {
+				return
+			}
+			var e Event
+			if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+				h.logger.Warn("invalid notification event", "error", err)
+				continue
+			}
+			h.dispatch(e)
+		}
+	}
+}
+
+func (h *Hub) dispatch(e Event) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subs[e.UserID] {
+		select {
+		case ch <- e:
+		default:
+			delete(h.subs[e.UserID], ch)
+			close(ch)
+			h.logger.Debug("dropped slow notification stream", "user_id", e.UserID)
+		}
+	}
+}
+
+func (h *Hub) closeAll() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for userID, subs := range h.subs {
+		for ch := range subs {
+			close(ch)
+		}
+		delete(h.subs, userID)
+	}
+}
+
+// Since returns the events after lastID that are still in the backlog. gap reports that
+// some events after lastID were already trimmed away, or lastID is not one we issued.
+func (h *Hub) Since(ctx context.Context, userID uint, lastID string) (list []Event, gap bool, err error) {
+	if !validID(lastID) {
+		return nil, true, nil
+	}
+	key := h.backlogKey(userID)
+	entries, err := h.rdb.XRange(ctx, key, lastID, "+").Result()
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, entry := range entries {
+		if entry.ID == lastID {
+			continue
+		}
+		typ, _ := entry.Values["type"].(string)
+		data, _ := entry.Values["data"].(string)
+		list = append(list, Event{ID: entry.ID, UserID: userID, Type: typ, Data: json.RawMessage(data)})
+	}
+
+	// when lastID itself is still there nothing in between can be missing
+	if len(entries) > 0 && entries[0].ID == lastID {
+		return list, false, nil
+	}
+	oldest, err := h.rdb.XRangeN(ctx, key, "-", "+", 1).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	return list, len(oldest) == 0 || After(oldest[0].ID, lastID), nil
+}
+
+// After reports whether stream ID a comes after b.
+func After(a, b string) bool {
+	am, as := splitID(a)
+	bm, bs := splitID(b)
+	return am > bm || am == bm && as > bs
+}
+
+func splitID(id string) (uint64, uint64) {
+	ms, seq, _ := strings.Cut(id, "-")
+	m, _ := strconv.ParseUint(ms, 10, 64)
+	s, _ := strconv.ParseUint(seq, 10, 64)
+	return m, s
+}
+
+func validID(id string) bool {
+	ms, seq, ok := strings.Cut(id, "-")
+	if !ok {
+		return false
+	}
+	_, err1 := strconv.ParseUint(ms, 10, 64)
+	_, err2 := strconv.ParseUint(seq, 10, 64)
+	return err1 == nil && err2 == nil
+}
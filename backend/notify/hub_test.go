@@ -0,0 +1,164 @@
+package notify
+
+import (
+	"context"
+	"os"
+	"slices"
+	"testing"
+
+	"github.com/Neat-Snap/blueprint-backend/logger"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestAfter(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{"1700000000001-0", "1700000000000-0", true},
+		{"1700000000000-0", "1700000000001-0", false},
+		{"1700000000000-1", "1700000000000-0", true},
+		{"1700000000000-0", "1700000000000-0", false},
+		// compared as numbers, not strings
+		{"10-0", "9-0", true},
+		{"5-10", "5-9", true},
+		{"1700000000000-2", "999999999999-99", true},
+	} {
+		if got := After(tc.a, tc.b); got != tc.want {
+			t.Errorf("After(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestValidID(t *testing.T) {
+	for id, want := range map[string]bool{
+		"1700000000000-0":  true,
+		"0-1":              true,
+		"":                 false,
+		"1700000000000":    false,
+		"-":                false,
+		"1700000000000-":   false,
+		"-0":               false,
+		"abc-0":            false,
+		"1-abc":            false,
+		"-1-0":             false,
+		"1-0-0":            false,
+		"1700000000000-+":  false,
+		"+":                false,
+		"$":                false,
+		" 1700000000000-0": false,
+	} {
+		if got := validID(id); got != want {
+			t.Errorf("validID(%q) = %v, want %v", id, got, want)
+		}
+	}
+}
+
+// testHub returns a hub on a Redis server, such as a local one:
+//
+//	docker run -p 6379:6379 redis
+//	NOTIFY_TEST_REDIS_ADDR=localhost:6379 go test ./notify
+func testHub(t *testing.T) *Hub {
+	t.Helper()
+	addr := os.Getenv("NOTIFY_TEST_REDIS_ADDR")
+	if addr == "" {
+		t.Skip("NOTIFY_TEST_REDIS_ADDR is not set")
+	}
+	rdb := redis.NewClient(&redis.Options{Addr: addr})
+	h := NewHub(rdb, logger.MultiLogger{}, "notify-test:"+t.Name())
+	t.Cleanup(func() {
+		h.pubsub.Close()
+		rdb.Del(context.Background(), h.backlogKey(1))
+		rdb.Close()
+	})
+	return h
+}
+
+func publish(t *testing.T, h *Hub, n int) []string {
+	t.Helper()
+	ctx := context.Background()
+	for i := 0; i < n; i++ {
+		if err := h.Publish(ctx, 1, EventRead, map[string]int{"i": i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries, err := h.rdb.XRange(ctx, h.backlogKey(1), "-", "+").Result()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(entries))
+	for i, e := range entries {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+func eventIDs(list []Event) []string {
+	ids := make([]string, len(list))
+	for i, e := range list {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+func TestHubSince(t *testing.T) {
+	h := testHub(t)
+	ctx := context.Background()
+	ids := publish(t, h, 5)
+
+	list, gap, err := h.Since(ctx, 1, ids[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if gap || !slices.Equal(eventIDs(list), ids[2:]) {
+		t.Errorf("Since(%s) = %v, gap %v; want %v without a gap", ids[1], eventIDs(list), gap, ids[2:])
+	}
+	if list[0].Type != EventRead || string(list[0].Data) != `{"i":2}` || list[0].UserID != 1 {
+		t.Errorf("first event = %+v", list[0])
+	}
+
+	list, gap, err = h.Since(ctx, 1, ids[4])
+	if err != nil || gap || len(list) != 0 {
+		t.Errorf("Since the newest event = %v, gap %v, err %v; want nothing", eventIDs(list), gap, err)
+	}
+
+	// IDs that are not ours ask for a resync rather than reaching XRANGE
+	for _, id := range []string{"garbage", "-", "+", "0"} {
+		list, gap, err = h.Since(ctx, 1, id)
+		if err != nil || !gap || len(list) != 0 {
+			t.Errorf("Since(%q) = %v, gap %v, err %v; want a gap", id, eventIDs(list), gap, err)
+		}
+	}
+}
+
+func TestHubSinceTrimmed(t *testing.T) {
+	h := testHub(t)
+	ctx := context.Background()
+	ids := publish(t, h, 5)
+
+	// the backlog lost its two oldest events, as MaxLen does once it is full
+	if err := h.rdb.XTrimMaxLen(ctx, h.backlogKey(1), 3).Err(); err != nil {
+		t.Fatal(err)
+	}
+	// the stream endpoint answers a gap with EventResync before the events it still has
+	list, gap, err := h.Since(ctx, 1, ids[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !gap || !slices.Equal(eventIDs(list), ids[2:]) {
+		t.Errorf("Since a trimmed event = %v, gap %v; want %v with a gap", eventIDs(list), gap, ids[2:])
+	}
+
+	// nothing is missing after the oldest event kept
+	if _, gap, err := h.Since(ctx, 1, ids[2]); err != nil || gap {
+		t.Errorf("Since the oldest kept event: gap %v, err %v", gap, err)
+	}
+
+	// an expired backlog has nothing to resume from
+	if err := h.rdb.Del(ctx, h.backlogKey(1)).Err(); err != nil {
+		t.Fatal(err)
+	}
+	if list, gap, err := h.Since(ctx, 1, ids[4]); err != nil || !gap || len(list) != 0 {
+		t.Errorf("Since on an expired backlog = %v, gap %v, err %v; want a gap", eventIDs(list), gap, err)
+	}
+}
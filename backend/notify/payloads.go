@@ -0,0 +1,300 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/utils/email"
+)
+
+// Payload is the data of one notification type, stored as JSON with each notification.
+// Its fields are what the type's text and link are built from, so clients never read
+// the payload to show a notification.
+type Payload interface {
+	NotificationType() string
+	// Validate reports a payload missing something its text or link needs
+	Validate() error
+	// Text returns the catalog keys of the title and body and the values of their
+	// {name} placeholders.
+	Text() (title, body string, params map[string]any)
+	// Link is the app path the notification leads to.
+	Link() string
+}
+
+const (
+	inboxLink   = "/dashboard/notifications"
+	accountLink = "/dashboard/account"
+)
+
+func teamLink(teamID uint) string {
+	return fmt.Sprintf("/dashboard/settings?team=%d", teamID)
+}
+
+func typeText(notificationType string, params map[string]any) (string, string, map[string]any) {
+	key := "notification.types." + notificationType
+	return key + ".subject", key + ".body", params
+}
+
+// requireFields returns an error naming the first of the name/value pairs whose value is
+// the zero value.
+func requireFields(pairs ...any) error {
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if v := reflect.ValueOf(pairs[i+1]); !v.IsValid() || v.IsZero() {
+			return fmt.Errorf("%s is required", pairs[i])
+		}
+	}
+	return nil
+}
+
+// TeamInvite tells an existing user about an invitation to a team.
+type TeamInvite struct {
+	TeamID   uint   `json:"team_id"`
+	TeamName string `json:"team_name"`
+	// accepts the invitation, see TeamsAPI.AcceptInvitationEndpoint
+	Token string `json:"token"`
+	Role  string `json:"role"`
+}
+
+func (TeamInvite) NotificationType() string { return TypeTeamInvite }
+
+func (p TeamInvite) Validate() error {
+	return requireFields("team_id", p.TeamID, "team_name", p.TeamName, "token", p.Token, "role", p.Role)
+}
+
+func (p TeamInvite) Text() (string, string, map[string]any) {
+	return typeText(TypeTeamInvite, map[string]any{"team_name": p.TeamName, "role": email.Role(p.Role)})
+}
+
+// the inbox is where invitations are accepted
+func (TeamInvite) Link() string { return inboxLink }
+
+// MemberJoined goes to a team's owner and everyone who can invite members.
+type MemberJoined struct {
+	TeamID   uint   `json:"team_id"`
+	TeamName string `json:"team_name"`
+	UserID   uint   `json:"user_id"`
+	Member   string `json:"member"`
+	Role     string `json:"role"`
+}
+
+func (MemberJoined) NotificationType() string { return TypeMemberJoined }
+
+func (p MemberJoined) Validate() error {
+	return requireFields("team_id", p.TeamID, "team_name", p.TeamName, "user_id", p.UserID, "member", p.Member)
+}
+
+func (p MemberJoined) Text() (string, string, map[string]any) {
+	return typeText(TypeMemberJoined, map[string]any{"team_name": p.TeamName, "member": p.Member, "role": email.Role(p.Role)})
+}
+
+func (p MemberJoined) Link() string { return teamLink(p.TeamID) }
+
+// MemberLeft goes to a team's owner and everyone who can remove members.
+type MemberLeft struct {
+	TeamID   uint   `json:"team_id"`
+	TeamName string `json:"team_name"`
+	UserID   uint   `json:"user_id"`
+	Member   string `json:"member"`
+}
+
+func (MemberLeft) NotificationType() string { return TypeMemberLeft }
+
+func (p MemberLeft) Validate() error {
+	return requireFields("team_id", p.TeamID, "team_name", p.TeamName, "user_id", p.UserID, "member", p.Member)
+}
+
+func (p MemberLeft) Text() (string, string, map[string]any) {
+	return typeText(TypeMemberLeft, map[string]any{"team_name": p.TeamName, "member": p.Member})
+}
+
+func (p MemberLeft) Link() string { return teamLink(p.TeamID) }
+
+// RoleChanged goes to the member whose role changed.
+type RoleChanged struct {
+	TeamID   uint   `json:"team_id"`
+	TeamName string `json:"team_name"`
+	Role     string `json:"role"`
+	FromRole string `json:"from_role"`
+	By       string `json:"by"`
+}
+
+func (RoleChanged) NotificationType() string { return TypeRoleChanged }
+
+func (p RoleChanged) Validate() error {
+	return requireFields("team_id", p.TeamID, "team_name", p.TeamName, "role", p.Role, "from_role", p.FromRole, "by", p.By)
+}
+
+func (p RoleChanged) Text() (string, string, map[string]any) {
+	return typeText(TypeRoleChanged, map[string]any{"team_name": p.TeamName, "role": email.Role(p.Role), "from_role": email.Role(p.FromRole), "by": p.By})
+}
+
+func (p RoleChanged) Link() string { return teamLink(p.TeamID) }
+
+// TransferRequest asks a member to take over a team.
+type TransferRequest struct {
+	TransferID uint      `json:"transfer_id"`
+	TeamID     uint      `json:"team_id"`
+	TeamName   string    `json:"team_name"`
+	From       string    `json:"from"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+func (TransferRequest) NotificationType() string { return TypeTransferRequest }
+
+func (p TransferRequest) Validate() error {
+	return requireFields("transfer_id", p.TransferID, "team_id", p.TeamID, "team_name", p.TeamName, "from", p.From, "expires_at", p.ExpiresAt)
+}
+
+func (p TransferRequest) Text() (string, string, map[string]any) {
+	return typeText(TypeTransferRequest, map[string]any{"team_name": p.TeamName, "from": p.From})
+}
+
+func (p TransferRequest) Link() string { return teamLink(p.TeamID) }
+
+// OwnershipTransferred goes to the previous and the new owner.
+type OwnershipTransferred struct {
+	TeamID     uint   `json:"team_id"`
+	TeamName   string `json:"team_name"`
+	FromUserID uint   `json:"from_user_id"`
+	ToUserID   uint   `json:"to_user_id"`
+	NewOwner   string `json:"new_owner"`
+}
+
+func (OwnershipTransferred) NotificationType() string { return TypeOwnershipTransferred }
+
+func (p OwnershipTransferred) Validate() error {
+	return requireFields("team_id", p.TeamID, "team_name", p.TeamName, "from_user_id", p.FromUserID, "to_user_id", p.ToUserID, "new_owner", p.NewOwner)
+}
+
+func (p OwnershipTransferred) Text() (string, string, map[string]any) {
+	return typeText(TypeOwnershipTransferred, map[string]any{"team_name": p.TeamName, "new_owner": p.NewOwner})
+}
+
+func (p OwnershipTransferred) Link() string { return teamLink(p.TeamID) }
+
+// TransferDeclined goes to the owner who offered the team.
+type TransferDeclined struct {
+	TeamID   uint   `json:"team_id"`
+	TeamName string `json:"team_name"`
+	By       string `json:"by"`
+}
+
+func (TransferDeclined) NotificationType() string { return TypeTransferDeclined }
+
+func (p TransferDeclined) Validate() error {
+	return requireFields("team_id", p.TeamID, "team_name", p.TeamName, "by", p.By)
+}
+
+func (p TransferDeclined) Text() (string, string, map[string]any) {
+	return typeText(TypeTransferDeclined, map[string]any{"team_name": p.TeamName, "by": p.By})
+}
+
+func (p TransferDeclined) Link() string { return teamLink(p.TeamID) }
+
+// EmailSuppressed tells a user that emails to their address stopped.
+type EmailSuppressed struct {
+	Email  string `json:"email"`
+	Reason string `json:"reason"`
+}
+
+func (EmailSuppressed) NotificationType() string { return TypeEmailSuppressed }
+
+func (p EmailSuppressed) Validate() error {
+	return requireFields("email", p.Email, "reason", p.Reason)
+}
+
+func (p EmailSuppressed) Text() (string, string, map[string]any) {
+	return typeText(TypeEmailSuppressed, map[string]any{"email": p.Email})
+}
+
+func (EmailSuppressed) Link() string { return accountLink }
+
+// SecurityAlert is the alert emailed by QueueSecurityAlertEmail, shown in the app too.
+type SecurityAlert email.SecurityAlert
+
+func (SecurityAlert) NotificationType() string { return TypeSecurityAlert }
+
+func (p SecurityAlert) Validate() error {
+	switch p.Kind {
+	case email.AlertPasswordChanged, email.AlertEmailChanged, email.AlertProviderLinked, email.AlertNewDevice:
+		return nil
+	}
+	return fmt.Errorf("unknown security alert kind %q", p.Kind)
+}
+
+// the email catalogs already word every kind of alert
+func (p SecurityAlert) Text() (string, string, map[string]any) {
+	key := "securityAlert." + string(p.Kind)
+	return key + ".subject", key + ".message", nil
+}
+
+func (SecurityAlert) Link() string { return accountLink }
+
+// Decode reads a payload of notificationType stored at version, upgrading it to the
+// current version. Rows from before payloads had versions read as version 1.
+func Decode(notificationType string, version int, data []byte) (Payload, error) {
+	spec := Lookup(notificationType)
+	if spec.New == nil {
+		return nil, fmt.Errorf("unknown notification type %q", notificationType)
+	}
+	version = max(version, 1)
+	if version > spec.Version() {
+		return nil, fmt.Errorf("%s payload version %d is newer than this build knows", notificationType, version)
+	}
+	raw := json.RawMessage(data)
+	for v := version; v < spec.Version(); v++ {
+		var err error
+		if raw, err = spec.Upgrades[v-1](raw); err != nil {
+			return nil, fmt.Errorf("upgrade %s payload from version %d: %w", notificationType, v, err)
+		}
+	}
+	p := spec.New()
+	if err := json.Unmarshal(raw, p); err != nil {
+		return nil, fmt.Errorf("decode %s payload: %w", notificationType, err)
+	}
+	return p, nil
+}
+
+// Rendered is a notification as its recipient reads it.
+type Rendered struct {
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	// path in the app, relative to APP_URL
+	Link string `json:"link"`
+}
+
+// Render returns the title, body and link of a stored notification in lang. Payloads
+// that cannot be decoded get a generic text leading to the inbox.
+func (d *Dispatcher) Render(lang, notificationType string, version int, data []byte) Rendered {
+	p, err := Decode(notificationType, version, data)
+	if err != nil {
+		d.logger.Warn("failed to decode notification payload", "error", err, "type", notificationType)
+		return d.render(lang, "notification.types.generic.subject", "notification.types.generic.body", nil, inboxLink)
+	}
+	return d.renderPayload(lang, p)
+}
+
+func (d *Dispatcher) renderPayload(lang string, p Payload) Rendered {
+	title, body, params := p.Text()
+	return d.render(lang, title, body, params, p.Link())
+}
+
+func (d *Dispatcher) render(lang, title, body string, params map[string]any, link string) Rendered {
+	return Rendered{
+		Title: d.emailClient.Translate(lang, title, params),
+		Body:  d.emailClient.Translate(lang, body, params),
+		Link:  link,
+	}
+}
+
+// Locale is the language user reads notifications in, the one their emails use.
+func (d *Dispatcher) Locale(ctx context.Context, user *db.User) string {
+	if user.Email == nil {
+		return email.DefaultLocale
+	}
+	return d.emailClient.Locale(ctx, *user.Email)
+}
@@ -0,0 +1,166 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/events"
+	"github.com/Neat-Snap/blueprint-backend/jobs"
+	"github.com/Neat-Snap/blueprint-backend/webpush"
+	"gorm.io/gorm"
+)
+
+// TypePushTest is the push sent to check that a browser receives them. It is not stored.
+const TypePushTest = "push_test"
+
+// how long push services hold a push for a browser that is offline
+const pushTTL = 24 * time.Hour
+
+// PushMessage is the JSON the service worker receives.
+type PushMessage struct {
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	Body  string `json:"body"`
+	URL   string `json:"url"`
+}
+
+type pushPayload struct {
+	SubscriptionID uint            `json:"subscription_id"`
+	Endpoint       string          `json:"endpoint"`
+	P256dh         string          `json:"p256dh"`
+	Auth           string          `json:"auth"`
+	Message        json.RawMessage `json:"message"`
+}
+
+var pushJob = jobs.Kind[pushPayload]("notifications.push")
+
+// UsePush turns the push channel on. Each browser gets its push from a job of its own on
+// q, so one slow or failing push service does not hold up the others.
+func (d *Dispatcher) UsePush(sender *webpush.Sender, q *jobs.Queue) {
+	d.push = sender
+	d.queue = q
+	jobs.Handle(q, pushJob, d.deliverPush)
+}
+
+// PushPublicKey is the VAPID key browsers subscribe with; ok is false while push is off.
+func (d *Dispatcher) PushPublicKey() (key string, ok bool) {
+	if d.push == nil {
+		return "", false
+	}
+	return d.push.PublicKey(), true
+}
+
+func (d *Dispatcher) sendPush(ctx context.Context, e events.Event) error {
+	if d.push == nil {
+		return nil
+	}
+	p, err := events.Decode[events.NotificationPushRequestedPayload](e)
+	if err != nil {
+		return err
+	}
+	subs, err := d.conn.Push.ListByUser(ctx, p.UserID)
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+	user, err := d.conn.Users.ByID(ctx, p.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	message, err := d.pushMessage(p.Type, d.Render(d.Locale(ctx, user), p.Type, p.Version, p.Data))
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		if _, err := jobs.Enqueue(ctx, d.queue, pushJob, pushPayload{
+			SubscriptionID: sub.ID,
+			Endpoint:       sub.Endpoint,
+			P256dh:         sub.P256dh,
+			Auth:           sub.Auth,
+			Message:        message,
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *Dispatcher) pushMessage(notificationType string, r Rendered) ([]byte, error) {
+	return json.Marshal(PushMessage{
+		Type:  notificationType,
+		Title: r.Title,
+		Body:  r.Body,
+		URL:   d.emailClient.Config.APP_URL + r.Link,
+	})
+}
+
+func (d *Dispatcher) deliverPush(ctx context.Context, p pushPayload) error {
+	err := d.pushTo(ctx, p.SubscriptionID, webpush.Subscription{Endpoint: p.Endpoint, P256dh: p.P256dh, Auth: p.Auth}, p.Message)
+	var status *webpush.StatusError
+	switch {
+	case errors.Is(err, webpush.ErrGone):
+		return nil
+	case errors.As(err, &status) && !status.Temporary():
+		// the push service will not take this push however often it is retried
+		d.logger.Warn("push rejected", "error", err, "subscription_id", p.SubscriptionID)
+		return nil
+	}
+	return err
+}
+
+// pushTo delivers one push and records the outcome on the subscription. Subscriptions
+// the push service no longer knows are deleted and ErrGone is returned.
+func (d *Dispatcher) pushTo(ctx context.Context, id uint, sub webpush.Subscription, message []byte) error {
+	err := d.push.Send(ctx, sub, message, webpush.Options{TTL: pushTTL, Urgency: webpush.UrgencyNormal})
+	if errors.Is(err, webpush.ErrGone) {
+		if err := d.conn.Push.DeleteByID(ctx, id); err != nil {
+			return err
+		}
+		d.logger.Info("removed expired push subscription", "subscription_id", id)
+		return webpush.ErrGone
+	}
+	if rerr := d.conn.Push.RecordResult(ctx, id, err == nil, time.Now()); rerr != nil {
+		d.logger.Warn("failed to record push result", "error", rerr, "subscription_id", id)
+	}
+	return err
+}
+
+// PushTestResult counts the outcome of SendTestPush per browser.
+type PushTestResult struct {
+	Sent    int `json:"sent"`
+	Failed  int `json:"failed"`
+	Removed int `json:"removed"`
+}
+
+// SendTestPush pushes a test message to every browser user subscribed, right away.
+func (d *Dispatcher) SendTestPush(ctx context.Context, user *db.User) (PushTestResult, error) {
+	var res PushTestResult
+	subs, err := d.conn.Push.ListByUser(ctx, user.ID)
+	if err != nil {
+		return res, err
+	}
+	key := "notification.types." + TypePushTest
+	message, err := d.pushMessage(TypePushTest, d.render(d.Locale(ctx, user), key+".subject", key+".body", nil, inboxLink))
+	if err != nil {
+		return res, err
+	}
+	for _, sub := range subs {
+		err := d.pushTo(ctx, sub.ID, webpush.Subscription{Endpoint: sub.Endpoint, P256dh: sub.P256dh, Auth: sub.Auth}, message)
+		switch {
+		case err == nil:
+			res.Sent++
+		case errors.Is(err, webpush.ErrGone):
+			res.Removed++
+		default:
+			d.logger.Warn("test push failed", "error", err, "subscription_id", sub.ID)
+			res.Failed++
+		}
+	}
+	return res, nil
+}
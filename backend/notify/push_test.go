@@ -0,0 +1,98 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/webpush"
+)
+
+// fakePushRepo keeps subscriptions by ID.
+type fakePushRepo struct {
+	db.PushSubscriptionsRepo
+	subs    map[uint]bool
+	results map[uint][]bool
+}
+
+func (r *fakePushRepo) DeleteByID(ctx context.Context, id uint) error {
+	delete(r.subs, id)
+	return nil
+}
+
+func (r *fakePushRepo) RecordResult(ctx context.Context, id uint, ok bool, at time.Time) error {
+	r.results[id] = append(r.results[id], ok)
+	return nil
+}
+
+func TestPushToStandIn(t *testing.T) {
+	var standIn *webpush.StandIn
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		status, err := standIn.Receive(strings.TrimPrefix(r.URL.Path, "/"), r.Header, body)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer srv.Close()
+	standIn = webpush.NewStandIn(srv.URL)
+
+	keys, err := webpush.GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	repo := &fakePushRepo{subs: map[uint]bool{1: true, 2: true, 3: true}, results: map[uint][]bool{}}
+	d := &Dispatcher{
+		conn: &db.Connection{Push: repo},
+		push: webpush.NewSender(keys, "mailto:ops@example.com", srv.Client()),
+	}
+	ctx := context.Background()
+
+	live, sub, err := standIn.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := d.pushTo(ctx, 1, sub, []byte(`{"title":"hi"}`)); err != nil {
+		t.Fatalf("push to a live subscription: %v", err)
+	}
+	if !repo.subs[1] || len(repo.results[1]) != 1 || !repo.results[1][0] {
+		t.Errorf("live subscription: kept=%v results=%v", repo.subs[1], repo.results[1])
+	}
+
+	// 410 Gone
+	expired, expiredSub, err := standIn.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	standIn.Expire(expired)
+	if err := d.pushTo(ctx, 2, expiredSub, []byte("x")); !errors.Is(err, webpush.ErrGone) {
+		t.Errorf("push to an expired subscription: err = %v, want ErrGone", err)
+	}
+	if repo.subs[2] {
+		t.Error("expired subscription was not deleted")
+	}
+
+	// 404 Not Found, through the job handler, which does not retry it
+	unknownSub := sub
+	unknownSub.Endpoint = strings.TrimSuffix(sub.Endpoint, live) + "0000000000000000"
+	if err := d.deliverPush(ctx, pushPayload{SubscriptionID: 3, Endpoint: unknownSub.Endpoint, P256dh: sub.P256dh, Auth: sub.Auth, Message: []byte(`{}`)}); err != nil {
+		t.Errorf("deliverPush to an unknown subscription: %v", err)
+	}
+	if repo.subs[3] {
+		t.Error("unknown subscription was not deleted")
+	}
+	if len(repo.results[2]) != 0 || len(repo.results[3]) != 0 {
+		t.Errorf("results were recorded for deleted subscriptions: %v", repo.results)
+	}
+	if !repo.subs[1] {
+		t.Error("live subscription was deleted")
+	}
+}
@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"encoding/json"
+	"slices"
+)
+
+// Delivery channels a notification type can use.
+const (
+	ChannelInApp  = "in_app"
+	ChannelEmail  = "email"
+	ChannelDigest = "digest"
+	ChannelPush   = "push"
+)
+
+// Notification types.
+const (
+	TypeTeamInvite           = "team_invite"
+	TypeMemberJoined         = "member_joined"
+	TypeMemberLeft           = "team_member_left"
+	TypeRoleChanged          = "role_changed"
+	TypeTransferRequest      = "team_transfer_request"
+	TypeOwnershipTransferred = "team_ownership_transferred"
+	TypeTransferDeclined     = "team_transfer_declined"
+	TypeEmailSuppressed      = "email_suppressed"
+	TypeSecurityAlert        = "security_alert"
+)
+
+// TypeSpec describes a notification type: its payload and how it may be delivered.
+type TypeSpec struct {
+	Type string `json:"type"`
+	// the channels a user can choose from
+	Channels []string `json:"channels"`
+	// used until the user picks their own
+	Default []string `json:"default"`
+	// security-critical types always use Default and cannot be changed
+	Locked bool `json:"locked"`
+
+	// returns an empty payload of the type to decode stored notifications into
+	New func() Payload `json:"-"`
+	// Upgrades[i] rewrites a version i+1 payload as version i+2, so notifications stored
+	// before the payload changed still decode; see Version
+	Upgrades []func(json.RawMessage) (json.RawMessage, error) `json:"-"`
+}
+
+// Types lists every notification type, in the order settings show them. A new type is
+// added here with its payload in payloads.go and its strings under notification.types in
+// the email catalogs.
+var Types = []TypeSpec{
+	{
+		Type: TypeTeamInvite,
+		// the invitation itself is always emailed to the invited address
+		Channels: []string{ChannelInApp, ChannelPush, ChannelDigest},
+		Default:  []string{ChannelInApp, ChannelPush, ChannelDigest},
+		New:      func() Payload { return &TeamInvite{} },
+	},
+	{
+		Type:     TypeMemberJoined,
+		Channels: []string{ChannelInApp, ChannelPush, ChannelEmail, ChannelDigest},
+		Default:  []string{ChannelInApp},
+		New:      func() Payload { return &MemberJoined{} },
+	},
+	{
+		Type:     TypeMemberLeft,
+		Channels: []string{ChannelInApp, ChannelPush, ChannelEmail, ChannelDigest},
+		Default:  []string{ChannelInApp},
+		New:      func() Payload { return &MemberLeft{} },
+	},
+	{
+		Type:     TypeRoleChanged,
+		Channels: []string{ChannelInApp, ChannelPush, ChannelEmail, ChannelDigest},
+		Default:  []string{ChannelInApp, ChannelPush, ChannelEmail},
+		New:      func() Payload { return &RoleChanged{} },
+	},
+	{
+		Type:     TypeTransferRequest,
+		Channels: []string{ChannelInApp, ChannelPush, ChannelEmail, ChannelDigest},
+		Default:  []string{ChannelInApp, ChannelPush, ChannelEmail, ChannelDigest},
+		New:      func() Payload { return &TransferRequest{} },
+	},
+	{
+		Type:     TypeOwnershipTransferred,
+		Channels: []string{ChannelInApp, ChannelPush, ChannelEmail, ChannelDigest},
+		Default:  []string{ChannelInApp, ChannelPush, ChannelEmail},
+		New:      func() Payload { return &OwnershipTransferred{} },
+	},
+	{
+		Type:     TypeTransferDeclined,
+		Channels: []string{ChannelInApp, ChannelPush, ChannelEmail, ChannelDigest},
+		Default:  []string{ChannelInApp},
+		New:      func() Payload { return &TransferDeclined{} },
+	},
+	{
+		Type: TypeEmailSuppressed,
+		// email is what stopped working, so this one can only be shown in the app
+		Channels: []string{ChannelInApp},
+		Default:  []string{ChannelInApp},
+		Locked:   true,
+		New:      func() Payload { return &EmailSuppressed{} },
+	},
+	{
+		Type:     TypeSecurityAlert,
+		Channels: []string{ChannelInApp, ChannelPush, ChannelEmail},
+		Default:  []string{ChannelInApp, ChannelPush, ChannelEmail},
+		Locked:   true,
+		New:      func() Payload { return &SecurityAlert{} },
+	},
+}
+
+// Lookup returns the spec of a notification type. Unknown types are shown in the app only.
+func Lookup(notificationType string) TypeSpec {
+	for _, spec := range Types {
+		if spec.Type == notificationType {
+			return spec
+		}
+	}
+	return TypeSpec{Type: notificationType, Channels: []string{ChannelInApp}, Default: []string{ChannelInApp}, Locked: true}
+}
+
+// Known reports whether notificationType is registered in Types.
+func Known(notificationType string) bool {
+	return slices.ContainsFunc(Types, func(s TypeSpec) bool { return s.Type == notificationType })
+}
+
+// Allows reports whether channel is one the type can be delivered on.
+func (s TypeSpec) Allows(channel string) bool {
+	return slices.Contains(s.Channels, channel)
+}
+
+// Version is the payload version notifications of the type are stored with now.
+func (s TypeSpec) Version() int {
+	return len(s.Upgrades) + 1
+}
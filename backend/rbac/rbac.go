@@ -0,0 +1,112 @@
+package rbac
+
+import (
+	"slices"
+	"sort"
+)
+
+type Permission string
+
+const (
+	TeamRead   Permission = "team.read"
+	TeamUpdate Permission = "team.update"
+	TeamDelete Permission = "team.delete"
+
+	MembersInvite     Permission = "members.invite"
+	MembersRemove     Permission = "members.remove"
+	MembersUpdateRole Permission = "members.update_role"
+
+	RolesManage Permission = "roles.manage"
+
+	AuditRead      Permission = "audit.read"
+	WebhooksManage Permission = "webhooks.manage"
+)
+
+// All lists every permission a role can be granted, in display order.
+var All = []Permission{
+	TeamRead,
+	TeamUpdate,
+	TeamDelete,
+	MembersInvite,
+	MembersRemove,
+	MembersUpdateRole,
+	RolesManage,
+	AuditRead,
+	WebhooksManage,
+}
+
+const (
+	RoleOwner   = "owner"
+	RoleAdmin   = "admin"
+	RoleRegular = "regular"
+)
+
+// BuiltinRoles are available in every team. The owner role is implied by Team.OwnerID
+// and cannot be assigned through membership endpoints.
+var BuiltinRoles = map[string][]Permission{
+	RoleOwner: All,
+	RoleAdmin: {
+		TeamRead,
+		TeamUpdate,
+		MembersInvite,
+		MembersRemove,
+		AuditRead,
+		WebhooksManage,
+	},
+	RoleRegular: {
+		TeamRead,
+	},
+}
+
+func IsBuiltin(role string) bool {
+	_, ok := BuiltinRoles[role]
+	return ok
+}
+
+func IsValid(p Permission) bool {
+	return slices.Contains(All, p)
+}
+
+type Set map[Permission]bool
+
+func NewSet(perms ...Permission) Set {
+	s := make(Set, len(perms))
+	for _, p := range perms {
+		s[p] = true
+	}
+	return s
+}
+
+func FromStrings(perms []string) Set {
+	s := make(Set, len(perms))
+	for _, p := range perms {
+		if IsValid(Permission(p)) {
+			s[Permission(p)] = true
+		}
+	}
+	return s
+}
+
+func (s Set) Has(p Permission) bool {
+	return s[p]
+}
+
+// Covers reports whether s holds every permission in other; used so nobody can grant
+// a role more powerful than their own.
+func (s Set) Covers(other Set) bool {
+	for p := range other {
+		if !s[p] {
+			return false
+		}
+	}
+	return true
+}
+
+func (s Set) List() []string {
+	out := make([]string, 0, len(s))
+	for p := range s {
+		out = append(out, string(p))
+	}
+	sort.Strings(out)
+	return out
+}
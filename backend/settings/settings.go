@@ -0,0 +1,304 @@
+// Package settings is the registry of user and team settings. A setting is declared once,
+// with its type, default and validation, and the API reads, patches and describes all of
+// them in bulk, so adding one takes a Register call rather than a handler and a column.
+package settings
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"sort"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+)
+
+type Scope string
+
+const (
+	ScopeUser Scope = "user"
+	ScopeTeam Scope = "team"
+)
+
+// Setting declares a setting with values of type T.
+type Setting[T comparable] struct {
+	Scope       Scope
+	Key         string
+	Description string
+	Default     T
+	// Enum, if set, lists every allowed value.
+	Enum []T
+	// Schema adds JSON Schema keywords beyond type, enum, default and description, like
+	// "minimum"; Validate has to enforce them.
+	Schema map[string]any
+	// Validate, if set, checks a value before it is saved for ownerID, the user or team
+	// it belongs to. Rejected values are reported with Invalid; any other error is ours.
+	Validate func(ctx context.Context, conn *db.Connection, ownerID uint, value T) error
+	// Column names the column that holds the value in the scope's own table, for settings
+	// that other code queries directly. The rest are kept in db.Setting.
+	Column string
+}
+
+// entry is a registered Setting with its type erased.
+type entry interface {
+	key() string
+	column() string
+	defaultValue() any
+	parse(raw json.RawMessage) (any, error)
+	check(ctx context.Context, conn *db.Connection, ownerID uint, value any) error
+	schema() map[string]any
+}
+
+var registry = map[Scope][]entry{}
+
+// Register adds s to the registry and returns it, to read its values typed with Get.
+// Settings are registered at init time, so a duplicate key panics.
+func Register[T comparable](s Setting[T]) *Setting[T] {
+	if lookup(s.Scope, s.Key) != nil {
+		panic(fmt.Sprintf("settings: %s setting %q registered twice", s.Scope, s.Key))
+	}
+	registry[s.Scope] = append(registry[s.Scope], &s)
+	return &s
+}
+
+func lookup(scope Scope, key string) entry {
+	for _, e := range registry[scope] {
+		if e.key() == key {
+			return e
+		}
+	}
+	return nil
+}
+
+// Get returns the value saved for ownerID, or the default if there is none or it is no
+// longer a valid value.
+func (s *Setting[T]) Get(ctx context.Context, conn *db.Connection, ownerID uint) (T, error) {
+	stored, err := load(ctx, conn, s.Scope, ownerID, []entry{s})
+	if err != nil {
+		return s.Default, err
+	}
+	if raw, ok := stored[s.Key]; ok {
+		if v, err := s.parse(raw); err == nil {
+			return v.(T), nil
+		}
+	}
+	return s.Default, nil
+}
+
+// Check tells whether value may be saved for ownerID.
+func (s *Setting[T]) Check(ctx context.Context, conn *db.Connection, ownerID uint, value T) error {
+	if s.Enum != nil && !slices.Contains(s.Enum, value) {
+		return Invalid(fmt.Sprintf("must be one of %s", enumList(s.Enum)))
+	}
+	if s.Validate != nil {
+		return s.Validate(ctx, conn, ownerID, value)
+	}
+	return nil
+}
+
+func (s *Setting[T]) key() string       { return s.Key }
+func (s *Setting[T]) column() string    { return s.Column }
+func (s *Setting[T]) defaultValue() any { return s.Default }
+
+func (s *Setting[T]) parse(raw json.RawMessage) (any, error) {
+	var v T
+	if err := json.Unmarshal(raw, &v); err != nil {
+		return nil, Invalid(fmt.Sprintf("must be a JSON %s", jsonType(reflect.TypeFor[T]())))
+	}
+	return v, nil
+}
+
+func (s *Setting[T]) check(ctx context.Context, conn *db.Connection, ownerID uint, value any) error {
+	return s.Check(ctx, conn, ownerID, value.(T))
+}
+
+func (s *Setting[T]) schema() map[string]any {
+	out := map[string]any{"type": jsonType(reflect.TypeFor[T]()), "default": s.Default}
+	if s.Description != "" {
+		out["description"] = s.Description
+	}
+	if s.Enum != nil {
+		out["enum"] = s.Enum
+	}
+	for k, v := range s.Schema {
+		out[k] = v
+	}
+	return out
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	default:
+		return "string"
+	}
+}
+
+func enumList[T any](values []T) string {
+	var b bytes.Buffer
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		fmt.Fprint(&b, v)
+	}
+	return b.String()
+}
+
+// Error reports a value rejected for a setting, or a setting that does not exist.
+type Error struct {
+	Key    string
+	Reason string
+}
+
+func (e *Error) Error() string { return e.Key + ": " + e.Reason }
+
+type invalidError string
+
+func (e invalidError) Error() string { return string(e) }
+
+// Invalid is what a Validate func returns for a value it rejects; reason is shown to the
+// client after the setting's key.
+func Invalid(reason string) error { return invalidError(reason) }
+
+// Values returns every setting of scope for ownerID, keyed by setting, with defaults for
+// those never set.
+func Values(ctx context.Context, conn *db.Connection, scope Scope, ownerID uint) (map[string]any, error) {
+	entries := registry[scope]
+	stored, err := load(ctx, conn, scope, ownerID, entries)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]any, len(entries))
+	for _, e := range entries {
+		values[e.key()] = e.defaultValue()
+		if raw, ok := stored[e.key()]; ok {
+			if v, err := e.parse(raw); err == nil {
+				values[e.key()] = v
+			}
+		}
+	}
+	return values, nil
+}
+
+// load reads what is saved for entries, as JSON, from their columns and from db.Setting.
+func load(ctx context.Context, conn *db.Connection, scope Scope, ownerID uint, entries []entry) (map[string]json.RawMessage, error) {
+	var columns []string
+	for _, e := range entries {
+		if e.column() != "" {
+			columns = append(columns, e.column())
+		}
+	}
+	row, err := conn.Settings.Columns(ctx, string(scope), ownerID, columns)
+	if err != nil {
+		return nil, err
+	}
+	saved, err := conn.Settings.List(ctx, string(scope), ownerID)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := make(map[string]json.RawMessage, len(entries))
+	for _, e := range entries {
+		if e.column() == "" {
+			continue
+		}
+		if v, ok := row[e.column()]; ok && v != nil {
+			raw, err := json.Marshal(v)
+			if err != nil {
+				return nil, err
+			}
+			stored[e.key()] = raw
+		}
+	}
+	for _, s := range saved {
+		if e := lookup(scope, s.Key); e != nil && e.column() == "" {
+			stored[s.Key] = json.RawMessage(s.Value)
+		}
+	}
+	return stored, nil
+}
+
+// Apply validates changes, keyed by setting, and saves them for ownerID: all of them, or
+// none if one is rejected with an *Error. A null value resets a setting to its default.
+func Apply(ctx context.Context, conn *db.Connection, scope Scope, ownerID uint, changes map[string]json.RawMessage) error {
+	keys := make([]string, 0, len(changes))
+	for k := range changes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	columns := map[string]any{}
+	var save []db.Setting
+	var reset []string
+	for _, k := range keys {
+		e := lookup(scope, k)
+		if e == nil {
+			return &Error{Key: k, Reason: "unknown setting"}
+		}
+		raw := bytes.TrimSpace(changes[k])
+		value := e.defaultValue()
+		if !bytes.Equal(raw, []byte("null")) {
+			v, err := e.parse(raw)
+			if err == nil {
+				err = e.check(ctx, conn, ownerID, v)
+			}
+			var reason invalidError
+			if errors.As(err, &reason) {
+				return &Error{Key: k, Reason: string(reason)}
+			}
+			if err != nil {
+				return err
+			}
+			value = v
+		}
+
+		switch {
+		case e.column() != "":
+			columns[e.column()] = value
+		case bytes.Equal(raw, []byte("null")):
+			reset = append(reset, k)
+		default:
+			encoded, err := json.Marshal(value)
+			if err != nil {
+				return err
+			}
+			save = append(save, db.Setting{Scope: string(scope), OwnerID: ownerID, Key: k, Value: string(encoded)})
+		}
+	}
+
+	return conn.WithTx(ctx, func(tx *db.Connection) error {
+		if err := tx.Settings.SetColumns(ctx, string(scope), ownerID, columns); err != nil {
+			return err
+		}
+		if err := tx.Settings.Save(ctx, save); err != nil {
+			return err
+		}
+		return tx.Settings.Delete(ctx, string(scope), ownerID, reset)
+	})
+}
+
+// Schema describes the settings object of scope, as Values returns it and Apply takes
+// it, as a JSON Schema (draft 2020-12).
+func Schema(scope Scope) map[string]any {
+	properties := map[string]any{}
+	for _, e := range registry[scope] {
+		properties[e.key()] = e.schema()
+	}
+	return map[string]any{
+		"$schema":              "https://json-schema.org/draft/2020-12/schema",
+		"title":                fmt.Sprintf("%s settings", scope),
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
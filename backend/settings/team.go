@@ -0,0 +1,48 @@
+package settings
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/rbac"
+	"gorm.io/gorm"
+)
+
+var (
+	DefaultInviteRole = Register(Setting[string]{
+		Scope:       ScopeTeam,
+		Key:         "default_invite_role",
+		Description: "Role given to people invited without one; a built-in role other than owner, or one of the team's roles.",
+		Default:     rbac.RoleRegular,
+		Validate:    validInviteRole,
+	})
+
+	InvitationExpiryDays = Register(Setting[int]{
+		Scope:       ScopeTeam,
+		Key:         "invitation_expiry_days",
+		Description: "Days an invitation can be accepted for, and invite links last unless they set their own expiry.",
+		Default:     7,
+		Schema:      map[string]any{"minimum": 1, "maximum": 90},
+		Validate: func(_ context.Context, _ *db.Connection, _ uint, days int) error {
+			if days < 1 || days > 90 {
+				return Invalid("must be between 1 and 90")
+			}
+			return nil
+		},
+	})
+)
+
+func validInviteRole(ctx context.Context, conn *db.Connection, teamID uint, role string) error {
+	if role == rbac.RoleOwner {
+		return Invalid("invitations cannot make someone the owner")
+	}
+	if _, ok := rbac.BuiltinRoles[role]; ok {
+		return nil
+	}
+	_, err := conn.TeamRoles.ByName(ctx, teamID, role)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Invalid("no such role")
+	}
+	return err
+}
@@ -0,0 +1,70 @@
+package settings
+
+import (
+	"context"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/utils/email"
+)
+
+var (
+	Theme = Register(Setting[string]{
+		Scope:       ScopeUser,
+		Key:         "theme",
+		Description: "Color theme of the app; system follows the device.",
+		Default:     "system",
+		Enum:        []string{"light", "dark", "system"},
+		Column:      "theme",
+	})
+
+	Language = Register(Setting[string]{
+		Scope:       ScopeUser,
+		Key:         "language",
+		Description: "Language of the app and of emails.",
+		Default:     "en",
+		Enum:        email.Locales,
+		Column:      "language",
+	})
+
+	Timezone = Register(Setting[string]{
+		Scope:       ScopeUser,
+		Key:         "timezone",
+		Description: "IANA timezone, e.g. Europe/Berlin; dates are shown and digests sent in it.",
+		Default:     "UTC",
+		Validate:    validTimezone,
+		Column:      "timezone",
+	})
+
+	DateFormat = Register(Setting[string]{
+		Scope:       ScopeUser,
+		Key:         "date_format",
+		Description: "How dates are written; auto follows the language.",
+		Default:     "auto",
+		Enum:        []string{"auto", "YYYY-MM-DD", "DD.MM.YYYY", "DD/MM/YYYY", "MM/DD/YYYY"},
+	})
+
+	TimeFormat = Register(Setting[string]{
+		Scope:       ScopeUser,
+		Key:         "time_format",
+		Description: "24 or 12 hour clock; auto follows the language.",
+		Default:     "auto",
+		Enum:        []string{"auto", "24h", "12h"},
+	})
+
+	StartPage = Register(Setting[string]{
+		Scope:       ScopeUser,
+		Key:         "start_page",
+		Description: "Page opened after signing in.",
+		Default:     "/dashboard",
+		Enum:        []string{"/dashboard", "/dashboard/notifications", "/dashboard/settings", "/dashboard/account"},
+	})
+)
+
+func validTimezone(_ context.Context, _ *db.Connection, _ uint, name string) error {
+	// LoadLocation also accepts "" and "Local", which mean the server's zone
+	if _, err := time.LoadLocation(name); err != nil || name == "" || name == "Local" {
+		return Invalid("unknown timezone")
+	}
+	return nil
+}
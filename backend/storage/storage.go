@@ -0,0 +1,49 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ErrNotFound is returned for a key nothing is stored under.
+var ErrNotFound = errors.New("object not found")
+
+// Object describes a stored file.
+type Object struct {
+	ContentType string
+	Size        int64
+	ModTime     time.Time
+}
+
+// Storage keeps uploaded files by key. Keys are slash separated paths like
+// "avatars/12/k3x9/256.jpg"; see ValidKey.
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get opens the file under key; the caller closes it.
+	Get(ctx context.Context, key string) (io.ReadCloser, Object, error)
+	// Delete removes the file under key; deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// ValidKey checks that key is made of path segments of lowercase letters, digits, '-',
+// '_' and '.', so it maps safely onto a file path and an object URL as it is.
+func ValidKey(key string) error {
+	if key == "" || len(key) > 512 {
+		return fmt.Errorf("invalid storage key %q", key)
+	}
+	for _, seg := range strings.Split(key, "/") {
+		if seg == "" || seg == "." || seg == ".." {
+			return fmt.Errorf("invalid storage key %q", key)
+		}
+		for _, c := range seg {
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-' || c == '_' || c == '.') {
+				return fmt.Errorf("invalid storage key %q", key)
+			}
+		}
+	}
+	return nil
+}
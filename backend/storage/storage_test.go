@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestValidKey(t *testing.T) {
+	for _, tc := range []struct {
+		key string
+		ok  bool
+	}{
+		{"avatars/12/0a1b2c/256.jpg", true},
+		{"team-logos/3/x_y/64.png", true},
+		{"a", true},
+		{"", false},
+		{"/avatars/1.jpg", false},
+		{"avatars//1.jpg", false},
+		{"avatars/1.jpg/", false},
+		{"..", false},
+		{"avatars/../../etc/passwd", false},
+		{"avatars/./1.jpg", false},
+		{"avatars\\..\\1.jpg", false},
+		{"Avatars/1.jpg", false},
+		{"avatars/1 .jpg", false},
+		{"avatars/%2e%2e/1.jpg", false},
+		{"avatars/\x00.jpg", false},
+		{strings.Repeat("a", 513), false},
+	} {
+		err := ValidKey(tc.key)
+		if (err == nil) != tc.ok {
+			t.Errorf("ValidKey(%q) = %v, want ok=%v", tc.key, err, tc.ok)
+		}
+	}
+}
+
+// testStorage runs the behavior every Storage must share against s.
+func testStorage(t *testing.T, s Storage) {
+	ctx := context.Background()
+	key := "tests/" + strings.ToLower(strings.ReplaceAll(t.Name(), "/", "-")) + "/64.png"
+	data := []byte("\x89PNG\r\n\x1a\nnot really")
+
+	if _, _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get before Put: err = %v, want ErrNotFound", err)
+	}
+	if err := s.Put(ctx, key, data, "image/png"); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	// overwrites replace the file
+	if err := s.Put(ctx, key, data, "image/png"); err != nil {
+		t.Fatalf("second Put: %v", err)
+	}
+
+	body, obj, err := s.Get(ctx, key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	got, err := io.ReadAll(body)
+	body.Close()
+	if err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Get returned %q, want %q", got, data)
+	}
+	if obj.ContentType != "image/png" {
+		t.Errorf("content type = %q, want image/png", obj.ContentType)
+	}
+	if obj.Size != int64(len(data)) {
+		t.Errorf("size = %d, want %d", obj.Size, len(data))
+	}
+
+	if err := s.Delete(ctx, key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, _, err := s.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Errorf("Get after Delete: err = %v, want ErrNotFound", err)
+	}
+	if err := s.Delete(ctx, key); err != nil {
+		t.Errorf("deleting a missing key: %v", err)
+	}
+
+	if err := s.Put(ctx, "tests/../escape.png", data, "image/png"); err == nil {
+		t.Error("Put accepted a key with ..")
+	}
+}
+
+func TestMemory(t *testing.T) {
+	testStorage(t, NewMemory())
+}
+
+func TestLocal(t *testing.T) {
+	dir := t.TempDir()
+	l, err := NewLocal(filepath.Join(dir, "uploads"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, l)
+
+	// keys cannot reach outside the directory
+	if err := os.WriteFile(filepath.Join(dir, "secret.png"), []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := l.Get(context.Background(), "../secret.png"); err == nil {
+		t.Error("Get read a file outside the directory")
+	}
+
+	// no temporary files are left behind
+	entries, err := os.ReadDir(filepath.Join(dir, "uploads", "tests", "testlocal"))
+	if err == nil {
+		for _, e := range entries {
+			if strings.HasPrefix(e.Name(), ".upload-") {
+				t.Errorf("temporary file %s was left behind", e.Name())
+			}
+		}
+	}
+}
+
+// TestS3 runs against a real bucket, such as a local MinIO:
+//
+//	docker run -p 9000:9000 minio/minio server /data
+//	STORAGE_TEST_S3_ENDPOINT=http://localhost:9000 STORAGE_TEST_S3_BUCKET=test \
+//	STORAGE_TEST_S3_ACCESS_KEY_ID=minioadmin STORAGE_TEST_S3_SECRET_ACCESS_KEY=minioadmin go test ./storage
+func TestS3(t *testing.T) {
+	endpoint := os.Getenv("STORAGE_TEST_S3_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("STORAGE_TEST_S3_ENDPOINT is not set")
+	}
+	s, err := NewS3(S3Config{
+		Endpoint:        endpoint,
+		Region:          os.Getenv("STORAGE_TEST_S3_REGION"),
+		Bucket:          os.Getenv("STORAGE_TEST_S3_BUCKET"),
+		AccessKeyID:     os.Getenv("STORAGE_TEST_S3_ACCESS_KEY_ID"),
+		SecretAccessKey: os.Getenv("STORAGE_TEST_S3_SECRET_ACCESS_KEY"),
+		PathStyle:       true,
+	}, &http.Client{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	testStorage(t, s)
+}
@@ -3,8 +3,10 @@ package utils
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"crypto/subtle"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -12,6 +14,7 @@ import (
 	"time"
 
 	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/events"
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/jackc/pgx/v5/pgconn"
 	"golang.org/x/crypto/argon2"
@@ -147,6 +150,9 @@ func SignUpEmailPassword(ctx context.Context, store *db.Connection, email, passw
 		if err := tx.Preferences.Create(ctx, u.ID); err != nil {
 			return err
 		}
+		if err := events.Emit(ctx, tx, events.UserRegistered, events.UserRegisteredPayload{UserID: u.ID, Email: e, Method: "password"}); err != nil {
+			return err
+		}
 
 		out = u
 		return nil
@@ -197,6 +203,12 @@ func GenerateJWT(secret []byte, email string, iss string, aud string) (string, e
 }
 
 func DecodeJWT(secret []byte, tokenStr string, iss string, aud string) (string, error) {
+	email, _, err := DecodeJWTClaims(secret, tokenStr, iss, aud)
+	return email, err
+}
+
+// DecodeJWTClaims is DecodeJWT that also returns the token's issue time, used to reject revoked sessions.
+func DecodeJWTClaims(secret []byte, tokenStr string, iss string, aud string) (string, time.Time, error) {
 	claims := jwt.MapClaims{}
 	parsed, err := jwt.ParseWithClaims(tokenStr, &claims, func(t *jwt.Token) (interface{}, error) {
 		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
@@ -208,7 +220,7 @@ func DecodeJWT(secret []byte, tokenStr string, iss string, aud string) (string,
 		if err == nil {
 			err = fmt.Errorf("invalid token")
 		}
-		return "", err
+		return "", time.Time{}, err
 	}
 
 	now := time.Now().Unix()
@@ -216,23 +228,23 @@ func DecodeJWT(secret []byte, tokenStr string, iss string, aud string) (string,
 		switch v := expVal.(type) {
 		case float64:
 			if int64(v) < now {
-				return "", fmt.Errorf("token expired")
+				return "", time.Time{}, fmt.Errorf("token expired")
 			}
 		case json.Number:
 			if n, err := v.Int64(); err == nil && n < now {
-				return "", fmt.Errorf("token expired")
+				return "", time.Time{}, fmt.Errorf("token expired")
 			}
 		}
 	}
 
 	if issVal, ok := claims["iss"].(string); !ok || issVal != iss {
-		return "", fmt.Errorf("invalid issuer")
+		return "", time.Time{}, fmt.Errorf("invalid issuer")
 	}
 
 	switch audVal := claims["aud"].(type) {
 	case string:
 		if audVal != aud {
-			return "", fmt.Errorf("invalid audience")
+			return "", time.Time{}, fmt.Errorf("invalid audience")
 		}
 	case []interface{}:
 		found := false
@@ -243,19 +255,35 @@ func DecodeJWT(secret []byte, tokenStr string, iss string, aud string) (string,
 			}
 		}
 		if !found {
-			return "", fmt.Errorf("invalid audience")
+			return "", time.Time{}, fmt.Errorf("invalid audience")
 		}
 	default:
-		return "", fmt.Errorf("invalid audience")
+		return "", time.Time{}, fmt.Errorf("invalid audience")
 	}
 
 	sub, ok := claims["email"]
 	if !ok {
-		return "", fmt.Errorf("the email subject was not found in jwt")
+		return "", time.Time{}, fmt.Errorf("the email subject was not found in jwt")
 	}
 	s, ok := sub.(string)
 	if !ok || s == "" {
-		return "", fmt.Errorf("invalid email claim")
+		return "", time.Time{}, fmt.Errorf("invalid email claim")
 	}
-	return s, nil
+
+	var issuedAt time.Time
+	switch v := claims["iat"].(type) {
+	case float64:
+		issuedAt = time.Unix(int64(v), 0)
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			issuedAt = time.Unix(n, 0)
+		}
+	}
+	return s, issuedAt, nil
+}
+
+// HashToken is used for bearer-style tokens that are stored server-side only as a digest.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
@@ -0,0 +1,122 @@
+package email
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var ErrMessageNotFound = errors.New("message not found")
+
+// CaptureMailer stores messages as JSON files instead of sending them, so local
+// development works without an email provider. Read them back through /dev/mailbox.
+type CaptureMailer struct {
+	dir string
+}
+
+type CapturedMessage struct {
+	ID     string    `json:"id"`
+	SentAt time.Time `json:"sent_at"`
+	Message
+}
+
+func NewCaptureMailer(dir string) (*CaptureMailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &CaptureMailer{dir: dir}, nil
+}
+
+func (m *CaptureMailer) Name() string { return "capture" }
+
+func (m *CaptureMailer) Send(ctx context.Context, msg Message) (string, error) {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	now := time.Now().UTC()
+	// IDs sort by time, which keeps listing cheap
+	id := now.Format("20060102T150405.000000") + "-" + hex.EncodeToString(b)
+
+	body, err := json.MarshalIndent(CapturedMessage{ID: id, SentAt: now, Message: msg}, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	if err := os.WriteFile(filepath.Join(m.dir, id+".json"), body, 0o644); err != nil {
+		return "", err
+	}
+	return id, nil
+}
+
+var capturedIDPattern = regexp.MustCompile(`^[0-9T.]+-[0-9a-f]{8}$`)
+
+// List returns captured messages newest first, optionally only those sent to recipient.
+func (m *CaptureMailer) List(recipient string, limit int) ([]CapturedMessage, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, e := range entries {
+		if id, ok := strings.CutSuffix(e.Name(), ".json"); ok && capturedIDPattern.MatchString(id) {
+			ids = append(ids, id)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.StringSlice(ids)))
+
+	out := make([]CapturedMessage, 0, limit)
+	for _, id := range ids {
+		if len(out) >= limit {
+			break
+		}
+		msg, err := m.Get(id)
+		if err != nil {
+			continue
+		}
+		if recipient != "" && !strings.EqualFold(msg.To, recipient) {
+			continue
+		}
+		out = append(out, *msg)
+	}
+	return out, nil
+}
+
+func (m *CaptureMailer) Get(id string) (*CapturedMessage, error) {
+	if !capturedIDPattern.MatchString(id) {
+		return nil, ErrMessageNotFound
+	}
+	body, err := os.ReadFile(filepath.Join(m.dir, id+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrMessageNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var msg CapturedMessage
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, err
+	}
+	return &msg, nil
+}
+
+// Clear deletes every captured message.
+func (m *CaptureMailer) Clear() error {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if strings.HasSuffix(e.Name(), ".json") {
+			if err := os.Remove(filepath.Join(m.dir, e.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
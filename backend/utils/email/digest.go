@@ -0,0 +1,89 @@
+package email
+
+import (
+	"context"
+	"crypto/hmac"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var DigestUnsubscribePurpose = "digest_unsubscribe"
+
+// DigestItem is one line of a digest email.
+type DigestItem struct {
+	// the notification's title in the recipient's language, see Locale
+	Title     string
+	CreatedAt time.Time
+}
+
+// DigestUnsubscribeToken returns the token of the unsubscribe link in userID's digests of
+// the given frequency. It is signed rather than stored; naming the frequency lets the
+// link stop working once the user picks another one.
+func DigestUnsubscribeToken(secret []byte, userID uint, frequency string) string {
+	claims := strconv.FormatUint(uint64(userID), 10) + "." + frequency
+	return claims + "." + hex.EncodeToString(codeMAC(secret, DigestUnsubscribePurpose, claims))
+}
+
+// ParseDigestUnsubscribeToken returns the user and frequency a DigestUnsubscribeToken was
+// issued for.
+func ParseDigestUnsubscribeToken(secret []byte, token string) (uint, string, error) {
+	i := strings.LastIndexByte(token, '.')
+	if i < 0 {
+		return 0, "", ErrMismatch
+	}
+	claims, sig := token[:i], token[i+1:]
+	got, err := hex.DecodeString(sig)
+	if err != nil || !hmac.Equal(got, codeMAC(secret, DigestUnsubscribePurpose, claims)) {
+		return 0, "", ErrMismatch
+	}
+	id, frequency, ok := strings.Cut(claims, ".")
+	if !ok || frequency == "" {
+		return 0, "", ErrMismatch
+	}
+	userID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return 0, "", ErrMismatch
+	}
+	return uint(userID), frequency, nil
+}
+
+// QueueDigestEmail sends userID a summary of items. frequency is "daily" or "weekly" and
+// picks the subject; item times are shown in loc.
+func (e *EmailClient) QueueDigestEmail(ctx context.Context, recipient string, userID uint, frequency string, items []DigestItem, loc *time.Location) error {
+	tr := e.translatorFor(ctx, recipient)
+	token := DigestUnsubscribeToken([]byte(e.Config.DIGEST_UNSUBSCRIBE_SECRET), userID, frequency)
+
+	lines := make([]map[string]any, 0, len(items))
+	for _, item := range items {
+		lines = append(lines, map[string]any{
+			"Title": item.Title,
+			"Time":  item.CreatedAt.In(loc).Format("2006-01-02 15:04"),
+		})
+	}
+
+	subject := tr.T("digest.subject." + frequency)
+	html, text, err := e.render(tr, "digest", subject, map[string]any{
+		"Count":          len(items),
+		"Items":          lines,
+		"URL":            e.Config.APP_URL + "/dashboard/notifications",
+		"UnsubscribeURL": e.Config.APP_URL + "/unsubscribe?token=" + token,
+	})
+	if err != nil {
+		return err
+	}
+
+	return e.Enqueue(ctx, Message{
+		Type:    TypeDigest,
+		To:      recipient,
+		Subject: subject,
+		HTML:    html,
+		Text:    text,
+		// one-click unsubscribe from the mail client, RFC 8058
+		Headers: map[string]string{
+			"List-Unsubscribe":      "<" + e.Config.BACKEND_PUBLIC_URL + "/notifications/digest/unsubscribe?token=" + token + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		},
+	})
+}
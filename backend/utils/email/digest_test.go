@@ -0,0 +1,39 @@
+package email
+
+import (
+	"encoding/hex"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDigestUnsubscribeToken(t *testing.T) {
+	secret := []byte("digest-secret")
+	token := DigestUnsubscribeToken(secret, 42, "weekly")
+
+	userID, frequency, err := ParseDigestUnsubscribeToken(secret, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if userID != 42 || frequency != "weekly" {
+		t.Errorf("parsed user %d, frequency %q; want 42, weekly", userID, frequency)
+	}
+
+	id, rest, _ := strings.Cut(token, ".")
+	_, sig, _ := strings.Cut(rest, ".")
+	for name, bad := range map[string]string{
+		"empty":            "",
+		"no signature":     "42.weekly",
+		"other secret":     DigestUnsubscribeToken([]byte("other"), 42, "weekly"),
+		"other user":       "43.weekly." + sig,
+		"other frequency":  id + ".daily." + sig,
+		"no frequency":     DigestUnsubscribeToken(secret, 42, ""),
+		"malformed hex":    id + ".weekly.zz",
+		"truncated":        token[:len(token)-2],
+		"non-numeric user": "x.weekly." + hex.EncodeToString(codeMAC(secret, DigestUnsubscribePurpose, "x.weekly")),
+	} {
+		if _, _, err := ParseDigestUnsubscribeToken(secret, bad); !errors.Is(err, ErrMismatch) {
+			t.Errorf("%s: err = %v, want ErrMismatch", name, err)
+		}
+	}
+}
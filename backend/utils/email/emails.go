@@ -2,25 +2,24 @@ package email
 
 import (
 	"context"
-	"fmt"
-	"os"
-	"path/filepath"
-	"runtime"
-	"strings"
+	"net/url"
 	"time"
 
 	"github.com/Neat-Snap/blueprint-backend/config"
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/jobs"
 	"github.com/Neat-Snap/blueprint-backend/logger"
 	"github.com/go-redis/redis/v8"
-	"github.com/resend/resend-go/v2"
 )
 
 type EmailClient struct {
-	apikey       string
-	resendClient *resend.Client
-	logger       logger.MultiLogger
-	R            *Redis
-	Config       config.Config
+	Mailer Mailer
+	logger logger.MultiLogger
+	R      *Redis
+	Config config.Config
+	queue  *jobs.Queue
+	locale LocaleResolver
+	conn   *db.Connection
 }
 
 var (
@@ -28,11 +27,10 @@ var (
 	ResetPasswordPurpose = "password_reset"
 )
 
-func NewEmailClient(cfg config.Config, logger logger.MultiLogger) *EmailClient {
+func NewEmailClient(cfg config.Config, logger logger.MultiLogger, mailer Mailer) *EmailClient {
 	return &EmailClient{
-		apikey:       cfg.RESEND_API_KEY,
-		resendClient: resend.NewClient(cfg.RESEND_API_KEY),
-		logger:       logger,
+		Mailer: mailer,
+		logger: logger,
 		R: &Redis{
 			R: redis.NewClient(&redis.Options{
 				Addr:     cfg.REDIS_HOST + ":" + cfg.REDIS_PORT,
@@ -45,40 +43,31 @@ func NewEmailClient(cfg config.Config, logger logger.MultiLogger) *EmailClient {
 	}
 }
 
-func loadTemplate(filename string) (string, error) {
-	_, src, _, _ := runtime.Caller(0) // path to emails.go
-	base := filepath.Dir(src)
-	data, err := os.ReadFile(filepath.Join(base, filename))
-	if err != nil {
-		return "", err
-	}
-	return string(data), nil
-}
-
-func (e *EmailClient) SendEmail(recipient string, subject string, body string) (string, error) {
-	params := &resend.SendEmailRequest{
-		From:    "Devenv <onboarding@resend.dev>",
-		To:      []string{recipient},
-		Html:    body,
-		Subject: subject,
+// Send delivers m through the configured transport right away, filling in the sender for
+// its type. Prefer Enqueue from request handlers.
+func (e *EmailClient) Send(ctx context.Context, m Message) (string, error) {
+	if m.From == "" {
+		m.From = e.fromFor(m.Type)
 	}
-
-	sent, err := e.resendClient.Emails.Send(params)
-	if err != nil {
-		e.logger.Error("failed to send email", "error", err)
-		return "", err
+	if e.conn != nil && m.RecordID != 0 {
+		// the address may have bounced while this message waited in the queue
+		suppressed, err := e.suppressed(ctx, m.To)
+		if err != nil {
+			return "", err
+		}
+		if suppressed {
+			e.track(ctx, m, StatusSuppressed, "", nil)
+			return "", ErrSuppressed
+		}
 	}
-	return sent.Id, nil
-}
-
-func (e *EmailClient) GetTemplateFromFile(filename string) (string, error) {
-	tmpl, err := loadTemplate(filename)
+	id, err := e.Mailer.Send(ctx, m)
 	if err != nil {
-		e.logger.Fatal("failed to load template", "error", err)
+		e.logger.Error("failed to send email", "error", err, "transport", e.Mailer.Name(), "type", m.Type)
+		e.track(ctx, m, StatusFailed, "", err)
 		return "", err
 	}
-
-	return tmpl, nil
+	e.track(ctx, m, StatusSent, id, nil)
+	return id, nil
 }
 
 func (e *EmailClient) buildActionUrl(id, code string) string {
@@ -89,57 +78,72 @@ func (e *EmailClient) buildResetPasswordUrl(id, code string) string {
 	return e.Config.APP_URL + "/auth/reset-password?cid=" + id + "&code=" + code
 }
 
-func (e *EmailClient) SendConfirmationEmail(recipient string, subject string, expiresMin int) (string, error) {
-	tmpl, err := e.GetTemplateFromFile("confirmation_template.html")
+// QueueConfirmationEmail creates a verification code for recipient and queues the email
+// carrying it. The returned confirmation ID is valid as soon as it is returned.
+func (e *EmailClient) QueueConfirmationEmail(ctx context.Context, recipient string, expiresMin int) (string, error) {
+	id, code, err := e.R.Create(ctx, []byte(e.Config.REDIS_SECRET), VerifyPurpose, recipient, 6, time.Duration(expiresMin)*time.Minute, 6)
 	if err != nil {
 		return "", err
 	}
 
-	id, code, err := e.R.Create(context.Background(), []byte(e.Config.REDIS_SECRET), VerifyPurpose, recipient, 6, time.Duration(expiresMin)*time.Minute, 6)
+	tr := e.translatorFor(ctx, recipient)
+	subject := tr.T("confirmation.subject")
+	html, text, err := e.render(tr, "confirmation", subject, map[string]any{
+		"Code":       code,
+		"ExpiresMin": expiresMin,
+		"ActionURL":  e.buildActionUrl(id, code),
+	})
 	if err != nil {
 		return "", err
 	}
 
-	html := strings.NewReplacer(
-		"{{APP_NAME}}", e.Config.APP_NAME,
-		"{{CODE}}", code,
-		"{{EXPIRES_MIN}}", fmt.Sprint(expiresMin),
-		"{{ACTION_URL}}", e.buildActionUrl(id, code),
-		"{{SUPPORT_EMAIL}}", e.Config.SUPPORT_EMAIL,
-		"{{CURRENT_YEAR}}", fmt.Sprint(time.Now().Year()),
-	).Replace(tmpl)
-
-	_, err = e.SendEmail(recipient, subject, html)
-	if err != nil {
+	if err := e.Enqueue(ctx, Message{Type: TypeVerification, To: recipient, Subject: subject, HTML: html, Text: text}); err != nil {
 		return "", err
 	}
 
 	return id, nil
 }
 
-func (e *EmailClient) SendResetPasswordEmail(recipient string, subject string, expiresMin int) (string, error) {
-	tmpl, err := e.GetTemplateFromFile("reset_password_template.html")
+func (e *EmailClient) QueueResetPasswordEmail(ctx context.Context, recipient string, expiresMin int) (string, error) {
+	id, code, err := e.R.Create(ctx, []byte(e.Config.REDIS_SECRET), ResetPasswordPurpose, recipient, 6, time.Duration(expiresMin)*time.Minute, 1)
 	if err != nil {
 		return "", err
 	}
 
-	id, code, err := e.R.Create(context.Background(), []byte(e.Config.REDIS_SECRET), ResetPasswordPurpose, recipient, 6, time.Duration(expiresMin)*time.Minute, 1)
+	tr := e.translatorFor(ctx, recipient)
+	subject := tr.T("resetPassword.subject")
+	html, text, err := e.render(tr, "reset_password", subject, map[string]any{
+		"ExpiresMin": expiresMin,
+		"ResetURL":   e.buildResetPasswordUrl(id, code),
+	})
 	if err != nil {
 		return "", err
 	}
 
-	html := strings.NewReplacer(
-		"{{APP_NAME}}", e.Config.APP_NAME,
-		"{{EXPIRES_MIN}}", fmt.Sprint(expiresMin),
-		"{{RESET_URL}}", e.buildResetPasswordUrl(id, code),
-		"{{SUPPORT_EMAIL}}", e.Config.SUPPORT_EMAIL,
-		"{{CURRENT_YEAR}}", fmt.Sprint(time.Now().Year()),
-	).Replace(tmpl)
-
-	_, err = e.SendEmail(recipient, subject, html)
-	if err != nil {
+	if err := e.Enqueue(ctx, Message{Type: TypePasswordReset, To: recipient, Subject: subject, HTML: html, Text: text}); err != nil {
 		return "", err
 	}
 
 	return id, nil
 }
+
+func (e *EmailClient) BuildInvitationUrl(token string) string {
+	return e.Config.APP_URL + "/invite?token=" + url.QueryEscape(token)
+}
+
+func (e *EmailClient) QueueInvitationEmail(ctx context.Context, recipient, teamName, inviter, role, token string, expiresDays int) error {
+	tr := e.translatorFor(ctx, recipient)
+	subject := tr.T("invitation.subject", "team", teamName)
+	html, text, err := e.render(tr, "invitation", subject, map[string]any{
+		"TeamName":    teamName,
+		"Inviter":     inviter,
+		"Role":        role,
+		"AcceptURL":   e.BuildInvitationUrl(token),
+		"ExpiresDays": expiresDays,
+	})
+	if err != nil {
+		return err
+	}
+
+	return e.Enqueue(ctx, Message{Type: TypeInvitation, To: recipient, Subject: subject, HTML: html, Text: text})
+}
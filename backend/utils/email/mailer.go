@@ -0,0 +1,79 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Neat-Snap/blueprint-backend/config"
+)
+
+// Message types. Each can be sent from its own address, see fromFor.
+const (
+	TypeVerification  = "verification"
+	TypePasswordReset = "password_reset"
+	TypeInvitation    = "invitation"
+	TypeSecurityAlert = "security_alert"
+	TypeNotification  = "notification"
+	TypeDigest        = "digest"
+	TypeFeedback      = "feedback"
+)
+
+// Message is a rendered email, ready to hand to the provider.
+type Message struct {
+	Type    string `json:"type"`
+	From    string `json:"from,omitempty"`
+	To      string `json:"to"`
+	Subject string `json:"subject"`
+	HTML    string `json:"html"`
+	Text    string `json:"text,omitempty"`
+	// extra headers, such as List-Unsubscribe
+	Headers map[string]string `json:"headers,omitempty"`
+	// the db.EmailMessage logging this message, when deliveries are tracked
+	RecordID uint `json:"record_id,omitempty"`
+}
+
+// Mailer hands a rendered message to a transport and returns the transport's message ID.
+type Mailer interface {
+	Name() string
+	Send(ctx context.Context, m Message) (string, error)
+}
+
+// NewMailer builds the transport selected by MAIL_TRANSPORT.
+func NewMailer(cfg config.Config) (Mailer, error) {
+	switch cfg.MAIL_TRANSPORT {
+	case "resend":
+		if cfg.RESEND_API_KEY == "" {
+			return nil, errors.New("RESEND_API_KEY is required for the resend transport")
+		}
+		return NewResendMailer(cfg.RESEND_API_KEY), nil
+	case "smtp":
+		if cfg.SMTP_HOST == "" {
+			return nil, errors.New("SMTP_HOST is required for the smtp transport")
+		}
+		return NewSMTPMailer(SMTPConfig{
+			Host:     cfg.SMTP_HOST,
+			Port:     cfg.SMTP_PORT,
+			Username: cfg.SMTP_USERNAME,
+			Password: cfg.SMTP_PASSWORD,
+			TLS:      cfg.SMTP_TLS,
+		})
+	case "capture":
+		if cfg.Env != "dev" {
+			return nil, errors.New("the capture transport is for development only and needs APP_ENV=dev")
+		}
+		return NewCaptureMailer(cfg.MAIL_CAPTURE_DIR)
+	case "":
+		return nil, errors.New("no email transport: set RESEND_API_KEY, or MAIL_TRANSPORT to smtp, or to capture with APP_ENV=dev")
+	default:
+		return nil, fmt.Errorf("unknown MAIL_TRANSPORT %q", cfg.MAIL_TRANSPORT)
+	}
+}
+
+// fromFor returns the sender for a message type: MAIL_FROM_<TYPE> when set, else MAIL_FROM.
+func (e *EmailClient) fromFor(messageType string) string {
+	if from := e.Config.MAIL_FROM_BY_TYPE[messageType]; from != "" {
+		return from
+	}
+	return e.Config.MAIL_FROM
+}
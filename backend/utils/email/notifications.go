@@ -0,0 +1,53 @@
+package email
+
+import (
+	"context"
+	"fmt"
+	"slices"
+)
+
+// QueueNotificationEmail emails an in-app notification whose title and body are already
+// in the recipient's language, see Locale. url is where its button leads.
+func (e *EmailClient) QueueNotificationEmail(ctx context.Context, recipient, title, body, url string) error {
+	tr := e.translatorFor(ctx, recipient)
+	html, text, err := e.render(tr, "notification", title, map[string]any{
+		"Body": body,
+		"URL":  url,
+	})
+	if err != nil {
+		return err
+	}
+
+	return e.Enqueue(ctx, Message{Type: TypeNotification, To: recipient, Subject: title, HTML: html, Text: text})
+}
+
+// Locale returns the language emails to recipient are written in.
+func (e *EmailClient) Locale(ctx context.Context, recipient string) string {
+	return e.translatorFor(ctx, recipient).lang
+}
+
+// Translate looks key up in the catalog of lang, as emails do, and fills its {name}
+// placeholders from params. Languages without a catalog get DefaultLocale.
+func (e *EmailClient) Translate(lang, key string, params map[string]any) string {
+	if !slices.Contains(Locales, lang) {
+		lang = DefaultLocale
+	}
+	tr := translator{lang: lang, app: e.Config.APP_NAME}
+	return tr.T(key, notificationArgs(tr, params)...)
+}
+
+// Role is a placeholder value naming a team role. Translate shows built-in roles in the
+// reader's language and custom ones as named by the team.
+type Role string
+
+// notificationArgs turns placeholder values into name/value pairs for translator.T.
+func notificationArgs(tr translator, params map[string]any) []any {
+	args := make([]any, 0, 2*len(params))
+	for name, value := range params {
+		if role, ok := value.(Role); ok {
+			value = tr.Role(string(role))
+		}
+		args = append(args, name, fmt.Sprint(value))
+	}
+	return args
+}
@@ -0,0 +1,16 @@
+package email
+
+import "testing"
+
+func TestTranslateRoles(t *testing.T) {
+	e := &EmailClient{}
+	params := map[string]any{"team_name": "Acme", "by": "Ann", "from_role": Role("member"), "role": Role("billing")}
+	for lang, want := range map[string]string{
+		"en": "Ann changed your role in the Acme team from member to billing.",
+		"ru": "Ваша роль в команде Acme изменена: участник → billing. Изменение внес(ла) Ann.",
+	} {
+		if got := e.Translate(lang, "notification.types.role_changed.body", params); got != want {
+			t.Errorf("%s: %q, want %q", lang, got, want)
+		}
+	}
+}
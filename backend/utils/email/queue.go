@@ -0,0 +1,86 @@
+package email
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/jobs"
+	"github.com/go-redis/redis/v8"
+	"github.com/google/uuid"
+)
+
+// SendJob only refers to the message. Bodies carry codes and links that act as
+// credentials, while jobs are kept, and shown to admins, long after they ran, so the
+// rendered message waits in Redis under its own key that expires on its own.
+var SendJob = jobs.Kind[queuedMessage]("email.send")
+
+// outboxTTL bounds how long a rendered message waiting to be sent is kept, well past the
+// retries of its job.
+const outboxTTL = 24 * time.Hour
+
+type queuedMessage struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// the db.EmailMessage logging this message, when deliveries are tracked
+	RecordID uint `json:"record_id,omitempty"`
+}
+
+func outboxKey(id string) string { return "email:outbox:" + id }
+
+// UseQueue makes the client send emails from background jobs on q. Without a queue they
+// are sent inline.
+func (e *EmailClient) UseQueue(q *jobs.Queue) {
+	e.queue = q
+	jobs.Handle(q, SendJob, func(ctx context.Context, qm queuedMessage) error {
+		raw, err := e.R.R.Get(ctx, outboxKey(qm.ID)).Bytes()
+		if errors.Is(err, redis.Nil) {
+			// sent by an earlier attempt, or waited longer than outboxTTL
+			e.logger.Warn("queued email is gone", "id", qm.ID, "type", qm.Type, "record_id", qm.RecordID)
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		var m Message
+		if err := json.Unmarshal(raw, &m); err != nil {
+			return err
+		}
+		_, err = e.Send(ctx, m)
+		if err != nil && !errors.Is(err, ErrSuppressed) {
+			return err
+		}
+		// retrying a suppressed message would not change anything
+		if err := e.R.R.Del(ctx, outboxKey(qm.ID)).Err(); err != nil {
+			e.logger.Warn("failed to drop sent email", "error", err, "id", qm.ID)
+		}
+		return nil
+	})
+	if e.conn != nil {
+		e.schedulePrune(q)
+	}
+}
+
+// Enqueue schedules m for delivery. The provider is retried with backoff, so a slow or
+// failing provider does not fail the request that produced the email.
+// Messages to suppressed addresses are logged and refused with ErrSuppressed.
+func (e *EmailClient) Enqueue(ctx context.Context, m Message) error {
+	if err := e.record(ctx, &m); err != nil {
+		return err
+	}
+	if e.queue == nil {
+		_, err := e.Send(ctx, m)
+		return err
+	}
+	raw, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	id := uuid.NewString()
+	if err := e.R.R.Set(ctx, outboxKey(id), raw, outboxTTL).Err(); err != nil {
+		return err
+	}
+	_, err = jobs.Enqueue(ctx, e.queue, SendJob, queuedMessage{ID: id, Type: m.Type, RecordID: m.RecordID})
+	return err
+}
@@ -0,0 +1,133 @@
+package email
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/resend/resend-go/v2"
+)
+
+type ResendMailer struct {
+	client *resend.Client
+}
+
+func NewResendMailer(apiKey string) *ResendMailer {
+	return &ResendMailer{client: resend.NewClient(apiKey)}
+}
+
+func (m *ResendMailer) Name() string { return "resend" }
+
+func (m *ResendMailer) Send(ctx context.Context, msg Message) (string, error) {
+	sent, err := m.client.Emails.SendWithContext(ctx, &resend.SendEmailRequest{
+		From:    msg.From,
+		To:      []string{msg.To},
+		Subject: msg.Subject,
+		Html:    msg.HTML,
+		Text:    msg.Text,
+		Headers: msg.Headers,
+	})
+	if err != nil {
+		return "", err
+	}
+	return sent.Id, nil
+}
+
+// signatures older than this are rejected to limit replays
+const resendWebhookTolerance = 5 * time.Minute
+
+var ErrInvalidSignature = errors.New("invalid webhook signature")
+
+// VerifyResendWebhook checks the Svix signature Resend puts on its webhooks: an
+// HMAC-SHA256 of "<svix-id>.<svix-timestamp>.<body>" keyed with the decoded whsec_ secret.
+func VerifyResendWebhook(secret string, h http.Header, body []byte, now time.Time) error {
+	id, ts, sigs := h.Get("svix-id"), h.Get("svix-timestamp"), h.Get("svix-signature")
+	if secret == "" || id == "" || ts == "" || sigs == "" {
+		return ErrInvalidSignature
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+	if d := now.Sub(time.Unix(unix, 0)); d > resendWebhookTolerance || d < -resendWebhookTolerance {
+		return ErrInvalidSignature
+	}
+	key, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(secret, "whsec_"))
+	if err != nil {
+		return fmt.Errorf("invalid webhook secret: %w", err)
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + ts + "."))
+	mac.Write(body)
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	// several space-separated "v1,<sig>" entries are sent while a secret is being rotated
+	for _, sig := range strings.Fields(sigs) {
+		version, value, ok := strings.Cut(sig, ",")
+		if ok && version == "v1" && hmac.Equal([]byte(value), []byte(expected)) {
+			return nil
+		}
+	}
+	return ErrInvalidSignature
+}
+
+type resendEvent struct {
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      struct {
+		EmailID string   `json:"email_id"`
+		To      []string `json:"to"`
+		Bounce  *struct {
+			Type    string `json:"type"`
+			SubType string `json:"subType"`
+			Message string `json:"message"`
+		} `json:"bounce"`
+	} `json:"data"`
+}
+
+// ParseResendEvent turns a webhook body into a DeliveryEvent. ok is false for event types
+// that do not change a message's delivery status, such as opens and clicks.
+func ParseResendEvent(body []byte) (ev DeliveryEvent, ok bool, err error) {
+	var raw resendEvent
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return DeliveryEvent{}, false, err
+	}
+	ev = DeliveryEvent{Transport: "resend", ProviderID: raw.Data.EmailID, At: raw.CreatedAt}
+	if len(raw.Data.To) > 0 {
+		ev.Recipient = raw.Data.To[0]
+	}
+	if ev.At.IsZero() {
+		ev.At = time.Now()
+	}
+
+	switch raw.Type {
+	case "email.delivered":
+		ev.Status = StatusDelivered
+	case "email.bounced":
+		ev.Status = StatusBounced
+		// Resend reports bounces it considers permanent unless told otherwise
+		ev.Permanent = true
+		if b := raw.Data.Bounce; b != nil {
+			ev.Permanent = b.Type != "Transient" && b.Type != "Undetermined"
+			ev.Detail = strings.TrimSpace(b.Type + " " + b.SubType + ": " + b.Message)
+		}
+	case "email.complained":
+		ev.Status = StatusComplained
+		ev.Detail = "marked as spam by the recipient"
+	default:
+		return DeliveryEvent{}, false, nil
+	}
+	if ev.ProviderID == "" {
+		return DeliveryEvent{}, false, errors.New("event without email_id")
+	}
+	return ev, true, nil
+}
@@ -0,0 +1,118 @@
+package email
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+var resendTestKey = []byte("resend-webhook-test-key")
+
+// signResend signs body the way Resend (Svix) does.
+func signResend(key []byte, id string, at time.Time, body []byte) http.Header {
+	ts := strconv.FormatInt(at.Unix(), 10)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(id + "." + ts + "."))
+	mac.Write(body)
+	h := http.Header{}
+	h.Set("svix-id", id)
+	h.Set("svix-timestamp", ts)
+	h.Set("svix-signature", "v1,"+base64.StdEncoding.EncodeToString(mac.Sum(nil)))
+	return h
+}
+
+func TestVerifyResendWebhook(t *testing.T) {
+	secret := "whsec_" + base64.StdEncoding.EncodeToString(resendTestKey)
+	body := []byte(`{"type":"email.delivered"}`)
+	now := time.Now()
+
+	if err := VerifyResendWebhook(secret, signResend(resendTestKey, "msg_1", now, body), body, now); err != nil {
+		t.Errorf("valid signature: %v", err)
+	}
+
+	// during a secret rotation the signature of either secret is accepted
+	rotating := signResend(resendTestKey, "msg_1", now, body)
+	rotating.Set("svix-signature", "v1,b2xk "+rotating.Get("svix-signature"))
+	if err := VerifyResendWebhook(secret, rotating, body, now); err != nil {
+		t.Errorf("valid signature among several: %v", err)
+	}
+
+	stale := signResend(resendTestKey, "msg_1", now.Add(-6*time.Minute), body)
+	future := signResend(resendTestKey, "msg_1", now.Add(6*time.Minute), body)
+	noID := signResend(resendTestKey, "msg_1", now, body)
+	noID.Del("svix-id")
+	otherVersion := signResend(resendTestKey, "msg_1", now, body)
+	otherVersion.Set("svix-signature", "v2,"+otherVersion.Get("svix-signature")[3:])
+
+	for name, tc := range map[string]struct {
+		secret string
+		header http.Header
+		body   []byte
+	}{
+		"other key":     {secret, signResend([]byte("other key"), "msg_1", now, body), body},
+		"tampered body": {secret, signResend(resendTestKey, "msg_1", now, body), []byte(`{"type":"email.bounced"}`)},
+		"other id": {secret, func() http.Header {
+			h := signResend(resendTestKey, "msg_1", now, body)
+			h.Set("svix-id", "msg_2")
+			return h
+		}(), body},
+		"stale":           {secret, stale, body},
+		"from the future": {secret, future, body},
+		"missing header":  {secret, noID, body},
+		"other version":   {secret, otherVersion, body},
+		"no secret":       {"", signResend(resendTestKey, "msg_1", now, body), body},
+	} {
+		if err := VerifyResendWebhook(tc.secret, tc.header, tc.body, now); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: err = %v, want ErrInvalidSignature", name, err)
+		}
+	}
+}
+
+func TestParseResendEvent(t *testing.T) {
+	at := time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC)
+	for name, tc := range map[string]struct {
+		body string
+		want DeliveryEvent
+	}{
+		"delivered": {
+			`{"type":"email.delivered","created_at":"2026-10-01T12:00:00Z","data":{"email_id":"e1","to":["a@example.com"]}}`,
+			DeliveryEvent{Transport: "resend", ProviderID: "e1", Recipient: "a@example.com", At: at, Status: StatusDelivered},
+		},
+		"permanent bounce": {
+			`{"type":"email.bounced","created_at":"2026-10-01T12:00:00Z","data":{"email_id":"e2","to":["b@example.com"],"bounce":{"type":"Permanent","subType":"General","message":"no such user"}}}`,
+			DeliveryEvent{Transport: "resend", ProviderID: "e2", Recipient: "b@example.com", At: at, Status: StatusBounced, Permanent: true, Detail: "Permanent General: no such user"},
+		},
+		"transient bounce": {
+			`{"type":"email.bounced","created_at":"2026-10-01T12:00:00Z","data":{"email_id":"e3","to":["c@example.com"],"bounce":{"type":"Transient","subType":"MailboxFull","message":"full"}}}`,
+			DeliveryEvent{Transport: "resend", ProviderID: "e3", Recipient: "c@example.com", At: at, Status: StatusBounced, Detail: "Transient MailboxFull: full"},
+		},
+		"complaint": {
+			`{"type":"email.complained","created_at":"2026-10-01T12:00:00Z","data":{"email_id":"e4","to":["d@example.com"]}}`,
+			DeliveryEvent{Transport: "resend", ProviderID: "e4", Recipient: "d@example.com", At: at, Status: StatusComplained, Detail: "marked as spam by the recipient"},
+		},
+	} {
+		got, ok, err := ParseResendEvent([]byte(tc.body))
+		if err != nil || !ok {
+			t.Errorf("%s: ok = %v, err = %v", name, ok, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%s: %+v\nwant %+v", name, got, tc.want)
+		}
+	}
+
+	if _, ok, err := ParseResendEvent([]byte(`{"type":"email.opened","data":{"email_id":"e5"}}`)); ok || err != nil {
+		t.Errorf("untracked type: ok = %v, err = %v", ok, err)
+	}
+	if _, _, err := ParseResendEvent([]byte(`{"type":"email.delivered","data":{"to":["a@example.com"]}}`)); err == nil {
+		t.Error("event without email_id was accepted")
+	}
+	if _, _, err := ParseResendEvent([]byte(`not json`)); err == nil {
+		t.Error("malformed body was accepted")
+	}
+}
@@ -0,0 +1,76 @@
+package email
+
+import (
+	"context"
+	"time"
+)
+
+var SecureAccountPurpose = "secure_account"
+
+const secureAccountLinkTTL = 7 * 24 * time.Hour
+
+type SecurityAlertKind string
+
+const (
+	AlertPasswordChanged SecurityAlertKind = "password_changed"
+	AlertEmailChanged    SecurityAlertKind = "email_changed"
+	AlertProviderLinked  SecurityAlertKind = "provider_linked"
+	AlertNewDevice       SecurityAlertKind = "new_device"
+)
+
+// Detail labels, translated under securityAlert.details in the email catalogs.
+const (
+	DetailTime       = "time"
+	DetailDevice     = "device"
+	DetailIP         = "ip"
+	DetailCountry    = "country"
+	DetailMethod     = "method"
+	DetailProvider   = "provider"
+	DetailChangedVia = "changedVia"
+	DetailNewAddress = "newAddress"
+)
+
+// Values of a Localized detail, translated under securityAlert.values.
+const (
+	ViaAccountSettings = "accountSettings"
+	ViaResetLink       = "resetLink"
+)
+
+type AlertDetail struct {
+	Label string `json:"label"`
+	Value string `json:"value"`
+	// Value is a catalog key rather than literal text
+	Localized bool `json:"localized,omitempty"`
+}
+
+type SecurityAlert struct {
+	Kind    SecurityAlertKind `json:"kind"`
+	Details []AlertDetail     `json:"details"`
+}
+
+func (e *EmailClient) buildSecureAccountUrl(id, code string) string {
+	return e.Config.APP_URL + "/auth/secure-account?cid=" + id + "&code=" + code
+}
+
+// QueueSecurityAlertEmail notifies recipient about account-critical activity and includes
+// a one-time "secure my account" link that revokes sessions and starts a password reset.
+func (e *EmailClient) QueueSecurityAlertEmail(ctx context.Context, recipient string, alert SecurityAlert) error {
+	id, code, err := e.R.Create(ctx, []byte(e.Config.REDIS_SECRET), SecureAccountPurpose, recipient, 12, secureAccountLinkTTL, 3)
+	if err != nil {
+		return err
+	}
+
+	tr := e.translatorFor(ctx, recipient)
+	subject := tr.T("securityAlert." + string(alert.Kind) + ".subject")
+	html, text, err := e.render(tr, "security_alert", subject, map[string]any{
+		"Kind":        string(alert.Kind),
+		"Details":     alert.Details,
+		"SecureURL":   e.buildSecureAccountUrl(id, code),
+		"ExpiresDays": int(secureAccountLinkTTL.Hours() / 24),
+	})
+	if err != nil {
+		return err
+	}
+
+	return e.Enqueue(ctx, Message{Type: TypeSecurityAlert, To: recipient, Subject: subject, HTML: html, Text: text})
+}
@@ -0,0 +1,200 @@
+package email
+
+import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/json"
+	"fmt"
+	"html"
+	htmltemplate "html/template"
+	"regexp"
+	"slices"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+const DefaultLocale = "en"
+
+// Locales are the languages emails are translated to; they match the frontend catalogs.
+var Locales = []string{"en", "ru", "zh"}
+
+var templateNames = []string{"confirmation", "reset_password", "invitation", "security_alert", "notification", "digest"}
+
+var (
+	catalogs      = make(map[string]map[string]string)
+	htmlTemplates = make(map[string]*htmltemplate.Template)
+	textTemplates = make(map[string]*texttemplate.Template)
+)
+
+// The templates and catalogs are compiled into the binary, so a broken one is a build
+// mistake and fails at startup.
+func init() {
+	for _, lang := range Locales {
+		data, err := templateFS.ReadFile("templates/locales/" + lang + ".json")
+		if err != nil {
+			panic(err)
+		}
+		var tree map[string]any
+		if err := json.Unmarshal(data, &tree); err != nil {
+			panic(fmt.Sprintf("email locale %s: %v", lang, err))
+		}
+		catalogs[lang] = make(map[string]string)
+		flattenCatalog(catalogs[lang], "", tree)
+	}
+
+	// t is rebound to the recipient's language on every render
+	parseFuncs := map[string]any{
+		"t":              func(string, ...any) string { return "" },
+		"role":           func(string) string { return "" },
+		"link":           link,
+		"msoButton":      msoButton,
+		"endMso":         endMso,
+		"officeSettings": officeSettings,
+	}
+	for _, name := range templateNames {
+		htmlTemplates[name] = htmltemplate.Must(htmltemplate.New(name).Funcs(parseFuncs).
+			ParseFS(templateFS, "templates/layout.html", "templates/"+name+".html"))
+		textTemplates[name] = texttemplate.Must(texttemplate.New(name).Funcs(parseFuncs).
+			ParseFS(templateFS, "templates/layout.txt", "templates/"+name+".txt"))
+	}
+}
+
+func flattenCatalog(out map[string]string, prefix string, tree map[string]any) {
+	for k, v := range tree {
+		switch v := v.(type) {
+		case string:
+			out[prefix+k] = v
+		case map[string]any:
+			flattenCatalog(out, prefix+k+".", v)
+		}
+	}
+}
+
+// LocaleResolver returns the language preferred by the account behind an address, or ""
+// when there is no such account.
+type LocaleResolver func(ctx context.Context, address string) string
+
+// UseLocaleResolver makes the client render emails in the recipient's language. Without a
+// resolver every email is in DefaultLocale.
+func (e *EmailClient) UseLocaleResolver(r LocaleResolver) {
+	e.locale = r
+}
+
+func (e *EmailClient) translatorFor(ctx context.Context, recipient string) translator {
+	lang := DefaultLocale
+	if e.locale != nil {
+		if l := e.locale(ctx, recipient); slices.Contains(Locales, l) {
+			lang = l
+		}
+	}
+	return translator{lang: lang, app: e.Config.APP_NAME}
+}
+
+type translator struct {
+	lang string
+	app  string
+}
+
+// T looks key up in the catalog, falling back to English and then to the key itself, and
+// fills {name} placeholders from name/value pairs. {app} is always the app name.
+func (t translator) T(key string, args ...any) string {
+	s, ok := catalogs[t.lang][key]
+	if !ok {
+		if s, ok = catalogs[DefaultLocale][key]; !ok {
+			return key
+		}
+	}
+	pairs := []string{"{app}", t.app}
+	for i := 0; i+1 < len(args); i += 2 {
+		pairs = append(pairs, "{"+fmt.Sprint(args[i])+"}", fmt.Sprint(args[i+1]))
+	}
+	return strings.NewReplacer(pairs...).Replace(s)
+}
+
+// Role names a team role: built-in roles are translated, custom ones are shown as named
+// by the team.
+func (t translator) Role(name string) string {
+	key := "roles." + name
+	if s := t.T(key); s != key {
+		return s
+	}
+	return name
+}
+
+var blankLines = regexp.MustCompile(`\n{3,}`)
+
+// render executes the named template in both formats. data is shared by both and gets the
+// fields every layout uses.
+func (e *EmailClient) render(tr translator, name, subject string, data map[string]any) (string, string, error) {
+	data["Lang"] = tr.lang
+	data["Subject"] = subject
+	data["AppName"] = e.Config.APP_NAME
+	data["SupportEmail"] = e.Config.SUPPORT_EMAIL
+	data["Year"] = time.Now().Year()
+
+	funcs := map[string]any{"t": tr.T, "role": tr.Role}
+
+	htmlTmpl, err := htmlTemplates[name].Clone()
+	if err != nil {
+		return "", "", err
+	}
+	var htmlBody bytes.Buffer
+	if err := htmlTmpl.Funcs(funcs).ExecuteTemplate(&htmlBody, "layout", data); err != nil {
+		return "", "", fmt.Errorf("render %s html: %w", name, err)
+	}
+
+	textTmpl, err := textTemplates[name].Clone()
+	if err != nil {
+		return "", "", err
+	}
+	var textBody bytes.Buffer
+	if err := textTmpl.Funcs(funcs).ExecuteTemplate(&textBody, "layout", data); err != nil {
+		return "", "", fmt.Errorf("render %s text: %w", name, err)
+	}
+
+	return htmlBody.String(), strings.TrimSpace(blankLines.ReplaceAllString(textBody.String(), "\n\n")) + "\n", nil
+}
+
+type buttonLink struct {
+	URL   string
+	Label string
+}
+
+func link(url, label string) buttonLink {
+	return buttonLink{URL: url, Label: label}
+}
+
+// html/template strips comments, so the Outlook-only markup is written out here.
+
+func msoButton(url, label string) htmltemplate.HTML {
+	return htmltemplate.HTML(`<!--[if mso]>
+      <v:roundrect xmlns:v="urn:schemas-microsoft-com:vml" xmlns:w="urn:schemas-microsoft-com:office:word"
+        href="` + html.EscapeString(url) + `" style="height:44px;v-text-anchor:middle;width:260px;" arcsize="10%"
+        stroke="f" fillcolor="#2563eb">
+        <w:anchorlock/>
+        <center style="color:#ffffff;font-family:Segoe UI, Arial,sans-serif;font-size:15px;font-weight:600;">
+          ` + html.EscapeString(label) + `
+        </center>
+      </v:roundrect>
+    <![endif]-->
+    <!--[if !mso]><!-- -->`)
+}
+
+func endMso() htmltemplate.HTML {
+	return `<!--<![endif]-->`
+}
+
+func officeSettings() htmltemplate.HTML {
+	return `<!--[if mso]>
+    <xml>
+      <o:OfficeDocumentSettings>
+        <o:PixelsPerInch>96</o:PixelsPerInch>
+      </o:OfficeDocumentSettings>
+    </xml>
+  <![endif]-->`
+}
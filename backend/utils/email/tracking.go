@@ -0,0 +1,182 @@
+package email
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/jobs"
+	"gorm.io/gorm"
+)
+
+// ErrSuppressed is returned for messages to an address on the suppression list.
+var ErrSuppressed = errors.New("emails to this address are suppressed after a bounce or complaint")
+
+// Delivery statuses, see db.EmailMessage.
+const (
+	StatusQueued     = "queued"
+	StatusSent       = "sent"
+	StatusFailed     = "failed"
+	StatusDelivered  = "delivered"
+	StatusBounced    = "bounced"
+	StatusComplained = "complained"
+	StatusSuppressed = "suppressed"
+)
+
+// how long the delivery log is kept
+const messageRetention = 90 * 24 * time.Hour
+
+var pruneLogJob = jobs.Kind[struct{}]("email.prune_log")
+
+// TrackDeliveries records every message in conn and refuses to send to suppressed
+// addresses. Call it before UseQueue so the log is pruned too.
+func (e *EmailClient) TrackDeliveries(conn *db.Connection) {
+	e.conn = conn
+}
+
+// record logs m before it is queued and stamps it with the record's ID.
+func (e *EmailClient) record(ctx context.Context, m *Message) error {
+	if e.conn == nil {
+		return nil
+	}
+	suppressed, err := e.suppressed(ctx, m.To)
+	if err != nil {
+		return err
+	}
+	rec := &db.EmailMessage{
+		Recipient: m.To,
+		Type:      m.Type,
+		Subject:   m.Subject,
+		Transport: e.Mailer.Name(),
+		Status:    StatusQueued,
+	}
+	if suppressed {
+		rec.Status = StatusSuppressed
+	}
+	if err := e.conn.Emails.CreateMessage(ctx, rec); err != nil {
+		return err
+	}
+	if suppressed {
+		return ErrSuppressed
+	}
+	m.RecordID = rec.ID
+	return nil
+}
+
+func (e *EmailClient) suppressed(ctx context.Context, address string) (bool, error) {
+	_, err := e.conn.Emails.Suppression(ctx, address)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// track stores the outcome of a send attempt. Failing to update the log must not turn a
+// sent email into a retried one, so errors are only logged.
+func (e *EmailClient) track(ctx context.Context, m Message, status, providerID string, sendErr error) {
+	if e.conn == nil || m.RecordID == 0 {
+		return
+	}
+	rec, err := e.conn.Emails.MessageByID(ctx, m.RecordID)
+	if err != nil {
+		e.logger.Warn("failed to load email record", "error", err, "record_id", m.RecordID)
+		return
+	}
+	rec.Status = status
+	rec.Transport = e.Mailer.Name()
+	rec.Error = ""
+	if sendErr != nil {
+		rec.Error = sendErr.Error()
+	}
+	if providerID != "" {
+		now := time.Now()
+		rec.ProviderID = providerID
+		rec.SentAt = &now
+	}
+	if err := e.conn.Emails.UpdateMessage(ctx, rec); err != nil {
+		e.logger.Warn("failed to update email record", "error", err, "record_id", m.RecordID)
+	}
+}
+
+func (e *EmailClient) schedulePrune(q *jobs.Queue) {
+	jobs.Handle(q, pruneLogJob, func(ctx context.Context, _ struct{}) error {
+		n, err := e.conn.Emails.DeleteMessagesBefore(ctx, time.Now().Add(-messageRetention))
+		if err != nil {
+			return err
+		}
+		if n > 0 {
+			e.logger.Info("pruned email log", "deleted", n)
+		}
+		return nil
+	})
+	if err := jobs.Every(q, pruneLogJob, 24*time.Hour, struct{}{}); err != nil {
+		e.logger.Error("failed to schedule email log pruning", "error", err)
+	}
+}
+
+// DeliveryEvent is what a provider webhook reported about a sent message.
+type DeliveryEvent struct {
+	Transport  string
+	ProviderID string
+	Recipient  string
+	// StatusDelivered, StatusBounced or StatusComplained
+	Status string
+	// a hard bounce; soft bounces are recorded but do not suppress the address
+	Permanent bool
+	Detail    string
+	At        time.Time
+}
+
+// ApplyDeliveryEvent updates the logged message and suppresses the address on a hard
+// bounce or complaint. It returns the suppression only when this event created it.
+func (e *EmailClient) ApplyDeliveryEvent(ctx context.Context, ev DeliveryEvent) (*db.EmailSuppression, error) {
+	if e.conn == nil {
+		return nil, errors.New("delivery tracking is not enabled")
+	}
+
+	var messageID *uint
+	rec, err := e.conn.Emails.MessageByProviderID(ctx, ev.Transport, ev.ProviderID)
+	switch {
+	case err == nil:
+		messageID = &rec.ID
+		if ev.Recipient == "" {
+			ev.Recipient = rec.Recipient
+		}
+		// a late "delivered" must not hide an earlier bounce or complaint
+		if ev.Status != StatusDelivered || rec.Status != StatusBounced && rec.Status != StatusComplained {
+			rec.Status = ev.Status
+		}
+		if ev.Status == StatusDelivered {
+			rec.DeliveredAt = &ev.At
+		} else {
+			rec.Error = ev.Detail
+		}
+		if err := e.conn.Emails.UpdateMessage(ctx, rec); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		// sent before tracking was enabled; the recipient is still worth suppressing
+	default:
+		return nil, err
+	}
+
+	reason := ""
+	switch {
+	case ev.Status == StatusBounced && ev.Permanent:
+		reason = "bounce"
+	case ev.Status == StatusComplained:
+		reason = "complaint"
+	}
+	if reason == "" || ev.Recipient == "" {
+		return nil, nil
+	}
+
+	s := &db.EmailSuppression{Email: ev.Recipient, Reason: reason, Detail: ev.Detail, MessageID: messageID}
+	created, err := e.conn.Emails.Suppress(ctx, s)
+	if err != nil || !created {
+		return nil, err
+	}
+	e.logger.Warn("email address suppressed", "reason", reason, "detail", ev.Detail)
+	return s, nil
+}
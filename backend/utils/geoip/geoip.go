@@ -0,0 +1,54 @@
+package geoip
+
+import (
+	"net"
+	"strings"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// Resolver looks up countries in a local MaxMind-format (GeoLite2/GeoIP2) database.
+// A nil Resolver is valid and resolves nothing, so GeoIP stays optional.
+type Resolver struct {
+	db *maxminddb.Reader
+}
+
+type countryRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+func Open(path string) (*Resolver, error) {
+	if path == "" {
+		return nil, nil
+	}
+	db, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	return &Resolver{db: db}, nil
+}
+
+// Country returns the ISO 3166-1 alpha-2 code for ip, or "" when unknown.
+func (g *Resolver) Country(ip string) string {
+	if g == nil || g.db == nil {
+		return ""
+	}
+	parsed := net.ParseIP(ip)
+	if parsed == nil || parsed.IsLoopback() || parsed.IsPrivate() {
+		return ""
+	}
+	var rec countryRecord
+	if err := g.db.Lookup(parsed, &rec); err != nil {
+		return ""
+	}
+	return strings.ToUpper(rec.Country.ISOCode)
+}
+
+func (g *Resolver) Close() error {
+	if g == nil || g.db == nil {
+		return nil
+	}
+	return g.db.Close()
+}
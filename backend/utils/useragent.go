@@ -0,0 +1,89 @@
+package utils
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type UserAgentInfo struct {
+	Browser    string
+	OS         string
+	DeviceType string
+}
+
+var browserMarkers = []struct{ marker, name string }{
+	{"edg/", "Edge"},
+	{"opr/", "Opera"},
+	{"yabrowser/", "Yandex"},
+	{"samsungbrowser/", "Samsung Internet"},
+	{"firefox/", "Firefox"},
+	{"fxios/", "Firefox"},
+	{"crios/", "Chrome"},
+	{"chrome/", "Chrome"},
+	{"safari/", "Safari"},
+	{"curl/", "curl"},
+	{"postmanruntime/", "Postman"},
+}
+
+var osMarkers = []struct{ marker, name string }{
+	{"windows", "Windows"},
+	{"iphone", "iOS"},
+	{"ipad", "iPadOS"},
+	{"android", "Android"},
+	{"cros", "ChromeOS"},
+	{"mac os x", "macOS"},
+	{"macintosh", "macOS"},
+	{"linux", "Linux"},
+}
+
+// ParseUserAgent extracts coarse browser, OS and device type from a User-Agent header.
+// It is intentionally simple: the result is shown to users and used to spot new devices.
+func ParseUserAgent(ua string) UserAgentInfo {
+	l := strings.ToLower(ua)
+	info := UserAgentInfo{Browser: "Unknown", OS: "Unknown", DeviceType: "desktop"}
+	if l == "" {
+		info.DeviceType = "unknown"
+		return info
+	}
+
+	for _, b := range browserMarkers {
+		if strings.Contains(l, b.marker) {
+			info.Browser = b.name
+			break
+		}
+	}
+	for _, o := range osMarkers {
+		if strings.Contains(l, o.marker) {
+			info.OS = o.name
+			break
+		}
+	}
+
+	switch {
+	case strings.Contains(l, "bot"), strings.Contains(l, "spider"), strings.Contains(l, "crawl"):
+		info.DeviceType = "bot"
+	case strings.Contains(l, "ipad"), strings.Contains(l, "tablet"):
+		info.DeviceType = "tablet"
+	case strings.Contains(l, "mobi"), strings.Contains(l, "iphone"), strings.Contains(l, "android"):
+		info.DeviceType = "mobile"
+	}
+	return info
+}
+
+// DeviceHash identifies a device class (browser, OS, device type) without storing anything more specific.
+func (i UserAgentInfo) DeviceHash() string {
+	sum := sha256.Sum256([]byte(i.Browser + "|" + i.OS + "|" + i.DeviceType))
+	return hex.EncodeToString(sum[:])
+}
+
+// ClientIP returns the request IP. middleware.RealIP has already rewritten RemoteAddr
+// from X-Real-IP / X-Forwarded-For, so only the port has to be stripped.
+func ClientIP(r *http.Request) string {
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		return host
+	}
+	return r.RemoteAddr
+}
@@ -0,0 +1,316 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+)
+
+const (
+	EventMemberAdded       = "member.added"
+	EventMemberRemoved     = "member.removed"
+	EventMemberRoleChanged = "member.role_changed"
+	EventInvitationCreated = "invitation.created"
+	EventInvitationRevoked = "invitation.revoked"
+	EventTeamUpdated       = "team.updated"
+	EventTeamDeleted       = "team.deleted"
+	EventPing              = "ping"
+)
+
+// EventTypes lists the events an endpoint can subscribe to.
+var EventTypes = []string{
+	EventMemberAdded,
+	EventMemberRemoved,
+	EventMemberRoleChanged,
+	EventInvitationCreated,
+	EventInvitationRevoked,
+	EventTeamUpdated,
+	EventTeamDeleted,
+}
+
+func IsEventType(e string) bool {
+	return slices.Contains(EventTypes, e)
+}
+
+const (
+	SignatureHeader = "X-Blueprint-Signature"
+	TimestampHeader = "X-Blueprint-Timestamp"
+	EventHeader     = "X-Blueprint-Event"
+	DeliveryHeader  = "X-Blueprint-Delivery"
+
+	maxAttempts     = 8
+	baseBackoff     = 30 * time.Second
+	maxBackoff      = 6 * time.Hour
+	claimBatch      = 20
+	pollInterval    = 5 * time.Second
+	deliveryTimeout = 10 * time.Second
+	// a claimed batch is sent concurrently, so it is done within about one delivery
+	// timeout; the lease leaves room for the database on top before another replica may
+	// claim the same rows
+	claimLease       = 2 * time.Minute
+	responseBodySize = 2048
+)
+
+type Dispatcher struct {
+	conn   *db.Connection
+	logger logger.MultiLogger
+	client *http.Client
+	wake   chan struct{}
+
+	AllowPrivate bool
+}
+
+func NewDispatcher(conn *db.Connection, logger logger.MultiLogger, allowPrivate bool) *Dispatcher {
+	return &Dispatcher{
+		conn:         conn,
+		logger:       logger,
+		client:       NewHTTPClient(deliveryTimeout, allowPrivate),
+		wake:         make(chan struct{}, 1),
+		AllowPrivate: allowPrivate,
+	}
+}
+
+// Envelope is the JSON body of every delivery.
+type Envelope struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"created_at"`
+	TeamID    uint      `json:"team_id"`
+	Data      any       `json:"data"`
+}
+
+func NewSecret() string {
+	b := make([]byte, 32)
+	_, _ = rand.Read(b)
+	return "whsec_" + hex.EncodeToString(b)
+}
+
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return "evt_" + hex.EncodeToString(b)
+}
+
+// Sign computes the signature sent in SignatureHeader: an HMAC-SHA256 over
+// "<timestamp>.<body>" keyed with the endpoint secret. Receivers should recompute it and
+// reject timestamps that are too old to stop replays.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Enqueue records a delivery for every active endpoint of the team subscribed to event.
+// Pass the transaction's connection so deliveries only exist if the change commits; the
+// worker is woken once they do.
+func (d *Dispatcher) Enqueue(ctx context.Context, conn *db.Connection, teamID uint, event string, data any) error {
+	endpoints, err := conn.Webhooks.ActiveEndpoints(ctx, teamID)
+	if err != nil {
+		return err
+	}
+
+	env := Envelope{ID: newEventID(), Type: event, CreatedAt: time.Now().UTC(), TeamID: teamID, Data: data}
+	body, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	var deliveries []db.WebhookDelivery
+	for _, e := range endpoints {
+		if !slices.Contains(e.Events, event) {
+			continue
+		}
+		deliveries = append(deliveries, db.WebhookDelivery{
+			EndpointID:    e.ID,
+			EventID:       env.ID,
+			Event:         event,
+			Payload:       string(body),
+			Status:        "pending",
+			NextAttemptAt: &now,
+		})
+	}
+	if err := conn.Webhooks.CreateDeliveries(ctx, deliveries); err != nil {
+		return err
+	}
+	if len(deliveries) > 0 {
+		conn.AfterCommit(d.Wake)
+	}
+	return nil
+}
+
+// Ping queues a test delivery to a single endpoint regardless of its subscriptions.
+func (d *Dispatcher) Ping(ctx context.Context, endpoint *db.WebhookEndpoint) (*db.WebhookDelivery, error) {
+	env := Envelope{ID: newEventID(), Type: EventPing, CreatedAt: time.Now().UTC(), TeamID: endpoint.TeamID, Data: map[string]any{"endpoint_id": endpoint.ID}}
+	body, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	return d.Redeliver(ctx, &db.WebhookDelivery{EndpointID: endpoint.ID, EventID: env.ID, Event: EventPing, Payload: string(body)})
+}
+
+// Redeliver queues a fresh attempt of an earlier delivery, keeping its event ID.
+func (d *Dispatcher) Redeliver(ctx context.Context, prev *db.WebhookDelivery) (*db.WebhookDelivery, error) {
+	now := time.Now()
+	next := []db.WebhookDelivery{{
+		EndpointID:    prev.EndpointID,
+		EventID:       prev.EventID,
+		Event:         prev.Event,
+		Payload:       prev.Payload,
+		Status:        "pending",
+		NextAttemptAt: &now,
+	}}
+	if err := d.conn.Webhooks.CreateDeliveries(ctx, next); err != nil {
+		return nil, err
+	}
+	d.Wake()
+	return &next[0], nil
+}
+
+// Wake makes the worker look for due deliveries now instead of at the next poll.
+func (d *Dispatcher) Wake() {
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run delivers due webhooks until ctx is cancelled. Several replicas can run it at once;
+// deliveries are leased before they are sent.
+func (d *Dispatcher) Run(ctx context.Context) {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		d.deliverDue(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-d.wake:
+		}
+	}
+}
+
+func (d *Dispatcher) deliverDue(ctx context.Context) {
+	for ctx.Err() == nil {
+		batch, err := d.conn.Webhooks.ClaimDueDeliveries(ctx, time.Now(), claimLease, claimBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				d.logger.Error("failed to claim webhook deliveries", "error", err)
+			}
+			return
+		}
+		var wg sync.WaitGroup
+		for i := range batch {
+			wg.Add(1)
+			go func(del *db.WebhookDelivery) {
+				defer wg.Done()
+				d.attempt(ctx, del)
+			}(&batch[i])
+		}
+		wg.Wait()
+		if len(batch) < claimBatch {
+			return
+		}
+	}
+}
+
+func (d *Dispatcher) attempt(ctx context.Context, del *db.WebhookDelivery) {
+	if del.Endpoint == nil || !del.Endpoint.Active {
+		del.Status = "failed"
+		del.Error = "endpoint is disabled or deleted"
+		del.NextAttemptAt = nil
+		d.save(ctx, del)
+		return
+	}
+
+	started := time.Now()
+	status, body, err := d.send(ctx, del.Endpoint, del)
+	now := time.Now()
+
+	del.Attempts++
+	del.LastAttemptAt = &now
+	del.DurationMs = now.Sub(started).Milliseconds()
+	del.ResponseStatus = status
+	del.ResponseBody = body
+	del.Error = ""
+
+	switch {
+	case err == nil && status >= 200 && status < 300:
+		del.Status = "succeeded"
+		del.NextAttemptAt = nil
+	default:
+		if err != nil {
+			del.Error = err.Error()
+		} else {
+			del.Error = fmt.Sprintf("endpoint responded with status %d", status)
+		}
+		if del.Attempts >= maxAttempts {
+			del.Status = "failed"
+			del.NextAttemptAt = nil
+		} else {
+			next := now.Add(Backoff(del.Attempts))
+			del.NextAttemptAt = &next
+		}
+	}
+	d.save(ctx, del)
+}
+
+func (d *Dispatcher) save(ctx context.Context, del *db.WebhookDelivery) {
+	if err := d.conn.Webhooks.UpdateDelivery(ctx, del); err != nil {
+		d.logger.Error("failed to record webhook delivery", "error", err, "delivery_id", del.ID)
+	}
+}
+
+func (d *Dispatcher) send(ctx context.Context, endpoint *db.WebhookEndpoint, del *db.WebhookDelivery) (int, string, error) {
+	body := []byte(del.Payload)
+	ts := time.Now().Unix()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Blueprint-Webhooks/1.0")
+	req.Header.Set(EventHeader, del.Event)
+	req.Header.Set(DeliveryHeader, del.EventID)
+	req.Header.Set(TimestampHeader, strconv.FormatInt(ts, 10))
+	req.Header.Set(SignatureHeader, Sign(endpoint.Secret, ts, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, "", err
+	}
+	defer resp.Body.Close()
+
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, responseBodySize))
+	return resp.StatusCode, string(snippet), nil
+}
+
+// Backoff returns the wait before the next attempt after the given number of failures:
+// 30s, 1m, 2m, ... capped at six hours.
+func Backoff(attempts int) time.Duration {
+	d := time.Duration(float64(baseBackoff) * math.Pow(2, float64(attempts-1)))
+	if d > maxBackoff || d <= 0 {
+		return maxBackoff
+	}
+	return d
+}
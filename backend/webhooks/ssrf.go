@@ -0,0 +1,123 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var ErrForbiddenDestination = errors.New("webhook destination resolves to a private or reserved address")
+
+// isForbiddenIP reports whether ip must never be dialled by the webhook client.
+func isForbiddenIP(ip net.IP) bool {
+	if ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() ||
+		ip.IsMulticast() {
+		return true
+	}
+	for _, n := range reservedNetworks {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// reservedNetworks are the special-purpose ranges the net.IP methods do not cover.
+var reservedNetworks = []*net.IPNet{
+	// "this network"; 0.0.0.0/8 reaches the local host on Linux
+	mustParseCIDR("0.0.0.0/8"),
+	// carrier-grade NAT
+	mustParseCIDR("100.64.0.0/10"),
+	// IETF protocol assignments
+	mustParseCIDR("192.0.0.0/24"),
+	// benchmarking
+	mustParseCIDR("198.18.0.0/15"),
+	// reserved, including the broadcast address
+	mustParseCIDR("240.0.0.0/4"),
+	// NAT64, which a gateway translates to the embedded IPv4 address, private or not
+	mustParseCIDR("64:ff9b::/96"),
+}
+
+func mustParseCIDR(s string) *net.IPNet {
+	_, n, err := net.ParseCIDR(s)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
+
+// ValidateURL checks the shape of a webhook URL and, unless allowPrivate is set, that its
+// host does not resolve to a forbidden address. The dialer checks again on every delivery,
+// since DNS answers can change after registration.
+func ValidateURL(ctx context.Context, raw string, allowPrivate bool) (*url.URL, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid url: %w", err)
+	}
+	if u.Scheme != "https" && !(allowPrivate && u.Scheme == "http") {
+		return nil, errors.New("webhook url must use https")
+	}
+	if u.Hostname() == "" || u.User != nil {
+		return nil, errors.New("webhook url must have a host and no credentials")
+	}
+	if allowPrivate {
+		return u, nil
+	}
+
+	ips, err := net.DefaultResolver.LookupIP(ctx, "ip", u.Hostname())
+	if err != nil {
+		return nil, fmt.Errorf("cannot resolve webhook host: %w", err)
+	}
+	for _, ip := range ips {
+		if isForbiddenIP(ip) {
+			return nil, ErrForbiddenDestination
+		}
+	}
+	return u, nil
+}
+
+// NewHTTPClient returns a client whose dialer refuses forbidden addresses after DNS
+// resolution, so a host that later rebinds to an internal address is still blocked.
+func NewHTTPClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 5 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || isForbiddenIP(ip) {
+				return ErrForbiddenDestination
+			}
+			return nil
+		}
+	}
+
+	transport := &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   5 * time.Second,
+		ResponseHeaderTimeout: timeout,
+		MaxIdleConns:          20,
+		IdleConnTimeout:       90 * time.Second,
+	}
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		// a redirect is reported as the delivery's response instead of being followed
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,134 @@
+package webpush
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// Payloads are encrypted as a single aes128gcm record (RFC 8188) with the key derivation
+// of RFC 8291.
+
+const (
+	recordSize = 4096
+	saltSize   = 16
+	// salt, record size, key ID length and the 65-byte public key
+	headerSize = saltSize + 4 + 1 + 65
+	tagSize    = 16
+	// push services accept 4096 bytes of body; one byte goes to the padding delimiter
+	MaxPayloadSize = recordSize - headerSize - tagSize - 1
+)
+
+var ErrPayloadTooLarge = fmt.Errorf("push payload is larger than %d bytes", MaxPayloadSize)
+
+// Encrypt encrypts plaintext for the browser that owns the p256dh public key and auth
+// secret of a subscription.
+func Encrypt(p256dh, authSecret, plaintext []byte) ([]byte, error) {
+	if len(plaintext) > MaxPayloadSize {
+		return nil, ErrPayloadTooLarge
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(p256dh)
+	if err != nil {
+		return nil, fmt.Errorf("invalid subscription key: %w", err)
+	}
+	if len(authSecret) != 16 {
+		return nil, errors.New("invalid subscription auth secret")
+	}
+
+	asPrivate, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	return encrypt(asPrivate, salt, uaPublic, authSecret, plaintext)
+}
+
+// encrypt is Encrypt with the sender's key pair and the salt chosen by the caller.
+func encrypt(asPrivate *ecdh.PrivateKey, salt []byte, uaPublic *ecdh.PublicKey, authSecret, plaintext []byte) ([]byte, error) {
+	gcm, nonce, err := contentKeys(asPrivate, uaPublic, uaPublic.Bytes(), asPrivate.PublicKey().Bytes(), authSecret, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	body.Write(salt)
+	_ = binary.Write(&body, binary.BigEndian, uint32(recordSize))
+	asPublic := asPrivate.PublicKey().Bytes()
+	body.WriteByte(byte(len(asPublic)))
+	body.Write(asPublic)
+	// 0x02 marks the last record; no further padding
+	record := append(bytes.Clone(plaintext), 0x02)
+	return gcm.Seal(body.Bytes(), nonce, record, nil), nil
+}
+
+// Decrypt reverses Encrypt for the holder of the subscription's private key, as a push
+// service stand-in does.
+func Decrypt(uaPrivate *ecdh.PrivateKey, authSecret, body []byte) ([]byte, error) {
+	if len(body) < headerSize+tagSize {
+		return nil, errors.New("push body too short")
+	}
+	salt := body[:saltSize]
+	idLen := int(body[saltSize+4])
+	if idLen != 65 || len(body) < saltSize+5+idLen+tagSize {
+		return nil, errors.New("unexpected key ID in push body")
+	}
+	asPublic, err := ecdh.P256().NewPublicKey(body[saltSize+5 : saltSize+5+idLen])
+	if err != nil {
+		return nil, err
+	}
+
+	gcm, nonce, err := contentKeys(uaPrivate, asPublic, uaPrivate.PublicKey().Bytes(), asPublic.Bytes(), authSecret, salt)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := gcm.Open(nil, nonce, body[saltSize+5+idLen:], nil)
+	if err != nil {
+		return nil, err
+	}
+	plain = bytes.TrimRight(plain, "\x00")
+	if len(plain) == 0 || plain[len(plain)-1] != 0x02 {
+		return nil, errors.New("push body is not a single final record")
+	}
+	return plain[:len(plain)-1], nil
+}
+
+// contentKeys derives the AES-GCM key and nonce both sides agree on.
+func contentKeys(priv *ecdh.PrivateKey, peer *ecdh.PublicKey, uaPublic, asPublic, authSecret, salt []byte) (cipher.AEAD, []byte, error) {
+	shared, err := priv.ECDH(peer)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyInfo := "WebPush: info\x00" + string(uaPublic) + string(asPublic)
+	ikm, err := hkdf.Key(sha256.New, shared, authSecret, keyInfo, 32)
+	if err != nil {
+		return nil, nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, nil, err
+	}
+	return gcm, nonce, nil
+}
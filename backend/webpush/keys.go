@@ -0,0 +1,99 @@
+package webpush
+
+import (
+	"context"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// Keys is the VAPID key pair the server signs pushes with. Browsers tie each
+// subscription to the public key, so changing it invalidates every subscription.
+type Keys struct {
+	private *ecdsa.PrivateKey
+}
+
+// GenerateKeys creates a new P-256 key pair.
+func GenerateKeys() (*Keys, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Keys{private: priv}, nil
+}
+
+// ParseKeys reads a private key in the unpadded base64url form other VAPID tools print.
+func ParseKeys(private string) (*Keys, error) {
+	raw, err := decodeBase64(private)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	key, err := ecdh.P256().NewPrivateKey(raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	pub := key.PublicKey().Bytes()
+	return &Keys{private: &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(pub[1:33]),
+			Y:     new(big.Int).SetBytes(pub[33:]),
+		},
+		D: new(big.Int).SetBytes(raw),
+	}}, nil
+}
+
+// PublicKey is the application server key browsers subscribe with.
+func (k *Keys) PublicKey() string {
+	pub, _ := k.private.PublicKey.ECDH()
+	return base64.RawURLEncoding.EncodeToString(pub.Bytes())
+}
+
+func (k *Keys) PrivateKey() string {
+	priv, _ := k.private.ECDH()
+	return base64.RawURLEncoding.EncodeToString(priv.Bytes())
+}
+
+// LoadOrCreateKeys returns the key pair stored under key in Redis, generating it on first
+// use. Every replica ends up with the same pair, whichever creates it.
+func LoadOrCreateKeys(ctx context.Context, rdb *redis.Client, key string) (keys *Keys, created bool, err error) {
+	stored, err := rdb.Get(ctx, key).Result()
+	if err == nil {
+		keys, err = ParseKeys(stored)
+		return keys, false, err
+	}
+	if !errors.Is(err, redis.Nil) {
+		return nil, false, err
+	}
+
+	keys, err = GenerateKeys()
+	if err != nil {
+		return nil, false, err
+	}
+	ok, err := rdb.SetNX(ctx, key, keys.PrivateKey(), 0).Result()
+	if err != nil {
+		return nil, false, err
+	}
+	if !ok {
+		// another replica got there first
+		return LoadOrCreateKeys(ctx, rdb, key)
+	}
+	return keys, true, nil
+}
+
+// browsers hand out keys in base64url, with or without padding, and sometimes in plain base64
+func decodeBase64(s string) ([]byte, error) {
+	for _, enc := range []*base64.Encoding{base64.RawURLEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.StdEncoding} {
+		if b, err := enc.DecodeString(s); err == nil {
+			return b, nil
+		}
+	}
+	return nil, errors.New("not base64")
+}
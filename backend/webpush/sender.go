@@ -0,0 +1,162 @@
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ErrGone is returned for a subscription the push service no longer knows (404 or 410).
+// It will never accept pushes again and should be deleted.
+var ErrGone = errors.New("push subscription has expired or was removed")
+
+// Subscription is what the browser's PushManager hands out, keys in base64url.
+type Subscription struct {
+	Endpoint string
+	P256dh   string
+	Auth     string
+}
+
+// Validate checks that the keys are a P-256 public key and a 16-byte auth secret.
+func (s Subscription) Validate() error {
+	p256dh, err := decodeBase64(s.P256dh)
+	if err != nil {
+		return errors.New("invalid subscription key")
+	}
+	if _, err := ecdh.P256().NewPublicKey(p256dh); err != nil {
+		return errors.New("invalid subscription key")
+	}
+	auth, err := decodeBase64(s.Auth)
+	if err != nil || len(auth) != 16 {
+		return errors.New("invalid subscription auth secret")
+	}
+	return nil
+}
+
+// Urgency values, RFC 8030 section 5.3.
+const (
+	UrgencyLow    = "low"
+	UrgencyNormal = "normal"
+	UrgencyHigh   = "high"
+)
+
+type Options struct {
+	// how long the push service keeps the message for an offline browser
+	TTL     time.Duration
+	Urgency string
+	// a newer message with the same topic replaces an undelivered one
+	Topic string
+}
+
+// StatusError is a push service response other than success or ErrGone.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("push service responded %d: %s", e.Code, e.Body)
+}
+
+// Temporary reports whether the push may succeed if tried again later.
+func (e *StatusError) Temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+// vapid tokens may be valid for up to 24 hours
+const vapidTokenTTL = 12 * time.Hour
+
+// Sender encrypts and delivers pushes, identifying the server with VAPID (RFC 8292).
+type Sender struct {
+	keys    *Keys
+	subject string
+	client  *http.Client
+}
+
+// NewSender returns a sender signing with keys. subject is a mailto: or https: contact
+// push services can use to reach the operator.
+func NewSender(keys *Keys, subject string, client *http.Client) *Sender {
+	return &Sender{keys: keys, subject: subject, client: client}
+}
+
+func (s *Sender) PublicKey() string {
+	return s.keys.PublicKey()
+}
+
+// Send delivers payload to sub.
+func (s *Sender) Send(ctx context.Context, sub Subscription, payload []byte, opts Options) error {
+	p256dh, err := decodeBase64(sub.P256dh)
+	if err != nil {
+		return fmt.Errorf("invalid subscription key: %w", err)
+	}
+	auth, err := decodeBase64(sub.Auth)
+	if err != nil {
+		return fmt.Errorf("invalid subscription auth secret: %w", err)
+	}
+	body, err := Encrypt(p256dh, auth, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := s.authorization(sub.Endpoint)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(opts.TTL.Seconds())))
+	if opts.Urgency != "" {
+		req.Header.Set("Urgency", opts.Urgency)
+	}
+	if opts.Topic != "" {
+		req.Header.Set("Topic", opts.Topic)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound, resp.StatusCode == http.StatusGone:
+		return ErrGone
+	default:
+		return &StatusError{Code: resp.StatusCode, Body: string(respBody)}
+	}
+}
+
+// authorization builds the VAPID header for a push to endpoint; the token's audience is
+// the push service's origin.
+func (s *Sender) authorization(endpoint string) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": time.Now().Add(vapidTokenTTL).Unix(),
+		"sub": s.subject,
+	}).SignedString(s.keys.private)
+	if err != nil {
+		return "", err
+	}
+	return "vapid t=" + token + ", k=" + s.keys.PublicKey(), nil
+}
@@ -0,0 +1,198 @@
+package webpush
+
+import (
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// standInMessages is how many received pushes a StandIn keeps.
+const standInMessages = 200
+
+// StandIn is an in-memory push service for development. Subscriptions it creates point
+// at its own endpoints under baseURL; pushes sent there are checked the way a real push
+// service would check them, decrypted with the subscription's private key and kept for
+// inspection. Expiring a subscription makes later pushes to it fail with 410 Gone.
+type StandIn struct {
+	baseURL string
+
+	mu       sync.Mutex
+	subs     map[string]*standInSubscription
+	messages []StandInMessage
+}
+
+type standInSubscription struct {
+	key     *ecdh.PrivateKey
+	auth    []byte
+	expired bool
+}
+
+// StandInMessage is a push the stand-in accepted.
+type StandInMessage struct {
+	SubscriptionID string    `json:"subscription_id"`
+	ReceivedAt     time.Time `json:"received_at"`
+	TTL            string    `json:"ttl"`
+	Urgency        string    `json:"urgency,omitempty"`
+	Topic          string    `json:"topic,omitempty"`
+	Payload        string    `json:"payload"`
+}
+
+// NewStandIn returns a stand-in whose endpoints are baseURL + "/" + subscription ID.
+func NewStandIn(baseURL string) *StandIn {
+	return &StandIn{baseURL: strings.TrimRight(baseURL, "/"), subs: make(map[string]*standInSubscription)}
+}
+
+// Subscribe creates a subscription as a browser's PushManager would.
+func (s *StandIn) Subscribe() (string, Subscription, error) {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return "", Subscription{}, err
+	}
+	auth := make([]byte, 16)
+	if _, err := rand.Read(auth); err != nil {
+		return "", Subscription{}, err
+	}
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", Subscription{}, err
+	}
+	id := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	s.subs[id] = &standInSubscription{key: key, auth: auth}
+	s.mu.Unlock()
+
+	return id, Subscription{
+		Endpoint: s.baseURL + "/" + id,
+		P256dh:   base64.RawURLEncoding.EncodeToString(key.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(auth),
+	}, nil
+}
+
+// Expire makes the push service forget a subscription. It reports whether id existed.
+func (s *StandIn) Expire(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sub, ok := s.subs[id]
+	if ok {
+		sub.expired = true
+	}
+	return ok
+}
+
+// Receive handles a push to subscription id and returns the status a push service would
+// answer with.
+func (s *StandIn) Receive(id string, header http.Header, body []byte) (int, error) {
+	s.mu.Lock()
+	sub, ok := s.subs[id]
+	s.mu.Unlock()
+	if !ok {
+		return http.StatusNotFound, errors.New("unknown subscription")
+	}
+	if sub.expired {
+		return http.StatusGone, errors.New("subscription expired")
+	}
+
+	if err := s.verifyVAPID(header.Get("Authorization")); err != nil {
+		return http.StatusUnauthorized, err
+	}
+	if header.Get("Content-Encoding") != "aes128gcm" {
+		return http.StatusUnsupportedMediaType, errors.New("content encoding must be aes128gcm")
+	}
+	if header.Get("TTL") == "" {
+		return http.StatusBadRequest, errors.New("missing TTL header")
+	}
+	if len(body) > recordSize {
+		return http.StatusRequestEntityTooLarge, ErrPayloadTooLarge
+	}
+	plain, err := Decrypt(sub.key, sub.auth, body)
+	if err != nil {
+		return http.StatusBadRequest, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.messages = append(s.messages, StandInMessage{
+		SubscriptionID: id,
+		ReceivedAt:     time.Now().UTC(),
+		TTL:            header.Get("TTL"),
+		Urgency:        header.Get("Urgency"),
+		Topic:          header.Get("Topic"),
+		Payload:        string(plain),
+	})
+	if over := len(s.messages) - standInMessages; over > 0 {
+		s.messages = s.messages[over:]
+	}
+	return http.StatusCreated, nil
+}
+
+// Messages returns the pushes received so far, newest first.
+func (s *StandIn) Messages() []StandInMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]StandInMessage, 0, len(s.messages))
+	for i := len(s.messages) - 1; i >= 0; i-- {
+		out = append(out, s.messages[i])
+	}
+	return out
+}
+
+func (s *StandIn) Clear() {
+	s.mu.Lock()
+	s.messages = nil
+	s.mu.Unlock()
+}
+
+// verifyVAPID checks the "vapid t=<jwt>, k=<key>" header of RFC 8292.
+func (s *StandIn) verifyVAPID(header string) error {
+	params, ok := strings.CutPrefix(header, "vapid ")
+	if !ok {
+		return errors.New("missing vapid authorization")
+	}
+	var token, key string
+	for _, part := range strings.Split(params, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch name {
+		case "t":
+			token = value
+		case "k":
+			key = value
+		}
+	}
+	raw, err := decodeBase64(key)
+	if err != nil {
+		return errors.New("invalid vapid key")
+	}
+	pub, err := ecdh.P256().NewPublicKey(raw)
+	if err != nil {
+		return errors.New("invalid vapid key")
+	}
+
+	u, err := url.Parse(s.baseURL)
+	if err != nil {
+		return err
+	}
+	verifyKey := &ecdsa.PublicKey{
+		Curve: elliptic.P256(),
+		X:     new(big.Int).SetBytes(pub.Bytes()[1:33]),
+		Y:     new(big.Int).SetBytes(pub.Bytes()[33:]),
+	}
+	_, err = jwt.Parse(token, func(*jwt.Token) (any, error) { return verifyKey, nil },
+		jwt.WithValidMethods([]string{jwt.SigningMethodES256.Alg()}),
+		jwt.WithAudience(u.Scheme+"://"+u.Host),
+		jwt.WithExpirationRequired(),
+	)
+	return err
+}
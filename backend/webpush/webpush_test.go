@@ -0,0 +1,175 @@
+package webpush
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdh"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func mustDecode(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// The example of RFC 8291 section 5.
+const (
+	rfcPlaintext = "When I grow up, I want to be a watermelon"
+	rfcASPrivate = "yfWPiYE-n46HLnH0KqZOF1fJJU3MYrct3AELtAQ-oRw"
+	rfcUAPrivate = "q1dXpw3UpT5VOmu_cf_v6ih07Aems3njxI-JWgLcM94"
+	rfcUAPublic  = "BCVxsr7N_eNgVRqvHtD0zTZsEc6-VV-JvLexhqUzORcxaOzi6-AYWXvTBHm4bjyPjs7Vd8pZGH6SRpkNtoIAiw4"
+	rfcAuth      = "BTBZMqHH6r4Tts7J_aSIgg"
+	rfcSalt      = "DGv6ra1nlYgDCS1FRnbzlw"
+	rfcBody      = "DGv6ra1nlYgDCS1FRnbzlwAAEABBBP4z9KsN6nGRTbVYI_c7VJSPQTBtkgcy27mlmlMoZIIgDll6e3vCYLocInmYWAmS6TlzAC8wEqKK6PBru3jl7A_yl95bQpu6cVPTpK4Mqgkf1CXztLVBSt2Ks3oZwbuwXPXLWyouBWLVWGNWQexSgSxsj_Qulcy4a-fN"
+)
+
+func TestEncryptRFC8291(t *testing.T) {
+	asPrivate, err := ecdh.P256().NewPrivateKey(mustDecode(t, rfcASPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	uaPublic, err := ecdh.P256().NewPublicKey(mustDecode(t, rfcUAPublic))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := encrypt(asPrivate, mustDecode(t, rfcSalt), uaPublic, mustDecode(t, rfcAuth), []byte(rfcPlaintext))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := base64.RawURLEncoding.EncodeToString(body); got != rfcBody {
+		t.Errorf("body = %s\nwant   %s", got, rfcBody)
+	}
+}
+
+func TestDecryptRFC8291(t *testing.T) {
+	uaPrivate, err := ecdh.P256().NewPrivateKey(mustDecode(t, rfcUAPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, err := Decrypt(uaPrivate, mustDecode(t, rfcAuth), mustDecode(t, rfcBody))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(plain) != rfcPlaintext {
+		t.Errorf("plaintext = %q, want %q", plain, rfcPlaintext)
+	}
+
+	// a different auth secret derives different keys
+	if _, err := Decrypt(uaPrivate, make([]byte, 16), mustDecode(t, rfcBody)); err == nil {
+		t.Error("Decrypt accepted the wrong auth secret")
+	}
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	uaPrivate, err := ecdh.P256().NewPrivateKey(mustDecode(t, rfcUAPrivate))
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth := mustDecode(t, rfcAuth)
+	for _, plaintext := range [][]byte{nil, []byte(rfcPlaintext), bytes.Repeat([]byte("x"), MaxPayloadSize)} {
+		body, err := Encrypt(uaPrivate.PublicKey().Bytes(), auth, plaintext)
+		if err != nil {
+			t.Fatalf("Encrypt %d bytes: %v", len(plaintext), err)
+		}
+		if len(body) > recordSize {
+			t.Errorf("%d bytes encrypt to %d, more than a push service accepts", len(plaintext), len(body))
+		}
+		got, err := Decrypt(uaPrivate, auth, body)
+		if err != nil {
+			t.Fatalf("Decrypt %d bytes: %v", len(plaintext), err)
+		}
+		if !bytes.Equal(got, plaintext) {
+			t.Errorf("round trip of %d bytes returned %d bytes", len(plaintext), len(got))
+		}
+	}
+
+	if _, err := Encrypt(uaPrivate.PublicKey().Bytes(), auth, make([]byte, MaxPayloadSize+1)); !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("oversized payload: err = %v, want ErrPayloadTooLarge", err)
+	}
+}
+
+// standInServer serves a StandIn the way /dev/push/endpoints does.
+func standInServer(t *testing.T) *StandIn {
+	t.Helper()
+	var standIn *StandIn
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		status, err := standIn.Receive(strings.TrimPrefix(r.URL.Path, "/"), r.Header, body)
+		if err != nil {
+			http.Error(w, err.Error(), status)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(srv.Close)
+	standIn = NewStandIn(srv.URL)
+	return standIn
+}
+
+func TestSenderToStandIn(t *testing.T) {
+	standIn := standInServer(t)
+	keys, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	sender := NewSender(keys, "mailto:ops@example.com", &http.Client{Timeout: 5 * time.Second})
+	ctx := context.Background()
+
+	id, sub, err := standIn.Subscribe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sub.Validate(); err != nil {
+		t.Fatalf("stand-in subscription is invalid: %v", err)
+	}
+	opts := Options{TTL: time.Hour, Urgency: UrgencyHigh, Topic: "inbox"}
+	if err := sender.Send(ctx, sub, []byte(`{"title":"hi"}`), opts); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+	messages := standIn.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("stand-in received %d messages, want 1", len(messages))
+	}
+	m := messages[0]
+	if m.SubscriptionID != id || m.Payload != `{"title":"hi"}` || m.TTL != "3600" || m.Urgency != UrgencyHigh || m.Topic != "inbox" {
+		t.Errorf("received %+v", m)
+	}
+
+	// a token signed by another key than the one it names is refused
+	other, err := GenerateKeys()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.private.PublicKey = keys.private.PublicKey
+	var status *StatusError
+	if err := NewSender(other, "", http.DefaultClient).Send(ctx, sub, []byte("x"), opts); !errors.As(err, &status) || status.Code != http.StatusUnauthorized {
+		t.Errorf("push with a forged signature: err = %v, want 401", err)
+	}
+
+	// expired subscriptions answer 410, unknown ones 404; both mean the subscription is gone
+	if !standIn.Expire(id) {
+		t.Fatal("Expire did not find the subscription")
+	}
+	if err := sender.Send(ctx, sub, []byte("x"), opts); !errors.Is(err, ErrGone) {
+		t.Errorf("push to an expired subscription: err = %v, want ErrGone", err)
+	}
+	unknown := sub
+	unknown.Endpoint = strings.TrimSuffix(sub.Endpoint, id) + "0000000000000000"
+	if err := sender.Send(ctx, unknown, []byte("x"), opts); !errors.Is(err, ErrGone) {
+		t.Errorf("push to an unknown subscription: err = %v, want ErrGone", err)
+	}
+	if got := len(standIn.Messages()); got != 1 {
+		t.Errorf("stand-in kept %d messages, want 1", got)
+	}
+}
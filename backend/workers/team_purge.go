@@ -0,0 +1,61 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/Neat-Snap/blueprint-backend/db"
+	"github.com/Neat-Snap/blueprint-backend/images"
+	"github.com/Neat-Snap/blueprint-backend/logger"
+)
+
+const teamPurgeBatch = 100
+
+// RunTeamPurge hard-deletes teams that have been in the trash longer than retention.
+// It runs once at start and then every interval until ctx is cancelled. Purging is
+// idempotent, so several replicas running it at once only duplicate work. Uploaded team
+// logos are removed from library along with their teams.
+func RunTeamPurge(ctx context.Context, conn *db.Connection, log logger.MultiLogger, library *images.Library, retention, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		purgeExpiredTeams(ctx, conn, log, library, retention)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purgeExpiredTeams(ctx context.Context, conn *db.Connection, log logger.MultiLogger, library *images.Library, retention time.Duration) {
+	cutoff := time.Now().Add(-retention)
+	for {
+		teams, err := conn.Teams.ListDeletedBefore(ctx, cutoff, teamPurgeBatch)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Error("failed to list expired teams", "error", err)
+			}
+			return
+		}
+		for _, t := range teams {
+			err := conn.WithTx(ctx, func(tx *db.Connection) error {
+				return tx.Teams.Purge(ctx, t.ID)
+			})
+			if err != nil {
+				log.Error("failed to purge team", "error", err, "team_id", t.ID)
+				return
+			}
+			log.Info("purged deleted team", "team_id", t.ID, "deleted_at", t.DeletedAt.Time)
+			if t.LogoKey != "" {
+				if err := library.Delete(ctx, images.TeamLogo, t.LogoKey); err != nil {
+					log.Warn("failed to delete purged team's logo", "error", err, "team_id", t.ID, "key", t.LogoKey)
+				}
+			}
+		}
+		if len(teams) < teamPurgeBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,71 @@
+"use client";
+
+import React, { useState } from "react";
+import Link from "next/link";
+import { useSearchParams } from "next/navigation";
+import { secureAccount } from "@/lib/auth";
+import { Button } from "@/components/ui/button";
+import { Card, CardContent, CardHeader, CardDescription, CardTitle } from "@/components/ui/card";
+
+// Opened from security alert emails. Nothing happens until the button is pressed, so mail
+// scanners and link prefetchers that open the link do not lock the user out.
+export default function SecureAccountPage() {
+  const params = useSearchParams();
+
+  const [loading, setLoading] = useState(false);
+  const [done, setDone] = useState(false);
+  const [error, setError] = useState<string | null>(null);
+
+  const cid = params.get("cid") || "";
+  const code = params.get("code") || "";
+
+  async function onConfirm() {
+    setLoading(true);
+    setError(null);
+    try {
+      await secureAccount(cid, code);
+      setDone(true);
+    } catch (err: unknown) {
+      const e = err as { response?: { data?: { message?: string } }; message?: string };
+      setError(e.response?.data?.message || e.message || "Failed to secure account");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  return (
+    <div className="min-h-dvh flex items-center justify-center p-4">
+      <Card className="w-full max-w-sm">
+        <CardHeader className="text-center">
+          <CardTitle className="text-xl">{done ? "Your account is secured" : "Secure your account"}</CardTitle>
+          <CardDescription>
+            {done
+              ? "You were signed out everywhere. If your account has a password, check your email for a link to set a new one."
+              : "This signs you out on every device and, if your account has a password, emails you a link to reset it."}
+          </CardDescription>
+        </CardHeader>
+        <CardContent className="space-y-4">
+          {error && (
+            <div role="alert" className="flex items-start gap-2 rounded-md border border-red-200 bg-red-50 p-3 text-sm text-red-700 dark:border-red-800 dark:bg-red-950/30 dark:text-red-300">
+              <svg xmlns="http://www.w3.org/2000/svg" width="18" height="18" viewBox="0 0 24 24" fill="none" stroke="currentColor" strokeWidth="2" strokeLinecap="round" strokeLinejoin="round" className="mt-0.5">
+                <circle cx="12" cy="12" r="10"></circle>
+                <line x1="12" y1="8" x2="12" y2="12"></line>
+                <line x1="12" y1="16" x2="12.01" y2="16"></line>
+              </svg>
+              <p>{error}</p>
+            </div>
+          )}
+          {done ? (
+            <Button asChild className="w-full">
+              <Link href="/auth/login">Go to sign in</Link>
+            </Button>
+          ) : (
+            <Button type="button" className="w-full" onClick={onConfirm} disabled={loading || !cid || !code}>
+              {loading ? "Securing..." : "Sign out everywhere"}
+            </Button>
+          )}
+        </CardContent>
+      </Card>
+    </div>
+  );
+}
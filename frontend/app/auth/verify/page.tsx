@@ -72,8 +72,9 @@ export default function VerifyEmailPage() {
     setLoading(true);
     setError(null);
     try {
-      await confirmEmail(confirmationId || confirmation_id, code);
-      router.push("/dashboard");
+      const res = await confirmEmail(confirmationId || confirmation_id, code);
+      // Invited users land on the acceptance screen instead of the dashboard
+      router.push(res?.invite_token ? `/invite?token=${encodeURIComponent(res.invite_token)}` : "/dashboard");
     } catch (err: unknown) {
       const e = err as { response?: { data?: { message?: string } }; message?: string };
       const msg = e.response?.data?.message || e.message || "Invalid or expired code";
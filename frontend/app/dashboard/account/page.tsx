@@ -6,9 +6,11 @@ import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
 import { Dialog, DialogContent, DialogDescription, DialogFooter, DialogHeader, DialogTitle } from "@/components/ui/dialog";
 import { Tabs, TabsContent, TabsList, TabsTrigger } from "@/components/ui/tabs";
-import { changeEmail, changePassword, confirmEmail, updateProfile, getPreferences, updateTheme, updateLanguage } from "@/lib/account";
+import { changeEmail, changePassword, confirmEmail, updateProfile, uploadAvatar, removeAvatar, getPreferences, updateTheme, updateLanguage } from "@/lib/account";
+import { Avatar, AvatarFallback, AvatarImage } from "@/components/ui/avatar";
 import { toast } from "sonner";
-import { User as UserIcon, Mail, Lock, Settings as SettingsIcon, Sun, Moon, Languages, Laptop } from "lucide-react";
+import { User as UserIcon, Mail, Lock, Settings as SettingsIcon, Sun, Moon, Languages, Laptop, Bell } from "lucide-react";
+import { NotificationPreferences } from "@/components/notification-preferences";
 import { getMe } from "@/lib/auth";
 import { useTheme } from "next-themes";
 import { useRouter, usePathname } from "next/navigation";
@@ -20,6 +22,8 @@ export default function AccountPage() {
   const [loading, setLoading] = useState(true);
   const [profileName, setProfileName] = useState("");
   const [avatarUrl, setAvatarUrl] = useState("");
+  const [avatarFile, setAvatarFile] = useState<File | null>(null);
+  const [avatarSaving, setAvatarSaving] = useState(false);
   const [savingProfile, setSavingProfile] = useState(false);
 
   const { theme, setTheme, resolvedTheme } = useTheme();
@@ -53,6 +57,7 @@ export default function AccountPage() {
       try {
         const me = await getMe();
         setProfileName(me.name || "");
+        setAvatarUrl(me.avatar_url || "");
         setNewEmail(me.email || "");
         try {
           const prefs = await getPreferences();
@@ -80,7 +85,7 @@ export default function AccountPage() {
     e.preventDefault();
     setSavingProfile(true);
     try {
-      await updateProfile(profileName.trim(), avatarUrl.trim());
+      await updateProfile(profileName.trim());
       setNameOpen(false);
       toast.success(t("profile.nameUpdated"));
     } finally {
@@ -90,13 +95,31 @@ export default function AccountPage() {
 
   async function onSaveAvatar(e: React.FormEvent) {
     e.preventDefault();
-    setSavingProfile(true);
+    if (!avatarFile) return;
+    setAvatarSaving(true);
     try {
-      await updateProfile(profileName.trim(), avatarUrl.trim());
+      const { avatar_url } = await uploadAvatar(avatarFile);
+      setAvatarUrl(avatar_url);
+      setAvatarFile(null);
       setAvatarOpen(false);
       toast.success(t("profile.avatarUpdated"));
+    } catch (err: any) {
+      toast.error(err?.response?.data?.message || t("profile.avatarFailed"));
     } finally {
-      setSavingProfile(false);
+      setAvatarSaving(false);
+    }
+  }
+
+  async function onRemoveAvatar() {
+    setAvatarSaving(true);
+    try {
+      await removeAvatar();
+      setAvatarUrl("");
+      setAvatarFile(null);
+      setAvatarOpen(false);
+      toast.success(t("profile.avatarRemoved"));
+    } finally {
+      setAvatarSaving(false);
     }
   }
 
@@ -164,6 +187,7 @@ export default function AccountPage() {
           <TabsTrigger value="profile" className="inline-flex items-center gap-2"><UserIcon className="h-4 w-4" /> {t("tabs.profile")}</TabsTrigger>
           <TabsTrigger value="email" className="inline-flex items-center gap-2"><Mail className="h-4 w-4" /> {t("tabs.email")}</TabsTrigger>
           <TabsTrigger value="security" className="inline-flex items-center gap-2"><Lock className="h-4 w-4" /> {t("tabs.security")}</TabsTrigger>
+          <TabsTrigger value="notifications" className="inline-flex items-center gap-2"><Bell className="h-4 w-4" /> {t("tabs.notifications")}</TabsTrigger>
         </TabsList>
 
         <TabsContent value="app" className="space-y-2">
@@ -272,8 +296,8 @@ export default function AccountPage() {
             <div className="flex items-center gap-3">
               <UserIcon className="h-4 w-4 text-muted-foreground" />
               <div>
-                <div className="text-sm font-medium">{t("profile.avatarUrl")}</div>
-                <div className="text-xs text-muted-foreground">{t("profile.avatarUrlDesc")}</div>
+                <div className="text-sm font-medium">{t("profile.avatar")}</div>
+                <div className="text-xs text-muted-foreground">{t("profile.avatarDesc")}</div>
               </div>
             </div>
             <Button size="sm" onClick={() => setAvatarOpen(true)}>{t("common.change")}</Button>
@@ -305,6 +329,10 @@ export default function AccountPage() {
             <Button size="sm" onClick={() => setPasswordOpen(true)}>{t("common.change")}</Button>
           </div>
         </TabsContent>
+
+        <TabsContent value="notifications" className="space-y-2">
+          <NotificationPreferences />
+        </TabsContent>
       </Tabs>
 
       <Dialog open={nameOpen} onOpenChange={setNameOpen}>
@@ -326,20 +354,29 @@ export default function AccountPage() {
         </DialogContent>
       </Dialog>
 
-      <Dialog open={avatarOpen} onOpenChange={setAvatarOpen}>
+      <Dialog open={avatarOpen} onOpenChange={(o) => { setAvatarOpen(o); if (!o) setAvatarFile(null); }}>
         <DialogContent>
           <DialogHeader>
             <DialogTitle>{t("profile.avatarTitle")}</DialogTitle>
-            <DialogDescription>{t("profile.avatarDesc")}</DialogDescription>
+            <DialogDescription>{t("profile.avatarHint")}</DialogDescription>
           </DialogHeader>
           <form onSubmit={onSaveAvatar} className="space-y-3">
-            <div className="space-y-1">
-              <Label htmlFor="avatar">{t("profile.avatarUrl")}</Label>
-              <Input id="avatar" value={avatarUrl} onChange={(e) => setAvatarUrl(e.target.value)} placeholder="https://..." />
+            <div className="flex items-center gap-3">
+              <Avatar className="h-16 w-16">
+                <AvatarImage src={avatarUrl} alt={profileName} />
+                <AvatarFallback>{(profileName || "?").slice(0, 1).toUpperCase()}</AvatarFallback>
+              </Avatar>
+              <div className="flex-1 space-y-1">
+                <Label htmlFor="avatar">{t("profile.avatarFile")}</Label>
+                <Input id="avatar" type="file" accept="image/jpeg,image/png,image/gif" onChange={(e) => setAvatarFile(e.target.files?.[0] ?? null)} />
+              </div>
             </div>
             <DialogFooter>
+              {avatarUrl && (
+                <Button type="button" variant="outline" className="mr-auto" disabled={avatarSaving} onClick={onRemoveAvatar}>{t("profile.avatarRemove")}</Button>
+              )}
               <Button type="button" variant="outline" onClick={() => setAvatarOpen(false)}>{t("common.cancel")}</Button>
-              <Button type="submit" disabled={savingProfile}>{savingProfile ? t("common.saving") : t("common.save")}</Button>
+              <Button type="submit" disabled={avatarSaving || !avatarFile}>{avatarSaving ? t("common.saving") : t("common.save")}</Button>
             </DialogFooter>
           </form>
         </DialogContent>
@@ -35,7 +35,7 @@ export default function DashboardLayout({ children }: { children: React.ReactNod
     (async () => {
       try {
         const me = await getMe();
-        setUser({ name: me.name || "", email: me.email || "", avatar: "" });
+        setUser({ name: me.name || "", email: me.email || "", avatar: me.avatar_url || "" });
       } catch (err: any) {
         if (err && err.redirectedToVerify) {
           return;
@@ -3,7 +3,18 @@
 import React, { useEffect, useMemo, useRef, useState } from "react";
 import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
 import { Button } from "@/components/ui/button";
-import { listNotifications, markNotificationRead, type Notification } from "@/lib/notifications";
+import {
+  archiveNotification,
+  deleteNotification,
+  getUnreadCount,
+  listNotifications,
+  markAllNotificationsRead,
+  markNotificationRead,
+  subscribeNotifications,
+  unarchiveNotification,
+  type Notification,
+  type TeamInviteData,
+} from "@/lib/notifications";
 import { acceptInvitation, checkInvitationStatus, type InvitationStatus } from "@/lib/teams";
 import { useRouter } from "next/navigation";
 import { useTeam } from "@/lib/teams-context";
@@ -13,12 +24,10 @@ import { Badge } from "@/components/ui/badge";
 import { toast } from "sonner";
 import { useTranslations } from "next-intl";
 
-function parseInviteData(data: string): { team_id?: number; team_name?: string; token?: string; role?: string } {
-  try {
-    return JSON.parse(data || "{}");
-  } catch {
-    return {} as { team_id?: number; team_name?: string; token?: string; role?: string };
-  }
+const INBOX = "/dashboard/notifications";
+
+function inviteData(n: Notification): Partial<TeamInviteData> {
+  return (n.type === "team_invite" ? n.data : null) ?? {};
 }
 
 export default function NotificationsPage() {
@@ -27,22 +36,90 @@ export default function NotificationsPage() {
   const t = useTranslations('Notifications');
   const [loading, setLoading] = useState(true);
   const [list, setList] = useState<Notification[]>([]);
+  const [nextCursor, setNextCursor] = useState<number | null>(null);
+  const [archived, setArchived] = useState<Notification[] | null>(null);
+  const [archivedCursor, setArchivedCursor] = useState<number | null>(null);
+  const [unreadCount, setUnreadCount] = useState(0);
+  const [loadingMore, setLoadingMore] = useState(false);
   const [expandedId, setExpandedId] = useState<number | null>(null);
-  const [tab, setTab] = useState<"unread" | "read">("unread");
+  const [tab, setTab] = useState<"unread" | "read" | "archived">("unread");
   const [inviteStatuses, setInviteStatuses] = useState<Record<number, InvitationStatus["status"] | "invalid" | undefined>>({});
   const markedRef = useRef<Set<number>>(new Set());
 
+  async function reload() {
+    const [page, count] = await Promise.all([listNotifications(), getUnreadCount()]);
+    setList(page.items);
+    setNextCursor(page.nextCursor);
+    setUnreadCount(count);
+    // fetched again when the tab is opened
+    setArchived(null);
+  }
+
+  function refreshUnreadCount() {
+    getUnreadCount().then(setUnreadCount).catch(() => {
+      // keep the current count
+    });
+  }
+
   useEffect(() => {
     (async () => {
       try {
-        const n = await listNotifications();
-        setList(n);
+        await reload();
       } finally {
         setLoading(false);
       }
     })();
   }, []);
 
+  useEffect(() => {
+    if (tab !== "archived" || archived !== null) return;
+    listNotifications({ archived: true }).then((page) => {
+      setArchived(page.items);
+      setArchivedCursor(page.nextCursor);
+    }).catch(() => {
+      setArchived([]);
+    });
+  }, [tab, archived]);
+
+  // Live updates from the notification stream
+  useEffect(() => {
+    return subscribeNotifications((e) => {
+      if (e.type === "notification") {
+        setList((prev) => (prev.some((x) => x.id === e.notification.id) ? prev : [e.notification, ...prev]));
+      } else if (e.type === "read") {
+        setList((prev) => prev.map((x) => (e.ids.includes(x.id) ? { ...x, readAt: x.readAt ?? e.readAt } : x)));
+      } else if (e.type === "archived" || e.type === "deleted") {
+        setList((prev) => prev.filter((x) => !e.ids.includes(x.id)));
+        setArchived(null);
+      } else {
+        reload().catch(() => {
+          // keep the current list
+        });
+        return;
+      }
+      refreshUnreadCount();
+    });
+  }, []);
+
+  async function onLoadMore() {
+    const archivedTab = tab === "archived";
+    const cursor = archivedTab ? archivedCursor : nextCursor;
+    if (!cursor) return;
+    setLoadingMore(true);
+    try {
+      const page = await listNotifications({ cursor, archived: archivedTab });
+      if (archivedTab) {
+        setArchived((prev) => [...(prev ?? []), ...page.items]);
+        setArchivedCursor(page.nextCursor);
+      } else {
+        setList((prev) => [...prev, ...page.items.filter((n) => !prev.some((x) => x.id === n.id))]);
+        setNextCursor(page.nextCursor);
+      }
+    } finally {
+      setLoadingMore(false);
+    }
+  }
+
   // After notifications load, check status of invite notifications to filter revoked/expired/accepted
   useEffect(() => {
     const inviteNotifs = list.filter((n) => n.type === "team_invite");
@@ -51,8 +128,7 @@ export default function NotificationsPage() {
     (async () => {
       const results = await Promise.all(
         inviteNotifs.map(async (n) => {
-          const payload = parseInviteData(n.data);
-          const token = payload.token;
+          const token = inviteData(n).token;
           if (!token) return { id: n.id, status: "invalid" as const };
           try {
             const res = await checkInvitationStatus(token);
@@ -96,7 +172,7 @@ export default function NotificationsPage() {
   }, [list]);
 
   async function onAcceptInvite(n: Notification) {
-    const payload = parseInviteData(n.data);
+    const payload = inviteData(n);
     if (!payload.token) return;
     // Re-check status to avoid errors if invite was revoked/expired just now
     try {
@@ -128,6 +204,33 @@ export default function NotificationsPage() {
     setList((prev) => prev.map((x) => (x.id === n.id ? { ...x, readAt: new Date().toISOString() } : x)));
   }
 
+  async function onMarkAllRead() {
+    await markAllNotificationsRead();
+    const now = new Date().toISOString();
+    setList((prev) => prev.map((x) => (x.readAt ? x : { ...x, readAt: now })));
+    setUnreadCount(0);
+  }
+
+  async function onArchive(n: Notification) {
+    await archiveNotification(n.id);
+    setList((prev) => prev.filter((x) => x.id !== n.id));
+    setArchived(null);
+  }
+
+  async function onUnarchive(n: Notification) {
+    await unarchiveNotification(n.id);
+    setArchived((prev) => (prev ?? []).filter((x) => x.id !== n.id));
+    reload().catch(() => {
+      // the stream will catch up
+    });
+  }
+
+  async function onDelete(n: Notification) {
+    await deleteNotification(n.id);
+    setList((prev) => prev.filter((x) => x.id !== n.id));
+    setArchived((prev) => (prev ? prev.filter((x) => x.id !== n.id) : prev));
+  }
+
   const consideredRead = (n: Notification) => {
     if (n.readAt) return true;
     if (n.type === "team_invite") {
@@ -141,6 +244,9 @@ export default function NotificationsPage() {
   const unread = useMemo(() => list.filter((n) => !consideredRead(n)), [list, inviteStatuses]);
   const read = useMemo(() => list.filter((n) => consideredRead(n)), [list, inviteStatuses]);
 
+  const shown = tab === "unread" ? unread : tab === "read" ? read : (archived ?? []);
+  const hasMore = tab === "archived" ? archivedCursor !== null : nextCursor !== null;
+
   if (loading) return null;
 
   return (
@@ -154,22 +260,28 @@ export default function NotificationsPage() {
         <CardHeader>
           <div className="flex items-center justify-between">
             <CardTitle>{t('inbox')}</CardTitle>
-            <Tabs value={tab} onValueChange={(v) => setTab(v as "unread" | "read")}
-              className="text-sm">
-              <TabsList>
-                <TabsTrigger value="unread">{t('unread', { count: unread.length })}</TabsTrigger>
-                <TabsTrigger value="read">{t('read', { count: read.length })}</TabsTrigger>
-              </TabsList>
-            </Tabs>
+            <div className="flex items-center gap-2">
+              {tab === "unread" && unreadCount > 0 && (
+                <Button variant="ghost" size="sm" type="button" onClick={onMarkAllRead}>{t('btnMarkAllRead')}</Button>
+              )}
+              <Tabs value={tab} onValueChange={(v) => setTab(v as "unread" | "read" | "archived")}
+                className="text-sm">
+                <TabsList>
+                  <TabsTrigger value="unread">{t('unread', { count: unreadCount })}</TabsTrigger>
+                  <TabsTrigger value="read">{t('readTab')}</TabsTrigger>
+                  <TabsTrigger value="archived">{t('archivedTab')}</TabsTrigger>
+                </TabsList>
+              </Tabs>
+            </div>
           </div>
         </CardHeader>
         <CardContent className="space-y-3">
-          {(tab === "unread" ? unread : read).length ? (
+          {shown.length ? (
             <ul className="divide-y">
-              {(tab === "unread" ? unread : read).map((n) => {
+              {shown.map((n) => {
                 const isInvite = n.type === "team_invite";
                 const isOpen = expandedId === n.id;
-                const d = isInvite ? parseInviteData(n.data) : {};
+                const d = inviteData(n);
                 const st = isInvite ? inviteStatuses[n.id] : undefined;
                 return (
                   <li key={n.id} className="py-3">
@@ -177,10 +289,8 @@ export default function NotificationsPage() {
                       <div className="flex items-start justify-between gap-3">
                         <CollapsibleTrigger asChild>
                           <button className="flex-1 text-left">
-                            <div className="font-medium">{isInvite ? t('teamInvitation') : (n.type || t('genericType'))}</div>
-                            <div className="text-xs text-muted-foreground">
-                              {isInvite ? (d.team_name ? t('invitedToTeam', { team: d.team_name }) : t('haveInvitation')) : new Date(n.createdAt).toLocaleString()}
-                            </div>
+                            <div className="font-medium">{n.title}</div>
+                            <div className="text-xs text-muted-foreground">{new Date(n.createdAt).toLocaleString()}</div>
                           </button>
                         </CollapsibleTrigger>
                         <div className="flex items-center gap-2">
@@ -201,19 +311,25 @@ export default function NotificationsPage() {
                             <Button variant="outline" size="sm" type="button" onClick={() => onMarkRead(n)}>{t('btnRead')}</Button>
                           </div>
                           ) : null}
+                          {n.link !== INBOX && (
+                            <Button variant="outline" size="sm" type="button" onClick={() => router.push(n.link)}>{t('btnOpen')}</Button>
+                          )}
+                          {tab === "archived" ? (
+                            <Button variant="outline" size="sm" type="button" onClick={() => onUnarchive(n)}>{t('btnRestore')}</Button>
+                          ) : (
+                            <Button variant="ghost" size="sm" type="button" onClick={() => onArchive(n)}>{t('btnArchive')}</Button>
+                          )}
+                          <Button variant="ghost" size="sm" type="button" onClick={() => onDelete(n)}>{t('btnDelete')}</Button>
                         </div>
                       </div>
                       <CollapsibleContent>
                         <div className="mt-3 rounded-md border p-3 text-sm">
-                          {isInvite ? (
-                            <div className="space-y-2">
-                              <div>
-                                <div>{t('detailTeam')} <span className="font-medium">{d.team_name || d.team_id}</span></div>
-                                <div>{t('detailRole')} <span className="font-medium">{d.role || t('roleRegular')}</span></div>
-                              </div>
+                          <p>{n.body}</p>
+                          {isInvite && (
+                            <div className="mt-2">
+                              <div>{t('detailTeam')} <span className="font-medium">{d.team_name || d.team_id}</span></div>
+                              <div>{t('detailRole')} <span className="font-medium">{d.role || t('roleRegular')}</span></div>
                             </div>
-                          ) : (
-                            <div className="text-muted-foreground">{t('noDetails')}</div>
                           )}
                         </div>
                       </CollapsibleContent>
@@ -225,6 +341,11 @@ export default function NotificationsPage() {
           ) : (
             <p className="text-sm text-muted-foreground">{t('allCaughtUp')}</p>
           )}
+          {hasMore && (
+            <div className="flex justify-center">
+              <Button variant="outline" size="sm" type="button" disabled={loadingMore} onClick={onLoadMore}>{t('btnLoadMore')}</Button>
+            </div>
+          )}
         </CardContent>
       </Card>
     </div>
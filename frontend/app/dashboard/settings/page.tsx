@@ -1,11 +1,25 @@
 "use client";
 
-import React from "react";
+import React, { useEffect } from "react";
+import { useSearchParams } from "next/navigation";
 import TeamSettingsPanel from "@/components/team-settings-panel";
+import { useTeam } from "@/lib/teams-context";
 import { useTranslations } from "next-intl";
 
 export default function SettingsPage() {
   const t = useTranslations('SettingsPage');
+  const search = useSearchParams();
+  const { current, all, switchTo } = useTeam();
+  // Notification links name the team they are about with ?team=
+  const teamParam = Number(search.get("team"));
+
+  useEffect(() => {
+    if (!teamParam || current?.id === teamParam || !all.some((x) => x.id === teamParam)) return;
+    switchTo(teamParam).catch(() => {
+      // stay on the current team
+    });
+  }, [teamParam, current?.id, all]);
+
   return (
     <div className="space-y-6">
       <div>
@@ -2,7 +2,7 @@
 
 import React, { useEffect, useRef, useState } from "react";
 import { useSearchParams, useRouter } from "next/navigation";
-import { acceptInvitation } from "@/lib/teams";
+import { acceptInvitation, redeemInviteLink } from "@/lib/teams";
 import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
 import { Button } from "@/components/ui/button";
 import { useTeam } from "@/lib/teams-context";
@@ -11,7 +11,11 @@ import { toast } from "sonner";
 export default function InviteAcceptPage() {
   const search = useSearchParams();
   const router = useRouter();
-  const token = search.get("token") || "";
+  const inviteToken = search.get("token") || "";
+  // Shareable team links use ?link= and go through the redeem endpoint instead
+  const linkToken = search.get("link") || "";
+  const token = inviteToken || linkToken;
+  const accept = (t: string) => (inviteToken ? acceptInvitation(t) : redeemInviteLink(t));
   const [status, setStatus] = useState<"idle" | "pending" | "success" | "error">("idle");
   const [error, setError] = useState<string | null>(null);
   const ranOnceRef = useRef<string | null>(null);
@@ -27,7 +31,7 @@ export default function InviteAcceptPage() {
       setStatus("pending");
       setError(null);
       try {
-        const res = await acceptInvitation(token);
+        const res = await accept(token);
         if (cancelled) return;
         setStatus("success");
 
@@ -55,7 +59,7 @@ export default function InviteAcceptPage() {
     setStatus("pending");
     setError(null);
     try {
-      const res = await acceptInvitation(token);
+      const res = await accept(token);
       setStatus("success");
       await refresh();
       await switchTo(res.team_id);
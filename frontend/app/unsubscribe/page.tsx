@@ -0,0 +1,59 @@
+"use client";
+
+import React, { useState } from "react";
+import Link from "next/link";
+import { useSearchParams } from "next/navigation";
+import { useTranslations } from "next-intl";
+import { Card, CardContent, CardHeader, CardTitle } from "@/components/ui/card";
+import { Button } from "@/components/ui/button";
+import { unsubscribeDigest } from "@/lib/notifications";
+
+// Confirms before unsubscribing, so mail scanners that open links don't do it for the user
+export default function UnsubscribePage() {
+  const t = useTranslations("Unsubscribe");
+  const search = useSearchParams();
+  const token = search.get("token") || "";
+  const [status, setStatus] = useState<"idle" | "pending" | "success" | "error">("idle");
+
+  const confirm = async () => {
+    setStatus("pending");
+    try {
+      await unsubscribeDigest(token);
+      setStatus("success");
+    } catch {
+      setStatus("error");
+    }
+  };
+
+  return (
+    <div className="mx-auto mt-16 max-w-md px-4">
+      <Card>
+        <CardHeader>
+          <CardTitle>{t("title")}</CardTitle>
+        </CardHeader>
+        <CardContent className="space-y-3">
+          {!token && <p className="text-sm text-muted-foreground">{t("noToken")}</p>}
+
+          {token && status !== "success" && (
+            <>
+              <p className="text-sm text-muted-foreground">{t("desc")}</p>
+              {status === "error" && <p className="text-sm text-red-600">{t("failed")}</p>}
+              <Button onClick={confirm} disabled={status === "pending"}>
+                {status === "pending" ? t("pending") : t("confirm")}
+              </Button>
+            </>
+          )}
+
+          {status === "success" && (
+            <>
+              <p className="text-sm">{t("success")}</p>
+              <Button variant="outline" asChild>
+                <Link href="/dashboard/account">{t("settings")}</Link>
+              </Button>
+            </>
+          )}
+        </CardContent>
+      </Card>
+    </div>
+  );
+}
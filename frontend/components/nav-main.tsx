@@ -20,7 +20,7 @@ import {
   SidebarMenuSubButton,
   SidebarMenuSubItem,
 } from "@/components/ui/sidebar"
-import { listNotifications } from "@/lib/notifications"
+import { getUnreadCount, subscribeNotifications } from "@/lib/notifications"
 import { useTranslations } from "next-intl"
 
 export function NavMain({
@@ -42,16 +42,20 @@ export function NavMain({
 
   useEffect(() => {
     let mounted = true
-    ;(async () => {
+    const refresh = async () => {
       try {
-        const n = await listNotifications()
-        const unread = n.filter((x) => !x.readAt).length
+        const unread = await getUnreadCount()
         if (mounted) setUnreadCount(unread)
       } catch {
         // ignore
       }
-    })()
-    return () => { mounted = false }
+    }
+    refresh()
+    const unsubscribe = subscribeNotifications(() => { refresh() })
+    return () => {
+      mounted = false
+      unsubscribe()
+    }
   }, [])
 
   return (
@@ -33,7 +33,7 @@ import {
 } from "@/components/ui/sidebar"
 import { logout as apiLogout } from "@/lib/auth"
 import { useAuth } from "@/lib/auth-context"
-import { listNotifications } from "@/lib/notifications"
+import { getUnreadCount, subscribeNotifications } from "@/lib/notifications"
 import { useTranslations } from "next-intl"
 
 export function NavUser({
@@ -54,8 +54,7 @@ export function NavUser({
 
   async function refreshUnread() {
     try {
-      const n = await listNotifications()
-      const unread = n.filter((x) => !x.readAt).length
+      const unread = await getUnreadCount()
       setUnreadCount(unread)
     } catch {
       // silent fail
@@ -64,6 +63,7 @@ export function NavUser({
 
   useEffect(() => {
     refreshUnread()
+    return subscribeNotifications(() => { refreshUnread() })
   }, [])
 
   return (
@@ -0,0 +1,216 @@
+"use client"
+
+import React, { useEffect, useState } from "react"
+import { Bell, BellRing, Mail, ShieldCheck } from "lucide-react"
+import { toast } from "sonner"
+import { useTranslations } from "next-intl"
+
+import { Button } from "@/components/ui/button"
+import { Checkbox } from "@/components/ui/checkbox"
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "@/components/ui/select"
+import { Switch } from "@/components/ui/switch"
+import {
+  getDigestSettings,
+  getNotificationPreferences,
+  updateDigestSettings,
+  updateNotificationPreferences,
+  type DigestFrequency,
+  type DigestSettings,
+  type NotificationChannel,
+  type NotificationPreference,
+} from "@/lib/notifications"
+import { disablePush, enablePush, isPushEnabled, isPushSupported, sendTestPush } from "@/lib/push"
+
+const CHANNELS: NotificationChannel[] = ["in_app", "push", "email", "digest"]
+
+function browserTimezone(): string {
+  try {
+    return Intl.DateTimeFormat().resolvedOptions().timeZone || "UTC"
+  } catch {
+    return "UTC"
+  }
+}
+
+function DigestSettingsRow() {
+  const t = useTranslations("Account.notifications.digest")
+  const [settings, setSettings] = useState<DigestSettings | null>(null)
+
+  useEffect(() => {
+    getDigestSettings().then(setSettings).catch(() => setSettings(null))
+  }, [])
+
+  if (!settings) return null
+  const browserZone = browserTimezone()
+
+  async function save(next: { frequency?: DigestFrequency; timezone?: string }) {
+    try {
+      setSettings(await updateDigestSettings(next))
+      toast.success(t("updated"))
+    } catch {
+      toast.error(t("updateFailed"))
+    }
+  }
+
+  return (
+    <div className="flex flex-wrap items-center justify-between gap-3 border-t p-3">
+      <div className="flex items-center gap-3">
+        <Mail className="h-4 w-4 text-muted-foreground" />
+        <div>
+          <div className="text-sm font-medium">{t("label")}</div>
+          <div className="text-xs text-muted-foreground">{t("desc", { timezone: settings.timezone })}</div>
+          {settings.timezone !== browserZone && (
+            <button
+              type="button"
+              className="text-xs text-primary underline-offset-4 hover:underline"
+              onClick={() => save({ timezone: browserZone })}
+            >
+              {t("useTimezone", { timezone: browserZone })}
+            </button>
+          )}
+        </div>
+      </div>
+      <Select value={settings.frequency} onValueChange={(value) => save({ frequency: value as DigestFrequency })}>
+        <SelectTrigger className="w-[150px]">
+          <SelectValue />
+        </SelectTrigger>
+        <SelectContent>
+          {settings.frequencies.map((f) => (
+            <SelectItem key={f} value={f}>{t(`frequencies.${f}`)}</SelectItem>
+          ))}
+        </SelectContent>
+      </Select>
+    </div>
+  )
+}
+
+// Push goes to browsers that subscribed, so it is turned on per device as well as per type.
+function PushDeviceRow() {
+  const t = useTranslations("Account.notifications.push")
+  const [enabled, setEnabled] = useState<boolean | null>(null)
+  const [busy, setBusy] = useState(false)
+
+  useEffect(() => {
+    if (!isPushSupported()) return
+    isPushEnabled().then(setEnabled).catch(() => setEnabled(false))
+  }, [])
+
+  if (enabled === null) return null
+
+  async function change(on: boolean) {
+    setBusy(true)
+    try {
+      if (on) {
+        const granted = await enablePush()
+        setEnabled(granted)
+        if (granted) toast.success(t("enabled"))
+        else toast.error(t("denied"))
+      } else {
+        await disablePush()
+        setEnabled(false)
+        toast.success(t("disabled"))
+      }
+    } catch {
+      toast.error(t("updateFailed"))
+    } finally {
+      setBusy(false)
+    }
+  }
+
+  async function test() {
+    try {
+      const res = await sendTestPush()
+      if (res.sent > 0) toast.success(t("testSent"))
+      else toast.error(t("testFailed"))
+    } catch {
+      toast.error(t("testFailed"))
+    }
+  }
+
+  return (
+    <div className="flex flex-wrap items-center justify-between gap-3 border-t p-3">
+      <div className="flex items-center gap-3">
+        <BellRing className="h-4 w-4 text-muted-foreground" />
+        <div>
+          <div className="text-sm font-medium">{t("label")}</div>
+          <div className="text-xs text-muted-foreground">{t("desc")}</div>
+        </div>
+      </div>
+      <div className="flex items-center gap-3">
+        {enabled && (
+          <Button variant="outline" size="sm" onClick={test}>
+            {t("test")}
+          </Button>
+        )}
+        <Switch checked={enabled} disabled={busy} onCheckedChange={change} aria-label={t("label")} />
+      </div>
+    </div>
+  )
+}
+
+export function NotificationPreferences() {
+  const t = useTranslations("Account.notifications")
+  const [prefs, setPrefs] = useState<NotificationPreference[] | null>(null)
+  const [saving, setSaving] = useState<string | null>(null)
+
+  useEffect(() => {
+    getNotificationPreferences().then(setPrefs).catch(() => setPrefs([]))
+  }, [])
+
+  async function toggle(pref: NotificationPreference, channel: NotificationChannel, on: boolean) {
+    const channels = on
+      ? CHANNELS.filter((c) => c === channel || pref.channels.includes(c))
+      : pref.channels.filter((c) => c !== channel)
+    setSaving(pref.type)
+    try {
+      setPrefs(await updateNotificationPreferences({ [pref.type]: channels }))
+      toast.success(t("updated"))
+    } catch {
+      toast.error(t("updateFailed"))
+    } finally {
+      setSaving(null)
+    }
+  }
+
+  if (!prefs) return null
+
+  return (
+    <div className="rounded-lg border">
+      <div className="flex items-center gap-3 border-b p-3">
+        <Bell className="h-4 w-4 text-muted-foreground" />
+        <div className="text-xs text-muted-foreground">{t("desc")}</div>
+      </div>
+      <div className="grid grid-cols-[1fr_repeat(4,5rem)] items-center gap-y-1 p-3 text-sm">
+        <div className="text-xs font-medium text-muted-foreground">{t("type")}</div>
+        {CHANNELS.map((c) => (
+          <div key={c} className="text-center text-xs font-medium text-muted-foreground">{t(`channels.${c}`)}</div>
+        ))}
+        {prefs.map((pref) => (
+          <React.Fragment key={pref.type}>
+            <div className="py-2">
+              <div className="flex items-center gap-2 font-medium">
+                {t(`types.${pref.type}` as any)}
+                {pref.locked && <ShieldCheck className="h-3.5 w-3.5 text-muted-foreground" />}
+              </div>
+              {pref.locked && <div className="text-xs text-muted-foreground">{t("locked")}</div>}
+              {pref.type === "team_invite" && <div className="text-xs text-muted-foreground">{t("invitesEmailed")}</div>}
+            </div>
+            {CHANNELS.map((c) => (
+              <div key={c} className="flex justify-center">
+                {pref.available.includes(c) && (
+                  <Checkbox
+                    checked={pref.channels.includes(c)}
+                    disabled={pref.locked || saving === pref.type}
+                    onCheckedChange={(v) => toggle(pref, c, v === true)}
+                    aria-label={t(`channels.${c}`)}
+                  />
+                )}
+              </div>
+            ))}
+          </React.Fragment>
+        ))}
+      </div>
+      <PushDeviceRow />
+      <DigestSettingsRow />
+    </div>
+  )
+}
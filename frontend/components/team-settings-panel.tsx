@@ -10,8 +10,8 @@ import { RadioGroup, RadioGroupItem } from "@/components/ui/radio-group";
 import { Badge } from "@/components/ui/badge";
 import { Tabs, TabsContent, TabsList, TabsTrigger } from "@/components/ui/tabs";
 import { useTeam } from "@/lib/teams-context";
-import { getTeam, updateTeam, removeMember, deleteTeam, createInvitation, listInvitations, revokeInvitation, updateMemberRole, type TeamInvitation } from "@/lib/teams";
-import { ALLOWED_TEAM_ICONS, renderTeamIcon } from "@/lib/icons";
+import { getTeam, updateTeam, uploadTeamLogo, resetTeamLogo, removeMember, deleteTeam, createInvitation, listInvitations, revokeInvitation, updateMemberRole, type TeamInvitation } from "@/lib/teams";
+import { ALLOWED_TEAM_ICONS, renderTeamIcon, renderTeamLogo } from "@/lib/icons";
 import { getMe } from "@/lib/auth";
 import { Trash2, Users, Type, ShieldAlert } from "lucide-react";
 import { toast } from "sonner";
@@ -24,6 +24,8 @@ export default function TeamSettingsPanel() {
   const [saving, setSaving] = useState(false);
   const [name, setName] = useState("");
   const [icon, setIcon] = useState("");
+  const [logoUrl, setLogoUrl] = useState("");
+  const [logoFile, setLogoFile] = useState<File | null>(null);
   const [ownerId, setOwnerId] = useState<number | null>(null);
   const [members, setMembers] = useState<{ id: number; name: string; email: string; role: string }[]>([]);
   const [meId, setMeId] = useState<number | null>(null);
@@ -58,6 +60,7 @@ export default function TeamSettingsPanel() {
         setMeId(me?.id ? Number(me.id) : null);
         setName(data.name);
         setIcon(data.icon || "");
+        setLogoUrl(data.logo_url || "");
         setOwnerId(data.owner_id);
         setMembers(data.members);
         const invs = await listInvitations(current.id);
@@ -86,6 +89,48 @@ export default function TeamSettingsPanel() {
     }
   }
 
+  async function handleUploadLogo() {
+    if (!current || !logoFile) return;
+    setSaving(true);
+    try {
+      const res = await uploadTeamLogo(current.id, logoFile);
+      setLogoUrl(res.logo_url);
+      setLogoFile(null);
+      setIconOpen(false);
+      await refresh();
+      toast.success(t('toast.teamUpdated'));
+    } catch (err: any) {
+      toast.error(err?.response?.data?.message || t('toast.logoUploadFailed'));
+    } finally {
+      setSaving(false);
+    }
+  }
+
+  // picking a built-in icon while a logo is set goes through the logo reset
+  async function handleSelectIcon(nextIcon?: string) {
+    if (!current) return;
+    if (!logoUrl) {
+      if (!nextIcon) return;
+      setIcon(nextIcon);
+      await saveTeam(name, nextIcon);
+      setIconOpen(false);
+      return;
+    }
+    setSaving(true);
+    try {
+      const res = await resetTeamLogo(current.id, nextIcon);
+      setIcon(res.icon || "");
+      setLogoUrl("");
+      setIconOpen(false);
+      await refresh();
+      toast.success(t('toast.teamUpdated'));
+    } catch {
+      toast.error(t('toast.teamUpdateFailed', { email: SUPPORT_EMAIL }));
+    } finally {
+      setSaving(false);
+    }
+  }
+
   async function handleRename() {
     await saveTeam(name, icon);
   }
@@ -192,8 +237,8 @@ export default function TeamSettingsPanel() {
           </div>
           <div className="flex items-center justify-between rounded-lg border p-3 transition-colors hover:bg-muted/50">
             <div className="flex items-center gap-3">
-              {icon ? (
-                renderTeamIcon(icon, "h-4 w-4")
+              {icon || logoUrl ? (
+                renderTeamLogo({ icon, logo_url: logoUrl }, "h-4 w-4")
               ) : (
                 <Type className="h-4 w-4 text-muted-foreground" />
               )}
@@ -297,7 +342,7 @@ export default function TeamSettingsPanel() {
       </Dialog>
 
       {/* Icon Picker Dialog */}
-      <Dialog open={iconOpen} onOpenChange={setIconOpen}>
+      <Dialog open={iconOpen} onOpenChange={(o) => { setIconOpen(o); if (!o) setLogoFile(null); }}>
         <DialogContent>
           <DialogHeader>
             <DialogTitle>{t('dialogs.icon.title')}</DialogTitle>
@@ -309,15 +354,30 @@ export default function TeamSettingsPanel() {
                 <button
                   key={ic}
                   type="button"
-                  onClick={async () => { setIcon(ic); await saveTeam(name, ic); setIconOpen(false); }}
-                  className={`flex h-10 w-10 items-center justify-center rounded border transition-colors ${icon === ic ? "border-ring bg-accent" : "hover:bg-muted"}`}
+                  disabled={saving}
+                  onClick={() => handleSelectIcon(ic)}
+                  className={`flex h-10 w-10 items-center justify-center rounded border transition-colors ${icon === ic && !logoUrl ? "border-ring bg-accent" : "hover:bg-muted"}`}
                   aria-label={ic}
                 >
                   {renderTeamIcon(ic, "size-5")}
                 </button>
               ))}
             </div>
+            <div className="space-y-1">
+              <Label htmlFor="team-logo">{t('dialogs.icon.logo')}</Label>
+              <div className="flex items-center gap-2">
+                {logoUrl && renderTeamLogo({ logo_url: logoUrl }, "h-10 w-10 rounded border")}
+                <Input id="team-logo" type="file" accept="image/jpeg,image/png,image/gif" onChange={(e) => setLogoFile(e.target.files?.[0] ?? null)} />
+              </div>
+              <p className="text-xs text-muted-foreground">{t('dialogs.icon.logoHint')}</p>
+            </div>
           </div>
+          <DialogModalFooter>
+            {logoUrl && (
+              <Button type="button" variant="outline" className="mr-auto" disabled={saving} onClick={() => handleSelectIcon()}>{t('dialogs.icon.removeLogo')}</Button>
+            )}
+            <Button type="button" disabled={saving || !logoFile} onClick={handleUploadLogo}>{t('dialogs.icon.upload')}</Button>
+          </DialogModalFooter>
         </DialogContent>
       </Dialog>
 
@@ -9,7 +9,7 @@ import { Button } from "@/components/ui/button";
 import { Dialog, DialogContent, DialogFooter, DialogHeader, DialogTitle } from "@/components/ui/dialog";
 import { Input } from "@/components/ui/input";
 import { Label } from "@/components/ui/label";
-import { ALLOWED_TEAM_ICONS, renderTeamIcon } from "@/lib/icons";
+import { ALLOWED_TEAM_ICONS, renderTeamIcon, renderTeamLogo } from "@/lib/icons";
 import { toast } from "sonner";
 import { useTranslations } from "next-intl";
 
@@ -74,8 +74,8 @@ export function TeamSwitcher() {
                 className="data-[state=open]:bg-sidebar-accent data-[state=open]:text-sidebar-accent-foreground"
               >
                 <div className="bg-sidebar-primary text-sidebar-primary-foreground flex aspect-square size-8 items-center justify-center rounded-lg">
-                  {current?.icon ? (
-                    renderTeamIcon(current.icon, "size-4") || <span className="text-xs font-medium">{currentBadge}</span>
+                  {current && (current.icon || current.logo_url) ? (
+                    renderTeamLogo(current, "size-4") || <span className="text-xs font-medium">{currentBadge}</span>
                   ) : (
                     <span className="text-xs font-medium">{currentBadge}</span>
                   )}
@@ -94,12 +94,12 @@ export function TeamSwitcher() {
               sideOffset={4}
             >
               <DropdownMenuLabel className="text-muted-foreground text-xs">{t('teams')}</DropdownMenuLabel>
-              {all.map((w: { id: number; name: string; icon?: string }, index: number) => {
+              {all.map((w: { id: number; name: string; icon?: string; logo_url?: string }, index: number) => {
                 const badge = (w.icon && w.icon.trim()) ? w.icon.trim() : (w.name?.slice(0, 2).toUpperCase());
                 return (
                   <DropdownMenuItem key={w.id} onClick={() => switchTo(w.id)} className="gap-2 p-2">
                     <div className="flex size-6 items-center justify-center rounded-md border">
-                      {w.icon ? (renderTeamIcon(w.icon, "size-4") || <span className="text-[11px] font-medium">{badge}</span>) : (
+                      {w.icon || w.logo_url ? (renderTeamLogo(w, "size-4") || <span className="text-[11px] font-medium">{badge}</span>) : (
                         <span className="text-[11px] font-medium">{badge}</span>
                       )}
                     </div>
@@ -1,13 +1,27 @@
 import api from "./api";
 
-export async function updateProfile(name: string, avatar_url: string) {
-  const { data } = await api.patch<{ name: string; avatar_url: string }>("/account/profile", {
-    name,
-    avatar_url,
-  });
+export async function updateProfile(name: string) {
+  const { data } = await api.patch<{ name: string; avatar_url: string }>("/account/profile", { name });
   return data;
 }
 
+export type AvatarUpload = {
+  avatar_url: string;
+  variants?: Record<string, string>;
+};
+
+// JPEG, PNG or GIF; the server crops it square and stores resized copies
+export async function uploadAvatar(file: File) {
+  const form = new FormData();
+  form.append("file", file);
+  const { data } = await api.post<AvatarUpload>("/account/avatar", form);
+  return data;
+}
+
+export async function removeAvatar() {
+  await api.delete("/account/avatar");
+}
+
 export async function changeEmail(email: string) {
   const { data } = await api.patch<{ confirmation_id: string }>("/account/email/change", { email });
   return data; // { confirmation_id }
@@ -21,21 +35,40 @@ export async function changePassword(current_password: string, new_password: str
   await api.patch("/account/password/change", { current_password, new_password });
 }
 
+export type UserSettings = {
+  theme: "light" | "dark" | "system";
+  language: string;
+  timezone: string;
+  date_format: "auto" | "YYYY-MM-DD" | "DD.MM.YYYY" | "DD/MM/YYYY" | "MM/DD/YYYY";
+  time_format: "auto" | "24h" | "12h";
+  start_page: string;
+};
+
+export async function getSettings() {
+  const { data } = await api.get<UserSettings>("/account/settings");
+  return data;
+}
+
+// null resets a setting to its default
+export async function updateSettings(changes: { [K in keyof UserSettings]?: UserSettings[K] | null }) {
+  const { data } = await api.patch<UserSettings>("/account/settings", changes);
+  return data;
+}
+
 export type UserPreferences = {
   theme?: "light" | "dark" | "system";
   language?: string;
 };
 
 export async function getPreferences() {
-  const { data } = await api.get<{ theme?: "light" | "dark" | "system"; lang?: string }>("/account/preferences");
-  // Map backend 'lang' to frontend 'language'
-  return { theme: data.theme, language: data.lang } satisfies UserPreferences;
+  const { theme, language } = await getSettings();
+  return { theme, language } satisfies UserPreferences;
 }
 
 export async function updateTheme(theme: "light" | "dark" | "system") {
-  await api.post("/account/preferences/theme", { theme });
+  await updateSettings({ theme });
 }
 
 export async function updateLanguage(language: string) {
-  await api.post("/account/preferences/language", { lang: language });
+  await updateSettings({ language });
 }
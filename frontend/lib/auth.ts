@@ -6,8 +6,9 @@ export async function signup(email: string, password: string) {
   return data;
 }
 
-export async function confirmEmail(confirmation_id: string, code: string): Promise<void> {
-  await api.post("/auth/confirm-email", { confirmation_id, code });
+export async function confirmEmail(confirmation_id: string, code: string): Promise<{ invite_token?: string }> {
+  const { data } = await api.post<{ invite_token?: string }>("/auth/confirm-email", { confirmation_id, code });
+  return data;
 }
 
 export async function login(email: string, password: string): Promise<void> {
@@ -23,7 +24,7 @@ export function beginGithubLogin() {
 }
 
 export async function getMe() {
-  const { data } = await api.get<{ id?: string; email?: string; name?: string }>("/auth/me");
+  const { data } = await api.get<{ id?: string; email?: string; name?: string; avatar_url?: string }>("/auth/me");
   return data;
 }
 
@@ -44,6 +45,11 @@ export async function requestPasswordReset(email: string): Promise<{ success: bo
   return data;
 }
 
+// revokes every session of the account and emails a password reset link
+export async function secureAccount(cid: string, code: string): Promise<void> {
+  await api.post("/auth/secure-account", { cid, code });
+}
+
 export async function confirmPasswordReset(reset_password_id: string, code: string, password: string): Promise<void> {
   // Backend may respond with a redirect (302). Axios treats it as success; we don't need the response body.
   await api.post("/auth/password/confirm", { reset_password_id, code, password });
@@ -41,6 +41,14 @@ const map: Record<(typeof ALLOWED_TEAM_ICONS)[number], React.ComponentType<{ cla
   database: Database,
 };
 
+// renderTeamLogo shows the team's uploaded logo, or its built-in icon when it has none.
+export function renderTeamLogo(team: { icon?: string; logo_url?: string }, className?: string): React.ReactNode {
+  if (team.logo_url) {
+    return React.createElement("img", { src: team.logo_url, alt: "", className: `${className ?? ""} object-contain` });
+  }
+  return renderTeamIcon(team.icon, className);
+}
+
 export function renderTeamIcon(key?: string, className?: string): React.ReactNode {
   if (!key) return null;
   const k = key.toLowerCase() as (typeof ALLOWED_TEAM_ICONS)[number];
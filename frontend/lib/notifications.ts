@@ -1,43 +1,221 @@
-import api from "./api";
+import api, { API_BASE_URL } from "./api";
 
+// Rendered by the backend in the user's language; data is only needed for actions
+// such as accepting an invitation.
 export type Notification = {
   id: number;
   createdAt: string;
-  updatedAt: string;
-  userId: number;
   type: string; // e.g., "team_invite"
-  data: string; // JSON string
+  data: Record<string, unknown> | null;
+  title: string;
+  body: string;
+  link: string; // path in the app
   readAt?: string | null;
+  archivedAt?: string | null;
 };
 
+export type TeamInviteData = { team_id: number; team_name: string; token: string; role: string };
+
 type BackendRec = {
   id?: number; ID?: number;
   created_at?: string; CreatedAt?: string;
-  updated_at?: string; UpdatedAt?: string;
-  user_id?: number; UserID?: number;
   type?: string; Type?: string;
-  data?: string; Data?: string;
+  data?: Record<string, unknown> | null;
+  title?: string;
+  body?: string;
+  link?: string;
   read_at?: string | null; ReadAt?: string | null;
+  archived_at?: string | null; ArchivedAt?: string | null;
 };
 
 function normalize(rec: BackendRec): Notification {
   return {
     id: rec.id ?? rec.ID,
     createdAt: rec.created_at ?? rec.CreatedAt,
-    updatedAt: rec.updated_at ?? rec.UpdatedAt,
-    userId: rec.user_id ?? rec.UserID,
     type: rec.type ?? rec.Type,
-    data: rec.data ?? rec.Data,
+    data: rec.data ?? null,
+    title: rec.title ?? "",
+    body: rec.body ?? "",
+    link: rec.link ?? "/dashboard/notifications",
     readAt: rec.read_at ?? rec.ReadAt ?? null,
+    archivedAt: rec.archived_at ?? rec.ArchivedAt ?? null,
   } as Notification;
 }
 
-export async function listNotifications(): Promise<Notification[]> {
-  const { data } = await api.get<BackendRec[]>("/notifications");
-  return Array.isArray(data) ? data.map(normalize) : [];
+export type NotificationPage = {
+  items: Notification[];
+  nextCursor: number | null;
+};
+
+export type ListNotificationsParams = {
+  cursor?: number | null;
+  limit?: number;
+  types?: string[];
+  read?: boolean;
+  archived?: boolean;
+};
+
+export async function listNotifications(params: ListNotificationsParams = {}): Promise<NotificationPage> {
+  const query: Record<string, string> = {};
+  if (params.cursor) query.cursor = String(params.cursor);
+  if (params.limit) query.limit = String(params.limit);
+  if (params.types?.length) query.type = params.types.join(",");
+  if (params.read !== undefined) query.read = String(params.read);
+  if (params.archived) query.archived = "true";
+  const { data } = await api.get<{ items: BackendRec[]; next_cursor: number | null }>("/notifications", { params: query });
+  return {
+    items: Array.isArray(data?.items) ? data.items.map(normalize) : [],
+    nextCursor: data?.next_cursor ?? null,
+  };
+}
+
+export async function getUnreadCount(): Promise<number> {
+  const { data } = await api.get<{ count: number }>("/notifications/unread-count");
+  return data?.count ?? 0;
 }
 
 export async function markNotificationRead(id: number): Promise<{ status: string }> {
   const { data } = await api.patch<{ status: string }>(`/notifications/${id}/read`);
   return data;
 }
+
+export async function markAllNotificationsRead(): Promise<{ updated: number }> {
+  const { data } = await api.post<{ updated: number }>("/notifications/read-all");
+  return data;
+}
+
+export async function archiveNotification(id: number): Promise<{ status: string }> {
+  const { data } = await api.patch<{ status: string }>(`/notifications/${id}/archive`);
+  return data;
+}
+
+export async function unarchiveNotification(id: number): Promise<{ status: string }> {
+  const { data } = await api.delete<{ status: string }>(`/notifications/${id}/archive`);
+  return data;
+}
+
+export async function deleteNotification(id: number): Promise<{ status: string }> {
+  const { data } = await api.delete<{ status: string }>(`/notifications/${id}`);
+  return data;
+}
+
+export type BulkNotificationAction = "read" | "archive" | "unarchive" | "delete";
+
+export async function bulkUpdateNotifications(action: BulkNotificationAction, ids: number[]): Promise<{ updated: number }> {
+  const { data } = await api.post<{ updated: number }>("/notifications/bulk", { action, ids });
+  return data;
+}
+
+export type NotificationChannel = "in_app" | "push" | "email" | "digest";
+
+export type NotificationPreference = {
+  type: string;
+  channels: NotificationChannel[];
+  available: NotificationChannel[];
+  default: NotificationChannel[];
+  locked: boolean;
+};
+
+export async function getNotificationPreferences(): Promise<NotificationPreference[]> {
+  const { data } = await api.get<{ preferences: NotificationPreference[] }>("/notifications/preferences");
+  return data?.preferences ?? [];
+}
+
+export async function updateNotificationPreferences(prefs: Record<string, NotificationChannel[]>): Promise<NotificationPreference[]> {
+  const { data } = await api.put<{ preferences: NotificationPreference[] }>("/notifications/preferences", { preferences: prefs });
+  return data?.preferences ?? [];
+}
+
+export type DigestFrequency = "off" | "daily" | "weekly";
+
+export type DigestSettings = {
+  frequency: DigestFrequency;
+  timezone: string;
+  frequencies: DigestFrequency[];
+};
+
+export async function getDigestSettings(): Promise<DigestSettings> {
+  const { data } = await api.get<DigestSettings>("/notifications/digest");
+  return data;
+}
+
+export async function updateDigestSettings(settings: { frequency?: DigestFrequency; timezone?: string }): Promise<DigestSettings> {
+  const { data } = await api.put<DigestSettings>("/notifications/digest", settings);
+  return data;
+}
+
+// Works signed out: the token from the digest email identifies the account
+export async function unsubscribeDigest(token: string): Promise<{ status: string }> {
+  const { data } = await api.post<{ status: string }>("/notifications/digest/unsubscribe", { token });
+  return data;
+}
+
+export type NotificationEvent =
+  | { type: "notification"; notification: Notification }
+  | { type: "read"; ids: number[]; readAt: string }
+  | { type: "archived"; ids: number[]; archivedAt: string }
+  | { type: "unarchived"; ids: number[] }
+  | { type: "deleted"; ids: number[] }
+  | { type: "resync" };
+
+type Listener = (e: NotificationEvent) => void;
+
+// One stream per tab, shared by every subscriber.
+const listeners = new Set<Listener>();
+let source: EventSource | null = null;
+
+function emit(e: NotificationEvent) {
+  listeners.forEach((l) => l(e));
+}
+
+function open() {
+  // The browser reconnects on its own and resumes with Last-Event-ID.
+  source = new EventSource(`${API_BASE_URL}/notifications/stream`, { withCredentials: true });
+  source.addEventListener("notification", (ev) => {
+    try {
+      emit({ type: "notification", notification: normalize(JSON.parse((ev as MessageEvent).data)) });
+    } catch {
+      // ignore malformed events
+    }
+  });
+  source.addEventListener("read", (ev) => {
+    try {
+      const payload = JSON.parse((ev as MessageEvent).data) as { ids?: number[]; read_at?: string };
+      emit({ type: "read", ids: payload.ids ?? [], readAt: payload.read_at ?? new Date().toISOString() });
+    } catch {
+      // ignore malformed events
+    }
+  });
+  source.addEventListener("archived", (ev) => {
+    try {
+      const payload = JSON.parse((ev as MessageEvent).data) as { ids?: number[]; archived_at?: string };
+      emit({ type: "archived", ids: payload.ids ?? [], archivedAt: payload.archived_at ?? new Date().toISOString() });
+    } catch {
+      // ignore malformed events
+    }
+  });
+  for (const type of ["unarchived", "deleted"] as const) {
+    source.addEventListener(type, (ev) => {
+      try {
+        const payload = JSON.parse((ev as MessageEvent).data) as { ids?: number[] };
+        emit({ type, ids: payload.ids ?? [] });
+      } catch {
+        // ignore malformed events
+      }
+    });
+  }
+  source.addEventListener("resync", () => emit({ type: "resync" }));
+}
+
+export function subscribeNotifications(listener: Listener): () => void {
+  if (typeof window === "undefined" || typeof EventSource === "undefined") return () => {};
+  listeners.add(listener);
+  if (!source) open();
+  return () => {
+    listeners.delete(listener);
+    if (!listeners.size && source) {
+      source.close();
+      source = null;
+    }
+  };
+}
@@ -0,0 +1,54 @@
+import api from "./api";
+
+const SERVICE_WORKER = "/sw.js";
+
+export function isPushSupported(): boolean {
+  return typeof window !== "undefined" && "serviceWorker" in navigator && "PushManager" in window && "Notification" in window;
+}
+
+// VAPID keys come base64url encoded; PushManager wants the raw bytes.
+function decodeKey(key: string): Uint8Array {
+  const base64 = (key + "=".repeat((4 - (key.length % 4)) % 4)).replace(/-/g, "+").replace(/_/g, "/");
+  const raw = atob(base64);
+  return Uint8Array.from(raw, (c) => c.charCodeAt(0));
+}
+
+async function currentSubscription(): Promise<PushSubscription | null> {
+  const registration = await navigator.serviceWorker.getRegistration(SERVICE_WORKER);
+  return registration ? registration.pushManager.getSubscription() : null;
+}
+
+export async function isPushEnabled(): Promise<boolean> {
+  if (!isPushSupported() || Notification.permission !== "granted") return false;
+  return (await currentSubscription()) !== null;
+}
+
+// Asks for permission, subscribes this browser and registers it with the backend.
+export async function enablePush(): Promise<boolean> {
+  if (!isPushSupported()) return false;
+  if ((await Notification.requestPermission()) !== "granted") return false;
+
+  const { data } = await api.get<{ public_key: string }>("/notifications/push/key");
+  const registration = await navigator.serviceWorker.register(SERVICE_WORKER);
+  await navigator.serviceWorker.ready;
+  const subscription =
+    (await registration.pushManager.getSubscription()) ??
+    (await registration.pushManager.subscribe({ userVisibleOnly: true, applicationServerKey: decodeKey(data.public_key) as BufferSource }));
+  await api.post("/notifications/push/subscriptions", subscription.toJSON());
+  return true;
+}
+
+export async function disablePush(): Promise<void> {
+  const subscription = await currentSubscription();
+  if (!subscription) return;
+  try {
+    await api.delete("/notifications/push/subscriptions", { data: { endpoint: subscription.endpoint } });
+  } finally {
+    await subscription.unsubscribe();
+  }
+}
+
+export async function sendTestPush(): Promise<{ sent: number; failed: number; removed: number }> {
+  const { data } = await api.post<{ sent: number; failed: number; removed: number }>("/notifications/push/test");
+  return data;
+}
@@ -3,14 +3,14 @@
 import React, { createContext, useCallback, useContext, useEffect, useMemo, useRef, useState } from "react";
 import { listTeams, createTeam as apiCreate, deleteTeam as apiDelete } from "./teams";
 
-export type CurrentTeam = { id: number; name: string; icon?: string } | null;
+export type CurrentTeam = { id: number; name: string; icon?: string; logo_url?: string } | null;
 
 type Ctx = {
   current: CurrentTeam;
   setCurrentId: (id: number | null) => void;
   switchTo: (id: number) => Promise<void>;
   switching: boolean;
-  all: { id: number; name: string; icon?: string }[];
+  all: { id: number; name: string; icon?: string; logo_url?: string }[];
   refresh: () => Promise<void>;
   createTeam: (name: string, icon?: string) => Promise<void>;
   deleteTeam: (id: number) => Promise<void>;
@@ -19,7 +19,7 @@ type Ctx = {
 const TeamCtx = createContext<Ctx | undefined>(undefined);
 
 export function TeamProvider({ children }: { children: React.ReactNode }) {
-  const [all, setAll] = useState<{ id: number; name: string; icon?: string }[]>([]);
+  const [all, setAll] = useState<{ id: number; name: string; icon?: string; logo_url?: string }[]>([]);
   const [currentId, setCurrentId] = useState<number | null>(() => {
     if (typeof window !== "undefined") {
       const saved = window.localStorage.getItem("currentTeamId");
@@ -43,7 +43,7 @@ export function TeamProvider({ children }: { children: React.ReactNode }) {
 
   const refresh = useCallback(async () => {
     const items = await listTeams();
-    const mapped = items.map((w: { id: number; name: string; icon?: string }) => ({ id: w.id, name: w.name, icon: w.icon }));
+    const mapped = items.map((w: { id: number; name: string; icon?: string; logo_url?: string }) => ({ id: w.id, name: w.name, icon: w.icon, logo_url: w.logo_url }));
     setAll(mapped);
     if (mapped.length === 0) {
       // No teams available
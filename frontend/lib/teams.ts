@@ -1,10 +1,12 @@
-import api from "./api";
+import api, { API_BASE_URL } from "./api";
 
-export type Team = { id: number; name: string; icon?: string; owner_id: number };
+// logo_url is set when the team has an uploaded logo, shown instead of its icon
+export type Team = { id: number; name: string; icon?: string; logo_url?: string; owner_id: number };
 export type TeamDetail = {
   id: number;
   name: string;
   icon?: string;
+  logo_url?: string;
   owner_id: number;
   members: { id: number; name: string; email: string; role: string }[];
 };
@@ -29,6 +31,22 @@ export async function updateTeam(id: number, name: string, icon?: string): Promi
   return data;
 }
 
+export type TeamLogo = { icon: string; logo_url: string; variants: Record<string, string> };
+
+// JPEG, PNG or GIF; the server fits it into a square and stores resized copies
+export async function uploadTeamLogo(id: number, file: File): Promise<TeamLogo> {
+  const form = new FormData();
+  form.append("file", file);
+  const { data } = await api.post<TeamLogo>(`/teams/${id}/logo`, form);
+  return data;
+}
+
+// removes the uploaded logo, switching to the given built-in icon or back to the previous one
+export async function resetTeamLogo(id: number, icon?: string): Promise<TeamLogo> {
+  const { data } = await api.delete<TeamLogo>(`/teams/${id}/logo`, { params: icon ? { icon } : undefined });
+  return data;
+}
+
 export async function deleteTeam(id: number): Promise<{ success: boolean; status: string }> {
   const { data } = await api.delete<{ success: boolean; status: string }>(`/teams/${id}`);
   return data;
@@ -59,10 +77,95 @@ export async function updateMemberRole(
   return data;
 }
 
-// owner reassignment removed
+export async function leaveTeam(teamId: number): Promise<{ status: string; personal_team_id?: number }> {
+  const { data } = await api.post<{ status: string; personal_team_id?: number }>(`/teams/${teamId}/leave`);
+  return data;
+}
+
+export type DeletedTeam = { id: number; name: string; icon?: string; logo_url?: string; deleted_at: string; purge_at: string };
+
+export async function listTrash(): Promise<DeletedTeam[]> {
+  const { data } = await api.get<DeletedTeam[]>("/teams/trash");
+  return data;
+}
+
+export async function restoreTeam(teamId: number): Promise<{ status: string; id: number; name: string }> {
+  const { data } = await api.post<{ status: string; id: number; name: string }>(`/teams/${teamId}/restore`);
+  return data;
+}
+
+export type AuditEvent = {
+  id: number;
+  created_at: string;
+  actor_id: number | null;
+  action: string;
+  target_type: string;
+  target_id: number | null;
+  target_label: string;
+  before?: Record<string, unknown>;
+  after?: Record<string, unknown>;
+  ip: string;
+  user_agent: string;
+};
+
+export type AuditFilters = { action?: string; actor_id?: number; target_id?: number; since?: string; until?: string };
+
+export async function listAuditEvents(
+  teamId: number,
+  filters: AuditFilters = {},
+  cursor?: number
+): Promise<{ items: AuditEvent[]; next_cursor: number | null }> {
+  const { data } = await api.get<{ items: AuditEvent[]; next_cursor: number | null }>(`/teams/${teamId}/audit`, {
+    params: { ...filters, cursor },
+  });
+  return data;
+}
+
+export function auditExportUrl(teamId: number, format: "csv" | "json", filters: AuditFilters = {}): string {
+  const params = new URLSearchParams({ format });
+  Object.entries(filters).forEach(([k, v]) => {
+    if (v !== undefined && v !== "") params.set(k, String(v));
+  });
+  return `${API_BASE_URL}/teams/${teamId}/audit?${params.toString()}`;
+}
+
+export type OwnershipTransfer = {
+  id: number;
+  team_id: number;
+  from_user_id: number;
+  to_user_id: number;
+  status: "pending" | "accepted" | "declined" | "cancelled";
+  created_at: string;
+  expires_at: string;
+};
+
+export async function startOwnershipTransfer(teamId: number, userId: number): Promise<OwnershipTransfer> {
+  const { data } = await api.post<OwnershipTransfer>(`/teams/${teamId}/transfer`, { user_id: userId });
+  return data;
+}
+
+export async function getOwnershipTransfer(teamId: number): Promise<OwnershipTransfer> {
+  const { data } = await api.get<OwnershipTransfer>(`/teams/${teamId}/transfer`);
+  return data;
+}
+
+export async function cancelOwnershipTransfer(teamId: number): Promise<{ status: string }> {
+  const { data } = await api.delete<{ status: string }>(`/teams/${teamId}/transfer`);
+  return data;
+}
+
+export async function acceptOwnershipTransfer(teamId: number): Promise<{ status: string; team_id: number; owner_id: number }> {
+  const { data } = await api.post<{ status: string; team_id: number; owner_id: number }>(`/teams/${teamId}/transfer/accept`);
+  return data;
+}
+
+export async function declineOwnershipTransfer(teamId: number): Promise<{ status: string }> {
+  const { data } = await api.post<{ status: string }>(`/teams/${teamId}/transfer/decline`);
+  return data;
+}
 
 export type TeamOverview = {
-  team: { id: number; name: string; icon?: string };
+  team: { id: number; name: string; icon?: string; logo_url?: string };
   stats: { members_count: number };
 };
 
@@ -82,6 +185,11 @@ export async function acceptInvitation(token: string): Promise<{ status: string;
   return data;
 }
 
+export async function redeemInviteLink(token: string): Promise<{ status: string; team_id: number; team_name: string; role: string }> {
+  const { data } = await api.post<{ status: string; team_id: number; team_name: string; role: string }>(`/teams/invite-links/redeem`, { token });
+  return data;
+}
+
 export type InvitationStatus = {
   status: string; // pending | revoked | accepted | expired
   team_id: number;
@@ -0,0 +1,28 @@
+// Shows Web Push notifications sent by the backend; see lib/push.ts.
+
+self.addEventListener("push", (event) => {
+  let message = {}
+  try {
+    message = event.data ? event.data.json() : {}
+  } catch {
+    message = { body: event.data ? event.data.text() : "" }
+  }
+  event.waitUntil(
+    self.registration.showNotification(message.title || "Notification", {
+      body: message.body || "",
+      tag: message.type,
+      data: { url: message.url || "/dashboard/notifications" },
+    })
+  )
+})
+
+self.addEventListener("notificationclick", (event) => {
+  event.notification.close()
+  const url = event.notification.data?.url || "/dashboard/notifications"
+  event.waitUntil(
+    self.clients.matchAll({ type: "window", includeUncontrolled: true }).then((windows) => {
+      const open = windows.find((w) => w.url === url && "focus" in w)
+      return open ? open.focus() : self.clients.openWindow(url)
+    })
+  )
+})